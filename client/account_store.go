@@ -2,6 +2,7 @@ package client
 
 import (
 	"encoding/json"
+	"sort"
 
 	"github.com/johnstarich/sage/client/direct"
 	"github.com/johnstarich/sage/client/model"
@@ -195,10 +196,24 @@ func (s *AccountStore) Remove(id string) error {
 	return s.Put(id, nil)
 }
 
+// LedgerAccountNames returns the ledger account names for all accounts
+func (s *AccountStore) LedgerAccountNames() ([]string, error) {
+	var names []string
+	var account model.Account
+	err := s.Iter(&account, func(id string) bool {
+		names = append(names, model.LedgerAccountName(account))
+		return true
+	})
+	sort.Strings(names)
+	return names, err
+}
+
 // ValidateAccount checks account for invalid data, runs validation for direct connect too
 func ValidateAccount(account model.Account) error {
 	var errs sErrors.Errors
 	switch kind := account.(type) {
+	case *model.ManualAccount:
+		errs.AddErr(model.ValidateManualAccount(kind))
 	case direct.Account:
 		errs.AddErr(direct.Validate(kind))
 	case web.Account:
@@ -213,6 +228,7 @@ type institutionDetector struct {
 	BasicInstitution *model.BasicInstitution
 	DirectConnect    *json.RawMessage
 	WebConnect       *json.RawMessage
+	Manual           bool
 }
 
 // UnmarshalAccount attempts to unmarshal JSON accounts from b
@@ -222,6 +238,12 @@ func UnmarshalAccount(b []byte) (model.Account, error) {
 		return nil, err
 	}
 	switch {
+	case instDetector.Manual:
+		var account model.ManualAccount
+		if err := json.Unmarshal(b, &account); err != nil {
+			return nil, err
+		}
+		return &account, nil
 	case instDetector.BasicInstitution != nil:
 		var account model.BasicAccount
 		if err := json.Unmarshal(b, &account); err != nil {
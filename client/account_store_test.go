@@ -460,3 +460,48 @@ func TestAccountStoreRemove(t *testing.T) {
 	require.Error(t, err)
 	assert.Equal(t, `Account not found by ID: "1234"`, err.Error())
 }
+
+func TestAccountStoreLedgerAccountNames(t *testing.T) {
+	db := plaindb.NewMockDB(plaindb.MockConfig{})
+	store, err := NewAccountStore(db)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Add(&model.BasicAccount{
+		AccountID:        "5678",
+		AccountType:      model.LiabilityAccount,
+		BasicInstitution: model.BasicInstitution{InstOrg: "Card"},
+	}))
+	require.NoError(t, store.Add(&model.BasicAccount{
+		AccountID:        "1234",
+		AccountType:      model.AssetAccount,
+		BasicInstitution: model.BasicInstitution{InstOrg: "Bank"},
+	}))
+	names, err := store.LedgerAccountNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"assets:Bank:****1234", "liabilities:Card:****5678"}, names)
+}
+
+func TestAccountStoreManualAccount(t *testing.T) {
+	db := plaindb.NewMockDB(plaindb.MockConfig{})
+	store, err := NewAccountStore(db)
+	require.NoError(t, err)
+
+	house := model.NewManualAccount("House", "Home", model.AssetAccount)
+	require.NoError(t, ValidateAccount(house))
+	require.NoError(t, store.Add(house))
+	require.NoError(t, store.Add(model.NewManualAccount("Contributions", "Contributions", model.EquityAccount)))
+
+	var account model.Account
+	found, err := store.Get("House", &account)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, house, account)
+
+	names, err := store.LedgerAccountNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"assets:House", "equity:Contributions"}, names)
+
+	err = ValidateAccount(&model.ManualAccount{Manual: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Account type must not be empty")
+}
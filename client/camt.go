@@ -0,0 +1,230 @@
+package client
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultCAMTInstitution names the institution of camt.053 accounts without a servicer BIC or name
+	DefaultCAMTInstitution = "camt"
+
+	camtCredit        = "CRDT"
+	camtBooked        = "BOOK"
+	camtClosingBooked = "CLBD"
+)
+
+// camtDocument is an ISO 20022 camt.053 bank to customer statement. Elements match any version's namespace.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID      string `xml:"Id"`
+	Account struct {
+		IBAN     string `xml:"Id>IBAN"`
+		Other    string `xml:"Id>Othr>Id"`
+		Currency string `xml:"Ccy"`
+		BIC      string `xml:"Svcr>FinInstnId>BIC"`
+		BICFI    string `xml:"Svcr>FinInstnId>BICFI"`
+		Name     string `xml:"Svcr>FinInstnId>Nm"`
+	} `xml:"Acct"`
+	ToDate   camtDate      `xml:"FrToDt>ToDtTm"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtBalance struct {
+	Type            string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount          camtAmount `xml:"Amt"`
+	CreditDebitFlag string     `xml:"CdtDbtInd"`
+	Date            camtDate   `xml:"Dt"`
+}
+
+type camtEntry struct {
+	Ref             string     `xml:"NtryRef"`
+	ServicerRef     string     `xml:"AcctSvcrRef"`
+	Amount          camtAmount `xml:"Amt"`
+	CreditDebitFlag string     `xml:"CdtDbtInd"`
+	Status          struct {
+		Value string `xml:",chardata"`
+		Code  string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookingDate    camtDate         `xml:"BookgDt"`
+	Details        []camtTxnDetails `xml:"NtryDtls>TxDtls"`
+	AdditionalInfo string           `xml:"AddtlNtryInf"`
+}
+
+type camtTxnDetails struct {
+	Creditor   string   `xml:"RltdPties>Cdtr>Nm"`
+	CreditorV8 string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Debtor     string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorV8   string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Remittance []string `xml:"RmtInf>Ustrd"`
+}
+
+// camtDate is either a date or date time element, like <Dt>2020-01-02</Dt> or <DtTm>2020-01-02T10:00:00</DtTm>
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+	Value    string `xml:",chardata"`
+}
+
+func (c camtDate) Time() (time.Time, error) {
+	value := strings.TrimSpace(c.Date)
+	if value == "" {
+		value = strings.TrimSpace(c.DateTime)
+	}
+	if value == "" {
+		value = strings.TrimSpace(c.Value)
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if len(value) > len("2006-01-02") {
+		// only use the date, since times may not include a time zone
+		value = value[:len("2006-01-02")]
+	}
+	date, err := time.Parse("2006-01-02", value)
+	return date, errors.Wrapf(err, "Invalid date: %q", value)
+}
+
+func (c camtAmount) signed(creditDebitFlag string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(strings.TrimSpace(c.Value))
+	if err != nil {
+		return decimal.Zero, errors.Errorf("Invalid amount: %q", c.Value)
+	}
+	if strings.TrimSpace(creditDebitFlag) != camtCredit {
+		amount = amount.Neg()
+	}
+	return amount, nil
+}
+
+// ReadCAMT053 reads r and parses it for an ISO 20022 camt.053 file's booked transactions
+func ReadCAMT053(r io.Reader) ([]model.Account, []ledger.Transaction, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, errors.Wrap(err, "Invalid camt.053 file")
+	}
+	if len(doc.Statements) == 0 {
+		return nil, nil, errors.New("No statements found in camt.053 file")
+	}
+
+	statements := make([]bankStatement, 0, len(doc.Statements))
+	for _, stmt := range doc.Statements {
+		statement, err := parseCAMTStatement(stmt)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "Invalid camt.053 statement %q", stmt.ID)
+		}
+		statements = append(statements, statement)
+	}
+	accounts, txns := importStatements(statements)
+	return accounts, txns, nil
+}
+
+func parseCAMTStatement(stmt camtStatement) (bankStatement, error) {
+	statement := bankStatement{
+		AccountID: strings.TrimSpace(stmt.Account.IBAN),
+		FID:       strings.TrimSpace(stmt.Account.BIC + stmt.Account.BICFI),
+		Org:       strings.TrimSpace(stmt.Account.Name),
+	}
+	if statement.AccountID == "" {
+		statement.AccountID = strings.TrimSpace(stmt.Account.Other)
+	}
+	if statement.AccountID == "" {
+		return statement, errors.New("Statement account must have an ID")
+	}
+	if statement.Org == "" {
+		statement.Org = statement.FID
+	}
+	if statement.Org == "" {
+		statement.Org = DefaultCAMTInstitution
+	}
+
+	for _, ntry := range stmt.Entries {
+		if status := strings.TrimSpace(ntry.Status.Value + ntry.Status.Code); status != camtBooked {
+			// skip pending entries, they may change before they're booked
+			continue
+		}
+		entry, err := parseCAMTEntry(ntry, stmt.Account.Currency)
+		if err != nil {
+			return statement, err
+		}
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	var err error
+	statement.EndDate, err = stmt.ToDate.Time()
+	if err != nil {
+		return statement, err
+	}
+	for _, bal := range stmt.Balances {
+		if strings.TrimSpace(bal.Type) != camtClosingBooked {
+			continue
+		}
+		balance, err := bal.Amount.signed(bal.CreditDebitFlag)
+		if err != nil {
+			return statement, err
+		}
+		balanceDate, err := bal.Date.Time()
+		if err != nil {
+			return statement, err
+		}
+		statement.ClosingBalance = &balance
+		statement.ClosingDate = balanceDate
+		if statement.EndDate.IsZero() {
+			statement.EndDate = balanceDate
+		}
+	}
+	return statement, nil
+}
+
+func parseCAMTEntry(ntry camtEntry, accountCurrency string) (statementEntry, error) {
+	amount, err := ntry.Amount.signed(ntry.CreditDebitFlag)
+	if err != nil {
+		return statementEntry{}, err
+	}
+	date, err := ntry.BookingDate.Time()
+	if err != nil {
+		return statementEntry{}, err
+	}
+	currency := strings.TrimSpace(ntry.Amount.Currency)
+	if currency == "" {
+		currency = strings.TrimSpace(accountCurrency)
+	}
+	entry := statementEntry{
+		Ref:      strings.TrimSpace(ntry.Ref),
+		Date:     date,
+		Amount:   amount,
+		Currency: currency,
+		Memo:     strings.TrimSpace(ntry.AdditionalInfo),
+	}
+	if entry.Ref == "" {
+		entry.Ref = strings.TrimSpace(ntry.ServicerRef)
+	}
+	if len(ntry.Details) > 0 {
+		details := ntry.Details[0]
+		// the payee is the other party: the creditor receiving a debit, or the debtor sending a credit
+		if amount.IsNegative() {
+			entry.Payee = strings.TrimSpace(details.Creditor + details.CreditorV8)
+		} else {
+			entry.Payee = strings.TrimSpace(details.Debtor + details.DebtorV8)
+		}
+		if remittance := strings.TrimSpace(strings.Join(details.Remittance, " ")); remittance != "" {
+			entry.Memo = remittance
+		}
+	}
+	return entry, nil
+}
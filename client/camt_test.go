@@ -0,0 +1,116 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCAMT053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>MSG-1</MsgId></GrpHdr>
+    <Stmt>
+      <Id>STMT-1</Id>
+      <FrToDt><FrDtTm>2020-01-01T00:00:00</FrDtTm><ToDtTm>2020-01-31T23:59:59</ToDtTm></FrToDt>
+      <Acct>
+        <Id><IBAN>DE89370400440532013000</IBAN></Id>
+        <Ccy>EUR</Ccy>
+        <Svcr><FinInstnId><BIC>COBADEFFXXX</BIC></FinInstnId></Svcr>
+      </Acct>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">100.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2020-01-01</Dt></Dt>
+      </Bal>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">1095.85</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2020-01-31</Dt></Dt>
+      </Bal>
+      <Ntry>
+        <NtryRef>REF-1</NtryRef>
+        <Amt Ccy="EUR">4.25</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2020-01-02</Dt></BookgDt>
+        <NtryDtls><TxDtls>
+          <RltdPties><Cdtr><Nm>Coffee Shop</Nm></Cdtr></RltdPties>
+          <RmtInf><Ustrd>Card payment</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <AcctSvcrRef>SVC-2</AcctSvcrRef>
+        <Amt Ccy="EUR">1000.10</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2020-01-03T10:00:00</DtTm></BookgDt>
+        <NtryDtls><TxDtls>
+          <RltdPties><Dbtr><Pty><Nm>Employer</Nm></Pty></Dbtr></RltdPties>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <NtryRef>REF-3</NtryRef>
+        <Amt Ccy="EUR">20.00</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2020-01-04</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+
+func TestReadCAMT053(t *testing.T) {
+	accounts, txns, err := ReadCAMT053(strings.NewReader(testCAMT053))
+	require.NoError(t, err)
+	assert.Equal(t, []model.Account{
+		&model.BasicAccount{
+			AccountDescription: "COBADEFFXXX - DE89370400440532013000",
+			AccountID:          "DE89370400440532013000",
+			AccountType:        model.AssetAccount,
+			BasicInstitution: model.BasicInstitution{
+				InstDescription: "COBADEFFXXX",
+				InstFID:         "COBADEFFXXX",
+				InstOrg:         "COBADEFFXXX",
+			},
+		},
+	}, accounts)
+
+	var txnStrings []string
+	for _, txn := range txns {
+		txnStrings = append(txnStrings, txn.String())
+	}
+	assert.Equal(t, []string{
+		`2020/01/02 Coffee Shop
+    assets:COBADEFFXXX:****3000  -4.25 EUR = 95.75 EUR ; Card payment id: COBADEFFXXX-DE89370400440532013000-REF-1
+    uncategorized                 4.25 EUR
+`,
+		`2020/01/03 Employer
+    assets:COBADEFFXXX:****3000   1000.1 EUR = 1095.85 EUR ; id: COBADEFFXXX-DE89370400440532013000-SVC-2
+    uncategorized                -1000.1 EUR
+`,
+	}, txnStrings)
+}
+
+func TestReadCAMT053Errors(t *testing.T) {
+	_, _, err := ReadCAMT053(strings.NewReader(`not xml`))
+	assert.Error(t, err)
+
+	_, _, err = ReadCAMT053(strings.NewReader(`<Document><BkToCstmrStmt></BkToCstmrStmt></Document>`))
+	assert.EqualError(t, err, "No statements found in camt.053 file")
+
+	_, _, err = ReadCAMT053(strings.NewReader(`<Document><BkToCstmrStmt><Stmt><Id>1</Id></Stmt></BkToCstmrStmt></Document>`))
+	assert.Error(t, err, "Account ID is required")
+
+	_, _, err = ReadCAMT053(strings.NewReader(`<Document><BkToCstmrStmt><Stmt>
+<Acct><Id><IBAN>DE89</IBAN></Id></Acct>
+<Ntry><Amt>four</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts><BookgDt><Dt>2020-01-02</Dt></BookgDt></Ntry>
+</Stmt></BkToCstmrStmt></Document>`))
+	assert.Error(t, err, "Amount is invalid")
+}
@@ -68,6 +68,8 @@ func Validate(account Account) error {
 		errs.ErrIf(impl.BankID() == "", "Routing number must not be empty")
 	case *CreditCard:
 		// no additional validation required
+	case *Investment:
+		errs.ErrIf(impl.BrokerID == "", "Broker ID must not be empty")
 	}
 
 	return errs.ErrOrNil()
@@ -84,6 +86,14 @@ func UnmarshalAccount(b []byte) (Account, error) {
 		return &maybeBank, nil
 	}
 
+	var maybeInvestment Investment
+	if err := json.Unmarshal(b, &maybeInvestment); err != nil {
+		return nil, err
+	}
+	if maybeInvestment.isInvestment() {
+		return &maybeInvestment, nil
+	}
+
 	var creditCard CreditCard
 	err := json.Unmarshal(b, &creditCard)
 	return &creditCard, err
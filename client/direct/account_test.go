@@ -103,6 +103,14 @@ func TestValidate(t *testing.T) {
 				"Account ID must not be empty",
 			},
 		},
+		{
+			description: "Investment",
+			account:     &Investment{},
+			expectedErr: []string{
+				"Account ID must not be empty",
+				"Broker ID must not be empty",
+			},
+		},
 		{
 			description: "Connector institution",
 			account: &CreditCard{
@@ -155,6 +163,16 @@ func TestUnmarshalAccount(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "investment",
+			data:        `{"BrokerID": "broker.com"}`,
+			expectAccount: &Investment{
+				BrokerID: "broker.com",
+				directAccount: directAccount{
+					DirectConnect: (*directConnect)(nil),
+				},
+			},
+		},
 		{
 			description: "credit card",
 			data:        `{}`,
@@ -155,7 +155,7 @@ func fetchTransactions(
 			return nil, err
 		}
 	}
-	if len(query.Bank) == 0 && len(query.CreditCard) == 0 {
+	if len(query.Bank) == 0 && len(query.CreditCard) == 0 && len(query.InvStmt) == 0 {
 		return nil, errors.Errorf("Invalid statement query: does not contain any statement requests: %+v", query)
 	}
 
@@ -284,8 +284,16 @@ func parseAcctInfo(connector Connector, acctInfo ofxgo.AcctInfo, logger *zap.Log
 			accountName = accountID
 		}
 		return NewCreditCard(accountID, accountName, connector), true
+	case acctInfo.InvAcctInfo != nil:
+		brokerID := acctInfo.InvAcctInfo.InvAcctFrom.BrokerID.String()
+		accountID := acctInfo.InvAcctInfo.InvAcctFrom.AcctID.String()
+		logger = logger.With(zap.String("accountID", accountID))
+		if accountName == "" {
+			accountName = accountID
+		}
+		return NewInvestment(accountID, brokerID, accountName, connector), true
 	default:
-		logger.Warn("Account was not a bank or credit card account")
+		logger.Warn("Account was not a bank, credit card, or investment account")
 		return nil, false
 	}
 }
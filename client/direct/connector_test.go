@@ -455,6 +455,25 @@ func TestParseAcctInfo(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "investment account",
+			acctInfo: ofxgo.AcctInfo{
+				InvAcctInfo: &ofxgo.InvAcctInfo{
+					InvAcctFrom: ofxgo.InvAcct{
+						BrokerID: "some broker ID",
+						AcctID:   "some account ID",
+					},
+				},
+			},
+			expectAccount: &Investment{
+				BrokerID: "some broker ID",
+				directAccount: directAccount{
+					AccountID:          "some account ID",
+					AccountDescription: "some account ID",
+					DirectConnect:      connector,
+				},
+			},
+		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
 			logger := zaptest.NewLogger(t)
@@ -19,6 +19,7 @@ const (
 	MessageSignon DriverMessage = iota + 1
 	MessageBank
 	MessageCreditCard
+	MessageInvestment
 )
 
 var directConnectInstitutions = make(map[string]Driver)
@@ -37,7 +38,7 @@ func Register(drivers ...Driver) {
 func supportedDriver(d Driver) bool {
 	for _, support := range d.MessageSupport() {
 		switch support {
-		case MessageBank, MessageCreditCard:
+		case MessageBank, MessageCreditCard, MessageInvestment:
 			return true
 		}
 	}
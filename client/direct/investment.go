@@ -0,0 +1,79 @@
+package direct
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aclindsa/ofxgo"
+	"github.com/johnstarich/sage/client/model"
+)
+
+// Investment represents a brokerage or retirement investment account
+type Investment struct {
+	directAccount
+	BrokerID string
+}
+
+// NewInvestment creates an account from investment account details
+func NewInvestment(id, brokerID, description string, connector Connector) Account {
+	return &Investment{
+		BrokerID: brokerID,
+		directAccount: directAccount{
+			AccountID:          id,
+			AccountDescription: description,
+			DirectConnect:      connector,
+		},
+	}
+}
+
+func (i *Investment) isInvestment() bool {
+	return i.BrokerID != ""
+}
+
+// Statement implements Requestor
+func (i *Investment) Statement(req *ofxgo.Request, start, end time.Time) error {
+	return generateInvestmentStatement(i, req, start, end, ofxgo.RandomUID)
+}
+
+func generateInvestmentStatement(
+	i *Investment,
+	req *ofxgo.Request,
+	start, end time.Time,
+	getUID func() (*ofxgo.UID, error),
+) error {
+	uid, err := getUID()
+	if err != nil {
+		return err
+	}
+
+	req.InvStmt = append(req.InvStmt, &ofxgo.InvStatementRequest{
+		TrnUID: *uid,
+		InvAcctFrom: ofxgo.InvAcct{
+			BrokerID: ofxgo.String(i.BrokerID),
+			AcctID:   ofxgo.String(i.ID()),
+		},
+		DtStart:        &ofxgo.Date{Time: start},
+		DtEnd:          &ofxgo.Date{Time: end},
+		Include:        true, // Include transactions (instead of only balance information)
+		IncludePos:     true, // Include positions for holdings snapshots
+		IncludeBalance: true,
+	})
+	return nil
+}
+
+func (i *Investment) Type() string {
+	return model.AssetAccount
+}
+
+func (i *Investment) UnmarshalJSON(data []byte) error {
+	var investment struct {
+		BrokerID string
+	}
+
+	if err := json.Unmarshal(data, &investment); err != nil {
+		return err
+	}
+
+	i.BrokerID = investment.BrokerID
+	return json.Unmarshal(data, &i.directAccount)
+}
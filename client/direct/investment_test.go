@@ -0,0 +1,79 @@
+package direct
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aclindsa/ofxgo"
+	"github.com/johnstarich/sage/client/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateInvestmentStatement(t *testing.T) {
+	someInstitution := &directConnect{
+		BasicInstitution: model.BasicInstitution{InstDescription: "some institution"},
+	}
+	investment := NewInvestment("some ID", "some broker ID", "some description", someInstitution).(*Investment)
+
+	for _, tc := range []struct {
+		description string
+		uidErr      bool
+		expectErr   bool
+	}{
+		{
+			description: "happy path",
+		},
+		{
+			description: "UID error",
+			uidErr:      true,
+			expectErr:   true,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			uid := ofxgo.UID("some UID")
+			getUID := func() (*ofxgo.UID, error) {
+				if tc.uidErr {
+					return nil, errors.New("some UID error")
+				}
+				return &uid, nil
+			}
+			var req ofxgo.Request
+			err := generateInvestmentStatement(investment, &req, someStartTime, someEndTime, getUID)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, ofxgo.Request{
+				InvStmt: []ofxgo.Message{
+					&ofxgo.InvStatementRequest{
+						TrnUID: uid,
+						InvAcctFrom: ofxgo.InvAcct{
+							BrokerID: "some broker ID",
+							AcctID:   "some ID",
+						},
+						DtStart:        &ofxgo.Date{Time: someStartTime},
+						DtEnd:          &ofxgo.Date{Time: someEndTime},
+						Include:        true,
+						IncludePos:     true,
+						IncludeBalance: true,
+					},
+				},
+			}, req)
+		})
+	}
+}
+
+func TestInvestmentStatement(t *testing.T) {
+	var req ofxgo.Request
+	err := (&Investment{}).Statement(&req, someStartTime, someEndTime)
+	require.NoError(t, err)
+	require.Len(t, req.InvStmt, 1)
+	assert.IsType(t, &ofxgo.InvStatementRequest{}, req.InvStmt[0])
+}
+
+func TestInvestmentType(t *testing.T) {
+	assert.Equal(t, model.AssetAccount, (&Investment{}).Type())
+}
@@ -20,16 +20,19 @@ func importTransactions(
 	var messages []ofxgo.Message
 	messages = append(messages, resp.Bank...)
 	messages = append(messages, resp.CreditCard...)
+	messages = append(messages, resp.InvStmt...)
 	if len(messages) == 0 {
 		return nil, nil, errors.New("No messages received")
 	}
 	fid := resp.Signon.Fid.String()
 	org := resp.Signon.Org.String()
+	securities := securityCommodities(resp)
 
 	var txns []ledger.Transaction
 	for _, message := range messages {
 		var ofxTxns []ofxgo.Transaction
 		var currency string
+		var parsedTxns []ledger.Transaction
 		account := model.LedgerAccountFormat{Institution: org}
 		switch statement := message.(type) {
 		case *ofxgo.CCStatementResponse:
@@ -46,6 +49,21 @@ func importTransactions(
 				ofxTxns = statement.BankTranList.Transactions
 			}
 			currency = normalizeCurrency(statement.CurDef.String())
+		case *ofxgo.InvStatementResponse:
+			account.AccountType = model.AssetAccount
+			account.AccountID = statement.InvAcctFrom.AcctID.String()
+			currency = normalizeCurrency(statement.CurDef.String())
+			if statement.InvTranList != nil {
+				for _, bankTxns := range statement.InvTranList.BankTransactions {
+					ofxTxns = append(ofxTxns, bankTxns.Transactions...)
+				}
+				makeTxnID := MakeUniqueTxnID(fid, account.AccountID)
+				for _, invTxn := range statement.InvTranList.InvTransactions {
+					if parsedTxn, ok := parseInvestmentTransaction(invTxn, securities, currency, account.String(), makeTxnID); ok {
+						parsedTxns = append(parsedTxns, parsedTxn)
+					}
+				}
+			}
 		default:
 			return nil, nil, errors.Errorf("Invalid statement type: %T", message)
 		}
@@ -54,6 +72,7 @@ func importTransactions(
 			parsedTxn := parseTransaction(ofxTxn, currency, account.String(), MakeUniqueTxnID(fid, account.AccountID))
 			txns = append(txns, parsedTxn)
 		}
+		txns = append(txns, parsedTxns...)
 
 		skeletonAccounts = append(skeletonAccounts, &model.BasicAccount{
 			AccountDescription: fmt.Sprintf("%s - %s", org, account.AccountID),
@@ -140,6 +159,7 @@ func parseTransaction(txn ofxgo.Transaction, currency, accountName string, makeT
 				Account:  accountName,
 				Amount:   amount,
 				Balance:  nil, // set balance in next section
+				Comment:  cleanMemo(string(txn.Memo)),
 				Currency: currency,
 				Tags:     map[string]string{"id": id},
 			},
@@ -152,6 +172,14 @@ func parseTransaction(txn ofxgo.Transaction, currency, accountName string, makeT
 	}
 }
 
+// memoLineBreaks replaces line breaks in memos, since posting comments must fit on one line
+var memoLineBreaks = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// cleanMemo prepares a statement memo for use as a posting comment, keeping it verbatim apart from line breaks
+func cleanMemo(memo string) string {
+	return strings.TrimSpace(memoLineBreaks.Replace(memo))
+}
+
 // balanceTransactions sorts and adds balances to each transaction
 func balanceTransactions(txns []ledger.Transaction, balance decimal.Decimal, balanceDate time.Time, statementEndDate time.Time) {
 	{
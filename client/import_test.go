@@ -363,6 +363,23 @@ func TestParseTransaction(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "memo",
+			accountName: "assets:Bank 1",
+			txn: ofxgo.Transaction{
+				Currency: usdCurrency,
+				Name:     ofxgo.String("Hey there"),
+				Memo:     ofxgo.String(" card ending 1234 "),
+				TrnAmt:   makeOFXAmount(1.25),
+			},
+			expectedTxn: ledger.Transaction{
+				Payee: "Hey there",
+				Postings: []ledger.Posting{
+					{Account: "assets:Bank 1", Currency: usd, Amount: decimal.NewFromFloat(1.25), Comment: "card ending 1234"},
+					{Account: model.Uncategorized, Currency: usd, Amount: decimal.NewFromFloat(-1.25)},
+				},
+			},
+		},
 	} {
 		someFID := "some FID"
 		makeTxnID := func(id string) string {
@@ -539,3 +556,21 @@ func TestBalanceTransactions(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTransactionMemoRoundTrip(t *testing.T) {
+	txn := parseTransaction(ofxgo.Transaction{
+		DtPosted: ofxgo.Date{Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Name:     ofxgo.String("Coffee"),
+		Memo:     ofxgo.String("POS DEBIT: 1234\nstore: 5"),
+		TrnAmt:   makeOFXAmount(-4.5),
+		FiTID:    "abc",
+	}, "$", "assets:Bank:****1234", func(id string) string { return "1234-5678-" + id })
+	assert.Equal(t, "POS DEBIT: 1234 store: 5", txn.Postings[0].Comment)
+
+	ldg, err := ledger.NewFromReader(strings.NewReader(txn.String()))
+	require.NoError(t, err)
+	readTxn, found := ldg.Transaction("1234-5678-abc")
+	require.True(t, found, "ID tag must survive a round trip")
+	assert.Equal(t, "POS DEBIT: 1234 store: 5", readTxn.Postings[0].Comment, "Memos should be kept verbatim")
+	assert.Equal(t, map[string]string{"id": "1234-5678-abc"}, readTxn.Postings[0].Tags)
+}
@@ -0,0 +1,345 @@
+package client
+
+import (
+	"time"
+
+	"github.com/aclindsa/ofxgo"
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// investmentIncomeAccount is the parent account for investment income, like dividends and interest
+	investmentIncomeAccount = model.RevenueAccount + ":Investments"
+	// investmentExpenseAccount is the parent account for investment expenses, like margin interest and fees
+	investmentExpenseAccount = model.ExpenseAccount + ":Investments"
+	// investmentEquityAccount is the parent account for security units that enter or leave without cash, like transfers and splits
+	investmentEquityAccount = model.EquityAccount + ":Investments"
+)
+
+// investmentIncomeAccounts maps OFX income types onto ledger accounts
+var investmentIncomeAccounts = map[string]string{
+	"CGLONG":   investmentIncomeAccount + ":Capital Gains:Long Term",
+	"CGSHORT":  investmentIncomeAccount + ":Capital Gains:Short Term",
+	"DIV":      investmentIncomeAccount + ":Dividends",
+	"INTEREST": investmentIncomeAccount + ":Interest",
+	"MISC":     investmentIncomeAccount + ":Miscellaneous",
+}
+
+// Holding is a snapshot of an investment account's position in a security
+type Holding struct {
+	Account string
+	// Commodity is the security's ticker symbol, or its unique ID (like a CUSIP) if the ticker is unknown
+	Commodity   string
+	Units       decimal.Decimal
+	UnitPrice   decimal.Decimal
+	MarketValue decimal.Decimal
+	Currency    string
+	// Date is when UnitPrice and MarketValue were recorded
+	Date time.Time
+}
+
+// Price returns the holding's unit price as a market price
+func (h Holding) Price() ledger.Price {
+	return ledger.Price{
+		Date:      h.Date,
+		Commodity: h.Commodity,
+		Amount:    h.UnitPrice,
+		Currency:  h.Currency,
+	}
+}
+
+// ParseOFXHoldings parses the OFX response for its investment positions
+func ParseOFXHoldings(resp *ofxgo.Response) ([]Holding, error) {
+	if resp == nil {
+		return nil, nil
+	}
+	org := resp.Signon.Org.String()
+	securities := securityCommodities(*resp)
+	var holdings []Holding
+	for _, message := range resp.InvStmt {
+		statement, ok := message.(*ofxgo.InvStatementResponse)
+		if !ok {
+			continue
+		}
+		account := model.LedgerAccountFormat{
+			AccountType: model.AssetAccount,
+			Institution: org,
+			AccountID:   statement.InvAcctFrom.AcctID.String(),
+		}
+		currency := normalizeCurrency(statement.CurDef.String())
+		for _, position := range statement.InvPosList {
+			invPos, ok := investmentPosition(position)
+			if !ok {
+				continue
+			}
+			holding := Holding{
+				Account:     account.String(),
+				Commodity:   securities.commodity(invPos.SecID),
+				Units:       amountToDecimal(invPos.Units),
+				UnitPrice:   amountToDecimal(invPos.UnitPrice),
+				MarketValue: amountToDecimal(invPos.MktVal),
+				Currency:    currency,
+				Date:        invPos.DtPriceAsOf.Time,
+			}
+			if invPos.Currency != nil {
+				if ok, _ := invPos.Currency.Valid(); ok {
+					holding.Currency = normalizeCurrency(invPos.Currency.CurSym.String())
+				}
+			}
+			holdings = append(holdings, holding)
+		}
+	}
+	return holdings, nil
+}
+
+func investmentPosition(position ofxgo.Position) (ofxgo.InvPosition, bool) {
+	switch pos := position.(type) {
+	case ofxgo.DebtPosition:
+		return pos.InvPos, true
+	case ofxgo.MFPosition:
+		return pos.InvPos, true
+	case ofxgo.OptPosition:
+		return pos.InvPos, true
+	case ofxgo.OtherPosition:
+		return pos.InvPos, true
+	case ofxgo.StockPosition:
+		return pos.InvPos, true
+	default:
+		return ofxgo.InvPosition{}, false
+	}
+}
+
+// securityList maps security unique IDs onto their ledger commodity names
+type securityList map[string]string
+
+// securityCommodities reads the response's security list for ticker symbols
+func securityCommodities(resp ofxgo.Response) securityList {
+	securities := make(securityList)
+	for _, message := range resp.SecList {
+		list, ok := message.(*ofxgo.SecurityList)
+		if !ok {
+			continue
+		}
+		for _, security := range list.Securities {
+			info, ok := securityInfo(security)
+			if ok && info.Ticker != "" {
+				securities[info.SecID.UniqueID.String()] = info.Ticker.String()
+			}
+		}
+	}
+	return securities
+}
+
+func securityInfo(security ofxgo.Security) (ofxgo.SecInfo, bool) {
+	switch sec := security.(type) {
+	case ofxgo.DebtInfo:
+		return sec.SecInfo, true
+	case ofxgo.MFInfo:
+		return sec.SecInfo, true
+	case ofxgo.OptInfo:
+		return sec.SecInfo, true
+	case ofxgo.OtherInfo:
+		return sec.SecInfo, true
+	case ofxgo.StockInfo:
+		return sec.SecInfo, true
+	default:
+		return ofxgo.SecInfo{}, false
+	}
+}
+
+// commodity returns the ticker symbol for id, or the unique ID if the ticker isn't known
+func (s securityList) commodity(id ofxgo.SecurityID) string {
+	if ticker, ok := s[id.UniqueID.String()]; ok {
+		return ticker
+	}
+	return id.UniqueID.String()
+}
+
+func amountToDecimal(amount ofxgo.Amount) decimal.Decimal {
+	// NOTE: Amount uses big.Rat internally, which can't form an invalid number with .String()
+	return decimal.RequireFromString(amount.String())
+}
+
+// investmentCurrency returns the transaction's currency if it's set, otherwise the statement's currency
+func investmentCurrency(currency ofxgo.Currency, statementCurrency string) string {
+	if ok, _ := currency.Valid(); ok {
+		return normalizeCurrency(currency.CurSym.String())
+	}
+	return statementCurrency
+}
+
+// parseInvestmentTransaction converts security transactions into ledger transactions with commodity postings.
+// Buys and sells record their total cost, including fees, so the cost basis can be tracked.
+// Returns false for journal transactions, which move cash or securities between sub-accounts of the same ledger account.
+func parseInvestmentTransaction(txn ofxgo.InvTransaction, securities securityList, currency, accountName string, makeTxnID func(string) string) (ledger.Transaction, bool) {
+	if buy, ok := investmentBuy(txn); ok {
+		currency = investmentCurrency(buy.Currency, currency)
+		units := amountToDecimal(buy.Units).Abs()
+		total := amountToDecimal(buy.Total).Abs()
+		return securityTransaction(buy.InvTran, securities.commodity(buy.SecID), units, total.Neg(), currency, accountName, accountName, makeTxnID), true
+	}
+	if sell, ok := investmentSell(txn); ok {
+		currency = investmentCurrency(sell.Currency, currency)
+		units := amountToDecimal(sell.Units).Abs().Neg()
+		total := amountToDecimal(sell.Total).Abs()
+		return securityTransaction(sell.InvTran, securities.commodity(sell.SecID), units, total, currency, accountName, accountName, makeTxnID), true
+	}
+
+	switch t := txn.(type) {
+	case ofxgo.Income:
+		currency = investmentCurrency(t.Currency, currency)
+		total := amountToDecimal(t.Total)
+		return cashTransaction(t.InvTran, securities.commodity(t.SecID), total, currency, accountName, incomeAccount(t.IncomeType.String()), makeTxnID), true
+	case ofxgo.Reinvest:
+		// income is used to buy more of the same security, so the income account pays for the purchase
+		currency = investmentCurrency(t.Currency, currency)
+		units := amountToDecimal(t.Units).Abs()
+		total := amountToDecimal(t.Total).Abs()
+		return securityTransaction(t.InvTran, securities.commodity(t.SecID), units, total.Neg(), currency, accountName, incomeAccount(t.IncomeType.String()), makeTxnID), true
+	case ofxgo.RetOfCap:
+		currency = investmentCurrency(t.Currency, currency)
+		// return of capital isn't income, it reduces the security's cost basis. Holdings reduce the lots' cost basis for ReturnOfCapitalAccount.
+		commodity := securities.commodity(t.SecID)
+		total := amountToDecimal(t.Total).Abs()
+		txn := cashTransaction(t.InvTran, commodity, total, currency, accountName, model.ReturnOfCapitalAccount, makeTxnID)
+		txn.Postings[1].Tags = map[string]string{model.SecurityTag: commodity}
+		return txn, true
+	case ofxgo.MarginInterest:
+		currency = investmentCurrency(t.Currency, currency)
+		total := amountToDecimal(t.Total).Abs()
+		return cashTransaction(t.InvTran, "Margin Interest", total.Neg(), currency, accountName, investmentExpenseAccount+":Margin Interest", makeTxnID), true
+	case ofxgo.InvExpense:
+		currency = investmentCurrency(t.Currency, currency)
+		total := amountToDecimal(t.Total).Abs()
+		return cashTransaction(t.InvTran, securities.commodity(t.SecID), total.Neg(), currency, accountName, investmentExpenseAccount+":Fees", makeTxnID), true
+	case ofxgo.Transfer:
+		commodity := securities.commodity(t.SecID)
+		units := amountToDecimal(t.Units).Abs()
+		if t.TferAction == ofxgo.TferActionOut {
+			units = units.Neg()
+		}
+		costBasis := amountToDecimal(t.AvgCostBasis).Mul(units)
+		if units.IsPositive() && costBasis.IsPositive() {
+			// transfers in with a known cost basis are recorded like a purchase, paid for by the transfer account
+			return securityTransaction(t.InvTran, commodity, units, costBasis.Neg(), currency, accountName, investmentEquityAccount+":Transfers", makeTxnID), true
+		}
+		return unitsTransaction(t.InvTran, commodity, units, accountName, investmentEquityAccount+":Transfers", makeTxnID), true
+	case ofxgo.Split:
+		// split units have no cost basis, so the holding's total cost basis is unchanged. Holdings scale the existing lots' units for SplitAccount.
+		commodity := securities.commodity(t.SecID)
+		units := amountToDecimal(t.NewUnits).Sub(amountToDecimal(t.OldUnits))
+		txn := unitsTransaction(t.InvTran, commodity, units, accountName, model.SplitAccount, makeTxnID)
+		if fracCash := amountToDecimal(t.FracCash); !fracCash.IsZero() {
+			currency = investmentCurrency(t.Currency, currency)
+			txn.Postings = append(txn.Postings,
+				ledger.Posting{Account: accountName, Amount: fracCash, Currency: currency},
+				ledger.Posting{Account: incomeAccount("MISC"), Amount: fracCash.Neg(), Currency: currency},
+			)
+		}
+		return txn, true
+	case ofxgo.ClosureOpt:
+		// exercised and assigned options are paired with a separate buy or sell of the underlying security, which records any cash
+		units := amountToDecimal(t.Units).Abs().Neg()
+		return unitsTransaction(t.InvTran, securities.commodity(t.SecID), units, accountName, investmentEquityAccount+":Option Closures", makeTxnID), true
+	default:
+		return ledger.Transaction{}, false
+	}
+}
+
+// cashTransaction moves 'cash' into 'accountName' from 'otherAccount'
+func cashTransaction(invTran ofxgo.InvTran, commodity string, cash decimal.Decimal, currency, accountName, otherAccount string, makeTxnID func(string) string) ledger.Transaction {
+	return ledger.Transaction{
+		Date:  invTran.DtTrade.Time,
+		Payee: investmentPayee(invTran, commodity),
+		Postings: []ledger.Posting{
+			{
+				Account:  accountName,
+				Amount:   cash,
+				Currency: currency,
+				Tags:     map[string]string{"id": makeTxnID(invTran.FiTID.String())},
+			},
+			{
+				Account:  otherAccount,
+				Amount:   cash.Neg(),
+				Currency: currency,
+			},
+		},
+	}
+}
+
+// unitsTransaction moves 'units' of 'commodity' into 'accountName' from 'otherAccount' without any cash
+func unitsTransaction(invTran ofxgo.InvTran, commodity string, units decimal.Decimal, accountName, otherAccount string, makeTxnID func(string) string) ledger.Transaction {
+	return cashTransaction(invTran, commodity, units, commodity, accountName, otherAccount, makeTxnID)
+}
+
+// securityTransaction trades 'units' of 'commodity' in 'accountName' for 'cash' in 'cashAccount'
+func securityTransaction(invTran ofxgo.InvTran, commodity string, units, cash decimal.Decimal, currency, accountName, cashAccount string, makeTxnID func(string) string) ledger.Transaction {
+	return ledger.Transaction{
+		Date:  invTran.DtTrade.Time,
+		Payee: investmentPayee(invTran, commodity),
+		Postings: []ledger.Posting{
+			{
+				Account:  accountName,
+				Amount:   units,
+				Currency: commodity,
+				Cost:     &ledger.Cost{Amount: cash.Abs(), Currency: currency, Total: true},
+				Tags:     map[string]string{"id": makeTxnID(invTran.FiTID.String())},
+			},
+			{
+				Account:  cashAccount,
+				Amount:   cash,
+				Currency: currency,
+			},
+		},
+	}
+}
+
+func investmentPayee(invTran ofxgo.InvTran, commodity string) string {
+	if memo := invTran.Memo.String(); memo != "" {
+		return memo
+	}
+	return commodity
+}
+
+func incomeAccount(incomeType string) string {
+	if account, ok := investmentIncomeAccounts[incomeType]; ok {
+		return account
+	}
+	return investmentIncomeAccount
+}
+
+func investmentBuy(txn ofxgo.InvTransaction) (ofxgo.InvBuy, bool) {
+	switch t := txn.(type) {
+	case ofxgo.BuyDebt:
+		return t.InvBuy, true
+	case ofxgo.BuyMF:
+		return t.InvBuy, true
+	case ofxgo.BuyOpt:
+		return t.InvBuy, true
+	case ofxgo.BuyOther:
+		return t.InvBuy, true
+	case ofxgo.BuyStock:
+		return t.InvBuy, true
+	default:
+		return ofxgo.InvBuy{}, false
+	}
+}
+
+func investmentSell(txn ofxgo.InvTransaction) (ofxgo.InvSell, bool) {
+	switch t := txn.(type) {
+	case ofxgo.SellDebt:
+		return t.InvSell, true
+	case ofxgo.SellMF:
+		return t.InvSell, true
+	case ofxgo.SellOpt:
+		return t.InvSell, true
+	case ofxgo.SellOther:
+		return t.InvSell, true
+	case ofxgo.SellStock:
+		return t.InvSell, true
+	default:
+		return ofxgo.InvSell{}, false
+	}
+}
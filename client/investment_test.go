@@ -0,0 +1,321 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aclindsa/ofxgo"
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const investmentOFX = `
+OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<SIGNONMSGSRSV1>
+	<SONRS>
+		<STATUS>
+			<CODE>0
+			<SEVERITY>INFO
+		</STATUS>
+		<DTSERVER>20200110
+		<LANGUAGE>ENG
+		<FI>
+			<ORG>Broker
+			<FID>1234
+		</FI>
+	</SONRS>
+</SIGNONMSGSRSV1>
+<INVSTMTMSGSRSV1>
+	<INVSTMTTRNRS>
+		<TRNUID>0
+		<STATUS>
+			<CODE>0
+			<SEVERITY>INFO
+		</STATUS>
+		<INVSTMTRS>
+			<DTASOF>20200110
+			<CURDEF>USD
+			<INVACCTFROM>
+				<BROKERID>broker.com
+				<ACCTID>5678
+			</INVACCTFROM>
+			<INVTRANLIST>
+				<DTSTART>20200101
+				<DTEND>20200110
+				<BUYSTOCK>
+					<INVBUY>
+						<INVTRAN>
+							<FITID>buy1
+							<DTTRADE>20200102
+						</INVTRAN>
+						<SECID>
+							<UNIQUEID>922908769
+							<UNIQUEIDTYPE>CUSIP
+						</SECID>
+						<UNITS>10
+						<UNITPRICE>150.25
+						<COMMISSION>4.95
+						<TOTAL>-1507.45
+						<SUBACCTSEC>CASH
+						<SUBACCTFUND>CASH
+					</INVBUY>
+					<BUYTYPE>BUY
+				</BUYSTOCK>
+				<SELLMF>
+					<INVSELL>
+						<INVTRAN>
+							<FITID>sell1
+							<DTTRADE>20200103
+							<MEMO>Sold fund
+						</INVTRAN>
+						<SECID>
+							<UNIQUEID>000000001
+							<UNIQUEIDTYPE>CUSIP
+						</SECID>
+						<UNITS>-5
+						<UNITPRICE>20
+						<TOTAL>100
+						<SUBACCTSEC>CASH
+						<SUBACCTFUND>CASH
+					</INVSELL>
+					<SELLTYPE>SELL
+					<AVGCOSTBASIS>18
+				</SELLMF>
+				<INCOME>
+					<INVTRAN>
+						<FITID>div1
+						<DTTRADE>20200104
+					</INVTRAN>
+					<SECID>
+						<UNIQUEID>922908769
+						<UNIQUEIDTYPE>CUSIP
+					</SECID>
+					<INCOMETYPE>DIV
+					<TOTAL>12.5
+					<SUBACCTSEC>CASH
+					<SUBACCTFUND>CASH
+				</INCOME>
+				<REINVEST>
+					<INVTRAN>
+						<FITID>reinvest1
+						<DTTRADE>20200105
+					</INVTRAN>
+					<SECID>
+						<UNIQUEID>922908769
+						<UNIQUEIDTYPE>CUSIP
+					</SECID>
+					<INCOMETYPE>CGLONG
+					<TOTAL>-30
+					<SUBACCTSEC>CASH
+					<UNITS>0.2
+					<UNITPRICE>150
+				</REINVEST>
+				<INVBANKTRAN>
+					<STMTTRN>
+						<TRNTYPE>CREDIT
+						<DTPOSTED>20200106
+						<TRNAMT>500
+						<FITID>deposit1
+						<NAME>Deposit
+					</STMTTRN>
+					<SUBACCTFUND>CASH
+				</INVBANKTRAN>
+			</INVTRANLIST>
+			<INVPOSLIST>
+				<POSSTOCK>
+					<INVPOS>
+						<SECID>
+							<UNIQUEID>922908769
+							<UNIQUEIDTYPE>CUSIP
+						</SECID>
+						<HELDINACCT>CASH
+						<POSTYPE>LONG
+						<UNITS>10.2
+						<UNITPRICE>155
+						<MKTVAL>1581
+						<DTPRICEASOF>20200110
+					</INVPOS>
+				</POSSTOCK>
+			</INVPOSLIST>
+		</INVSTMTRS>
+	</INVSTMTTRNRS>
+</INVSTMTMSGSRSV1>
+<SECLISTMSGSRSV1>
+	<SECLIST>
+		<STOCKINFO>
+			<SECINFO>
+				<SECID>
+					<UNIQUEID>922908769
+					<UNIQUEIDTYPE>CUSIP
+				</SECID>
+				<SECNAME>Total Stock Market ETF
+				<TICKER>VTI
+			</SECINFO>
+		</STOCKINFO>
+	</SECLIST>
+</SECLISTMSGSRSV1>
+</OFX>
+`
+
+func TestReadOFXInvestments(t *testing.T) {
+	accounts, txns, err := ReadOFX(strings.NewReader(investmentOFX))
+	require.NoError(t, err)
+
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "assets:Broker:****5678", model.LedgerAccountName(accounts[0]))
+
+	var txnStrings []string
+	for _, txn := range txns {
+		require.NoError(t, txn.Validate())
+		txnStrings = append(txnStrings, txn.String())
+	}
+	assert.Equal(t, []string{
+		`2020/01/06 Deposit
+    assets:Broker:****5678   $ 500 ; id: 1234-5678-deposit1
+    uncategorized           $ -500
+`,
+		`2020/01/02 VTI
+    assets:Broker:****5678        10 VTI @@ $ 1507.45 ; id: 1234-5678-buy1
+    assets:Broker:****5678  $ -1507.45
+`,
+		`2020/01/03 Sold fund
+    assets:Broker:****5678   -5 "000000001" @@ $ 100 ; id: 1234-5678-sell1
+    assets:Broker:****5678  $ 100
+`,
+		`2020/01/04 VTI
+    assets:Broker:****5678           $ 12.5 ; id: 1234-5678-div1
+    revenues:Investments:Dividends  $ -12.5
+`,
+		`2020/01/05 VTI
+    assets:Broker:****5678                        0.2 VTI @@ $ 30 ; id: 1234-5678-reinvest1
+    revenues:Investments:Capital Gains:Long Term  $ -30
+`,
+	}, txnStrings)
+}
+
+func TestParseInvestmentTransaction(t *testing.T) {
+	securities := securityList{"922908769": "VTI"}
+	secID := ofxgo.SecurityID{UniqueID: "922908769", UniqueIDType: "CUSIP"}
+	invTran := func(id string) ofxgo.InvTran {
+		return ofxgo.InvTran{FiTID: ofxgo.String(id), DtTrade: ofxgo.Date{Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}}
+	}
+	makeTxnID := MakeUniqueTxnID("1234", "5678")
+	const account = "assets:Broker:****5678"
+
+	for _, tc := range []struct {
+		description string
+		txn         ofxgo.InvTransaction
+		expectTxn   string
+		expectOK    bool
+	}{
+		{
+			description: "return of capital",
+			txn:         ofxgo.RetOfCap{InvTran: invTran("roc1"), SecID: secID, Total: makeOFXAmount(20)},
+			expectTxn: `2020/01/02 VTI
+    assets:Broker:****5678                 $ 20 ; id: 1234-5678-roc1
+    equity:Investments:Return of Capital  $ -20 ; security: VTI
+`,
+			expectOK: true,
+		},
+		{
+			description: "margin interest",
+			txn:         ofxgo.MarginInterest{InvTran: invTran("margin1"), Total: makeOFXAmount(-3.5)},
+			expectTxn: `2020/01/02 Margin Interest
+    assets:Broker:****5678                $ -3.5 ; id: 1234-5678-margin1
+    expenses:Investments:Margin Interest   $ 3.5
+`,
+			expectOK: true,
+		},
+		{
+			description: "expense",
+			txn:         ofxgo.InvExpense{InvTran: invTran("fee1"), SecID: secID, Total: makeOFXAmount(1)},
+			expectTxn: `2020/01/02 VTI
+    assets:Broker:****5678     $ -1 ; id: 1234-5678-fee1
+    expenses:Investments:Fees   $ 1
+`,
+			expectOK: true,
+		},
+		{
+			description: "transfer in with cost basis",
+			txn:         ofxgo.Transfer{InvTran: invTran("in1"), SecID: secID, Units: makeOFXAmount(2), TferAction: ofxgo.TferActionIn, AvgCostBasis: makeOFXAmount(100)},
+			expectTxn: `2020/01/02 VTI
+    assets:Broker:****5678           2 VTI @@ $ 200 ; id: 1234-5678-in1
+    equity:Investments:Transfers  $ -200
+`,
+			expectOK: true,
+		},
+		{
+			description: "transfer out",
+			txn:         ofxgo.Transfer{InvTran: invTran("out1"), SecID: secID, Units: makeOFXAmount(2), TferAction: ofxgo.TferActionOut},
+			expectTxn: `2020/01/02 VTI
+    assets:Broker:****5678        -2 VTI ; id: 1234-5678-out1
+    equity:Investments:Transfers   2 VTI
+`,
+			expectOK: true,
+		},
+		{
+			description: "split with fractional cash",
+			txn:         ofxgo.Split{InvTran: invTran("split1"), SecID: secID, OldUnits: makeOFXAmount(10), NewUnits: makeOFXAmount(20), FracCash: makeOFXAmount(1.5)},
+			expectTxn: `2020/01/02 VTI
+    assets:Broker:****5678                10 VTI ; id: 1234-5678-split1
+    equity:Investments:Splits            -10 VTI
+    assets:Broker:****5678               $ 1.5
+    revenues:Investments:Miscellaneous  $ -1.5
+`,
+			expectOK: true,
+		},
+		{
+			description: "option closure",
+			txn:         ofxgo.ClosureOpt{InvTran: invTran("close1"), SecID: secID, Units: makeOFXAmount(1)},
+			expectTxn: `2020/01/02 VTI
+    assets:Broker:****5678              -1 VTI ; id: 1234-5678-close1
+    equity:Investments:Option Closures   1 VTI
+`,
+			expectOK: true,
+		},
+		{
+			description: "journal",
+			txn:         ofxgo.JrnlFund{InvTran: invTran("journal1"), Total: makeOFXAmount(5)},
+			expectOK:    false,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			txn, ok := parseInvestmentTransaction(tc.txn, securities, "$", account, makeTxnID)
+			require.Equal(t, tc.expectOK, ok)
+			if !tc.expectOK {
+				return
+			}
+			require.NoError(t, txn.Validate())
+			assert.Equal(t, tc.expectTxn, txn.String())
+		})
+	}
+}
+
+func TestParseOFXHoldings(t *testing.T) {
+	resp, err := ofxgo.ParseResponse(strings.NewReader(investmentOFX))
+	require.NoError(t, err)
+	holdings, err := ParseOFXHoldings(resp)
+	require.NoError(t, err)
+	require.Len(t, holdings, 1)
+	holding := holdings[0]
+	assert.Equal(t, "assets:Broker:****5678", holding.Account)
+	assert.Equal(t, "VTI", holding.Commodity)
+	assert.Equal(t, "10.2", holding.Units.String())
+	assert.Equal(t, "1581", holding.MarketValue.String())
+	price := holding.Price()
+	assert.Equal(t, "2020/01/10", price.Date.Format(ledger.DateFormat))
+	assert.Equal(t, "VTI", price.Commodity)
+	assert.Equal(t, "155", price.Amount.String())
+	assert.Equal(t, "$", price.Currency)
+
+	holdings, err = ParseOFXHoldings(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, holdings)
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"time"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// ValuationAccount balances valuation entries for manual accounts, like a change in a house's appraised value
+const ValuationAccount = model.EquityAccount + ":Valuation Adjustments"
+
+// ValuationTransaction returns a transaction which changes the account's balance on 'date' to 'value' and asserts the new balance.
+// The difference is posted to counterpart, which defaults to ValuationAccount.
+func ValuationTransaction(ldg *ledger.Ledger, account model.Account, date time.Time, value decimal.Decimal, currency, counterpart string) (ledger.Transaction, error) {
+	if date.IsZero() {
+		return ledger.Transaction{}, errors.New("Valuation date must be set")
+	}
+	if currency == "" {
+		return ledger.Transaction{}, errors.New("Valuation currency must not be empty")
+	}
+	if counterpart == "" {
+		counterpart = ValuationAccount
+	}
+	accountName := model.LedgerAccountName(account)
+	if accountName == counterpart {
+		return ledger.Transaction{}, errors.Errorf("Valuation counterpart account must be different from the valued account: %q", counterpart)
+	}
+
+	var balance decimal.Decimal
+	for _, txn := range ldg.TransactionsBetween(time.Time{}, date) {
+		for _, p := range txn.Postings {
+			if !p.Kind.IsVirtual() && p.Account == accountName && p.Currency == currency {
+				balance = balance.Add(p.Amount)
+			}
+		}
+	}
+
+	change := value.Sub(balance)
+	return ledger.Transaction{
+		Date:  date,
+		Payee: "Valuation: " + account.Description(),
+		Postings: []ledger.Posting{
+			{Account: accountName, Amount: change, Balance: &value, Currency: currency},
+			{Account: counterpart, Amount: change.Neg(), Currency: currency},
+		},
+	}, nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuationTransaction(t *testing.T) {
+	ldg, err := ledger.NewFromReader(strings.NewReader(`
+2020/01/01 Bought a house
+    assets:House   $ 200000
+    equity:Opening Balances   $ -200000
+
+2020/06/01 Renovation
+    assets:House   $ 15000
+    assets:Bank:****1234   $ -15000
+`))
+	require.NoError(t, err)
+	house := model.NewManualAccount("House", "House", model.AssetAccount)
+	date := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	txn, err := ValuationTransaction(ldg, house, date, decimal.New(230000, 0), "$", "")
+	require.NoError(t, err)
+	require.NoError(t, txn.Validate())
+	assert.Equal(t, `2020/12/31 Valuation: House
+    assets:House                   $ 15000 = $ 230000
+    equity:Valuation Adjustments  $ -15000
+`, txn.String())
+
+	txn, err = ValuationTransaction(ldg, house, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), decimal.New(190000, 0), "$", "expenses:Depreciation")
+	require.NoError(t, err)
+	assert.Equal(t, "-10000", txn.Postings[0].Amount.String(), "Only includes postings before the valuation date")
+	assert.Equal(t, "expenses:Depreciation", txn.Postings[1].Account)
+
+	_, err = ValuationTransaction(ldg, house, time.Time{}, decimal.Zero, "$", "")
+	assert.EqualError(t, err, "Valuation date must be set")
+	_, err = ValuationTransaction(ldg, house, date, decimal.Zero, "", "")
+	assert.EqualError(t, err, "Valuation currency must not be empty")
+	_, err = ValuationTransaction(ldg, house, date, decimal.Zero, "$", "assets:House")
+	assert.EqualError(t, err, `Valuation counterpart account must be different from the valued account: "assets:House"`)
+}
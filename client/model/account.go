@@ -16,6 +16,14 @@ const (
 	LiabilityAccount = "liabilities"
 	ExpenseAccount   = "expenses"
 	RevenueAccount   = "revenues"
+	EquityAccount    = "equity"
+
+	// SplitAccount balances the units added or removed by stock splits. Holdings scale existing lots for splits, instead of adding new lots.
+	SplitAccount = EquityAccount + ":Investments:Splits"
+	// ReturnOfCapitalAccount balances cash returned from a security's cost basis. Holdings reduce the cost basis of the lots named by the posting's SecurityTag.
+	ReturnOfCapitalAccount = EquityAccount + ":Investments:Return of Capital"
+	// SecurityTag names the commodity a return of capital applies to, like '; security: VTI'
+	SecurityTag = "security"
 
 	// RedactSuffixLength the number of characters that remain unredacted at the end of a redacted string
 	RedactSuffixLength = 4
@@ -96,6 +104,13 @@ type LedgerAccountFormat struct {
 
 // LedgerFormat parses the account and returns a ledger account format
 func LedgerFormat(a Account) *LedgerAccountFormat {
+	if manual, ok := a.(*ManualAccount); ok {
+		// manual accounts have no institution and nothing to redact
+		return &LedgerAccountFormat{
+			AccountType: manual.Type(),
+			Remaining:   manual.ID(),
+		}
+	}
 	return &LedgerAccountFormat{
 		AccountType: a.Type(),
 		Institution: a.Institution().Org(),
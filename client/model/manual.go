@@ -0,0 +1,66 @@
+package model
+
+import (
+	"strings"
+
+	sErrors "github.com/johnstarich/sage/errors"
+)
+
+// ManualAccount is an account without an institution or connector, like cash, a house, or a loan between friends.
+// Its balance only changes with manually entered transactions and valuation entries, so it's never synced.
+type ManualAccount struct {
+	AccountDescription string
+	// AccountID is the ledger account name after the account type, like 'Cash' or 'Loans:Car'
+	AccountID   string
+	AccountType string
+	// Manual is always true, which identifies manual accounts when unmarshaling
+	Manual bool
+}
+
+// NewManualAccount creates a manual account for the ledger account 'accountType:id'
+func NewManualAccount(id, description, accountType string) *ManualAccount {
+	return &ManualAccount{
+		AccountDescription: description,
+		AccountID:          id,
+		AccountType:        accountType,
+		Manual:             true,
+	}
+}
+
+// Description returns the account's display name
+func (m *ManualAccount) Description() string {
+	return m.AccountDescription
+}
+
+// ID returns the account's ledger name, without its type
+func (m *ManualAccount) ID() string {
+	return m.AccountID
+}
+
+// Institution always returns nil, manual accounts have no institution
+func (m *ManualAccount) Institution() Institution {
+	return nil
+}
+
+// Type returns the ledger account type, such as 'assets' or 'equity'
+func (m *ManualAccount) Type() string {
+	return m.AccountType
+}
+
+// ValidateManualAccount checks the manual account's fields. Unlike institution accounts, any ledger account type is allowed.
+func ValidateManualAccount(account *ManualAccount) error {
+	var errs sErrors.Errors
+	errs.AddErr(ValidatePartialAccount(account))
+	if !errs.ErrIf(account.Type() == "", "Account type must not be empty") {
+		validType := false
+		switch account.Type() {
+		case AssetAccount, LiabilityAccount, EquityAccount, ExpenseAccount, RevenueAccount:
+			validType = true
+		}
+		errs.ErrIf(!validType, "Account type must be one of %q, %q, %q, %q, or %q: %q", AssetAccount, LiabilityAccount, EquityAccount, ExpenseAccount, RevenueAccount, account.Type())
+	}
+	id := account.ID()
+	errs.ErrIf(id != "" && (strings.HasPrefix(id, ":") || strings.HasSuffix(id, ":") || strings.Contains(id, "::")), "Account ID must not have empty components: %q", id)
+	errs.ErrIf(strings.Contains(id, "  ") || strings.ContainsAny(id, "\t;"), "Account ID must not contain tabs, semicolons, or consecutive spaces: %q", id)
+	return errs.ErrOrNil()
+}
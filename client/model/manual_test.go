@@ -0,0 +1,71 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManualAccount(t *testing.T) {
+	a := NewManualAccount("Loans:Car", "Car loan", LiabilityAccount)
+	assert.Equal(t, "Loans:Car", a.ID())
+	assert.Equal(t, "Car loan", a.Description())
+	assert.Nil(t, a.Institution())
+	assert.Equal(t, LiabilityAccount, a.Type())
+	assert.True(t, a.Manual)
+	assert.Equal(t, "liabilities:Loans:Car", LedgerAccountName(a), "Manual account names aren't redacted")
+}
+
+func TestValidateManualAccount(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		account     *ManualAccount
+		errors      []string
+	}{
+		{
+			description: "valid asset",
+			account:     NewManualAccount("Cash", "Wallet", AssetAccount),
+		},
+		{
+			description: "valid equity",
+			account:     NewManualAccount("Owner Contributions", "Contributions", EquityAccount),
+		},
+		{
+			description: "all empty",
+			account:     &ManualAccount{},
+			errors: []string{
+				"Account description must not be empty",
+				"Account ID must not be empty",
+				"Account type must not be empty",
+			},
+		},
+		{
+			description: "bad account type",
+			account:     NewManualAccount("Cash", "Wallet", "not normal"),
+			errors:      []string{`Account type must be one of "assets", "liabilities", "equity", "expenses", or "revenues": "not normal"`},
+		},
+		{
+			description: "empty component",
+			account:     NewManualAccount("Loans::Car", "Car loan", LiabilityAccount),
+			errors:      []string{`Account ID must not have empty components: "Loans::Car"`},
+		},
+		{
+			description: "bad characters",
+			account:     NewManualAccount("Cash  Box", "Cash box", AssetAccount),
+			errors:      []string{`Account ID must not contain tabs, semicolons, or consecutive spaces: "Cash  Box"`},
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			err := ValidateManualAccount(tc.account)
+			if len(tc.errors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, errMessage := range tc.errors {
+				assert.Contains(t, err.Error(), errMessage)
+			}
+		})
+	}
+}
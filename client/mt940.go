@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultMT940Institution names the institution of MT940 accounts without a bank code
+const DefaultMT940Institution = "MT940"
+
+var (
+	// mt940Tag matches the start of a field, like ":61:"
+	mt940Tag = regexp.MustCompile(`^:([0-9]{2}[A-Z]?):`)
+	// mt940Balance matches balance fields, like "C200131EUR995,75"
+	mt940Balance = regexp.MustCompile(`^([CD])([0-9]{6})([A-Z]{3})([0-9,]+)`)
+	// mt940Line matches statement lines, like "2001020102D4,25NMSCNONREF//BANKREF"
+	mt940Line = regexp.MustCompile(`^([0-9]{6})([0-9]{4})?(R?[CD])[A-Z]?([0-9,]+)([A-Z][A-Z0-9]{3})([^/\n]*)(?://([^\n]*))?`)
+	// mt940InfoSubfield matches structured information subfields, like "?20"
+	mt940InfoSubfield = regexp.MustCompile(`\?([0-9]{2})`)
+)
+
+const mt940NoReference = "NONREF"
+
+type mt940Field struct {
+	tag   string
+	value string
+}
+
+// ReadMT940 reads r and parses it for an MT940 file's booked transactions
+func ReadMT940(r io.Reader) ([]model.Account, []ledger.Transaction, error) {
+	fields, err := readMT940Fields(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var statements []bankStatement
+	var statement *bankStatement
+	var currency string
+	for _, field := range fields {
+		if field.tag != "20" && statement == nil {
+			continue
+		}
+		switch field.tag {
+		case "20":
+			statements = append(statements, bankStatement{})
+			statement = &statements[len(statements)-1]
+		case "25":
+			statement.FID, statement.AccountID = parseMT940Account(field.value)
+			statement.Org = statement.FID
+			if statement.Org == "" {
+				statement.Org = DefaultMT940Institution
+			}
+		case "60F", "60M":
+			_, _, currency, err = parseMT940Balance(field.value)
+			if err != nil {
+				return nil, nil, err
+			}
+		case "61":
+			entry, err := parseMT940Line(field.value, currency)
+			if err != nil {
+				return nil, nil, err
+			}
+			statement.Entries = append(statement.Entries, entry)
+		case "86":
+			if len(statement.Entries) > 0 {
+				entry := &statement.Entries[len(statement.Entries)-1]
+				entry.Payee, entry.Memo = parseMT940Info(field.value)
+			}
+		case "62F", "62M":
+			balance, balanceDate, _, err := parseMT940Balance(field.value)
+			if err != nil {
+				return nil, nil, err
+			}
+			statement.ClosingBalance = &balance
+			statement.ClosingDate = balanceDate
+			statement.EndDate = balanceDate
+		}
+	}
+	if len(statements) == 0 {
+		return nil, nil, errors.New("No statements found in MT940 file")
+	}
+	for _, statement := range statements {
+		if statement.AccountID == "" {
+			return nil, nil, errors.New("MT940 statement must have an account identification field (:25:)")
+		}
+	}
+	accounts, txns := importStatements(statements)
+	return accounts, txns, nil
+}
+
+// readMT940Fields splits an MT940 file into its fields, joining continuation lines
+func readMT940Fields(r io.Reader) ([]mt940Field, error) {
+	var fields []mt940Field
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if match := mt940Tag.FindStringSubmatch(line); match != nil {
+			fields = append(fields, mt940Field{tag: match[1], value: line[len(match[0]):]})
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "-" || strings.HasPrefix(trimmed, "{") {
+			// skip statement separators and SWIFT headers
+			continue
+		}
+		if len(fields) > 0 {
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+	return fields, scanner.Err()
+}
+
+// parseMT940Account parses account identifications like "10020030/1234567" (bank code and account number) or an IBAN
+func parseMT940Account(value string) (fid, accountID string) {
+	value = strings.TrimSpace(value)
+	if slash := strings.IndexRune(value, '/'); slash != -1 {
+		return value[:slash], value[slash+1:]
+	}
+	return "", value
+}
+
+func parseMT940Amount(value string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(strings.Replace(value, ",", ".", 1))
+	if err != nil {
+		return decimal.Zero, errors.Errorf("Invalid amount: %q", value)
+	}
+	return amount, nil
+}
+
+func parseMT940Date(value string) (time.Time, error) {
+	date, err := time.Parse("060102", value)
+	return date, errors.Wrapf(err, "Invalid date: %q", value)
+}
+
+func parseMT940Balance(value string) (balance decimal.Decimal, date time.Time, currency string, err error) {
+	match := mt940Balance.FindStringSubmatch(value)
+	if match == nil {
+		return decimal.Zero, time.Time{}, "", errors.Errorf("Invalid balance: %q", value)
+	}
+	date, err = parseMT940Date(match[2])
+	if err != nil {
+		return
+	}
+	balance, err = parseMT940Amount(match[4])
+	if match[1] == "D" {
+		balance = balance.Neg()
+	}
+	return balance, date, match[3], err
+}
+
+// parseMT940Line parses a statement line (:61:) into an entry. The bank's reference is used as the entry reference.
+// If there's only a customer reference, it's combined with the date and amount, since customer references aren't unique.
+func parseMT940Line(value, currency string) (statementEntry, error) {
+	match := mt940Line.FindStringSubmatch(value)
+	if match == nil {
+		return statementEntry{}, errors.Errorf("Invalid statement line: %q", value)
+	}
+	date, err := parseMT940Date(match[1])
+	if err != nil {
+		return statementEntry{}, err
+	}
+	amount, err := parseMT940Amount(match[4])
+	if err != nil {
+		return statementEntry{}, err
+	}
+	switch match[3] {
+	case "D", "RC":
+		// debits and reversed credits
+		amount = amount.Neg()
+	}
+
+	customerRef, bankRef := strings.TrimSpace(match[6]), strings.TrimSpace(match[7])
+	var ref string
+	switch {
+	case bankRef != "" && bankRef != mt940NoReference:
+		ref = bankRef
+	case customerRef != "" && customerRef != mt940NoReference:
+		// customer references repeat, like mandate references on recurring debits, so include the date and amount
+		ref = fmt.Sprintf("%s-%s-%s", date.Format("20060102"), amount, customerRef)
+	}
+	return statementEntry{
+		Ref:      ref,
+		Date:     date,
+		Amount:   amount,
+		Currency: currency,
+	}, nil
+}
+
+// parseMT940Info parses information to account owner (:86:) into a payee and memo.
+// Structured information like "166?00SEPA?20memo?32payee" uses subfields 20-29 and 60-63 for the memo and 32-33 for the payee. Otherwise the information is the memo.
+func parseMT940Info(value string) (payee, memo string) {
+	value = strings.ReplaceAll(value, "\n", "")
+	indexes := mt940InfoSubfield.FindAllStringSubmatchIndex(value, -1)
+	if len(indexes) == 0 {
+		return "", strings.TrimSpace(value)
+	}
+	var payeeParts, memoParts []string
+	for i, index := range indexes {
+		end := len(value)
+		if i+1 < len(indexes) {
+			end = indexes[i+1][0]
+		}
+		subfield, content := value[index[2]:index[3]], value[index[1]:end]
+		switch {
+		case subfield >= "20" && subfield <= "29", subfield >= "60" && subfield <= "63":
+			memoParts = append(memoParts, content)
+		case subfield == "32" || subfield == "33":
+			payeeParts = append(payeeParts, content)
+		}
+	}
+	return strings.TrimSpace(strings.Join(payeeParts, "")), strings.TrimSpace(strings.Join(memoParts, ""))
+}
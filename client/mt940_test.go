@@ -0,0 +1,91 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMT940 = `:20:STARTUMSE
+:25:10020030/1234567
+:28C:00001/001
+:60F:C200101EUR100,00
+:61:2001020102D4,25NMSCNONREF//BANKREF1
+:86:106?00KARTENZAHLUNG?20Card payment?21 at shop?32Coffee Shop
+:61:2001030103C1000,10NTRFSALARY-JAN
+:86:Salary for January
+:62F:C200131EUR1095,85
+-
+`
+
+func TestReadMT940(t *testing.T) {
+	accounts, txns, err := ReadMT940(strings.NewReader(testMT940))
+	require.NoError(t, err)
+	assert.Equal(t, []model.Account{
+		&model.BasicAccount{
+			AccountDescription: "10020030 - 1234567",
+			AccountID:          "1234567",
+			AccountType:        model.AssetAccount,
+			BasicInstitution: model.BasicInstitution{
+				InstDescription: "10020030",
+				InstFID:         "10020030",
+				InstOrg:         "10020030",
+			},
+		},
+	}, accounts)
+
+	var txnStrings []string
+	for _, txn := range txns {
+		txnStrings = append(txnStrings, txn.String())
+	}
+	assert.Equal(t, []string{
+		`2020/01/02 Coffee Shop
+    assets:10020030:****4567  -4.25 EUR = 95.75 EUR ; Card payment at shop id: 10020030-1234567-BANKREF1
+    uncategorized              4.25 EUR
+`,
+		`2020/01/03 Salary for January
+    assets:10020030:****4567   1000.1 EUR = 1095.85 EUR ; id: 10020030-1234567-20200103-1000.1-SALARY-JAN
+    uncategorized             -1000.1 EUR
+`,
+	}, txnStrings)
+}
+
+func TestParseMT940Line(t *testing.T) {
+	for _, tc := range []struct {
+		line         string
+		expectRef    string
+		expectAmount string
+		expectErr    bool
+	}{
+		{line: "2001020102D4,25NMSCNONREF//BANKREF", expectRef: "BANKREF", expectAmount: "-4.25"},
+		{line: "200102C5,NTRFCUSTREF//BANKREF", expectRef: "BANKREF", expectAmount: "5"},
+		{line: "200102C5,NTRFCUSTREF", expectRef: "20200102-5-CUSTREF", expectAmount: "5"},
+		{line: "200103C5,NTRFCUSTREF", expectRef: "20200103-5-CUSTREF", expectAmount: "5"},
+		{line: "200102RD5,00NTRFNONREF", expectRef: "", expectAmount: "5"},
+		{line: "200102RC5,00NTRFREF\nsupplementary details", expectRef: "20200102--5-REF", expectAmount: "-5"},
+		{line: "not a statement line", expectErr: true},
+		{line: "201302D5,00NTRFREF", expectErr: true},
+	} {
+		t.Run(tc.line, func(t *testing.T) {
+			entry, err := parseMT940Line(tc.line, "EUR")
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectRef, entry.Ref)
+			assert.Equal(t, tc.expectAmount, entry.Amount.String())
+		})
+	}
+}
+
+func TestReadMT940Errors(t *testing.T) {
+	_, _, err := ReadMT940(strings.NewReader(""))
+	assert.EqualError(t, err, "No statements found in MT940 file")
+
+	_, _, err = ReadMT940(strings.NewReader(":20:STARTUMSE\n:60F:C200101EUR100,00\n"))
+	assert.Error(t, err, "Account identification is required")
+}
@@ -0,0 +1,398 @@
+package client
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// qifCurrency is assumed for all QIF amounts, since QIF files don't include a currency
+	qifCurrency = "$"
+	// DefaultQIFInstitution names the institution of QIF accounts when none is provided
+	DefaultQIFInstitution = "QIF"
+)
+
+// qifAccountTypes maps QIF account types onto ledger account types. Other types, like investments and category lists, are skipped.
+var qifAccountTypes = map[string]string{
+	"bank":  model.AssetAccount,
+	"cash":  model.AssetAccount,
+	"oth a": model.AssetAccount,
+	"ccard": model.LiabilityAccount,
+	"oth l": model.LiabilityAccount,
+}
+
+type qifSplit struct {
+	category string
+	memo     string
+	amount   decimal.Decimal
+}
+
+type qifRecord struct {
+	date     string
+	amount   string
+	payee    string
+	memo     string
+	number   string
+	cleared  string
+	category string
+	splits   []qifSplit
+
+	// account list fields
+	name        string
+	accountType string
+	description string
+}
+
+type qifAccount struct {
+	name        string
+	description string
+	accountType string
+	// hasTransactions is true if any transactions were read for this account, otherwise it's only listed in the file
+	hasTransactions bool
+}
+
+// ReadQIF reads r and parses it for a QIF file's bank, credit card, and cash transactions.
+// Accounts are named by the file's account headers, otherwise by 'accountID'. All accounts are at 'institution'.
+// Transfers between accounts in the file are only read from one of the accounts.
+func ReadQIF(r io.Reader, institution, accountID string) ([]model.Account, []ledger.Transaction, error) {
+	if institution == "" {
+		institution = DefaultQIFInstitution
+	}
+	accountTypes := make(map[string]string)
+	var accounts []qifAccount
+	var txns []ledger.Transaction
+	var current *qifAccount
+	inAccountList := false
+	sectionType := ""
+	occurrences := make(map[string]int)
+
+	record := qifRecord{}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			header := strings.ToLower(strings.TrimSpace(line[1:]))
+			switch {
+			case header == "account":
+				inAccountList = true
+			case strings.HasPrefix(header, "type:"):
+				inAccountList = false
+				sectionType = strings.TrimSpace(strings.TrimPrefix(header, "type:"))
+				if _, supported := qifAccountTypes[sectionType]; supported && current == nil {
+					if accountID == "" {
+						return nil, nil, errors.New("QIF file has no account header, an account ID is required")
+					}
+					accounts = append(accounts, qifAccount{name: accountID, accountType: qifAccountTypes[sectionType]})
+					current = &accounts[len(accounts)-1]
+				}
+			}
+			// other headers are options, like !Option:AutoSwitch
+			continue
+		}
+
+		code, value := line[0], strings.TrimSpace(line[1:])
+		if !inAccountList && qifAccountTypes[sectionType] == "" {
+			// skip unsupported sections, like category lists and investments
+			continue
+		}
+		if code != '^' {
+			if err := record.add(code, value); err != nil {
+				return nil, nil, errors.Wrapf(err, "Invalid QIF on line %d", lineNumber)
+			}
+			continue
+		}
+
+		// end of record
+		switch {
+		case inAccountList:
+			account := qifAccount{
+				name:        record.name,
+				description: record.description,
+				accountType: qifAccountTypes[strings.ToLower(record.accountType)],
+			}
+			if account.accountType == "" {
+				account.accountType = model.AssetAccount
+			}
+			accountTypes[account.name] = account.accountType
+			accounts = append(accounts, account)
+			current = &accounts[len(accounts)-1]
+		case qifAccountTypes[sectionType] != "":
+			txn, err := record.transaction(institution, *current, accountTypes, occurrences)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "Invalid QIF transaction ending on line %d", lineNumber)
+			}
+			txns = append(txns, txn)
+			current.hasTransactions = true
+		}
+		record = qifRecord{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var skeletonAccounts []model.Account
+	added := make(map[string]bool)
+	fileAccounts := make(map[string]bool)
+	for _, account := range accounts {
+		if account.name == "" || !account.hasTransactions || added[account.name] {
+			continue
+		}
+		added[account.name] = true
+		fileAccounts[qifLedgerAccount(institution, account.name, account.accountType)] = true
+		description := account.description
+		if description == "" {
+			description = fmt.Sprintf("%s - %s", institution, account.name)
+		}
+		skeletonAccounts = append(skeletonAccounts, &model.BasicAccount{
+			AccountDescription: description,
+			AccountID:          account.name,
+			AccountType:        account.accountType,
+			BasicInstitution: model.BasicInstitution{
+				InstDescription: institution,
+				InstOrg:         institution,
+			},
+		})
+	}
+	return skeletonAccounts, dropMirroredTransfers(txns, fileAccounts), nil
+}
+
+// transferKey identifies one side of a transfer: 'amount' moved into 'account' from 'other' on 'date'
+type transferKey struct {
+	date, account, other, amount string
+}
+
+// dropMirroredTransfers drops transfers between accounts in 'fileAccounts' which were already read from the other account.
+// QIF files list a transfer in both accounts' transactions, like '[Savings]' in checking and '[Checking]' in savings, so keeping both would count it twice.
+// Only plain 2 posting transfers are dropped, so split transactions keep their other categories.
+func dropMirroredTransfers(txns []ledger.Transaction, fileAccounts map[string]bool) []ledger.Transaction {
+	transfers := make(map[transferKey]int)
+	addTransfers := func(txn ledger.Transaction) {
+		account := txn.Postings[0].Account
+		for _, p := range txn.Postings[1:] {
+			if fileAccounts[p.Account] && p.Account != account {
+				transfers[transferKey{txn.Date.Format(ledger.DateFormat), p.Account, account, p.Amount.String()}]++
+			}
+		}
+	}
+	// split transactions are always kept, so their transfers are found first
+	for _, txn := range txns {
+		if len(txn.Postings) > 2 {
+			addTransfers(txn)
+		}
+	}
+
+	result := make([]ledger.Transaction, 0, len(txns))
+	for _, txn := range txns {
+		if len(txn.Postings) == 2 {
+			first := txn.Postings[0]
+			mirror := transferKey{txn.Date.Format(ledger.DateFormat), first.Account, txn.Postings[1].Account, first.Amount.String()}
+			if transfers[mirror] > 0 {
+				transfers[mirror]--
+				continue
+			}
+			addTransfers(txn)
+		}
+		result = append(result, txn)
+	}
+	return result
+}
+
+func (q *qifRecord) add(code byte, value string) error {
+	switch code {
+	case 'D':
+		q.date = value
+	case 'T', 'U':
+		q.amount = value
+	case 'P':
+		q.payee = value
+	case 'M':
+		q.memo = value
+	case 'N':
+		q.name = value
+		q.number = value
+	case 'C':
+		q.cleared = value
+	case 'L':
+		q.category = value
+	case 'S':
+		q.splits = append(q.splits, qifSplit{category: value})
+	case 'E':
+		if len(q.splits) == 0 {
+			return errors.New("Split memo must follow a split category")
+		}
+		q.splits[len(q.splits)-1].memo = value
+	case '$':
+		if len(q.splits) == 0 {
+			return errors.New("Split amount must follow a split category")
+		}
+		amount, err := parseQIFAmount(value)
+		if err != nil {
+			return err
+		}
+		q.splits[len(q.splits)-1].amount = amount
+	}
+	// account list fields share codes with transaction fields. Other fields, like addresses, are ignored.
+	switch code {
+	case 'T':
+		q.accountType = value
+	case 'D':
+		q.description = value
+	}
+	return nil
+}
+
+// transaction converts the record into a transaction for 'account'. Transfer categories name accounts in 'accountTypes'.
+func (q qifRecord) transaction(institution string, account qifAccount, accountTypes map[string]string, occurrences map[string]int) (ledger.Transaction, error) {
+	date, err := parseQIFDate(q.date)
+	if err != nil {
+		return ledger.Transaction{}, err
+	}
+	amount, err := parseQIFAmount(q.amount)
+	if err != nil {
+		return ledger.Transaction{}, err
+	}
+
+	// only stable fields identify the record, so clearing or recategorizing it before exporting again keeps the same ID.
+	// identical records, like two coffees on the same day, are distinguished by their order in the file
+	recordKey := strings.Join([]string{account.name, date.Format(ledger.DateFormat), amount.String(), q.payee, q.number}, "\n")
+	occurrences[recordKey]++
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\n%d", recordKey, occurrences[recordKey])))
+	makeTxnID := MakeUniqueTxnID(institution, account.name)
+
+	ledgerAccount := qifLedgerAccount(institution, account.name, account.accountType)
+	txn := ledger.Transaction{
+		Date:  date,
+		Payee: q.payee,
+		Code:  q.number,
+		Postings: []ledger.Posting{
+			{
+				Account:  ledgerAccount,
+				Amount:   amount,
+				Currency: qifCurrency,
+				Comment:  cleanMemo(q.memo),
+				Tags:     map[string]string{"id": makeTxnID(fmt.Sprintf("qif%x", hash[:12]))},
+			},
+		},
+	}
+	if q.cleared != "" {
+		txn.Status = ledger.StatusCleared
+	}
+
+	if len(q.splits) == 0 {
+		txn.Postings = append(txn.Postings, ledger.Posting{
+			Account:  qifCategory(q.category, amount, institution, accountTypes),
+			Amount:   amount.Neg(),
+			Currency: qifCurrency,
+		})
+		return txn, nil
+	}
+	remaining := amount
+	for _, split := range q.splits {
+		txn.Postings = append(txn.Postings, ledger.Posting{
+			Account:  qifCategory(split.category, split.amount, institution, accountTypes),
+			Amount:   split.amount.Neg(),
+			Currency: qifCurrency,
+			Comment:  cleanMemo(split.memo),
+		})
+		remaining = remaining.Sub(split.amount)
+	}
+	if !remaining.IsZero() {
+		txn.Postings = append(txn.Postings, ledger.Posting{
+			Account:  model.Uncategorized,
+			Amount:   remaining.Neg(),
+			Currency: qifCurrency,
+		})
+	}
+	return txn, nil
+}
+
+func qifLedgerAccount(institution, accountName, accountType string) string {
+	format := model.LedgerAccountFormat{
+		AccountType: accountType,
+		Institution: institution,
+		AccountID:   accountName,
+	}
+	return format.String()
+}
+
+// qifCategory maps a QIF category onto a ledger account. Categories like "Food:Groceries" become expenses or revenues based on the sign of 'amount'.
+// Transfer categories like "[Savings]" become the named account.
+func qifCategory(category string, amount decimal.Decimal, institution string, accountTypes map[string]string) string {
+	// remove classes, like "Food:Groceries/Vacation"
+	if slash := strings.IndexRune(category, '/'); slash != -1 {
+		category = category[:slash]
+	}
+	category = strings.TrimSpace(category)
+	if strings.HasPrefix(category, "[") && strings.HasSuffix(category, "]") {
+		accountName := strings.TrimSpace(category[1 : len(category)-1])
+		accountType := accountTypes[accountName]
+		if accountType == "" {
+			accountType = model.AssetAccount
+		}
+		return qifLedgerAccount(institution, accountName, accountType)
+	}
+	if category == "" {
+		return model.Uncategorized
+	}
+	if amount.IsNegative() {
+		return model.ExpenseAccount + ":" + category
+	}
+	return model.RevenueAccount + ":" + category
+}
+
+// parseQIFDate parses dates like "01/02/2020", "1/2'20", "1/ 2/20", or "2020-01-02". Month and day order follows US Quicken exports.
+func parseQIFDate(value string) (time.Time, error) {
+	normalized := strings.NewReplacer("'", "/", " ", "", "-", "/", ".", "/").Replace(value)
+	parts := strings.Split(normalized, "/")
+	if len(parts) != 3 {
+		return time.Time{}, errors.Errorf("Invalid date: %q", value)
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, errors.Errorf("Invalid date: %q", value)
+		}
+		numbers[i] = number
+	}
+	year, month, day := numbers[2], numbers[0], numbers[1]
+	if len(parts[0]) == 4 {
+		year, month, day = numbers[0], numbers[1], numbers[2]
+	}
+	if len(strconv.Itoa(year)) <= 2 {
+		const centuryPivot = 70
+		if year < centuryPivot {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Month() != time.Month(month) || date.Day() != day {
+		return time.Time{}, errors.Errorf("Invalid date: %q", value)
+	}
+	return date, nil
+}
+
+func parseQIFAmount(value string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(strings.ReplaceAll(value, ",", ""))
+	if err != nil {
+		return decimal.Zero, errors.Errorf("Invalid amount: %q", value)
+	}
+	return amount, nil
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadQIF(t *testing.T) {
+	for _, tc := range []struct {
+		description    string
+		qif            string
+		accountID      string
+		expectAccounts []string
+		expectTxns     []string
+		expectErr      bool
+	}{
+		{
+			description: "bank with splits",
+			accountID:   "1234",
+			qif: `!Type:Bank
+D01/02/2020
+T-1,004.25
+PCoffee and rent
+N101
+C*
+SFood:Coffee
+EMorning coffee
+$-4.25
+SHousing:Rent/Apartment
+$-900.00
+^
+D1/3'20
+T1000.10
+PPaycheck
+LSalary
+^
+D1/ 4/20
+T-100
+PTransfer to savings
+L[Savings]
+^
+`,
+			expectAccounts: []string{"assets:QIF:****1234"},
+			expectTxns: []string{
+				`2020/01/02 * (101) Coffee and rent
+    assets:QIF:****1234    $ -1004.25
+    expenses:Food:Coffee       $ 4.25 ; Morning coffee
+    expenses:Housing:Rent       $ 900
+    uncategorized               $ 100
+`,
+				`2020/01/03 Paycheck
+    assets:QIF:****1234   $ 1000.1
+    revenues:Salary      $ -1000.1
+`,
+				`2020/01/04 Transfer to savings
+    assets:QIF:****1234  $ -100
+    assets:QIF:****ings   $ 100
+`,
+			},
+		},
+		{
+			description: "account headers",
+			qif: `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+^
+NVisa
+TCCard
+^
+!Clear:AutoSwitch
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D2020-01-05
+T-20.00
+PGrocery store
+MWeekly groceries
+^
+!Type:Cat
+NFood
+E
+^
+`,
+			expectAccounts: []string{"liabilities:QIF:****Visa"},
+			expectTxns: []string{
+				`2020/01/05 Grocery store
+    liabilities:QIF:****Visa  $ -20 ; Weekly groceries
+    uncategorized              $ 20
+`,
+			},
+		},
+		{
+			description: "transfers between accounts in the file",
+			qif: `!Account
+NChecking
+TBank
+^
+!Type:Bank
+D01/04/2020
+T-100
+PTransfer to savings
+L[Savings]
+^
+D01/05/2020
+T-30
+PRent and savings
+SHousing:Rent
+$-10
+S[Savings]
+$-20
+^
+!Account
+NSavings
+TBank
+^
+!Type:Bank
+D01/04/2020
+T100
+PTransfer from checking
+L[Checking]
+^
+D01/05/2020
+T20
+PRent and savings
+L[Checking]
+^
+D01/06/2020
+T-5
+PTransfer to checking
+L[Checking]
+^
+`,
+			expectAccounts: []string{"assets:QIF:****king", "assets:QIF:****ings"},
+			expectTxns: []string{
+				`2020/01/04 Transfer to savings
+    assets:QIF:****king  $ -100
+    assets:QIF:****ings   $ 100
+`,
+				`2020/01/05 Rent and savings
+    assets:QIF:****king    $ -30
+    expenses:Housing:Rent   $ 10
+    assets:QIF:****ings     $ 20
+`,
+				`2020/01/06 Transfer to checking
+    assets:QIF:****ings  $ -5
+    assets:QIF:****king   $ 5
+`,
+			},
+		},
+		{
+			description: "missing account ID",
+			qif: `!Type:Bank
+D01/02/2020
+T-4.25
+^
+`,
+			expectErr: true,
+		},
+		{
+			description: "invalid date",
+			accountID:   "1234",
+			qif: `!Type:Bank
+D02/30/2020
+T-4.25
+^
+`,
+			expectErr: true,
+		},
+		{
+			description: "split amount without category",
+			accountID:   "1234",
+			qif: `!Type:Bank
+D01/02/2020
+T-4.25
+$-4.25
+^
+`,
+			expectErr: true,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			accounts, txns, err := ReadQIF(strings.NewReader(tc.qif), "", tc.accountID)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var accountNames []string
+			for _, account := range accounts {
+				accountNames = append(accountNames, model.LedgerAccountName(account))
+			}
+			assert.Equal(t, tc.expectAccounts, accountNames)
+			var txnStrings []string
+			for _, txn := range txns {
+				assert.NotEmpty(t, txn.Postings[0].ID())
+				delete(txn.Postings[0].Tags, "id")
+				txnStrings = append(txnStrings, txn.String())
+			}
+			assert.Equal(t, tc.expectTxns, txnStrings)
+		})
+	}
+}
+
+func TestReadQIFTransactionIDs(t *testing.T) {
+	const qif = `!Type:Bank
+D01/02/2020
+T-4.25
+PCoffee
+^
+D01/02/2020
+T-4.25
+PCoffee
+^
+`
+	_, txns, err := ReadQIF(strings.NewReader(qif), "Bank", "1234")
+	require.NoError(t, err)
+	require.Len(t, txns, 2)
+	id := func(txn ledger.Transaction) string {
+		return txn.Postings[0].ID()
+	}
+	assert.NotEqual(t, id(txns[0]), id(txns[1]), "Identical records should have different IDs")
+
+	_, reimported, err := ReadQIF(strings.NewReader(qif), "Bank", "1234")
+	require.NoError(t, err)
+	assert.Equal(t, id(txns[0]), id(reimported[0]), "IDs should be stable across imports")
+	assert.Equal(t, id(txns[1]), id(reimported[1]))
+
+	_, edited, err := ReadQIF(strings.NewReader(`!Type:Bank
+D01/02/2020
+T-4.25
+PCoffee
+CX
+LFood:Coffee
+MMorning coffee
+^
+`), "Bank", "1234")
+	require.NoError(t, err)
+	require.Len(t, edited, 1)
+	assert.Equal(t, id(txns[0]), id(edited[0]), "Clearing, categorizing, or adding a memo should keep the same ID")
+}
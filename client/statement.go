@@ -0,0 +1,103 @@
+package client
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+)
+
+// bankStatement is a parsed bank account statement, like a camt.053 or MT940 statement
+type bankStatement struct {
+	Org       string
+	FID       string
+	AccountID string
+	Entries   []statementEntry
+
+	// ClosingBalance is the booked balance on ClosingDate, if provided
+	ClosingBalance *decimal.Decimal
+	ClosingDate    time.Time
+	// EndDate is the last day covered by the statement
+	EndDate time.Time
+}
+
+// statementEntry is a booked entry in a bank statement
+type statementEntry struct {
+	// Ref is the statement's entry reference, unique to the account
+	Ref      string
+	Date     time.Time
+	Amount   decimal.Decimal
+	Currency string
+	Payee    string
+	Memo     string
+}
+
+// importStatements converts bank statements into skeleton accounts and transactions with booked balances
+func importStatements(statements []bankStatement) (skeletonAccounts []model.Account, txns []ledger.Transaction) {
+	addedAccounts := make(map[string]bool)
+	// entries without references, like two coffees on the same day, are distinguished by their order in the statement
+	occurrences := make(map[string]int)
+	for _, statement := range statements {
+		account := model.LedgerAccountFormat{
+			AccountType: model.AssetAccount,
+			Institution: statement.Org,
+			AccountID:   statement.AccountID,
+		}
+		makeTxnID := MakeUniqueTxnID(statement.FID, statement.AccountID)
+		statementTxns := make([]ledger.Transaction, 0, len(statement.Entries))
+		for _, entry := range statement.Entries {
+			ref := entry.Ref
+			if ref == "" {
+				key := fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s", statement.AccountID, entry.Date.Format(ledger.DateFormat), entry.Amount, entry.Payee, entry.Memo)
+				occurrences[key]++
+				hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", key, occurrences[key])))
+				ref = fmt.Sprintf("%x", hash[:12])
+			}
+			payee, comment := entry.Payee, cleanMemo(entry.Memo)
+			if payee == "" {
+				payee, comment = entry.Memo, ""
+			}
+			currency := normalizeCurrency(entry.Currency)
+			statementTxns = append(statementTxns, ledger.Transaction{
+				Date:  entry.Date,
+				Payee: payee,
+				Postings: []ledger.Posting{
+					{
+						Account:  account.String(),
+						Amount:   entry.Amount,
+						Currency: currency,
+						Comment:  comment,
+						Tags:     map[string]string{"id": makeTxnID(ref)},
+					},
+					{
+						Account:  model.Uncategorized,
+						Amount:   entry.Amount.Neg(),
+						Currency: currency,
+					},
+				},
+			})
+		}
+		if statement.ClosingBalance != nil {
+			balanceTransactions(statementTxns, *statement.ClosingBalance, statement.ClosingDate, statement.EndDate)
+		}
+		txns = append(txns, statementTxns...)
+
+		if !addedAccounts[statement.AccountID] {
+			addedAccounts[statement.AccountID] = true
+			skeletonAccounts = append(skeletonAccounts, &model.BasicAccount{
+				AccountDescription: fmt.Sprintf("%s - %s", statement.Org, statement.AccountID),
+				AccountID:          statement.AccountID,
+				AccountType:        account.AccountType,
+				BasicInstitution: model.BasicInstitution{
+					InstDescription: statement.Org,
+					InstFID:         statement.FID,
+					InstOrg:         statement.Org,
+				},
+			})
+		}
+	}
+	return skeletonAccounts, txns
+}
@@ -73,6 +73,7 @@ type xmlInstitution struct {
 	Profile struct {
 		Bank       bool `xml:"bankmsgset,attr"`
 		CreditCard bool `xml:"creditcardmsgset,attr"`
+		Investment bool `xml:"invstmtmsgset,attr"`
 	} `xml:"profile"`
 }
 
@@ -114,6 +115,9 @@ func generateOFXHome(r io.Reader) (io.Reader, error) {
 		if inst.Profile.CreditCard {
 			d.InstSupport = append(d.InstSupport, direct.MessageCreditCard)
 		}
+		if inst.Profile.Investment {
+			d.InstSupport = append(d.InstSupport, direct.MessageInvestment)
+		}
 		if updatedDriver, shouldAdd := checkDriver(d); shouldAdd {
 			ofxDrivers = append(ofxDrivers, updatedDriver)
 		}
@@ -0,0 +1,158 @@
+// Package duplicate holds likely duplicate transactions for review before they're added to the ledger
+package duplicate
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/johnstarich/sage/pipe"
+	"github.com/johnstarich/sage/plaindb"
+	"github.com/pkg/errors"
+)
+
+// Action resolves a held duplicate
+type Action string
+
+const (
+	// Confirm discards the held transaction as a duplicate. Its ID is remembered, so it's skipped if imported again.
+	Confirm Action = "confirm"
+	// Merge confirms the held transaction as a duplicate and copies its missing details, like balance assertions, into the existing transaction
+	Merge Action = "merge"
+	// Keep adds the held transaction to the ledger, it isn't a duplicate
+	Keep Action = "keep"
+)
+
+// Ledger adds and merges transactions, like a *ledger.Store
+type Ledger interface {
+	AddTransactions(txns []ledger.Transaction) error
+	MergeDuplicate(existingID string, duplicate ledger.Transaction) error
+}
+
+// Store holds likely duplicates for review
+type Store struct {
+	mu     sync.Mutex
+	bucket plaindb.Bucket
+}
+
+// NewStore returns the duplicates bucket
+func NewStore(db plaindb.DB) (*Store, error) {
+	bucket, err := db.Bucket("duplicates", "1", &storeUpgrader{})
+	return &Store{
+		bucket: bucket,
+	}, err
+}
+
+type storeUpgrader struct{}
+
+func (u *storeUpgrader) Parse(dataVersion, id string, data json.RawMessage) (interface{}, error) {
+	switch dataVersion {
+	case "1":
+		var duplicate ledger.Duplicate
+		err := json.Unmarshal(data, &duplicate)
+		return duplicate, err
+	default:
+		return nil, errors.Errorf("Unsupported version: %q", dataVersion)
+	}
+}
+
+func (u *storeUpgrader) Upgrade(dataVersion, id string, data interface{}) (newVersion string, newData interface{}, err error) {
+	return dataVersion, data, nil
+}
+
+// duplicateID returns the ID of the held transaction
+func duplicateID(duplicate ledger.Duplicate) string {
+	if len(duplicate.Transaction.Postings) == 0 {
+		return ""
+	}
+	return duplicate.Transaction.Postings[0].ID()
+}
+
+// Hold saves duplicates for review. Duplicates without an ID can't be reviewed and are returned instead.
+// If a duplicate fails to save, it and the remaining duplicates are returned with the error.
+func (s *Store) Hold(duplicates []ledger.Duplicate) (unheld []ledger.Transaction, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, duplicate := range duplicates {
+		id := duplicateID(duplicate)
+		if id == "" {
+			unheld = append(unheld, duplicate.Transaction)
+			continue
+		}
+		if err := s.bucket.Put(id, duplicate); err != nil {
+			for _, unsaved := range duplicates[i:] {
+				unheld = append(unheld, unsaved.Transaction)
+			}
+			return unheld, err
+		}
+	}
+	return unheld, nil
+}
+
+// List returns all held duplicates sorted by date
+func (s *Store) List() ([]ledger.Duplicate, error) {
+	var duplicate ledger.Duplicate
+	var duplicates []ledger.Duplicate
+	err := s.bucket.Iter(&duplicate, func(string) bool {
+		duplicates = append(duplicates, duplicate)
+		return true
+	})
+	sort.Slice(duplicates, func(a, b int) bool {
+		dateA, dateB := duplicates[a].Transaction.Date, duplicates[b].Transaction.Date
+		if dateA.Equal(dateB) {
+			return duplicateID(duplicates[a]) < duplicateID(duplicates[b])
+		}
+		return dateA.Before(dateB)
+	})
+	return duplicates, err
+}
+
+// Resolve applies 'action' to the held duplicate with ID 'id', then stops holding it
+// If the action causes balance assertions to fail, the duplicate was still resolved, so it stops being held before returning the error.
+func (s *Store) Resolve(id string, action Action, ldg Ledger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var duplicate ledger.Duplicate
+	var assertionErr error
+	return pipe.OpFuncs{
+		func() error {
+			found, err := s.bucket.Get(id, &duplicate)
+			if err == nil && !found {
+				err = errors.New("Duplicate not found: " + id)
+			}
+			return err
+		},
+		func() error {
+			err := resolve(action, duplicate, ldg)
+			if ledger.IsAssertionError(err) {
+				assertionErr = err
+				return nil
+			}
+			return err
+		},
+		func() error {
+			return s.bucket.Put(id, nil)
+		},
+		func() error { return assertionErr },
+	}.Do()
+}
+
+// resolve applies 'action' to 'duplicate' in the ledger
+func resolve(action Action, duplicate ledger.Duplicate, ldg Ledger) error {
+	switch action {
+	case Confirm:
+		// only remember the ID, the held transaction's details are discarded
+		txn := duplicate.Transaction
+		txn.Comment = ""
+		txn.Postings = append([]ledger.Posting(nil), txn.Postings...)
+		txn.Postings[0].Balance = nil
+		return ldg.MergeDuplicate(duplicate.ExistingID, txn)
+	case Merge:
+		return ldg.MergeDuplicate(duplicate.ExistingID, duplicate.Transaction)
+	case Keep:
+		return ldg.AddTransactions([]ledger.Transaction{duplicate.Transaction})
+	default:
+		return errors.Errorf("Invalid action %q, must be one of: %s, %s, %s", action, Confirm, Merge, Keep)
+	}
+}
@@ -0,0 +1,167 @@
+package duplicate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/johnstarich/sage/plaindb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJournal = `
+2020/01/02 Starbucks
+    assets:Bank:Checking   $-4.25  ; id: txn-1
+    expenses:Food
+
+2020/01/10 Paycheck
+    assets:Bank:Checking   $1000.10  ; id: txn-2
+    revenues:Salary
+`
+
+const heldJournal = `
+2020/01/03 POS STARBUCKS #445  ; Card purchase
+    assets:Bank:Checking   $-4.25 = $-4.25  ; id: dup-1
+    uncategorized
+
+2020/01/11 PAYCHECK
+    assets:Bank:Checking   $1000.10  ; id: dup-2
+    uncategorized
+`
+
+func mockDBStore(t *testing.T) *Store {
+	db := plaindb.NewMockDB(plaindb.MockConfig{FileReader: func(fileName string) ([]byte, error) {
+		return []byte(`{}`), nil
+	}})
+	store, err := NewStore(db)
+	require.NoError(t, err)
+	return store
+}
+
+func allTransactions(ldg *ledger.Ledger) []ledger.Transaction {
+	return ldg.TransactionsBetween(time.Time{}, time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func parseLedger(t *testing.T, journal string) *ledger.Ledger {
+	ldg, err := ledger.NewFromReader(strings.NewReader(journal))
+	require.NoError(t, err)
+	return ldg
+}
+
+// holdDuplicates holds duplicates from heldJournal for the ledger parsed from 'journal', returning the ledger and store
+func holdDuplicates(t *testing.T, journal string) (*ledger.Ledger, *Store) {
+	ldg := parseLedger(t, journal)
+	unique, duplicates := ldg.FindDuplicates(allTransactions(parseLedger(t, heldJournal)))
+	require.Empty(t, unique)
+	require.Len(t, duplicates, 2)
+
+	store := mockDBStore(t)
+	unheld, err := store.Hold(duplicates)
+	require.NoError(t, err)
+	assert.Empty(t, unheld)
+	return ldg, store
+}
+
+func TestStoreHoldAndList(t *testing.T) {
+	_, store := holdDuplicates(t, testJournal)
+	duplicates, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, duplicates, 2)
+	assert.Equal(t, "dup-1", duplicates[0].Transaction.Postings[0].ID())
+	assert.Equal(t, "txn-1", duplicates[0].ExistingID)
+	assert.Equal(t, "dup-2", duplicates[1].Transaction.Postings[0].ID())
+	assert.Equal(t, "txn-2", duplicates[1].ExistingID)
+
+	unheld, err := store.Hold([]ledger.Duplicate{{Transaction: ledger.Transaction{Payee: "no postings"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []ledger.Transaction{{Payee: "no postings"}}, unheld)
+}
+
+func TestStoreResolve(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		id          string
+		action      Action
+		expectErr   bool
+	}{
+		{
+			description: "confirm",
+			id:          "dup-1",
+			action:      Confirm,
+		},
+		{
+			description: "merge",
+			id:          "dup-1",
+			action:      Merge,
+		},
+		{
+			description: "keep",
+			id:          "dup-2",
+			action:      Keep,
+		},
+		{
+			description: "invalid action",
+			id:          "dup-1",
+			action:      Action("bogus"),
+			expectErr:   true,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			ldg, store := holdDuplicates(t, testJournal)
+			err := store.Resolve(tc.id, tc.action, ldg)
+			if tc.expectErr {
+				assert.Error(t, err)
+				duplicates, err := store.List()
+				require.NoError(t, err)
+				assert.Len(t, duplicates, 2, "Failed resolutions should keep holding the duplicate")
+				return
+			}
+			require.NoError(t, err)
+
+			duplicates, err := store.List()
+			require.NoError(t, err)
+			require.Len(t, duplicates, 1)
+			assert.NotEqual(t, tc.id, duplicates[0].Transaction.Postings[0].ID())
+
+			txns := allTransactions(ldg)
+			switch tc.action {
+			case Keep:
+				require.Len(t, txns, 3)
+				assert.Equal(t, "dup-2", txns[2].Postings[0].ID())
+			default:
+				require.Len(t, txns, 2)
+				assert.Equal(t, "txn-1", txns[0].Postings[0].ID())
+				assert.Equal(t, "dup-1", txns[0].Tags["duplicates"])
+				if tc.action == Merge {
+					assert.Equal(t, "Card purchase", txns[0].Comment)
+					require.NotNil(t, txns[0].Postings[0].Balance)
+					assert.Equal(t, "-4.25", txns[0].Postings[0].Balance.String())
+				} else {
+					assert.Empty(t, txns[0].Comment)
+					assert.Nil(t, txns[0].Postings[0].Balance)
+				}
+			}
+
+			assert.Error(t, store.Resolve(tc.id, tc.action, ldg), "Resolved duplicates should not be found")
+		})
+	}
+}
+
+func TestStoreResolveFailedAssertion(t *testing.T) {
+	ldg, store := holdDuplicates(t, `
+2020/01/01 Opening Balance
+    assets:Bank:Checking   $10  ; id: Opening-Balance
+    equity:Opening Balances
+`+testJournal)
+	err := store.Resolve("dup-1", Merge, ldg)
+	assert.True(t, ledger.IsAssertionError(err))
+
+	duplicates, err := store.List()
+	require.NoError(t, err)
+	assert.Len(t, duplicates, 1, "Merged duplicate should stop being held despite failed assertion")
+	txns := allTransactions(ldg)
+	require.Len(t, txns, 3)
+	assert.Equal(t, "dup-1", txns[1].Tags["duplicates"])
+}
@@ -0,0 +1,91 @@
+package holdings
+
+import (
+	"sort"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// GainsOptions select the accounts and period for a gains report
+type GainsOptions struct {
+	// Account is an account name or prefix. All sub-accounts are included. Defaults to all asset accounts.
+	Account string
+	// Start and End are the report's period, inclusive
+	Start, End time.Time
+}
+
+// Gains reports realized gains from sales during a period and unrealized gains on the holdings at its end
+type Gains struct {
+	Start, End time.Time
+	Realized   []RealizedGain
+	Unrealized []Holding
+	// Totals sum the gains for each currency
+	Totals []GainTotal
+}
+
+// GainTotal sums realized and unrealized gains in one currency
+type GainTotal struct {
+	Currency           string
+	ShortTerm          decimal.Decimal
+	LongTerm           decimal.Decimal
+	Realized           decimal.Decimal
+	Unrealized         decimal.Decimal
+	UnrealizedUnpriced bool `json:",omitempty"` // true if some holdings have no known price, so their gains are missing from Unrealized
+}
+
+// GainsReport returns the realized gains for sales between the options' start and end dates, and the unrealized gains of holdings at the end date
+func GainsReport(ldg *ledger.Ledger, options GainsOptions) (Gains, error) {
+	if options.Start.IsZero() || options.End.IsZero() {
+		return Gains{}, errors.New("Gains report start and end dates are required")
+	}
+	if options.End.Before(options.Start) {
+		return Gains{}, errors.New("Gains report end date must not be before the start date")
+	}
+
+	t := track(ldg, options.End)
+	gains := Gains{
+		Start:      options.Start,
+		End:        options.End,
+		Realized:   []RealizedGain{},
+		Unrealized: t.holdings(ldg.Prices(), options.Account, options.End),
+	}
+	totals := make(map[string]*GainTotal)
+	total := func(currency string) *GainTotal {
+		if totals[currency] == nil {
+			totals[currency] = &GainTotal{Currency: currency}
+		}
+		return totals[currency]
+	}
+	for _, gain := range t.realized {
+		if gain.Date.Before(options.Start) || !matchesAccount(options.Account, gain.Account) {
+			continue
+		}
+		gains.Realized = append(gains.Realized, gain)
+		currencyTotal := total(gain.Currency)
+		currencyTotal.Realized = currencyTotal.Realized.Add(gain.Gain)
+		if gain.LongTerm {
+			currencyTotal.LongTerm = currencyTotal.LongTerm.Add(gain.Gain)
+		} else {
+			currencyTotal.ShortTerm = currencyTotal.ShortTerm.Add(gain.Gain)
+		}
+	}
+	for _, holding := range gains.Unrealized {
+		currencyTotal := total(holding.Currency)
+		if holding.UnrealizedGain == nil {
+			currencyTotal.UnrealizedUnpriced = true
+			continue
+		}
+		currencyTotal.Unrealized = currencyTotal.Unrealized.Add(*holding.UnrealizedGain)
+	}
+
+	for _, currencyTotal := range totals {
+		gains.Totals = append(gains.Totals, *currencyTotal)
+	}
+	sort.Slice(gains.Totals, func(a, b int) bool {
+		return gains.Totals[a].Currency < gains.Totals[b].Currency
+	})
+	return gains, nil
+}
@@ -0,0 +1,108 @@
+package holdings
+
+import (
+	"testing"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGainsReport(t *testing.T) {
+	ldg := parseLedger(t, brokerageJournal)
+	gainStrings := func(gains []RealizedGain) []string {
+		var results []string
+		for _, g := range gains {
+			term := "short"
+			if g.LongTerm {
+				term = "long"
+			}
+			results = append(results, g.TransactionID+" "+g.Account+" "+g.LotID+" "+g.Date.Format(ledger.DateFormat)+" "+g.Units.String()+" "+g.Commodity+
+				" proceeds "+g.Proceeds.String()+" cost "+g.CostBasis.String()+" gain "+g.Gain.String()+" "+term)
+		}
+		return results
+	}
+
+	t.Run("realized and unrealized", func(t *testing.T) {
+		gains, err := GainsReport(ldg, GainsOptions{Start: parseDate(t, "2020/01/01"), End: parseDate(t, "2020/12/31")})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"sell-1 assets:Broker Opening-Balance 2020/03/04 5 VTI proceeds 650 cost 0 gain 650 short",
+			"sell-1 assets:Broker buy-1 2020/03/04 7 VTI proceeds 910 cost 700 gain 210 short",
+			"sell-2 assets:Broker buy-2 2020/04/05 5 VTI proceeds 750 cost 600 gain 150 short",
+		}, gainStrings(gains.Realized))
+		assert.Len(t, gains.Unrealized, 2)
+		require.Len(t, gains.Totals, 1)
+		total := gains.Totals[0]
+		assert.Equal(t, "$", total.Currency)
+		assert.Equal(t, "1010", total.Realized.String())
+		assert.Equal(t, "1010", total.ShortTerm.String())
+		assert.Equal(t, "0", total.LongTerm.String())
+		assert.Equal(t, "380", total.Unrealized.String())
+		assert.False(t, total.UnrealizedUnpriced)
+	})
+
+	t.Run("long term and account filter", func(t *testing.T) {
+		gains, err := GainsReport(ldg, GainsOptions{Account: "assets:IRA", Start: parseDate(t, "2021/01/01"), End: parseDate(t, "2021/12/31")})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"sell-3 assets:IRA buy-1 2021/03/01 1 VTI proceeds 200 cost 100 gain 100 long",
+		}, gainStrings(gains.Realized))
+		require.Len(t, gains.Totals, 1)
+		assert.Equal(t, "100", gains.Totals[0].LongTerm.String())
+	})
+
+	t.Run("sold more than held", func(t *testing.T) {
+		ldg := parseLedger(t, `
+2020/01/02 Buy
+    assets:Broker   1 VTI @ $100  ; id: buy-1
+    assets:Broker   $-100
+
+2020/01/03 Sell
+    assets:Broker   -2 VTI @ $150  ; id: sell-1
+    assets:Broker   $300
+`)
+		gains, err := GainsReport(ldg, GainsOptions{Start: parseDate(t, "2020/01/01"), End: parseDate(t, "2020/01/31")})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"sell-1 assets:Broker buy-1 2020/01/03 1 VTI proceeds 150 cost 100 gain 50 short",
+			"sell-1 assets:Broker  2020/01/03 1 VTI proceeds 150 cost 0 gain 150 short",
+		}, gainStrings(gains.Realized))
+	})
+
+	t.Run("sell across a split", func(t *testing.T) {
+		ldg := parseLedger(t, `
+2020/01/02 Buy
+    assets:Broker   10 VTI @ $100  ; id: buy-1
+    assets:Broker   $-1000
+
+2020/06/01 Buy
+    assets:Broker   10 VTI @ $150  ; id: buy-2
+    assets:Broker   $-1500
+
+2020/07/01 Split 2 for 1
+    assets:Broker   20 VTI  ; id: split-1
+    equity:Investments:Splits   -20 VTI
+
+2021/03/01 Sell
+    assets:Broker   -25 VTI @ $80  ; id: sell-1
+    assets:Broker   $2000
+`)
+		gains, err := GainsReport(ldg, GainsOptions{Start: parseDate(t, "2021/01/01"), End: parseDate(t, "2021/12/31")})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"sell-1 assets:Broker buy-1 2021/03/01 20 VTI proceeds 1600 cost 1000 gain 600 long",
+			"sell-1 assets:Broker buy-2 2021/03/01 5 VTI proceeds 400 cost 375 gain 25 short",
+		}, gainStrings(gains.Realized), "Splits should keep each lot's cost basis and acquisition date")
+		assert.Equal(t, []string{
+			"assets:Broker 15 VTI cost 1125 $ value 1200 gain 75 | buy-2 2020/06/01 15 @ 75",
+		}, holdingStrings(Holdings(ldg, Options{})))
+	})
+
+	t.Run("invalid dates", func(t *testing.T) {
+		_, err := GainsReport(ldg, GainsOptions{})
+		assert.EqualError(t, err, "Gains report start and end dates are required")
+		_, err = GainsReport(ldg, GainsOptions{Start: parseDate(t, "2020/02/01"), End: parseDate(t, "2020/01/01")})
+		assert.EqualError(t, err, "Gains report end date must not be before the start date")
+	})
+}
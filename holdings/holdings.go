@@ -0,0 +1,479 @@
+// Package holdings tracks securities lots, cost basis, and gains in a ledger's asset accounts
+package holdings
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// lotTag selects a specific lot to sell, like '; lot: some-txn-ID'. Untagged sales use the oldest lots first (FIFO).
+	lotTag = "lot"
+	// divisionPrecision is the number of decimal places kept when splitting a lot's cost basis or a sale's proceeds
+	divisionPrecision = 8
+	// longTermYears is the holding period a lot must exceed for a long term gain
+	longTermYears = 1
+)
+
+// Options select the accounts and date for holdings
+type Options struct {
+	// Account is an account name or prefix. All sub-accounts are included. Defaults to all asset accounts.
+	Account string
+	// Date is the day holdings are reported on. Defaults to the last transaction's date.
+	Date time.Time
+}
+
+// Lot is a purchase of a commodity, reduced by later sales of that lot
+type Lot struct {
+	// ID is the acquiring transaction's ID, or its date if it doesn't have an ID
+	ID        string
+	Account   string
+	Commodity string
+	// Date is when the lot was acquired
+	Date  time.Time
+	Units decimal.Decimal
+	// CostBasis is the total cost of the lot's remaining units, in Currency
+	CostBasis decimal.Decimal
+	Currency  string
+}
+
+// UnitCost returns the lot's cost per unit
+func (l Lot) UnitCost() decimal.Decimal {
+	if l.Units.IsZero() {
+		return decimal.Zero
+	}
+	return l.CostBasis.DivRound(l.Units, divisionPrecision)
+}
+
+// Holding is an account's position in a commodity with its lots and value
+type Holding struct {
+	Account   string
+	Commodity string
+	Units     decimal.Decimal
+	// CostBasis is the sum of the lots' cost bases, in Currency
+	CostBasis decimal.Decimal
+	Currency  string
+	Lots      []Lot
+	// Price is the latest known unit price in Currency on or before the holdings date, from market prices or trades
+	Price     *decimal.Decimal `json:",omitempty"`
+	PriceDate *time.Time       `json:",omitempty"`
+	// MarketValue and UnrealizedGain are set if a price is known
+	MarketValue    *decimal.Decimal `json:",omitempty"`
+	UnrealizedGain *decimal.Decimal `json:",omitempty"`
+}
+
+// RealizedGain is the gain from selling units of one lot
+type RealizedGain struct {
+	TransactionID string
+	Account       string
+	Commodity     string
+	// LotID is the sold lot's ID. Empty if more units were sold than held, in which case the units have no cost basis.
+	LotID string `json:",omitempty"`
+	// Acquired is when the lot was bought, Date is when it was sold
+	Acquired  time.Time `json:",omitempty"`
+	Date      time.Time
+	Units     decimal.Decimal
+	Proceeds  decimal.Decimal
+	CostBasis decimal.Decimal
+	Gain      decimal.Decimal
+	Currency  string
+	// LongTerm is true if the lot was held for more than a year
+	LongTerm bool
+}
+
+// Holdings returns the open lots grouped by account and commodity on the options' date, valued with the latest known prices
+func Holdings(ldg *ledger.Ledger, options Options) []Holding {
+	date := options.Date
+	if date.IsZero() {
+		date = ldg.LastTransactionTime()
+	}
+	return track(ldg, date).holdings(ldg.Prices(), options.Account, date)
+}
+
+// lotKey identifies an account's lots of a commodity bought with the same currency
+type lotKey struct {
+	account, commodity, currency string
+}
+
+type priceKey struct {
+	commodity, currency string
+}
+
+// tracker replays transactions to track open lots and realized gains
+type tracker struct {
+	// tracked commodities are bought or sold with a cost somewhere in the ledger, mapped to their first cost's currency
+	tracked    map[string]string
+	lots       map[lotKey][]*Lot
+	realized   []RealizedGain
+	lastTrades map[priceKey]ledger.Price
+}
+
+// track replays all transactions up to 'end' for all asset accounts, so transfers between accounts keep their cost basis
+func track(ldg *ledger.Ledger, end time.Time) *tracker {
+	txns := ldg.TransactionsBetween(time.Time{}, ldg.LastTransactionTime())
+	sort.SliceStable(txns, func(a, b int) bool {
+		return txns[a].Date.Before(txns[b].Date)
+	})
+	t := &tracker{
+		tracked:    make(map[string]string),
+		lots:       make(map[lotKey][]*Lot),
+		lastTrades: make(map[priceKey]ledger.Price),
+	}
+	for _, txn := range txns {
+		for _, p := range txn.Postings {
+			if _, tracked := t.tracked[p.Currency]; !tracked && p.Cost != nil && isAssetAccount(p.Account) {
+				t.tracked[p.Currency] = p.Cost.Currency
+			}
+		}
+	}
+	for _, txn := range txns {
+		if txn.Date.After(end) {
+			break
+		}
+		t.add(txn)
+	}
+	return t
+}
+
+func isAssetAccount(account string) bool {
+	return account == model.AssetAccount || strings.HasPrefix(account, model.AssetAccount+":")
+}
+
+// matchesAccount returns true if 'account' is 'filter' or one of its sub-accounts. An empty filter matches all accounts.
+func matchesAccount(filter, account string) bool {
+	return filter == "" || account == filter || strings.HasPrefix(account, filter+":")
+}
+
+func lotID(txn ledger.Transaction) string {
+	if id := txn.Postings[0].ID(); id != "" {
+		return id
+	}
+	return txn.Date.Format(ledger.DateFormat)
+}
+
+// isSplit returns true if 'txn' is a stock split, balanced by model.SplitAccount
+func isSplit(txn ledger.Transaction) bool {
+	for _, p := range txn.Postings {
+		if p.Account == model.SplitAccount {
+			return true
+		}
+	}
+	return false
+}
+
+// returnOfCapital returns the commodity a return of capital applies to, if 'txn' is balanced by model.ReturnOfCapitalAccount
+func returnOfCapital(txn ledger.Transaction) (string, bool) {
+	for _, p := range txn.Postings {
+		if p.Account == model.ReturnOfCapitalAccount && p.Tags[model.SecurityTag] != "" {
+			return p.Tags[model.SecurityTag], true
+		}
+	}
+	return "", false
+}
+
+// add updates lots with the transaction's purchases, sales, transfers, splits, and returns of capital.
+// Postings with a cost buy or sell. Postings of tracked commodities without a cost transfer lots between accounts, any units not transferred in have no cost basis.
+// Splits scale the units of existing lots instead, keeping their cost basis and acquisition date. Returns of capital reduce the cost basis of existing lots.
+func (t *tracker) add(txn ledger.Transaction) {
+	if len(txn.Postings) == 0 {
+		return
+	}
+	if commodity, ok := returnOfCapital(txn); ok {
+		for _, p := range txn.Postings {
+			if !p.Kind.IsVirtual() && p.Amount.IsPositive() && isAssetAccount(p.Account) {
+				t.returnCapital(lotKey{account: p.Account, commodity: commodity, currency: p.Currency}, p.Amount)
+			}
+		}
+		return
+	}
+	split := isSplit(txn)
+	transferred := make(map[string][]Lot)
+	var transfersIn []ledger.Posting
+	for _, p := range txn.Postings {
+		if p.Kind.IsVirtual() || p.Amount.IsZero() || !isAssetAccount(p.Account) {
+			continue
+		}
+		if p.Cost == nil {
+			if _, tracked := t.tracked[p.Currency]; !tracked {
+				continue
+			}
+			if split && t.split(p.Account, p.Currency, p.Amount) {
+				continue
+			}
+			if p.Amount.IsPositive() {
+				transfersIn = append(transfersIn, p)
+				continue
+			}
+			// transfers out keep their cost basis, which is moved to the receiving account's lots
+			remaining := p.Amount.Neg()
+			for _, key := range t.keys(p.Account, p.Currency) {
+				var removed []Lot
+				removed, remaining = t.remove(key, remaining, p.Tags[lotTag])
+				transferred[p.Currency] = append(transferred[p.Currency], removed...)
+			}
+			continue
+		}
+
+		units := p.Amount.Abs()
+		total := p.Cost.Amount.Abs()
+		if !p.Cost.Total {
+			total = total.Mul(units)
+		}
+		key := lotKey{account: p.Account, commodity: p.Currency, currency: p.Cost.Currency}
+		t.lastTrades[priceKey{p.Currency, p.Cost.Currency}] = ledger.Price{
+			Date:      txn.Date,
+			Commodity: p.Currency,
+			Amount:    total.DivRound(units, divisionPrecision),
+			Currency:  p.Cost.Currency,
+		}
+		if p.Amount.IsPositive() {
+			t.lots[key] = append(t.lots[key], &Lot{
+				ID:        lotID(txn),
+				Account:   p.Account,
+				Commodity: p.Currency,
+				Date:      txn.Date,
+				Units:     units,
+				CostBasis: total,
+				Currency:  p.Cost.Currency,
+			})
+			continue
+		}
+		t.sell(key, txn, units, total, p.Tags[lotTag])
+	}
+
+	for _, p := range transfersIn {
+		remaining := p.Amount
+		for len(transferred[p.Currency]) > 0 && remaining.IsPositive() {
+			lot := transferred[p.Currency][0]
+			if lot.Units.GreaterThan(remaining) {
+				portion := lot
+				portion.Units = remaining
+				portion.CostBasis = lot.CostBasis.Mul(remaining).DivRound(lot.Units, divisionPrecision)
+				lot.Units = lot.Units.Sub(remaining)
+				lot.CostBasis = lot.CostBasis.Sub(portion.CostBasis)
+				transferred[p.Currency][0] = lot
+				lot = portion
+			} else {
+				transferred[p.Currency] = transferred[p.Currency][1:]
+			}
+			lot.Account = p.Account
+			key := lotKey{account: p.Account, commodity: lot.Commodity, currency: lot.Currency}
+			t.lots[key] = append(t.lots[key], &lot)
+			sortLots(t.lots[key])
+			remaining = remaining.Sub(lot.Units)
+		}
+		if remaining.IsPositive() {
+			key := lotKey{account: p.Account, commodity: p.Currency, currency: t.tracked[p.Currency]}
+			t.lots[key] = append(t.lots[key], &Lot{
+				ID:        lotID(txn),
+				Account:   p.Account,
+				Commodity: p.Currency,
+				Date:      txn.Date,
+				Units:     remaining,
+				Currency:  key.currency,
+			})
+		}
+	}
+}
+
+// split scales the units of an account's lots of 'commodity' by the units a split adds or removes, keeping their cost basis and acquisition date.
+// Returns false if there aren't any lots to scale.
+func (t *tracker) split(account, commodity string, units decimal.Decimal) bool {
+	var lots []*Lot
+	held := decimal.Zero
+	for _, key := range t.keys(account, commodity) {
+		for _, lot := range t.lots[key] {
+			lots = append(lots, lot)
+			held = held.Add(lot.Units)
+		}
+	}
+	if !held.IsPositive() {
+		return false
+	}
+	newHeld := held.Add(units)
+	remaining := newHeld
+	for i, lot := range lots {
+		if i == len(lots)-1 {
+			// avoid rounding errors, so the lots add up to the new units held
+			lot.Units = remaining
+			break
+		}
+		lot.Units = lot.Units.Mul(newHeld).DivRound(held, divisionPrecision)
+		remaining = remaining.Sub(lot.Units)
+	}
+	return true
+}
+
+// returnCapital reduces the cost basis of the lots in 'key' by 'cash', split between lots by units.
+// Cost basis can't go below zero, so any cash beyond the lots' cost basis is ignored.
+func (t *tracker) returnCapital(key lotKey, cash decimal.Decimal) {
+	lots := t.lots[key]
+	held, basis := decimal.Zero, decimal.Zero
+	for _, lot := range lots {
+		held = held.Add(lot.Units)
+		basis = basis.Add(lot.CostBasis)
+	}
+	if !held.IsPositive() {
+		return
+	}
+	remaining := decimal.Min(cash, basis)
+	for i, lot := range lots {
+		reduction := remaining
+		if i < len(lots)-1 {
+			reduction = decimal.Min(cash.Mul(lot.Units).DivRound(held, divisionPrecision), lot.CostBasis, remaining)
+		}
+		lot.CostBasis = lot.CostBasis.Sub(reduction)
+		remaining = remaining.Sub(reduction)
+	}
+}
+
+// keys returns the lot keys for an account's commodity, sorted by currency
+func (t *tracker) keys(account, commodity string) []lotKey {
+	var keys []lotKey
+	for key := range t.lots {
+		if key.account == account && key.commodity == commodity {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		return keys[a].currency < keys[b].currency
+	})
+	return keys
+}
+
+// sortLots sorts lots by acquisition date, oldest first
+func sortLots(lots []*Lot) {
+	sort.SliceStable(lots, func(a, b int) bool {
+		return lots[a].Date.Before(lots[b].Date)
+	})
+}
+
+// remove takes 'units' from the lots in 'key', starting with lots matching 'id', then the oldest lots.
+// Returns the removed portions of each lot and any units that couldn't be removed.
+func (t *tracker) remove(key lotKey, units decimal.Decimal, id string) (removed []Lot, unmatched decimal.Decimal) {
+	lots := t.lots[key]
+	if id != "" {
+		// move the specified lots to the front, keeping the rest in FIFO order
+		sort.SliceStable(lots, func(a, b int) bool {
+			return lots[a].ID == id && lots[b].ID != id
+		})
+	}
+	remaining := units
+	for len(lots) > 0 && remaining.IsPositive() {
+		lot := lots[0]
+		if lot.Units.GreaterThan(remaining) {
+			portion := *lot
+			portion.Units = remaining
+			portion.CostBasis = lot.CostBasis.Mul(remaining).DivRound(lot.Units, divisionPrecision)
+			lot.Units = lot.Units.Sub(remaining)
+			lot.CostBasis = lot.CostBasis.Sub(portion.CostBasis)
+			removed = append(removed, portion)
+			remaining = decimal.Zero
+			break
+		}
+		removed = append(removed, *lot)
+		remaining = remaining.Sub(lot.Units)
+		lots = lots[1:]
+	}
+	if len(lots) == 0 {
+		delete(t.lots, key)
+	} else {
+		sortLots(lots)
+		t.lots[key] = lots
+	}
+	return removed, remaining
+}
+
+// sell removes 'units' from lots and records the realized gain for each lot. Proceeds are split between lots by units.
+func (t *tracker) sell(key lotKey, txn ledger.Transaction, units, proceeds decimal.Decimal, id string) {
+	removed, unmatched := t.remove(key, units, id)
+	if unmatched.IsPositive() {
+		removed = append(removed, Lot{Units: unmatched})
+	}
+	remainingProceeds := proceeds
+	for i, lot := range removed {
+		lotProceeds := remainingProceeds
+		if i < len(removed)-1 {
+			lotProceeds = proceeds.Mul(lot.Units).DivRound(units, divisionPrecision)
+		}
+		remainingProceeds = remainingProceeds.Sub(lotProceeds)
+		gain := RealizedGain{
+			TransactionID: txn.Postings[0].ID(),
+			Account:       key.account,
+			Commodity:     key.commodity,
+			LotID:         lot.ID,
+			Acquired:      lot.Date,
+			Date:          txn.Date,
+			Units:         lot.Units,
+			Proceeds:      lotProceeds,
+			CostBasis:     lot.CostBasis,
+			Gain:          lotProceeds.Sub(lot.CostBasis),
+			Currency:      key.currency,
+		}
+		gain.LongTerm = !lot.Date.IsZero() && txn.Date.After(lot.Date.AddDate(longTermYears, 0, 0))
+		t.realized = append(t.realized, gain)
+	}
+}
+
+// latestPrice returns the most recent price for 'key' on or before 'date', from market prices or trades
+func (t *tracker) latestPrice(prices []ledger.Price, key priceKey, date time.Time) (ledger.Price, bool) {
+	latest, found := t.lastTrades[key]
+	for i := len(prices) - 1; i >= 0; i-- {
+		price := prices[i]
+		if price.Date.After(date) || price.Commodity != key.commodity || price.Currency != key.currency {
+			continue
+		}
+		if !found || !price.Date.Before(latest.Date) {
+			latest, found = price, true
+		}
+		break
+	}
+	return latest, found
+}
+
+// holdings groups open lots in 'account' into holdings, valued at the latest prices on or before 'date'
+func (t *tracker) holdings(prices []ledger.Price, account string, date time.Time) []Holding {
+	holdings := make([]Holding, 0, len(t.lots))
+	for key, lots := range t.lots {
+		if !matchesAccount(account, key.account) {
+			continue
+		}
+		holding := Holding{
+			Account:   key.account,
+			Commodity: key.commodity,
+			Currency:  key.currency,
+		}
+		for _, lot := range lots {
+			holding.Units = holding.Units.Add(lot.Units)
+			holding.CostBasis = holding.CostBasis.Add(lot.CostBasis)
+			holding.Lots = append(holding.Lots, *lot)
+		}
+		if holding.Units.IsZero() {
+			continue
+		}
+		if price, found := t.latestPrice(prices, priceKey{key.commodity, key.currency}, date); found && key.currency != "" {
+			marketValue := holding.Units.Mul(price.Amount)
+			unrealized := marketValue.Sub(holding.CostBasis)
+			holding.Price = &price.Amount
+			holding.PriceDate = &price.Date
+			holding.MarketValue = &marketValue
+			holding.UnrealizedGain = &unrealized
+		}
+		holdings = append(holdings, holding)
+	}
+	sort.Slice(holdings, func(a, b int) bool {
+		if holdings[a].Account != holdings[b].Account {
+			return holdings[a].Account < holdings[b].Account
+		}
+		if holdings[a].Commodity != holdings[b].Commodity {
+			return holdings[a].Commodity < holdings[b].Commodity
+		}
+		return holdings[a].Currency < holdings[b].Currency
+	})
+	return holdings
+}
@@ -0,0 +1,139 @@
+package holdings
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const brokerageJournal = `
+2019/12/31 Opening Balances
+    assets:Broker   5 VTI  ; id: Opening-Balance
+    equity:Opening Balances   -5 VTI
+
+2020/01/02 Buy
+    assets:Broker   10 VTI @ $100  ; id: buy-1
+    assets:Broker   $-1000
+
+2020/02/03 Buy
+    assets:Broker   10 VTI @@ $1200  ; id: buy-2
+    assets:Broker   $-1200
+
+2020/03/04 Sell oldest lots
+    assets:Broker   -12 VTI @ $130  ; id: sell-1
+    assets:Broker   $1560
+
+2020/04/05 Sell specific lot
+    assets:Broker   -5 VTI @ $150  ; id: sell-2, lot: buy-2
+    assets:Broker   $750
+
+2020/05/06 Transfer
+    assets:Broker   -2 VTI  ; id: transfer-1
+    assets:IRA   2 VTI
+
+2020/06/01 Dividend
+    assets:Broker   $10  ; id: dividend-1
+    revenues:Dividends   $-10
+
+P 2020/06/15 VTI $160
+
+2021/03/01 Sell long term
+    assets:IRA   -1 VTI @ $200  ; id: sell-3
+    assets:IRA   $200
+`
+
+func parseLedger(t *testing.T, journal string) *ledger.Ledger {
+	ldg, err := ledger.NewFromReader(strings.NewReader(journal))
+	require.NoError(t, err)
+	return ldg
+}
+
+func parseDate(t *testing.T, s string) time.Time {
+	date, err := time.Parse("2006/01/02", s)
+	require.NoError(t, err)
+	return date
+}
+
+func holdingStrings(holdings []Holding) []string {
+	var results []string
+	for _, h := range holdings {
+		result := h.Account + " " + h.Units.String() + " " + h.Commodity + " cost " + h.CostBasis.String() + " " + h.Currency
+		if h.MarketValue != nil {
+			result += " value " + h.MarketValue.String() + " gain " + h.UnrealizedGain.String()
+		}
+		for _, lot := range h.Lots {
+			result += " | " + lot.ID + " " + lot.Date.Format(ledger.DateFormat) + " " + lot.Units.String() + " @ " + lot.UnitCost().String()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestHoldings(t *testing.T) {
+	ldg := parseLedger(t, brokerageJournal)
+
+	t.Run("after purchases", func(t *testing.T) {
+		holdings := Holdings(ldg, Options{Date: parseDate(t, "2020/02/03")})
+		assert.Equal(t, []string{
+			"assets:Broker 25 VTI cost 2200 $ value 3000 gain 800 | Opening-Balance 2019/12/31 5 @ 0 | buy-1 2020/01/02 10 @ 100 | buy-2 2020/02/03 10 @ 120",
+		}, holdingStrings(holdings))
+	})
+
+	t.Run("after sales and transfers", func(t *testing.T) {
+		holdings := Holdings(ldg, Options{Date: parseDate(t, "2020/06/30")})
+		assert.Equal(t, []string{
+			"assets:Broker 6 VTI cost 700 $ value 960 gain 260 | buy-1 2020/01/02 1 @ 100 | buy-2 2020/02/03 5 @ 120",
+			"assets:IRA 2 VTI cost 200 $ value 320 gain 120 | buy-1 2020/01/02 2 @ 100",
+		}, holdingStrings(holdings))
+		require.NotNil(t, holdings[0].PriceDate)
+		assert.Equal(t, "2020/06/15", holdings[0].PriceDate.Format(ledger.DateFormat))
+	})
+
+	t.Run("latest trade price", func(t *testing.T) {
+		holdings := Holdings(ldg, Options{Date: parseDate(t, "2020/04/05")})
+		require.Len(t, holdings, 1)
+		require.NotNil(t, holdings[0].Price)
+		assert.Equal(t, "150", holdings[0].Price.String())
+	})
+
+	t.Run("account filter", func(t *testing.T) {
+		holdings := Holdings(ldg, Options{Account: "assets:IRA"})
+		assert.Equal(t, []string{
+			"assets:IRA 1 VTI cost 100 $ value 200 gain 100 | buy-1 2020/01/02 1 @ 100",
+		}, holdingStrings(holdings))
+	})
+}
+
+func TestHoldingsWithoutPrices(t *testing.T) {
+	ldg := parseLedger(t, `
+2020/01/01 Opening Balances
+    assets:Broker   5 VTI  ; id: Opening-Balance
+    equity:Opening Balances   -5 VTI
+`)
+	holdings := Holdings(ldg, Options{})
+	assert.Empty(t, holdings, "Commodities never bought with a cost aren't tracked")
+}
+
+func TestHoldingsReturnOfCapital(t *testing.T) {
+	ldg := parseLedger(t, `
+2020/01/02 Buy
+    assets:Broker   10 VTI @ $100  ; id: buy-1
+    assets:Broker   $-1000
+
+2020/02/03 Buy
+    assets:Broker   10 VTI @ $120  ; id: buy-2
+    assets:Broker   $-1200
+
+2020/03/04 VTI
+    assets:Broker   $50  ; id: roc-1
+    equity:Investments:Return of Capital   $-50  ; security: VTI
+`)
+	holdings := Holdings(ldg, Options{})
+	assert.Equal(t, []string{
+		"assets:Broker 20 VTI cost 2150 $ value 2400 gain 250 | buy-1 2020/01/02 10 @ 97.5 | buy-2 2020/02/03 10 @ 117.5",
+	}, holdingStrings(holdings), "Return of capital should reduce each lot's cost basis by units held")
+}
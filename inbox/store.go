@@ -0,0 +1,219 @@
+// Package inbox stages newly downloaded transactions for review before they're added to the ledger
+package inbox
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/johnstarich/sage/pipe"
+	"github.com/johnstarich/sage/plaindb"
+	"github.com/pkg/errors"
+)
+
+// Ledger looks up and adds transactions, like a *ledger.Store
+type Ledger interface {
+	Transaction(id string) (ledger.Transaction, bool)
+	AddTransactions(txns []ledger.Transaction) error
+}
+
+// Store holds new transactions with their proposed categories until they're approved or rejected
+type Store struct {
+	mu      sync.Mutex
+	bucket  plaindb.Bucket
+	enabled bool
+}
+
+// entry is a staged transaction. Rejected entries are kept, so their transactions aren't staged again on the next sync.
+type entry struct {
+	Transaction ledger.Transaction
+	Rejected    bool `json:",omitempty"`
+}
+
+// NewStore returns the inbox bucket. If enabled is false, new transactions skip review.
+func NewStore(db plaindb.DB, enabled bool) (*Store, error) {
+	bucket, err := db.Bucket("inbox", "1", &storeUpgrader{})
+	return &Store{
+		bucket:  bucket,
+		enabled: enabled,
+	}, err
+}
+
+type storeUpgrader struct{}
+
+func (u *storeUpgrader) Parse(dataVersion, id string, data json.RawMessage) (interface{}, error) {
+	switch dataVersion {
+	case "1":
+		var e entry
+		err := json.Unmarshal(data, &e)
+		return e, err
+	default:
+		return nil, errors.Errorf("Unsupported version: %q", dataVersion)
+	}
+}
+
+func (u *storeUpgrader) Upgrade(dataVersion, id string, data interface{}) (newVersion string, newData interface{}, err error) {
+	return dataVersion, data, nil
+}
+
+// Enabled returns true if new transactions should be staged for review
+func (s *Store) Enabled() bool {
+	return s.enabled
+}
+
+func transactionID(txn ledger.Transaction) string {
+	if len(txn.Postings) == 0 {
+		return ""
+	}
+	return txn.Postings[0].ID()
+}
+
+// Add stages new transactions for review. Transactions already in the ledger or the inbox are skipped, keeping any edits to staged ones.
+// Transactions without an ID can't be reviewed and are returned instead.
+func (s *Store) Add(txns []ledger.Transaction, ldg Ledger) (unstaged []ledger.Transaction, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, txn := range txns {
+		id := transactionID(txn)
+		if id == "" {
+			unstaged = append(unstaged, txn)
+			continue
+		}
+		if _, found := ldg.Transaction(id); found {
+			continue
+		}
+		var e entry
+		found, err := s.bucket.Get(id, &e)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			continue
+		}
+		if err := s.bucket.Put(id, entry{Transaction: txn}); err != nil {
+			return nil, err
+		}
+	}
+	return unstaged, nil
+}
+
+// List returns all transactions awaiting review sorted by date
+func (s *Store) List() ([]ledger.Transaction, error) {
+	var e entry
+	var txns []ledger.Transaction
+	err := s.bucket.Iter(&e, func(string) bool {
+		if !e.Rejected {
+			txns = append(txns, e.Transaction)
+		}
+		return true
+	})
+	sort.SliceStable(txns, func(a, b int) bool {
+		return txns[a].Date.Before(txns[b].Date)
+	})
+	return txns, err
+}
+
+// getPending returns the staged transaction with ID 'id'. Returns an error if it's not awaiting review.
+func (s *Store) getPending(id string) (ledger.Transaction, error) {
+	var e entry
+	found, err := s.bucket.Get(id, &e)
+	if err == nil && (!found || e.Rejected) {
+		err = errors.New("Transaction not found in inbox: " + id)
+	}
+	return e.Transaction, err
+}
+
+// Update replaces staged transactions with edited versions, like a different category. Transactions are matched by ID.
+// No transactions are changed if any are invalid.
+func (s *Store) Update(txns []ledger.Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pipe.OpFuncs{
+		func() error {
+			for _, txn := range txns {
+				id := transactionID(txn)
+				if _, err := s.getPending(id); err != nil {
+					return err
+				}
+				if err := txn.Validate(); err != nil {
+					return errors.Wrap(err, "Invalid transaction "+id)
+				}
+			}
+			return nil
+		},
+		func() error {
+			for _, txn := range txns {
+				if err := s.bucket.Put(transactionID(txn), entry{Transaction: txn}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}.Do()
+}
+
+// Approve adds the staged transactions with the given IDs to the ledger, then removes them from the inbox
+// If balance assertions fail, the transactions were still added, so they're removed from the inbox before returning the error.
+func (s *Store) Approve(ids []string, ldg Ledger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var txns []ledger.Transaction
+	var assertionErr error
+	return pipe.OpFuncs{
+		func() error {
+			for _, id := range ids {
+				txn, err := s.getPending(id)
+				if err != nil {
+					return err
+				}
+				txns = append(txns, txn)
+			}
+			return nil
+		},
+		func() error {
+			err := ldg.AddTransactions(txns)
+			if ledger.IsAssertionError(err) {
+				assertionErr = err
+				return nil
+			}
+			return err
+		},
+		func() error {
+			for _, id := range ids {
+				if err := s.bucket.Put(id, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		func() error { return assertionErr },
+	}.Do()
+}
+
+// Reject discards the staged transactions with the given IDs. They won't be staged again if downloaded later.
+func (s *Store) Reject(ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var txns []ledger.Transaction
+	return pipe.OpFuncs{
+		func() error {
+			for _, id := range ids {
+				txn, err := s.getPending(id)
+				if err != nil {
+					return err
+				}
+				txns = append(txns, txn)
+			}
+			return nil
+		},
+		func() error {
+			for _, txn := range txns {
+				if err := s.bucket.Put(transactionID(txn), entry{Transaction: txn, Rejected: true}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}.Do()
+}
@@ -0,0 +1,168 @@
+package inbox
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/johnstarich/sage/plaindb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJournal = `
+2020/01/02 Coffee
+    assets:Bank:Checking   $-4.25  ; id: txn-1
+    expenses:Food
+`
+
+const newJournal = `
+2020/01/02 Coffee
+    assets:Bank:Checking   $-4.25  ; id: txn-1
+    expenses:Food
+
+2020/01/05 Groceries
+    assets:Bank:Checking   $-20.00  ; id: txn-3
+    uncategorized
+
+2020/01/03 Paycheck
+    assets:Bank:Checking   $1000.10  ; id: txn-2
+    revenues:Salary
+`
+
+func mockDBStore(t *testing.T) *Store {
+	db := plaindb.NewMockDB(plaindb.MockConfig{FileReader: func(fileName string) ([]byte, error) {
+		return []byte(`{}`), nil
+	}})
+	store, err := NewStore(db, true)
+	require.NoError(t, err)
+	return store
+}
+
+func parseTransactions(t *testing.T, journal string) (*ledger.Ledger, []ledger.Transaction) {
+	ldg, err := ledger.NewFromReader(strings.NewReader(journal))
+	require.NoError(t, err)
+	return ldg, ldg.TransactionsBetween(time.Time{}, time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func ids(txns []ledger.Transaction) []string {
+	var txnIDs []string
+	for _, txn := range txns {
+		txnIDs = append(txnIDs, transactionID(txn))
+	}
+	return txnIDs
+}
+
+// stageTransactions stages newJournal's transactions for review, returning the ledger and store
+func stageTransactions(t *testing.T) (*ledger.Ledger, *Store) {
+	ldg, _ := parseTransactions(t, testJournal)
+	_, txns := parseTransactions(t, newJournal)
+	store := mockDBStore(t)
+	unstaged, err := store.Add(txns, ldg)
+	require.NoError(t, err)
+	assert.Empty(t, unstaged)
+	return ldg, store
+}
+
+func TestStoreAdd(t *testing.T) {
+	ldg, store := stageTransactions(t)
+	assert.True(t, store.Enabled())
+	txns, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"txn-2", "txn-3"}, ids(txns), "Transactions already in the ledger should be skipped")
+
+	edited := txns[1]
+	edited.Postings[1].Account = "expenses:Groceries"
+	require.NoError(t, store.Update([]ledger.Transaction{edited}))
+	_, newTxns := parseTransactions(t, newJournal)
+	unstaged, err := store.Add(newTxns, ldg)
+	require.NoError(t, err)
+	assert.Empty(t, unstaged)
+	txns, err = store.List()
+	require.NoError(t, err)
+	assert.Equal(t, "expenses:Groceries", txns[1].Postings[1].Account, "Adding again should keep edits")
+
+	unstaged, err = store.Add([]ledger.Transaction{{Payee: "no postings"}}, ldg)
+	require.NoError(t, err)
+	assert.Equal(t, []ledger.Transaction{{Payee: "no postings"}}, unstaged)
+}
+
+func TestStoreUpdate(t *testing.T) {
+	_, store := stageTransactions(t)
+	txns, err := store.List()
+	require.NoError(t, err)
+
+	t.Run("not found", func(t *testing.T) {
+		_, missing := parseTransactions(t, testJournal)
+		assert.Error(t, store.Update(missing))
+	})
+
+	t.Run("unbalanced", func(t *testing.T) {
+		edited := txns[0]
+		edited.Postings = append([]ledger.Posting(nil), edited.Postings...)
+		edited.Postings[1].Amount = edited.Postings[0].Amount
+		renamed := txns[1]
+		renamed.Payee = "Renamed"
+		assert.Error(t, store.Update([]ledger.Transaction{renamed, edited}))
+
+		staged, err := store.List()
+		require.NoError(t, err)
+		assert.Equal(t, "Groceries", staged[1].Payee, "No transactions should change if any are invalid")
+	})
+}
+
+func TestStoreApprove(t *testing.T) {
+	ldg, store := stageTransactions(t)
+	assert.Error(t, store.Approve([]string{"txn-2", "txn-4"}, ldg))
+	_, found := ldg.Transaction("txn-2")
+	assert.False(t, found, "No transactions should be approved if any aren't found")
+
+	require.NoError(t, store.Approve([]string{"txn-2"}, ldg))
+	_, found = ldg.Transaction("txn-2")
+	assert.True(t, found)
+	txns, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"txn-3"}, ids(txns))
+}
+
+func TestStoreApproveFailedAssertion(t *testing.T) {
+	ldg, _ := parseTransactions(t, `
+2020/01/01 Opening Balance
+    assets:Bank:Checking   $10  ; id: Opening-Balance
+    equity:Opening Balances
+`)
+	_, txns := parseTransactions(t, `
+2020/01/02 Coffee
+    assets:Bank:Checking   $-4.25 = $1  ; id: txn-1
+    expenses:Food
+`)
+	store := mockDBStore(t)
+	_, err := store.Add(txns, ldg)
+	require.NoError(t, err)
+
+	err = store.Approve([]string{"txn-1"}, ldg)
+	assert.True(t, ledger.IsAssertionError(err))
+	_, found := ldg.Transaction("txn-1")
+	assert.True(t, found)
+	staged, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, staged, "Approved transactions should be removed despite failed assertion")
+}
+
+func TestStoreReject(t *testing.T) {
+	ldg, store := stageTransactions(t)
+	assert.Error(t, store.Reject([]string{"txn-1"}))
+	require.NoError(t, store.Reject([]string{"txn-3"}))
+	txns, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"txn-2"}, ids(txns))
+
+	assert.Error(t, store.Approve([]string{"txn-3"}, ldg), "Rejected transactions should not be approved")
+	_, newTxns := parseTransactions(t, newJournal)
+	_, err = store.Add(newTxns, ldg)
+	require.NoError(t, err)
+	txns, err = store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"txn-2"}, ids(txns), "Rejected transactions should not be staged again")
+}
@@ -0,0 +1,153 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// parseAmount parses a commodity amount, like '$ 1.25', '-$1.25', '1.25 EUR', or '10 "ABC 123"'
+func parseAmount(amount string) (decimal.Decimal, string, error) {
+	amount = strings.TrimSpace(amount)
+	if amount == "" {
+		return decimal.Zero, "", errors.New("Amount must not be empty")
+	}
+
+	negative := false
+	if amount[0] == '-' || amount[0] == '+' {
+		// sign may appear before a prefixed commodity, i.e. -$1.25
+		negative = amount[0] == '-'
+		amount = strings.TrimSpace(amount[1:])
+	}
+
+	var prefix, suffix string
+	var err error
+	if amount != "" && !isNumberStart(rune(amount[0])) && amount[0] != '.' {
+		prefix, amount, err = readCommodity(amount)
+		if err != nil {
+			return decimal.Zero, "", err
+		}
+		amount = strings.TrimSpace(amount)
+	}
+
+	numberEnd := strings.IndexFunc(amount, func(r rune) bool {
+		return !isNumberStart(r) && r != ',' && r != '.'
+	})
+	number := amount
+	if numberEnd != -1 {
+		number = amount[:numberEnd]
+		suffix, amount, err = readCommodity(strings.TrimSpace(amount[numberEnd:]))
+		if err != nil {
+			return decimal.Zero, "", err
+		}
+		if strings.TrimSpace(amount) != "" {
+			return decimal.Zero, "", errors.Errorf("Unexpected text after amount: %q", amount)
+		}
+	}
+
+	if prefix != "" && suffix != "" {
+		return decimal.Zero, "", errors.Errorf("Amount must not have both a prefix and suffix commodity: %q and %q", prefix, suffix)
+	}
+	// TODO support thousands delimiter other than ','
+	number = strings.ReplaceAll(number, ",", "")
+	value, err := decimal.NewFromString(number)
+	if err != nil {
+		return decimal.Zero, "", err
+	}
+	if negative {
+		value = value.Neg()
+	}
+	return value, prefix + suffix, nil
+}
+
+func isNumberStart(r rune) bool {
+	return unicode.IsDigit(r) || r == '-' || r == '+'
+}
+
+// readCommodity reads a quoted commodity or an unquoted commodity from the start of s
+func readCommodity(s string) (commodity, remaining string, err error) {
+	if s == "" {
+		return "", "", nil
+	}
+	if s[0] == '"' {
+		end := strings.IndexRune(s[1:], '"')
+		if end == -1 {
+			return "", "", errors.Errorf("Commodity is missing an end quote: %s", s)
+		}
+		commodity = s[1 : end+1]
+		if commodity == "" {
+			return "", "", errors.New("Quoted commodity must not be empty")
+		}
+		return commodity, s[end+2:], nil
+	}
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !isCommodityRune(r)
+	})
+	if end == -1 {
+		end = len(s)
+	}
+	if end == 0 {
+		return "", "", errors.Errorf("Invalid commodity: %s", s)
+	}
+	return s[:end], s[end:], nil
+}
+
+// isCommodityRune returns true if r is allowed in an unquoted commodity
+func isCommodityRune(r rune) bool {
+	if unicode.IsSpace(r) || unicode.IsDigit(r) {
+		return false
+	}
+	return !strings.ContainsRune(`-+.,;:'"=@*()[]{}/&!%~`, r)
+}
+
+// isPrefixCommodity returns true if the commodity is written before the amount, like '$ 1.25'
+func isPrefixCommodity(commodity string) bool {
+	runes := []rune(commodity)
+	return len(runes) == 1 && unicode.IsSymbol(runes[0])
+}
+
+func formatCommodity(commodity string) string {
+	for _, r := range commodity {
+		if !isCommodityRune(r) {
+			return strconv.Quote(commodity)
+		}
+	}
+	return commodity
+}
+
+// formatAmount formats amount with its commodity, right-aligning the number to numberLen characters
+func formatAmount(amount decimal.Decimal, commodity string, numberLen int) string {
+	switch {
+	case commodity == "":
+		return stringPad(amount.String(), numberLen)
+	case isPrefixCommodity(commodity):
+		return stringPad(fmt.Sprintf("%s %s", commodity, amount.String()), numberLen+len(commodity)+1)
+	default:
+		return fmt.Sprintf("%s %s", stringPad(amount.String(), numberLen), formatCommodity(commodity))
+	}
+}
+
+// sumByCommodity adds up all postings' balancing amounts, grouped by commodity
+func sumByCommodity(postings []Posting) map[string]decimal.Decimal {
+	sums := make(map[string]decimal.Decimal)
+	for _, p := range postings {
+		amount, commodity := p.balanceAmount()
+		sums[commodity] = sums[commodity].Add(amount)
+	}
+	return sums
+}
+
+// sortedCommodities returns the commodities in 'amounts' in a stable order
+func sortedCommodities(amounts map[string]decimal.Decimal) []string {
+	commodities := make([]string, 0, len(amounts))
+	for commodity := range amounts {
+		commodities = append(commodities, commodity)
+	}
+	sort.Strings(commodities)
+	return commodities
+}
@@ -0,0 +1,74 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	for _, tc := range []struct {
+		input     string
+		amount    float64
+		commodity string
+		shouldErr bool
+	}{
+		{input: "$ 1.25", amount: 1.25, commodity: "$"},
+		{input: "$1.25", amount: 1.25, commodity: "$"},
+		{input: "$ -1.25", amount: -1.25, commodity: "$"},
+		{input: "-$1.25", amount: -1.25, commodity: "$"},
+		{input: "€1,234.50", amount: 1234.50, commodity: "€"},
+		{input: "1.25 EUR", amount: 1.25, commodity: "EUR"},
+		{input: "-1.25 CAD", amount: -1.25, commodity: "CAD"},
+		{input: "1.25EUR", amount: 1.25, commodity: "EUR"},
+		{input: "EUR 1.25", amount: 1.25, commodity: "EUR"},
+		{input: `10 "ABC 123"`, amount: 10, commodity: "ABC 123"},
+		{input: `"ABC 123" 10`, amount: 10, commodity: "ABC 123"},
+		{input: "1.25", amount: 1.25},
+		{input: ".5", amount: 0.5},
+		{input: "", shouldErr: true},
+		{input: "$", shouldErr: true},
+		{input: "$ abc", shouldErr: true},
+		{input: "$ 1 EUR", shouldErr: true},
+		{input: `"ABC 1`, shouldErr: true},
+		{input: `"" 1`, shouldErr: true},
+		{input: "1 EUR extra", shouldErr: true},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			amount, commodity, err := parseAmount(tc.input)
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, decFloat(tc.amount).String(), amount.String())
+			assert.Equal(t, tc.commodity, commodity)
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		amount      float64
+		commodity   string
+		numberLen   int
+		expect      string
+	}{
+		{description: "prefix symbol", amount: 1.25, commodity: "$", numberLen: 5, expect: " $ 1.25"},
+		{description: "suffix code", amount: -1.25, commodity: "EUR", numberLen: 5, expect: "-1.25 EUR"},
+		{description: "quoted", amount: 10, commodity: "ABC 123", numberLen: 1, expect: `10 "ABC 123"`},
+		{description: "no commodity", amount: 2, numberLen: 3, expect: "  2"},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			str := formatAmount(*decFloat(tc.amount), tc.commodity, tc.numberLen)
+			assert.Equal(t, tc.expect, str)
+
+			amount, commodity, err := parseAmount(str)
+			require.NoError(t, err)
+			assert.Equal(t, decFloat(tc.amount).String(), amount.String())
+			assert.Equal(t, tc.commodity, commodity)
+		})
+	}
+}
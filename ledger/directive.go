@@ -0,0 +1,283 @@
+package ledger
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	accountDirective      = "account"
+	aliasDirective        = "alias"
+	endAliasesDirective   = "end aliases"
+	applyAccountDirective = "apply account"
+	endApplyDirective     = "end apply account"
+	endDirective          = "end"
+	includeDirective      = "include"
+	yearDirective         = "year"
+	applyYearDirective    = "apply year"
+	shortYearDirective    = "Y"
+)
+
+// otherDirectives are the keywords of ledger and hledger directives which are preserved as-is without affecting parsing
+var otherDirectives = map[string]bool{
+	"A":            true,
+	"C":            true,
+	"D":            true,
+	"N":            true,
+	"apply":        true,
+	"assert":       true,
+	"bucket":       true,
+	"capture":      true,
+	"check":        true,
+	"commodity":    true,
+	"decimal-mark": true,
+	"define":       true,
+	"eval":         true,
+	"expr":         true,
+	"payee":        true,
+	"python":       true,
+	"tag":          true,
+	"test":         true,
+	"value":        true,
+}
+
+// isDirective returns true if the unindented 'line' starts with a known directive keyword,
+// or is a periodic or automated transaction, like '~ monthly' or '= expenses:food'
+func isDirective(line string) bool {
+	if strings.HasPrefix(line, "~") || strings.HasPrefix(line, "=") {
+		return true
+	}
+	keyword := strings.Fields(line)[0]
+	switch {
+	case otherDirectives[keyword],
+		keyword == aliasDirective,
+		keyword == endDirective,
+		keyword == yearDirective:
+		return true
+	case strings.HasPrefix(keyword, shortYearDirective):
+		_, err := strconv.Atoi(strings.TrimPrefix(keyword, shortYearDirective))
+		return keyword == shortYearDirective || err == nil
+	default:
+		return false
+	}
+}
+
+// AccountDeclaration is an 'account' directive, declaring an account and optionally its type
+type AccountDeclaration struct {
+	Account string
+	Comment string            `json:",omitempty"`
+	Tags    map[string]string `json:",omitempty"`
+}
+
+// Type returns the account's declared type from its 'type' tag, i.e. Asset, Liability, Equity, Revenue, or Expense. Returns an empty string if not declared.
+func (a AccountDeclaration) Type() string {
+	return a.Tags["type"]
+}
+
+// accountAlias rewrites account names matching either a literal account prefix or a regular expression
+type accountAlias struct {
+	from    string
+	pattern *regexp.Regexp
+	to      string
+}
+
+func (a accountAlias) apply(account string) string {
+	if a.pattern != nil {
+		return a.pattern.ReplaceAllString(account, a.to)
+	}
+	if account == a.from {
+		return a.to
+	}
+	if strings.HasPrefix(account, a.from+":") {
+		return a.to + account[len(a.from):]
+	}
+	return account
+}
+
+// unapply returns the aliased name of 'account', like 'checking' for 'assets:Bank 1:Savings' with 'alias checking = assets:Bank 1'
+// Regular expression aliases can't be reversed, so 'account' is returned unchanged.
+func (a accountAlias) unapply(account string) string {
+	if a.pattern != nil {
+		return account
+	}
+	if account == a.to {
+		return a.from
+	}
+	if strings.HasPrefix(account, a.to+":") {
+		return a.from + account[len(a.to):]
+	}
+	return account
+}
+
+// parseAlias parses an alias directive's value, like 'checking = assets:Bank 1' or '/^(.*):old$/ = $1:new'
+func parseAlias(value string) (accountAlias, error) {
+	tokens := strings.SplitN(value, "=", 2)
+	if len(tokens) != 2 {
+		return accountAlias{}, errors.Errorf("Alias must be in the form 'alias OLD = NEW': %q", value)
+	}
+	from, to := strings.TrimSpace(tokens[0]), strings.TrimSpace(tokens[1])
+	if from == "" {
+		return accountAlias{}, errors.Errorf("Alias must not be empty: %q", value)
+	}
+	if len(from) > 1 && strings.HasPrefix(from, "/") && strings.HasSuffix(from, "/") {
+		pattern, err := regexp.Compile("(?i)" + from[1:len(from)-1])
+		if err != nil {
+			return accountAlias{}, errors.Wrap(err, "Invalid alias regular expression")
+		}
+		// support hledger-style \1 back references
+		to = regexp.MustCompile(`\\(\d+)`).ReplaceAllString(to, "$${$1}")
+		return accountAlias{pattern: pattern, to: to}, nil
+	}
+	return accountAlias{from: from, to: to}, nil
+}
+
+// directiveState tracks directives which affect how later lines are parsed
+type directiveState struct {
+	aliases       []accountAlias
+	applyAccounts []string
+	year          int
+}
+
+// copy returns a copy safe to modify without changing 'd', used to scope directives to an included file
+func (d directiveState) copy() directiveState {
+	return directiveState{
+		aliases:       append([]accountAlias(nil), d.aliases...),
+		applyAccounts: append([]string(nil), d.applyAccounts...),
+		year:          d.year,
+	}
+}
+
+// applyAccount returns the current 'apply account' prefix, if any
+func (d directiveState) applyAccount() string {
+	return strings.Join(d.applyAccounts, ":")
+}
+
+// account returns the full account name after applying any parent account prefixes and aliases
+func (d directiveState) account(account string) string {
+	if prefix := d.applyAccount(); prefix != "" {
+		account = prefix + ":" + account
+	}
+	// most recent aliases take precedence
+	for i := len(d.aliases) - 1; i >= 0; i-- {
+		account = d.aliases[i].apply(account)
+	}
+	return account
+}
+
+// unapply returns the account name to write for 'account', reversing its aliases and 'apply account' prefix.
+// If the reversed name doesn't resolve back to 'account', only the prefix is removed.
+func (d directiveState) unapply(account string) string {
+	prefix := d.applyAccount()
+	if prefix != "" {
+		prefix += ":"
+	}
+	name := account
+	// aliases were applied most recent first, so reverse them oldest first
+	for _, alias := range d.aliases {
+		name = alias.unapply(name)
+	}
+	name = strings.TrimPrefix(name, prefix)
+	if d.account(name) == account {
+		return name
+	}
+	return strings.TrimPrefix(account, prefix)
+}
+
+// parseDirective updates the directive state if 'line' is a directive affecting how later lines are parsed
+func (d *directiveState) parseDirective(line string) error {
+	line = strings.TrimSpace(strings.SplitN(line, ";", 2)[0])
+	switch {
+	case strings.HasPrefix(line, aliasDirective+" "):
+		alias, err := parseAlias(strings.TrimPrefix(line, aliasDirective+" "))
+		if err != nil {
+			return err
+		}
+		d.aliases = append(d.aliases, alias)
+	case line == endAliasesDirective:
+		d.aliases = nil
+	case strings.HasPrefix(line, applyAccountDirective+" "):
+		account := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, applyAccountDirective)), ":")
+		if account == "" {
+			return errors.New("Apply account directive must have an account")
+		}
+		d.applyAccounts = append(d.applyAccounts, account)
+	case line == endApplyDirective || line == endDirective:
+		if len(d.applyAccounts) == 0 {
+			return errors.Errorf("Found %q without a matching %q", line, applyAccountDirective)
+		}
+		d.applyAccounts = d.applyAccounts[:len(d.applyAccounts)-1]
+	case strings.HasPrefix(line, yearDirective+" "),
+		strings.HasPrefix(line, applyYearDirective+" "),
+		strings.HasPrefix(line, shortYearDirective) && len(line) > 1 && (line[1] == ' ' || unicode.IsDigit(rune(line[1]))):
+		value := strings.TrimPrefix(line, applyYearDirective)
+		value = strings.TrimPrefix(value, yearDirective)
+		value = strings.TrimPrefix(value, shortYearDirective)
+		year, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return errors.Errorf("Invalid year directive: %q", line)
+		}
+		d.year = year
+	}
+	return nil
+}
+
+// parseAccountDirective parses an account directive, like 'account assets:Bank 1  ; type: Asset'
+func parseAccountDirective(line string) (AccountDeclaration, error) {
+	var decl AccountDeclaration
+	tokens := strings.SplitN(line, ";", 2)
+	line = strings.TrimSpace(strings.TrimPrefix(tokens[0], accountDirective))
+	if len(tokens) == 2 {
+		decl.Comment, decl.Tags = parseTags(strings.TrimSpace(tokens[1]))
+	}
+	// account names end at two spaces or a tab
+	if end := strings.Index(line, "  "); end != -1 {
+		line = line[:end]
+	}
+	if end := strings.IndexRune(line, '\t'); end != -1 {
+		line = line[:end]
+	}
+	decl.Account = strings.TrimSpace(line)
+	if decl.Account == "" {
+		return decl, errors.New("Account directive must have an account name")
+	}
+	return decl, nil
+}
+
+// addComment merges an indented comment line into the declaration's comment and tags
+func (a *AccountDeclaration) addComment(line string) {
+	comment, tags := parseTags(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), ";")))
+	if comment != "" {
+		if a.Comment != "" {
+			a.Comment += " "
+		}
+		a.Comment += comment
+	}
+	for key, value := range tags {
+		if a.Tags == nil {
+			a.Tags = make(map[string]string)
+		}
+		a.Tags[key] = value
+	}
+}
+
+// parseIncludeDirective returns the included file path of an include directive, like 'include 2019.journal'
+func parseIncludeDirective(line string) (string, error) {
+	path := strings.TrimSpace(strings.TrimPrefix(strings.SplitN(line, ";", 2)[0], includeDirective))
+	if path == "" {
+		return "", errors.New("Include directive must have a file path")
+	}
+	return path, nil
+}
+
+// includeKey returns an identifier for an included file at 'path', relative to the including file identified by 'parentKey'
+func includeKey(parentKey, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(filepath.Dir(parentKey), path)
+}
@@ -0,0 +1,207 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountAlias(t *testing.T) {
+	for _, tc := range []struct {
+		alias   string
+		account string
+		expect  string
+	}{
+		{alias: "checking = assets:Bank 1", account: "checking", expect: "assets:Bank 1"},
+		{alias: "checking = assets:Bank 1", account: "checking:sub", expect: "assets:Bank 1:sub"},
+		{alias: "checking = assets:Bank 1", account: "checkings", expect: "checkings"},
+		{alias: `/^(.*):old$/ = \1:new`, account: "expenses:OLD", expect: "expenses:new"},
+		{alias: `/bank/ = Bank`, account: "assets:bank 1", expect: "assets:Bank 1"},
+	} {
+		t.Run(tc.alias+" "+tc.account, func(t *testing.T) {
+			alias, err := parseAlias(tc.alias)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, alias.apply(tc.account))
+		})
+	}
+
+	_, err := parseAlias("checking")
+	assert.Error(t, err)
+	_, err = parseAlias("/(/ = bad")
+	assert.Error(t, err)
+}
+
+func TestDirectiveStateUnapply(t *testing.T) {
+	checking, err := parseAlias("checking = assets:Bank 1")
+	require.NoError(t, err)
+	renamed, err := parseAlias(`/^(.*):old$/ = \1:new`)
+	require.NoError(t, err)
+	directives := directiveState{
+		aliases:       []accountAlias{checking, renamed},
+		applyAccounts: []string{"personal"},
+	}
+	for _, account := range []string{"checking", "checking:sub", "expenses:food", "expenses:old"} {
+		resolved := directives.account(account)
+		assert.Equal(t, resolved, directives.account(directives.unapply(resolved)), "Unapplied %q should resolve to the same account", account)
+	}
+	assert.Equal(t, "checking:sub", directives.unapply(directives.account("checking:sub")))
+	assert.Equal(t, "expenses:new", directives.unapply(directives.account("expenses:old")), "Regular expression aliases can't be reversed")
+}
+
+func TestParseAccountDirective(t *testing.T) {
+	decl, err := parseAccountDirective("account assets:Bank 1  ; type: Asset")
+	require.NoError(t, err)
+	assert.Equal(t, AccountDeclaration{
+		Account: "assets:Bank 1",
+		Tags:    map[string]string{"type": "Asset"},
+	}, decl)
+	assert.Equal(t, "Asset", decl.Type())
+
+	_, err = parseAccountDirective("account  ")
+	assert.Error(t, err)
+}
+
+func TestReadDirectives(t *testing.T) {
+	const input = `account assets:Bank 1
+    ; type: A
+alias checking = assets:Bank 1
+Y 2019
+
+01/02 some burger place
+    expenses:food   $ 1.25
+    checking
+
+apply account personal
+2019/01/03 coffee
+    expenses:food   $ 3
+    checking
+end apply account
+
+year 2020
+end aliases
+01/04 unaliased
+    expenses:food   $ 2
+    checking
+`
+	ldg, err := NewFromReader(bytes.NewBufferString(input))
+	require.NoError(t, err)
+	assert.Equal(t, []AccountDeclaration{
+		{Account: "assets:Bank 1", Tags: map[string]string{"type": "A"}},
+	}, ldg.AccountDeclarations())
+
+	require.Len(t, ldg.transactions, 3)
+	assert.Equal(t, parseDate(t, "2019/01/02"), ldg.transactions[0].Date)
+	assert.Equal(t, "assets:Bank 1", ldg.transactions[0].Postings[1].Account)
+	assert.Equal(t, "personal:expenses:food", ldg.transactions[1].Postings[0].Account)
+	assert.Equal(t, "personal:checking", ldg.transactions[1].Postings[1].Account)
+	assert.Equal(t, parseDate(t, "2020/01/04"), ldg.transactions[2].Date)
+	assert.Equal(t, "checking", ldg.transactions[2].Postings[1].Account)
+	assert.Equal(t, input, ldg.String())
+
+	t.Run("rewrite inside apply account", func(t *testing.T) {
+		ldg.transactions[1].Payee = "tea"
+		ldg.markChanged(ldg.transactions[1])
+		assert.Contains(t, ldg.String(), `apply account personal
+2019/01/03 tea
+    expenses:food   $ 3
+    checking       $ -3
+end apply account
+`)
+	})
+
+	t.Run("rewrite aliased transaction", func(t *testing.T) {
+		ldg.transactions[0].Payee = "burger"
+		ldg.markChanged(ldg.transactions[0])
+		assert.Contains(t, ldg.String(), `Y 2019
+
+2019/01/02 burger
+    expenses:food   $ 1.25
+    checking       $ -1.25
+`)
+	})
+
+	t.Run("unmatched end", func(t *testing.T) {
+		_, err := NewFromReader(bytes.NewBufferString("end apply account\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("other directives", func(t *testing.T) {
+		const input = `commodity $1,000.00
+payee Coffee Shop
+tag project
+D $1,000.00
+Y2019
+~ monthly
+    expenses:rent   $ 500
+    assets:Bank 1
+`
+		ldg, err := NewFromReader(bytes.NewBufferString(input))
+		require.NoError(t, err)
+		assert.Empty(t, ldg.transactions)
+		assert.Equal(t, input, ldg.String())
+	})
+
+	t.Run("unknown directive", func(t *testing.T) {
+		_, err := NewFromReader(bytes.NewBufferString("not a directive\n"))
+		assert.EqualError(t, err, "Unknown directive or line format detected: not a directive")
+	})
+
+	t.Run("includes require a file", func(t *testing.T) {
+		_, err := NewFromReader(bytes.NewBufferString("include 2019.journal\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestNewFromFileIncludes(t *testing.T) {
+	main := &mockFile{}
+	main.buf.WriteString(`; main journal
+include 2019.journal
+include years/2020.journal
+`)
+	journal2019 := main.Relative("2019.journal").(*mockFile)
+	journal2019.buf.WriteString(`alias checking = assets:Bank 1
+
+2019/01/02 some burger place ; id: A
+    expenses:food   $ 1.25
+    checking
+`)
+	journal2020 := main.Relative("years/2020.journal").(*mockFile)
+	journal2020.buf.WriteString(`2020/01/02 some burger place ; id: B
+    expenses:food   $ 2
+    checking
+`)
+
+	ldg, err := NewFromFile(main)
+	require.NoError(t, err)
+	require.Len(t, ldg.transactions, 2)
+	assert.Equal(t, "assets:Bank 1", ldg.transactions[0].Postings[1].Account)
+	assert.Equal(t, "checking", ldg.transactions[1].Postings[1].Account, "Aliases should only apply to the included file")
+
+	require.NoError(t, ldg.UpdateTransaction("B", Transaction{Comment: "updated"}))
+	require.NoError(t, syncLedgerFile(ldg, main)())
+	assert.Zero(t, main.writes, "Unchanged files should not be written")
+	assert.Zero(t, journal2019.writes, "Unchanged files should not be written")
+	assert.Equal(t, 1, journal2020.writes)
+	assert.Equal(t, `2020/01/02 some burger place ; updated id: B
+    expenses:food   $ 2
+    checking       $ -2
+`, journal2020.buf.String())
+
+	t.Run("include cycle", func(t *testing.T) {
+		main := &mockFile{}
+		main.buf.WriteString("include other.journal\n")
+		main.Relative("other.journal").(*mockFile).buf.WriteString("include other.journal\n")
+		_, err := NewFromFile(main)
+		assert.Error(t, err)
+	})
+
+	t.Run("include cycle with main file", func(t *testing.T) {
+		main := &mockFile{path: "main.journal"}
+		main.buf.WriteString("include other.journal\n")
+		main.Relative("other.journal").(*mockFile).buf.WriteString("include main.journal\n")
+		_, err := NewFromFile(main)
+		assert.EqualError(t, err, `Error reading included file "other.journal": Include cycle detected: main.journal -> other.journal -> main.journal`)
+	})
+}
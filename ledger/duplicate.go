@@ -0,0 +1,177 @@
+package ledger
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DuplicateWindow is the maximum time between a transaction and its likely duplicate
+	DuplicateWindow = 3 * day
+	// duplicateIDsTag holds the space separated IDs of confirmed duplicates of a transaction, so they're skipped on future imports
+	duplicateIDsTag = "duplicates"
+	// minPayeeSimilarity is the minimum fraction of shared payee words for a likely duplicate
+	minPayeeSimilarity = 0.6
+)
+
+// Duplicate is a new transaction which is likely a duplicate of an existing one, like the same purchase imported from both a file and Direct Connect
+type Duplicate struct {
+	Transaction Transaction
+	// ExistingID is the ID of the transaction in the ledger it duplicates
+	ExistingID string
+}
+
+// duplicateIDs returns the IDs of confirmed duplicates of this transaction
+func (t Transaction) duplicateIDs() []string {
+	return strings.Fields(t.Tags[duplicateIDsTag])
+}
+
+// FindDuplicates separates new transactions into unique ones and likely duplicates of existing transactions.
+// Likely duplicates have the same account, amount, and currency as an existing transaction, a date within DuplicateWindow, and a similar payee.
+// They must also come from a different source than the existing transaction, since a source doesn't duplicate its own transactions.
+// Transactions with IDs already in the ledger are considered unique, since they're skipped when added.
+func (l *Ledger) FindDuplicates(txns []Transaction) (unique []Transaction, duplicates []Duplicate) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	matched := make(map[*Transaction]bool)
+	for _, txn := range txns {
+		if len(txn.Postings) == 0 || l.idSet[txn.Postings[0].ID()] != nil {
+			unique = append(unique, txn)
+			continue
+		}
+		var match *Transaction
+		var matchDistance time.Duration
+		for _, existing := range l.transactions {
+			if matched[existing] || !isLikelyDuplicate(*existing, txn) {
+				continue
+			}
+			if distance := absDuration(existing.Date.Sub(txn.Date)); match == nil || distance < matchDistance {
+				match, matchDistance = existing, distance
+			}
+		}
+		if match == nil {
+			unique = append(unique, txn)
+			continue
+		}
+		matched[match] = true
+		duplicates = append(duplicates, Duplicate{
+			Transaction: txn,
+			ExistingID:  match.Postings[0].ID(),
+		})
+	}
+	return unique, duplicates
+}
+
+// isLikelyDuplicate returns true if 'txn' is likely a duplicate of 'existing'
+func isLikelyDuplicate(existing, txn Transaction) bool {
+	if len(existing.Postings) == 0 || existing.Postings[0].ID() == "" || absDuration(existing.Date.Sub(txn.Date)) > DuplicateWindow {
+		return false
+	}
+	posting := txn.Postings[0]
+	if idSource(existing.Postings[0].ID()) == idSource(posting.ID()) {
+		return false
+	}
+	samePosting := false
+	for _, p := range existing.Postings {
+		if p.Account == posting.Account && p.Currency == posting.Currency && p.Amount.Equal(posting.Amount) {
+			samePosting = true
+			break
+		}
+	}
+	return samePosting && payeeSimilarity(existing.Payee, txn.Payee) >= minPayeeSimilarity
+}
+
+// idSource returns the source of a transaction ID, like the 'FID-ACCTID' prefix of 'FID-ACCTID-FITID'.
+// IDs without a prefix are their own source.
+func idSource(id string) string {
+	tokens := strings.SplitN(id, "-", 3)
+	if len(tokens) < 3 {
+		return id
+	}
+	return tokens[0] + "-" + tokens[1]
+}
+
+// payeeSimilarity returns the fraction of the shorter payee's words found in the other payee, ignoring case, punctuation, and numbers like store or card numbers
+func payeeSimilarity(a, b string) float64 {
+	wordsA, wordsB := payeeWords(a), payeeWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	if len(wordsB) < len(wordsA) {
+		wordsA, wordsB = wordsB, wordsA
+	}
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA))
+}
+
+func payeeWords(payee string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(payee), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+// MergeDuplicate records 'duplicate' as a confirmed duplicate of the transaction with ID 'existingID', so it's skipped if imported again.
+// Balance assertions and comments missing from the existing transaction are copied from the duplicate.
+// Returns an Error if a copied balance assertion fails, but the duplicate is still merged.
+func (l *Ledger) MergeDuplicate(existingID string, duplicate Transaction) error {
+	if len(duplicate.Postings) == 0 {
+		return errors.New("Duplicate transaction must have postings")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	existing := l.idSet[existingID]
+	if existing == nil {
+		return errors.New("Transaction not found by ID: " + existingID)
+	}
+	duplicateID := duplicate.Postings[0].ID()
+	if duplicateID == "" {
+		return errors.New("Duplicate transaction must have an ID")
+	}
+	if l.idSet[duplicateID] != nil {
+		return NewValidateError(0, duplicateTransactionError(duplicateID))
+	}
+	if strings.ContainsAny(duplicateID, " ,") {
+		return errors.Errorf("Duplicate transaction ID must not contain spaces or commas: %q", duplicateID)
+	}
+
+	txn := *existing
+	tags := make(map[string]string, len(txn.Tags)+1)
+	for key, value := range txn.Tags {
+		tags[key] = value
+	}
+	tags[duplicateIDsTag] = strings.Join(append(txn.duplicateIDs(), duplicateID), " ")
+	txn.Tags = tags
+	if txn.Comment == "" {
+		txn.Comment = duplicate.Comment
+	}
+	duplicatePosting := duplicate.Postings[0]
+	txn.Postings = append([]Posting(nil), txn.Postings...)
+	for i, p := range txn.Postings {
+		if p.Account == duplicatePosting.Account && p.Balance == nil && duplicatePosting.Balance != nil {
+			balance := *duplicatePosting.Balance
+			txn.Postings[i].Balance = &balance
+			break
+		}
+	}
+	if err := txn.Validate(); err != nil {
+		return err
+	}
+
+	previousFailures := l.failedAssertionKeys()
+	*existing = txn
+	l.idSet[duplicateID] = existing
+	l.markChanged(existing)
+	return l.newAssertionsErrorSince(previousFailures)
+}
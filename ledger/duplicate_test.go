@@ -0,0 +1,121 @@
+package ledger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayeeSimilarity(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected float64
+	}{
+		{"Coffee Shop", "coffee shop", 1},
+		{"POS 1234 STARBUCKS #445", "Starbucks", 1},
+		{"Starbucks Seattle", "Starbucks", 1},
+		{"Blue Bottle Coffee", "Peets Coffee", 0.5},
+		{"Starbucks", "Costco", 0},
+		{"", "Costco", 0},
+		{"1234", "1234", 0},
+	} {
+		assert.Equal(t, tc.expected, payeeSimilarity(tc.a, tc.b), "%q vs %q", tc.a, tc.b)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	const checking = "assets:Bank:****1234"
+	makeTxn := func(id, date, payee string, amount float64) Transaction {
+		return Transaction{
+			Date:  parseDate(t, date),
+			Payee: payee,
+			Postings: []Posting{
+				{Account: checking, Amount: *decFloat(amount), Currency: usd, Tags: makeIDTag(id)},
+				{Account: "expenses:uncategorized", Amount: *decFloat(-amount), Currency: usd},
+			},
+		}
+	}
+	ldg, err := New([]Transaction{
+		makeTxn("direct-5678-1", "2020/01/02", "STARBUCKS #445", -4.25),
+		makeTxn("direct-5678-2", "2020/01/05", "Costco", -104.5),
+		makeTxn("direct-5678-3", "2020/01/05", "Costco", -104.5),
+		makeTxn("direct-5678-4", "2020/01/10", "Target", -20),
+	})
+	require.NoError(t, err)
+
+	unique, duplicates := ldg.FindDuplicates([]Transaction{
+		makeTxn("direct-5678-1", "2020/01/02", "STARBUCKS #445", -4.25),
+		makeTxn("file-5678-1", "2020/01/03", "Starbucks", -4.25),
+		makeTxn("file-5678-2", "2020/01/05", "Costco Wholesale", -104.5),
+		makeTxn("file-5678-3", "2020/01/06", "Costco", -104.5),
+		makeTxn("file-5678-4", "2020/01/07", "Costco", -104.5),
+		makeTxn("file-5678-5", "2020/01/03", "Starbucks", -5),
+		makeTxn("file-5678-6", "2020/01/20", "Starbucks", -4.25),
+		makeTxn("file-5678-7", "2020/01/03", "Payment", -4.25),
+		makeTxn("direct-5678-5", "2020/01/10", "Target", -20),
+	})
+
+	var uniqueIDs []string
+	for _, txn := range unique {
+		uniqueIDs = append(uniqueIDs, txn.Postings[0].ID())
+	}
+	assert.Equal(t, []string{"direct-5678-1", "file-5678-4", "file-5678-5", "file-5678-6", "file-5678-7", "direct-5678-5"}, uniqueIDs, "Transactions from the same source as the existing transaction should be unique")
+	var duplicateIDs []string
+	for _, dupe := range duplicates {
+		duplicateIDs = append(duplicateIDs, dupe.Transaction.Postings[0].ID()+" "+dupe.ExistingID)
+	}
+	assert.Equal(t, []string{"file-5678-1 direct-5678-1", "file-5678-2 direct-5678-2", "file-5678-3 direct-5678-3"}, duplicateIDs)
+}
+
+func TestIDSource(t *testing.T) {
+	assert.Equal(t, "1234-5678", idSource("1234-5678-abc"))
+	assert.Equal(t, "1234-5678", idSource("1234-5678-2020-01-02-abc"))
+	assert.Equal(t, "csv-abc", idSource("csv-abc"))
+	assert.Equal(t, "", idSource(""))
+}
+
+func TestMergeDuplicate(t *testing.T) {
+	existing := Transaction{
+		Date:  parseDate(t, "2020/01/02"),
+		Payee: "STARBUCKS #445",
+		Postings: []Posting{
+			{Account: "assets", Amount: *decFloat(-4.25), Currency: usd, Tags: makeIDTag("direct-1")},
+			{Account: "expenses", Amount: *decFloat(4.25), Currency: usd},
+		},
+	}
+	duplicate := existing
+	duplicate.Payee = "Starbucks"
+	duplicate.Comment = "from file"
+	duplicate.Postings = []Posting{
+		{Account: "assets", Amount: *decFloat(-4.25), Balance: decFloat(-4.25), Currency: usd, Tags: makeIDTag("file-1")},
+		{Account: "expenses", Amount: *decFloat(4.25), Currency: usd},
+	}
+	ldg, err := New([]Transaction{existing})
+	require.NoError(t, err)
+
+	assert.EqualError(t, ldg.MergeDuplicate("non-existent", duplicate), "Transaction not found by ID: non-existent")
+	assert.IsType(t, Error{}, ldg.MergeDuplicate("direct-1", existing), "Transactions already in the ledger can't be merged")
+
+	require.NoError(t, ldg.MergeDuplicate("direct-1", duplicate))
+	txn, found := ldg.Transaction("file-1")
+	require.True(t, found)
+	assert.Equal(t, "STARBUCKS #445", txn.Payee)
+	assert.Equal(t, "from file", txn.Comment)
+	assert.Equal(t, []string{"file-1"}, txn.duplicateIDs())
+	require.NotNil(t, txn.Postings[0].Balance)
+	assert.Equal(t, "-4.25", txn.Postings[0].Balance.String())
+
+	unique, duplicates := ldg.FindDuplicates([]Transaction{duplicate})
+	assert.Len(t, unique, 1, "Merged duplicates should be skipped as exact duplicates")
+	assert.Empty(t, duplicates)
+	require.NoError(t, ldg.AddTransactions(unique))
+	assert.Equal(t, 1, ldg.Size())
+
+	parsed, err := NewFromReader(strings.NewReader(ldg.String()))
+	require.NoError(t, err)
+	txn, found = parsed.Transaction("file-1")
+	require.True(t, found, "Duplicate IDs should be read from the ledger file")
+	assert.Equal(t, []string{"file-1"}, txn.duplicateIDs())
+}
@@ -1,19 +1,67 @@
 package ledger
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
 
 type Error struct {
 	firstFailedTxnIndex int
 	cause               error
+	// Assertions contains every failed balance assertion, if any
+	Assertions []AssertionError
 }
 
 func NewValidateError(firstFailure int, cause error) error {
 	if cause == nil {
 		return nil
 	}
-	return Error{firstFailure, cause}
+	return Error{firstFailedTxnIndex: firstFailure, cause: cause}
+}
+
+// newAssertionsError returns an Error for the failed balance assertions, or nil if there are none
+func newAssertionsError(firstFailure int, assertions []AssertionError) error {
+	if len(assertions) == 0 {
+		return nil
+	}
+	cause := error(assertions[0])
+	if len(assertions) > 1 {
+		cause = fmt.Errorf("%s (and %d more failed balance assertions)", assertions[0], len(assertions)-1)
+	}
+	return Error{
+		firstFailedTxnIndex: firstFailure,
+		cause:               cause,
+		Assertions:          assertions,
+	}
+}
+
+// IsAssertionError returns true if 'err' is an Error for failed balance assertions. The change which caused it was still applied.
+func IsAssertionError(err error) bool {
+	ledgerErr, isLedgerErr := err.(Error)
+	return isLedgerErr && len(ledgerErr.Assertions) > 0
 }
 
 func (e Error) Error() string {
 	return fmt.Sprintf("Failed to validate ledger at transaction index #%d: %s", e.firstFailedTxnIndex, e.cause)
 }
+
+// AssertionError is a balance assertion which did not match the account's running balance
+type AssertionError struct {
+	Account     string
+	Currency    string
+	Expected    decimal.Decimal
+	Actual      decimal.Decimal
+	Transaction Transaction
+}
+
+func (e AssertionError) Error() string {
+	return fmt.Sprintf(
+		"Balance assertion failed for account %q in transaction %s %q: expected %s, actual %s",
+		e.Account,
+		e.Transaction.Date.Format(DateFormat),
+		e.Transaction.Payee,
+		formatAmount(e.Expected, e.Currency, 1),
+		formatAmount(e.Actual, e.Currency, 1),
+	)
+}
@@ -0,0 +1,146 @@
+package ledger
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	payeeField = iota
+	commentField
+	codeField
+	dateField
+	// postingsField is the first posting's field, the rest follow in order
+	postingsField
+)
+
+// searchDateFormat is the date format indexed for free text searches, like 'Monday 2 January 2006'
+const searchDateFormat = "Monday 2 January 2006"
+
+// searchIndex is an inverted index of transactions' lowercased words, used for free text searches
+type searchIndex struct {
+	// words maps each word to the transactions containing it and a bit mask of the fields it was found in
+	words map[string]map[*Transaction]uint64
+	// txnWords are the words indexed for each transaction, used to remove it again
+	txnWords map[*Transaction][]string
+
+	// suffixesMu guards suffixes, which is rebuilt by the next match after the vocabulary changes.
+	// Matches only hold the ledger's read lock, so they may rebuild it concurrently.
+	suffixesMu sync.Mutex
+	// suffixes are all suffixes of all words, sorted for binary search
+	suffixes []wordSuffix
+	// suffixesStale is set when words are added or removed. Assumes the ledger's write lock is held to set it.
+	suffixesStale bool
+}
+
+// wordSuffix is a suffix of an indexed word. Any word containing a term has a suffix starting with that term.
+type wordSuffix struct {
+	suffix string
+	word   string
+}
+
+// fieldBit returns the bit mask for a transaction field. Postings past the 64th share the last bit.
+func fieldBit(field int) uint64 {
+	if field > 63 {
+		field = 63
+	}
+	return 1 << uint(field)
+}
+
+// update indexes 'txn', replacing any previously indexed words
+func (s *searchIndex) update(txn *Transaction) {
+	s.remove(txn)
+	if s.words == nil {
+		s.words = make(map[string]map[*Transaction]uint64)
+		s.txnWords = make(map[*Transaction][]string)
+	}
+
+	fields := make(map[string]uint64)
+	addField := func(field int, value string) {
+		for _, word := range strings.Fields(strings.ToLower(value)) {
+			fields[word] |= fieldBit(field)
+		}
+	}
+	addField(payeeField, txn.Payee)
+	addField(commentField, txn.Comment)
+	addField(codeField, txn.Code)
+	addField(dateField, txn.Date.Format(searchDateFormat))
+	for i, p := range txn.Postings {
+		addField(postingsField+i, p.Account)
+	}
+
+	words := make([]string, 0, len(fields))
+	for word, mask := range fields {
+		if s.words[word] == nil {
+			s.words[word] = make(map[*Transaction]uint64)
+			s.suffixesStale = true
+		}
+		s.words[word][txn] = mask
+		words = append(words, word)
+	}
+	s.txnWords[txn] = words
+}
+
+// remove removes 'txn' from the index
+func (s *searchIndex) remove(txn *Transaction) {
+	for _, word := range s.txnWords[txn] {
+		delete(s.words[word], txn)
+		if len(s.words[word]) == 0 {
+			delete(s.words, word)
+			s.suffixesStale = true
+		}
+	}
+	delete(s.txnWords, txn)
+}
+
+// match returns all transactions with a word containing 'term' and a bit mask of the matching fields. Assumes 'term' is lowercase.
+func (s *searchIndex) match(term string) map[*Transaction]uint64 {
+	suffixes := s.sortedSuffixes()
+	matches := make(map[*Transaction]uint64)
+	matchedWords := make(map[string]bool)
+	for i := sort.Search(len(suffixes), func(i int) bool { return suffixes[i].suffix >= term }); i < len(suffixes) && strings.HasPrefix(suffixes[i].suffix, term); i++ {
+		word := suffixes[i].word
+		if matchedWords[word] {
+			continue
+		}
+		matchedWords[word] = true
+		for txn, mask := range s.words[word] {
+			matches[txn] |= mask
+		}
+	}
+	return matches
+}
+
+// sortedSuffixes returns the sorted suffixes of all words, rebuilding them if the vocabulary changed
+func (s *searchIndex) sortedSuffixes() []wordSuffix {
+	s.suffixesMu.Lock()
+	defer s.suffixesMu.Unlock()
+	if !s.suffixesStale {
+		return s.suffixes
+	}
+	suffixes := make([]wordSuffix, 0, len(s.suffixes))
+	for word := range s.words {
+		for i := range word {
+			suffixes = append(suffixes, wordSuffix{suffix: word[i:], word: word})
+		}
+	}
+	sort.Slice(suffixes, func(a, b int) bool {
+		return suffixes[a].suffix < suffixes[b].suffix
+	})
+	s.suffixes = suffixes
+	s.suffixesStale = false
+	return suffixes
+}
+
+// scores returns all transactions matching any of 'terms', scored by the number of fields matching each term. Assumes 'terms' are lowercase.
+func (s *searchIndex) scores(terms []string) map[*Transaction]int {
+	scores := make(map[*Transaction]int)
+	for _, term := range terms {
+		for txn, mask := range s.match(term) {
+			scores[txn] += bits.OnesCount64(mask)
+		}
+	}
+	return scores
+}
@@ -0,0 +1,38 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchIndex(t *testing.T) {
+	txn := &Transaction{
+		Date:    parseDate(t, "2020/01/02"),
+		Payee:   "Some Burger Place",
+		Comment: "burgers",
+		Postings: []Posting{
+			{Account: "expenses:food"},
+			{Account: "assets:Bank 1"},
+		},
+	}
+	var index searchIndex
+	index.update(txn)
+	assert.Equal(t, map[*Transaction]int{txn: 2}, index.scores([]string{"burger"}), "Payee and comment should match")
+	assert.Equal(t, map[*Transaction]int{txn: 3}, index.scores([]string{"burger", "food"}))
+	assert.Equal(t, map[*Transaction]int{txn: 1}, index.scores([]string{"thursday"}), "Dates should be searchable")
+	assert.Empty(t, index.scores([]string{"coffee"}))
+	assert.Equal(t, map[*Transaction]int{txn: 2}, index.scores([]string{"urge"}), "Terms should match inside words")
+	assert.Equal(t, map[*Transaction]int{txn: 1}, index.scores([]string{"bank"}), "Terms should match whole words")
+
+	txn.Payee = "coffee"
+	index.update(txn)
+	assert.Equal(t, map[*Transaction]int{txn: 1}, index.scores([]string{"burger"}), "Old words should be removed")
+	assert.Equal(t, map[*Transaction]int{txn: 1}, index.scores([]string{"coffee"}))
+
+	index.remove(txn)
+	assert.Empty(t, index.scores([]string{"coffee"}))
+	assert.Empty(t, index.words)
+	assert.Empty(t, index.txnWords)
+	assert.Empty(t, index.sortedSuffixes())
+}
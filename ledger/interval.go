@@ -0,0 +1,87 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Interval is the length of time covered by each balance in a balance series
+type Interval string
+
+// Intervals for balance series. Weeks start on Monday.
+const (
+	IntervalDay     Interval = "day"
+	IntervalWeek    Interval = "week"
+	IntervalMonth   Interval = "month"
+	IntervalQuarter Interval = "quarter"
+	IntervalYear    Interval = "year"
+)
+
+const daysPerWeek = 7
+
+// ParseInterval parses an interval name: day, week, month, quarter, or year. Empty strings default to month.
+func ParseInterval(s string) (Interval, error) {
+	switch interval := Interval(s); interval {
+	case "":
+		return IntervalMonth, nil
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalQuarter, IntervalYear:
+		return interval, nil
+	default:
+		return "", errors.Errorf("Invalid interval %q, must be one of: day, week, month, quarter, year", s)
+	}
+}
+
+// Start returns the beginning of the interval containing 't'
+func (i Interval) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch i {
+	case IntervalDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	case IntervalWeek:
+		daysSinceMonday := (int(t.Weekday()) + daysPerWeek - int(time.Monday)) % daysPerWeek
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+	case IntervalQuarter:
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Add returns 't' moved forward by 'n' intervals
+func (i Interval) Add(t time.Time, n int) time.Time {
+	switch i {
+	case IntervalDay:
+		return t.AddDate(0, 0, n)
+	case IntervalWeek:
+		return t.AddDate(0, 0, daysPerWeek*n)
+	case IntervalQuarter:
+		return t.AddDate(0, 3*n, 0)
+	case IntervalYear:
+		return t.AddDate(n, 0, 0)
+	default:
+		return t.AddDate(0, n, 0)
+	}
+}
+
+// index returns the number of intervals from the one containing 'start' to the one containing 't'. Negative if 't' is in an earlier interval.
+func (i Interval) index(start, t time.Time) int {
+	start, t = i.Start(start), i.Start(t)
+	monthNum := func(t time.Time) int {
+		return int(t.Month()) - 1 + 12*t.Year()
+	}
+	switch i {
+	case IntervalDay:
+		return int(t.Sub(start) / (24 * time.Hour))
+	case IntervalWeek:
+		return int(t.Sub(start) / (daysPerWeek * 24 * time.Hour))
+	case IntervalQuarter:
+		return (monthNum(t) - monthNum(start)) / 3
+	case IntervalYear:
+		return t.Year() - start.Year()
+	default:
+		return monthNum(t) - monthNum(start)
+	}
+}
@@ -0,0 +1,50 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInterval(t *testing.T) {
+	interval, err := ParseInterval("")
+	require.NoError(t, err)
+	assert.Equal(t, IntervalMonth, interval)
+
+	interval, err = ParseInterval("week")
+	require.NoError(t, err)
+	assert.Equal(t, IntervalWeek, interval)
+
+	_, err = ParseInterval("fortnight")
+	assert.Error(t, err)
+}
+
+func TestInterval(t *testing.T) {
+	// a Wednesday
+	date := time.Date(2020, 5, 13, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		interval Interval
+		start    string
+		next     string
+	}{
+		{interval: IntervalDay, start: "2020/05/13", next: "2020/05/14"},
+		{interval: IntervalWeek, start: "2020/05/11", next: "2020/05/18"},
+		{interval: IntervalMonth, start: "2020/05/01", next: "2020/06/01"},
+		{interval: IntervalQuarter, start: "2020/04/01", next: "2020/07/01"},
+		{interval: IntervalYear, start: "2020/01/01", next: "2021/01/01"},
+	} {
+		t.Run(string(tc.interval), func(t *testing.T) {
+			start := tc.interval.Start(date)
+			assert.Equal(t, parseDate(t, tc.start), start)
+			assert.Equal(t, parseDate(t, tc.next), tc.interval.Add(start, 1))
+			assert.Equal(t, 0, tc.interval.index(start, date))
+			assert.Equal(t, 2, tc.interval.index(start, tc.interval.Add(date, 2)))
+			assert.Equal(t, -1, tc.interval.index(start, tc.interval.Add(date, -1)))
+		})
+	}
+
+	sunday := time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, parseDate(t, "2020/05/11"), IntervalWeek.Start(sunday), "Weeks start on Monday")
+}
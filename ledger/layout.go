@@ -0,0 +1,380 @@
+package ledger
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/johnstarich/sage/vcs"
+)
+
+// fileBlock is a contiguous region of a ledger file, like a transaction or a run of comments and directives.
+// The original text is kept so unchanged regions are written back byte-for-byte.
+type fileBlock struct {
+	text string
+	// txnIndex is the index of this block's transaction while reading, -1 if it isn't a transaction
+	txnIndex int
+	txn      *Transaction
+	// directives are the 'apply account' prefixes and aliases in effect for this transaction, reversed again when rewriting it
+	directives directiveState
+	// include is the file included by this block's include directive
+	include *includedFile
+}
+
+// includedFile is a ledger file read from an include directive
+type includedFile struct {
+	file   vcs.File
+	blocks []fileBlock
+}
+
+// txnChange is a change to a transaction which hasn't been written to the ledger file yet
+type txnChange int
+
+const (
+	// txnUpdated is an added or modified transaction
+	txnUpdated txnChange = iota
+	// txnRemoved is a removed transaction
+	txnRemoved
+)
+
+func (b fileBlock) isTransaction() bool {
+	return b.txnIndex != -1 || b.txn != nil
+}
+
+func (b fileBlock) isRaw() bool {
+	return !b.isTransaction() && b.include == nil
+}
+
+// resolveTransactions sets each transaction block's txn from the transactions read, including all included files' blocks.
+// Records the included file containing each transaction in 'txnFiles', nil for the main file.
+func resolveTransactions(blocks []fileBlock, transactions Transactions, file *includedFile, txnFiles map[*Transaction]*includedFile) {
+	for i := range blocks {
+		if blocks[i].isTransaction() {
+			blocks[i].txn = transactions[blocks[i].txnIndex]
+			txnFiles[blocks[i].txn] = file
+		}
+		if blocks[i].include != nil {
+			resolveTransactions(blocks[i].include.blocks, transactions, blocks[i].include, txnFiles)
+		}
+	}
+}
+
+// appendRawBlock adds raw text to the end of 'blocks', merging with a previous raw block if possible
+func appendRawBlock(blocks []fileBlock, text string) []fileBlock {
+	if len(blocks) > 0 && blocks[len(blocks)-1].isRaw() {
+		blocks[len(blocks)-1].text += text
+		return blocks
+	}
+	return append(blocks, fileBlock{text: text, txnIndex: -1})
+}
+
+// scanRawLines is a bufio.SplitFunc like bufio.ScanLines, but keeps line endings to preserve the original text
+func scanRawLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	// request more data
+	return 0, nil, nil
+}
+
+// isCommentStart returns true if a top-level line starting with 'c' is a comment
+func isCommentStart(c byte) bool {
+	return strings.IndexByte(";#*%|", c) != -1
+}
+
+// markChanged records 'txn' as added or modified since the ledger file was last written and updates the search index
+// Assumes the write lock is held
+func (l *Ledger) markChanged(txn *Transaction) {
+	if l.changes == nil {
+		l.changes = make(map[*Transaction]txnChange)
+	}
+	l.changes[txn] = txnUpdated
+	l.index.update(txn)
+}
+
+// markRemoved records 'txn' as removed since the ledger file was last written and removes it from the search index
+// Assumes the write lock is held
+func (l *Ledger) markRemoved(txn *Transaction) {
+	if l.changes == nil {
+		l.changes = make(map[*Transaction]txnChange)
+	}
+	l.changes[txn] = txnRemoved
+	l.index.remove(txn)
+}
+
+// changedFiles returns true for 'main' if the main ledger file has unwritten changes and the included files with unwritten changes
+// Assumes at least a read lock is held
+func (l *Ledger) changedFiles() (main bool, includes map[*includedFile]bool) {
+	includes = make(map[*includedFile]bool)
+	main = len(l.addedPrices) > 0
+	for file := range l.unwritten {
+		if file == nil {
+			main = true
+		} else {
+			includes[file] = true
+		}
+	}
+	for txn, change := range l.changes {
+		file, inLayout := l.txnFiles[txn]
+		switch {
+		case !inLayout:
+			// new transactions are always added to the main file
+			main = main || change != txnRemoved
+		case file == nil:
+			main = true
+		default:
+			includes[file] = true
+		}
+	}
+	return
+}
+
+// renderMain returns the main ledger file's blocks with all changes applied
+// Assumes at least a read lock is held
+func (l *Ledger) renderMain() []fileBlock {
+	var newTxns Transactions
+	for txn, change := range l.changes {
+		if _, inLayout := l.txnFiles[txn]; !inLayout && change != txnRemoved {
+			newTxns = append(newTxns, txn)
+		}
+	}
+	newTxns.Sort()
+
+	var blocks []fileBlock
+	if len(l.addedPrices) > 0 {
+		var prices strings.Builder
+		for _, price := range l.addedPrices {
+			prices.WriteString(price.String())
+			prices.WriteRune('\n')
+		}
+		prices.WriteRune('\n')
+		blocks = appendRawBlock(blocks, prices.String())
+	}
+	blocks = applyChanges(blocks, l.layout, l.changes, &newTxns)
+	for _, txn := range newTxns {
+		blocks = appendNewTxn(blocks, txn)
+	}
+	return blocks
+}
+
+// appendNewTxns appends new transactions to the end of the main file's layout, if they are the only changes and none sort before an existing transaction.
+// Returns the appended text and true if appended, otherwise the layout is unchanged and renderMain must be used instead.
+// Assumes the write lock is held
+func (l *Ledger) appendNewTxns() (string, bool) {
+	if len(l.unwritten) > 0 || len(l.addedPrices) > 0 {
+		return "", false
+	}
+	var newTxns Transactions
+	for txn, change := range l.changes {
+		if _, inLayout := l.txnFiles[txn]; inLayout {
+			return "", false
+		}
+		if change != txnRemoved {
+			newTxns = append(newTxns, txn)
+		}
+	}
+	if len(newTxns) == 0 {
+		return "", false
+	}
+	newTxns.Sort()
+	for _, block := range l.layout {
+		// renderMain inserts new txns before the first later txn
+		if block.txn != nil && newTxns[0].Date.Before(block.txn.Date) {
+			return "", false
+		}
+	}
+
+	// appendNewTxn may add to the last raw block's text, so include its new suffix
+	lastIndex := len(l.layout) - 1
+	var lastText string
+	if lastIndex >= 0 {
+		lastText = l.layout[lastIndex].text
+	}
+	for _, txn := range newTxns {
+		l.layout = appendNewTxn(l.layout, txn)
+	}
+	if lastIndex < 0 {
+		return blocksText(l.layout), true
+	}
+	return l.layout[lastIndex].text[len(lastText):] + blocksText(l.layout[lastIndex+1:]), true
+}
+
+// fileWrite is the new contents of a changed ledger file
+type fileWrite struct {
+	// include is the changed included file, nil for the main file
+	include  *includedFile
+	contents []byte
+	// appended is true if 'contents' should be appended to the file instead of replacing it
+	appended bool
+}
+
+// takeChanges applies all changes to the ledger's layout and returns the changed files' new contents.
+// Only changed files are rendered and only changed transactions within them are re-rendered. Unchanged blocks are reused as-is.
+// If the only changes are new transactions at the end of the main file, only the new text is returned to append to it.
+// The caller must write each file, or call markUnwritten to retry later.
+func (l *Ledger) takeChanges() []fileWrite {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	main, includes := l.changedFiles()
+	var writes []fileWrite
+	if main {
+		if appended, ok := l.appendNewTxns(); ok {
+			writes = append(writes, fileWrite{contents: []byte(appended), appended: true})
+		} else {
+			l.layout = l.renderMain()
+			writes = append(writes, fileWrite{contents: []byte(blocksText(l.layout))})
+		}
+	}
+	var applyIncludes func(blocks []fileBlock)
+	applyIncludes = func(blocks []fileBlock) {
+		for _, block := range blocks {
+			if block.include == nil {
+				continue
+			}
+			if includes[block.include] {
+				block.include.blocks = applyChanges(nil, block.include.blocks, l.changes, nil)
+				writes = append(writes, fileWrite{include: block.include, contents: []byte(blocksText(block.include.blocks))})
+			}
+			applyIncludes(block.include.blocks)
+		}
+	}
+	applyIncludes(l.layout)
+
+	if l.txnFiles == nil {
+		l.txnFiles = make(map[*Transaction]*includedFile)
+	}
+	for txn, change := range l.changes {
+		if change == txnRemoved {
+			delete(l.txnFiles, txn)
+		} else if _, inLayout := l.txnFiles[txn]; !inLayout {
+			l.txnFiles[txn] = nil
+		}
+	}
+	l.changes = nil
+	l.addedPrices = nil
+	l.unwritten = nil
+	return writes
+}
+
+// markUnwritten records a file from takeChanges as failing to write, so it is written again next time
+func (l *Ledger) markUnwritten(include *includedFile) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.unwritten == nil {
+		l.unwritten = make(map[*includedFile]bool)
+	}
+	l.unwritten[include] = true
+}
+
+// applyChanges appends 'blocks' to 'result' with changes applied: removed transactions are dropped and modified ones are re-rendered in place.
+// If 'newTxns' is not nil, inserts and removes new txns before the first later txn.
+func applyChanges(result, blocks []fileBlock, changes map[*Transaction]txnChange, newTxns *Transactions) []fileBlock {
+	removedTxn := false
+	for _, block := range blocks {
+		if block.txn == nil {
+			if removedTxn && endsBlock(result) {
+				// drop the removed transaction's separating blank lines
+				block.text = trimLeadingBlankLines(block.text)
+			}
+			removedTxn = false
+			if block.text != "" || block.include != nil {
+				result = append(result, block)
+			}
+			continue
+		}
+		change, changed := changes[block.txn]
+		if changed && change == txnRemoved {
+			removedTxn = true
+			continue
+		}
+		removedTxn = false
+		for newTxns != nil && len(*newTxns) > 0 && (*newTxns)[0].Date.Before(block.txn.Date) {
+			result = appendNewTxn(result, (*newTxns)[0])
+			*newTxns = (*newTxns)[1:]
+		}
+		if changed {
+			block.text = unapplyDirectives(*block.txn, block.directives).String()
+		}
+		result = append(result, block)
+	}
+	return result
+}
+
+// appendNewTxn appends a block for a new transaction, separated from surrounding blocks by blank lines
+func appendNewTxn(blocks []fileBlock, txn *Transaction) []fileBlock {
+	if suffix := blocksSuffix(blocks, 2); suffix != "" {
+		var separator string
+		if !strings.HasSuffix(suffix, "\n") {
+			separator = "\n"
+			suffix += separator
+		}
+		if !strings.HasSuffix(suffix, "\n\n") {
+			separator += "\n"
+		}
+		if separator != "" {
+			blocks = appendRawBlock(blocks, separator)
+		}
+	}
+	blocks = append(blocks, fileBlock{text: txn.String(), txnIndex: -1, txn: txn})
+	return appendRawBlock(blocks, "\n")
+}
+
+// blocksText returns the combined text of all blocks
+func blocksText(blocks []fileBlock) string {
+	var buf strings.Builder
+	for _, block := range blocks {
+		buf.WriteString(block.text)
+	}
+	return buf.String()
+}
+
+// blocksSuffix returns up to the last n bytes of the blocks' combined text
+func blocksSuffix(blocks []fileBlock, n int) string {
+	var suffix string
+	for i := len(blocks) - 1; i >= 0 && len(suffix) < n; i-- {
+		suffix = blocks[i].text + suffix
+	}
+	if len(suffix) > n {
+		suffix = suffix[len(suffix)-n:]
+	}
+	return suffix
+}
+
+// endsBlock returns true if the blocks are empty or end with a blank line
+func endsBlock(blocks []fileBlock) bool {
+	suffix := blocksSuffix(blocks, 3)
+	return suffix == "" || strings.HasSuffix(suffix, "\n\n") || strings.HasSuffix(suffix, "\n\r\n")
+}
+
+// unapplyDirectives returns a copy of txn with its postings' accounts written as they were before applying 'apply account' prefixes and aliases
+func unapplyDirectives(txn Transaction, directives directiveState) Transaction {
+	if directives.applyAccount() == "" && len(directives.aliases) == 0 {
+		return txn
+	}
+	postings := make([]Posting, len(txn.Postings))
+	for i, p := range txn.Postings {
+		p.Account = directives.unapply(p.Account)
+		postings[i] = p
+	}
+	txn.Postings = postings
+	return txn
+}
+
+// trimLeadingBlankLines removes any blank lines from the start of text
+func trimLeadingBlankLines(text string) string {
+	for {
+		line := text
+		if i := strings.IndexByte(text, '\n'); i != -1 {
+			line = text[:i+1]
+		}
+		if line == "" || strings.TrimSpace(line) != "" {
+			return text
+		}
+		text = text[len(line):]
+	}
+}
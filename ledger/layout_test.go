@@ -0,0 +1,217 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const handEditedLedger = `; Personal journal, edited by hand and with hledger
+# another comment style
+
+account assets:Bank 1
+    ; type: A
+commodity $1,000.00
+decimal-mark .
+
+comment
+2019/01/01 not a transaction
+    inside a comment block
+end comment
+
+2019/01/02 some burger place ; id: A
+	expenses:food      $ 1.25 ; id: B
+	; a comment line inside the txn
+	assets:Bank 1 ; id: C
+
+2019/01/05 coffee ; id: D
+    expenses:food        $ 3 ; id: E
+    assets:Bank 1  ; id: F
+; trailing comment`
+
+func TestLedgerStringRoundTrip(t *testing.T) {
+	t.Run("unchanged", func(t *testing.T) {
+		ldg, err := NewFromReader(bytes.NewBufferString(handEditedLedger))
+		require.NoError(t, err)
+		assert.Len(t, ldg.transactions, 2)
+		assert.Equal(t, handEditedLedger, ldg.String())
+	})
+
+	t.Run("crlf line endings", func(t *testing.T) {
+		input := "; comment\r\n\r\n2019/01/02 some burger place\r\n\texpenses:food  $ 1.25\r\n\tassets:Bank 1\r\n"
+		ldg, err := NewFromReader(bytes.NewBufferString(input))
+		require.NoError(t, err)
+		assert.Equal(t, input, ldg.String())
+	})
+
+	t.Run("update and add transactions", func(t *testing.T) {
+		ldg, err := NewFromReader(bytes.NewBufferString(handEditedLedger))
+		require.NoError(t, err)
+		require.NoError(t, ldg.UpdateTransaction("D", Transaction{Comment: "updated"}))
+		require.NoError(t, ldg.AddTransactions([]Transaction{
+			{
+				Date:  parseDate(t, "2019/01/03"),
+				Payee: "between",
+				Postings: []Posting{
+					{Account: "expenses:food", Amount: *decFloat(2), Currency: usd},
+					{Account: "assets:Bank 1", Amount: *decFloat(-2), Currency: usd},
+				},
+			},
+			{
+				Date:  parseDate(t, "2019/01/10"),
+				Payee: "after",
+				Postings: []Posting{
+					{Account: "expenses:food", Amount: *decFloat(4), Currency: usd},
+					{Account: "assets:Bank 1", Amount: *decFloat(-4), Currency: usd},
+				},
+			},
+		}))
+
+		assert.Equal(t, `; Personal journal, edited by hand and with hledger
+# another comment style
+
+account assets:Bank 1
+    ; type: A
+commodity $1,000.00
+decimal-mark .
+
+comment
+2019/01/01 not a transaction
+    inside a comment block
+end comment
+
+2019/01/02 some burger place ; id: A
+	expenses:food      $ 1.25 ; id: B
+	; a comment line inside the txn
+	assets:Bank 1 ; id: C
+
+2019/01/03 between
+    expenses:food   $ 2
+    assets:Bank 1  $ -2
+
+2019/01/05 coffee ; updated id: D
+    expenses:food   $ 3 ; id: E
+    assets:Bank 1  $ -3 ; id: F
+; trailing comment
+
+2019/01/10 after
+    expenses:food   $ 4
+    assets:Bank 1  $ -4
+
+`, ldg.String())
+	})
+
+	t.Run("update transaction with comment lines", func(t *testing.T) {
+		ldg, err := NewFromReader(bytes.NewBufferString(handEditedLedger))
+		require.NoError(t, err)
+		require.NoError(t, ldg.UpdateTransaction("A", Transaction{Comment: "updated"}))
+		assert.Contains(t, ldg.String(), `
+2019/01/02 some burger place ; updated id: A
+    expenses:food   $ 1.25 ; id: B
+    ; a comment line inside the txn
+    assets:Bank 1  $ -1.25 ; id: C
+`)
+
+		ldg, err = NewFromReader(bytes.NewBufferString(`2019/01/02 coffee ; id: A
+    ; before postings
+    ;another line before postings
+    expenses:food   $ 3
+    assets:Bank 1
+        ; after the last posting
+`))
+		require.NoError(t, err)
+		require.NoError(t, ldg.UpdateTransaction("A", Transaction{Comment: "updated"}))
+		assert.Equal(t, `2019/01/02 coffee ; updated id: A
+    ; before postings
+    ; another line before postings
+    expenses:food   $ 3
+    assets:Bank 1  $ -3
+    ; after the last posting
+`, ldg.String())
+	})
+
+	t.Run("remove transactions", func(t *testing.T) {
+		ldg, err := NewFromReader(bytes.NewBufferString(handEditedLedger))
+		require.NoError(t, err)
+		require.NoError(t, ldg.RemoveTransaction("A"))
+		assert.Equal(t, `; Personal journal, edited by hand and with hledger
+# another comment style
+
+account assets:Bank 1
+    ; type: A
+commodity $1,000.00
+decimal-mark .
+
+comment
+2019/01/01 not a transaction
+    inside a comment block
+end comment
+
+2019/01/05 coffee ; id: D
+    expenses:food        $ 3 ; id: E
+    assets:Bank 1  ; id: F
+; trailing comment`, ldg.String())
+	})
+
+	t.Run("unknown indented line", func(t *testing.T) {
+		_, err := NewFromReader(bytes.NewBufferString("\n    assets:Bank 1  $ 1\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestLedgerTakeChangesAppend(t *testing.T) {
+	newTxn := func(date, payee string) Transaction {
+		return Transaction{
+			Date:  parseDate(t, date),
+			Payee: payee,
+			Postings: []Posting{
+				{Account: "expenses:food", Amount: *decFloat(2), Currency: usd},
+				{Account: "assets:Bank 1", Amount: *decFloat(-2), Currency: usd},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		description  string
+		txns         []Transaction
+		unwritten    bool
+		expectAppend bool
+	}{
+		{
+			description:  "new transactions at the end",
+			txns:         []Transaction{newTxn("2019/01/10", "after"), newTxn("2019/01/05", "same day")},
+			expectAppend: true,
+		},
+		{
+			description: "new transaction before the end",
+			txns:        []Transaction{newTxn("2019/01/10", "after"), newTxn("2019/01/03", "between")},
+		},
+		{
+			description: "previous write failed",
+			txns:        []Transaction{newTxn("2019/01/10", "after")},
+			unwritten:   true,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			ldg, err := NewFromReader(bytes.NewBufferString(handEditedLedger))
+			require.NoError(t, err)
+			require.NoError(t, ldg.AddTransactions(tc.txns))
+			if tc.unwritten {
+				ldg.markUnwritten(nil)
+			}
+			expected := ldg.String()
+
+			writes := ldg.takeChanges()
+			require.Len(t, writes, 1)
+			assert.Equal(t, tc.expectAppend, writes[0].appended)
+			if tc.expectAppend {
+				assert.Equal(t, expected, handEditedLedger+string(writes[0].contents))
+			} else {
+				assert.Equal(t, expected, string(writes[0].contents))
+			}
+			assert.Equal(t, expected, ldg.String())
+		})
+	}
+}
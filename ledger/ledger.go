@@ -3,11 +3,15 @@ package ledger
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/johnstarich/sage/vcs"
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
 )
@@ -17,7 +21,21 @@ import (
 type Ledger struct {
 	transactions Transactions
 	idSet        map[string]*Transaction
-	mu           sync.RWMutex
+	prices       []Price
+	// addedPrices are prices added after reading the ledger file
+	addedPrices []Price
+	// layout is the ledger file's original blocks, used to write it back without losing comments, directives, or formatting
+	layout []fileBlock
+	// txnFiles maps each transaction in the layout to the included file containing it, nil for the main file
+	txnFiles map[*Transaction]*includedFile
+	// changes are transactions added, modified, or removed since the ledger file was last written
+	changes map[*Transaction]txnChange
+	// unwritten are files which failed to write, nil for the main file
+	unwritten map[*includedFile]bool
+	// index is the free text search index of all transactions
+	index    searchIndex
+	accounts []AccountDeclaration
+	mu       sync.RWMutex
 }
 
 // New creates a ledger with the given transactions. Must not contain any duplicate IDs
@@ -27,21 +45,61 @@ func New(transactions []Transaction) (*Ledger, error) {
 	if len(duplicates) > 0 {
 		return nil, duplicateTransactionError(strings.Join(duplicates, ", "))
 	}
-	return &Ledger{
+	ldg := &Ledger{
 		transactions: transactionPtrs,
 		idSet:        idSet,
-	}, nil
+		// a new ledger hasn't been written yet
+		unwritten: map[*includedFile]bool{nil: true},
+	}
+	for _, txn := range transactionPtrs {
+		ldg.markChanged(txn)
+	}
+	return ldg, nil
 }
 
-// NewFromReader creates a ledger from the given "plain-text accounting" ledger-encoded reader
+// NewFromReader creates a ledger from the given "plain-text accounting" ledger-encoded reader.
+// Include directives are not supported, see NewFromFile.
 func NewFromReader(reader io.Reader) (*Ledger, error) {
-	var transactions []Transaction
 	scanner := bufio.NewScanner(reader)
-	transactions, err := readAllTransactions(scanner)
+	j, err := readAllTransactions(scanner)
 	if err != nil {
 		return nil, err
 	}
-	return New(transactions)
+	return newFromJournal(j)
+}
+
+// NewFromFile creates a ledger from the given "plain-text accounting" ledger file.
+// Include directives are resolved relative to the file's path.
+func NewFromFile(file vcs.File) (*Ledger, error) {
+	contents, err := file.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading ledger file")
+	}
+	var j journal
+	// the main file starts the include chain, so including it again is detected as a cycle
+	includeChain := []string{includeKey("", file.Path())}
+	j.blocks, err = j.readFile(bufio.NewScanner(bytes.NewReader(contents)), file, includeChain, directiveState{})
+	if err != nil {
+		return nil, err
+	}
+	return newFromJournal(j)
+}
+
+func newFromJournal(j journal) (*Ledger, error) {
+	ldg, err := New(j.transactions)
+	if err != nil {
+		return nil, err
+	}
+	ldg.txnFiles = make(map[*Transaction]*includedFile, len(ldg.transactions))
+	resolveTransactions(j.blocks, ldg.transactions, nil, ldg.txnFiles)
+	ldg.layout = j.blocks
+	ldg.changes = nil
+	ldg.unwritten = nil
+	ldg.accounts = j.accounts
+	ldg.prices = j.prices
+	sortPrices(ldg.prices)
+	ldg.transactions.Sort()
+	return ldg, nil
 }
 
 // makeTransactionPtrs converts to a slice of txn pointers. NOTE: does not copy the underlying txn
@@ -84,6 +142,14 @@ func makeIDSet(transactions []*Transaction) (idSet map[string]*Transaction, uniq
 				}
 			}
 		}
+		for _, id := range transaction.duplicateIDs() {
+			if idSet[id] != nil {
+				txnIsDupe = true
+				duplicates = append(duplicates, id)
+			} else {
+				idSet[id] = transaction
+			}
+		}
 		if !txnIsDupe {
 			uniqueTxns = append(uniqueTxns, transaction)
 		}
@@ -91,24 +157,36 @@ func makeIDSet(transactions []*Transaction) (idSet map[string]*Transaction, uniq
 	return
 }
 
+// String serializes the ledger into a "plain-text accounting" ledger file.
+// If the ledger was read from a file, unchanged regions are written exactly as they were read.
 func (l *Ledger) String() string {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
-	sortedTxns := make(Transactions, len(l.transactions))
-	copy(sortedTxns, l.transactions)
-	sortedTxns.Sort()
-	var buf bytes.Buffer
-	for _, txn := range sortedTxns {
-		buf.WriteString(txn.String())
-		buf.WriteRune('\n')
-	}
-	return buf.String()
+	return blocksText(l.renderMain())
+}
+
+// AccountDeclarations returns all accounts declared with account directives
+func (l *Ledger) AccountDeclarations() []AccountDeclaration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	accounts := make([]AccountDeclaration, len(l.accounts))
+	copy(accounts, l.accounts)
+	return accounts
 }
 
-// Validate returns a descriptive error should anything be wrong with the current ledger's transactions
+// Validate returns a descriptive error should anything be wrong with the current ledger's transactions, including failed balance assertions
 func (l *Ledger) Validate() error {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
+	if err := l.validateTransactions(); err != nil {
+		return err
+	}
+	return assertionsError(l.failedAssertions())
+}
+
+// validateTransactions validates each transaction individually
+// Assumes at least a read lock is held
+func (l *Ledger) validateTransactions() error {
 	for ix, txn := range l.transactions {
 		if err := txn.Validate(); err != nil {
 			return NewValidateError(ix, err)
@@ -117,11 +195,98 @@ func (l *Ledger) Validate() error {
 	return nil
 }
 
+type failedAssertion struct {
+	txnIndex int
+	txn      *Transaction
+	posting  int
+	err      AssertionError
+}
+
+type assertionKey struct {
+	txn     *Transaction
+	posting int
+}
+
+func (f failedAssertion) key() assertionKey {
+	return assertionKey{txn: f.txn, posting: f.posting}
+}
+
+// failedAssertions computes running balances for each account and commodity, then returns every balance assertion that doesn't match
+// Running balances start from each account's first posting. Virtual postings are skipped.
+// Assumes at least a read lock is held
+func (l *Ledger) failedAssertions() []failedAssertion {
+	var failures []failedAssertion
+	balances := make(map[string]map[string]decimal.Decimal)
+	for ix, txn := range l.transactions {
+		for postingIx, p := range txn.Postings {
+			if p.Kind.IsVirtual() {
+				continue
+			}
+			if balances[p.Account] == nil {
+				balances[p.Account] = make(map[string]decimal.Decimal)
+			}
+			balance := balances[p.Account][p.Currency].Add(p.Amount)
+			balances[p.Account][p.Currency] = balance
+			if p.Balance != nil && !p.Balance.Equal(balance) {
+				failures = append(failures, failedAssertion{
+					txnIndex: ix,
+					txn:      txn,
+					posting:  postingIx,
+					err: AssertionError{
+						Account:     p.Account,
+						Currency:    p.Currency,
+						Expected:    *p.Balance,
+						Actual:      balance,
+						Transaction: *txn,
+					},
+				})
+			}
+		}
+	}
+	return failures
+}
+
+// failedAssertionKeys returns the keys of all failed balance assertions, used to find new failures after a change with newAssertionsErrorSince
+// Assumes at least a read lock is held
+func (l *Ledger) failedAssertionKeys() map[assertionKey]bool {
+	keys := make(map[assertionKey]bool)
+	for _, failure := range l.failedAssertions() {
+		keys[failure.key()] = true
+	}
+	return keys
+}
+
+// newAssertionsErrorSince returns an Error for the failed balance assertions which aren't in 'previousFailures'
+// Assumes at least a read lock is held
+func (l *Ledger) newAssertionsErrorSince(previousFailures map[assertionKey]bool) error {
+	var newFailures []failedAssertion
+	for _, failure := range l.failedAssertions() {
+		if !previousFailures[failure.key()] {
+			newFailures = append(newFailures, failure)
+		}
+	}
+	return assertionsError(newFailures)
+}
+
+func assertionsError(failures []failedAssertion) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	assertions := make([]AssertionError, 0, len(failures))
+	for _, failure := range failures {
+		assertions = append(assertions, failure.err)
+	}
+	return newAssertionsError(failures[0].txnIndex, assertions)
+}
+
 func duplicateTransactionError(id string) error {
 	return errors.Errorf("Duplicate transaction IDs found: %s", id)
 }
 
+// Transaction returns a copy of the transaction with 'id', if found
 func (l *Ledger) Transaction(id string) (txn Transaction, found bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 	txnPtr, found := l.idSet[id]
 	if found {
 		return *txnPtr, found
@@ -129,6 +294,19 @@ func (l *Ledger) Transaction(id string) (txn Transaction, found bool) {
 	return Transaction{}, found
 }
 
+// TransactionsBetween returns copies of all transactions dated between start and end, inclusive
+func (l *Ledger) TransactionsBetween(start, end time.Time) []Transaction {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var txns []Transaction
+	for _, txn := range l.transactions {
+		if !txn.Date.Before(start) && !txn.Date.After(end) {
+			txns = append(txns, *txn)
+		}
+	}
+	return txns
+}
+
 // FirstTransactionTime returns the first transaction's Date field. Returns 0 if there are no transactions
 func (l *Ledger) FirstTransactionTime() time.Time {
 	l.mu.RLock()
@@ -152,11 +330,17 @@ func (l *Ledger) LastTransactionTime() time.Time {
 }
 
 // AddTransactions attempts to add the provided transactions.
-// Returns an error if the ledger fails validation (i.e. fail balance assertions).
+// Returns an error if the ledger fails validation.
 // In the event of an error, attempts to add all valid transactions up to the error.
+// Transactions are still added if they cause balance assertions to fail, but the new failures are returned in an Error.
 func (l *Ledger) AddTransactions(txns []Transaction) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	return l.addTransactions(txns)
+}
+
+// addTransactions adds txns, assumes the write lock is held
+func (l *Ledger) addTransactions(txns []Transaction) error {
 	transactionPtrs := makeTransactionPtrs(txns)
 	for i := range transactionPtrs {
 		transactionPtrs[i].Date = transactionPtrs[i].Date.UTC()
@@ -167,7 +351,7 @@ func (l *Ledger) AddTransactions(txns []Transaction) error {
 		idSet:        idSet,
 		transactions: newTransactions,
 	}
-	err := testLedger.Validate()
+	err := testLedger.validateTransactions()
 	if err != nil {
 		validateErr, ok := err.(Error)
 		if ok && validateErr.firstFailedTxnIndex >= len(l.transactions) {
@@ -178,9 +362,117 @@ func (l *Ledger) AddTransactions(txns []Transaction) error {
 			return err
 		}
 	}
+
+	previousFailures := l.failedAssertionKeys()
 	l.idSet = idSet
 	l.transactions = newTransactions
-	return err
+	added := make(map[*Transaction]bool, len(transactionPtrs))
+	for _, txn := range transactionPtrs {
+		added[txn] = true
+	}
+	for _, txn := range l.transactions {
+		if added[txn] {
+			l.markChanged(txn)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// only report balance assertions which failed because of the new txns
+	return l.newAssertionsErrorSince(previousFailures)
+}
+
+// CreateTransaction adds a new, manually entered transaction and returns its ID.
+// If the first posting doesn't have an ID, a stable one is generated from the transaction's contents and saved in its tags.
+func (l *Ledger) CreateTransaction(txn Transaction) (string, error) {
+	if err := txn.Validate(); err != nil {
+		return "", NewValidateError(0, err)
+	}
+	if txn.Date.IsZero() {
+		return "", NewValidateError(0, errors.New("Transaction date must be set"))
+	}
+	if isOpeningTransaction(txn) {
+		return "", NewValidateError(0, errors.New("Create opening balances with /api/v1/updateOpeningBalance"))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := txn.Postings[0].ID()
+	if id == "" {
+		id = makeManualID(txn, func(id string) bool { return l.idSet[id] != nil })
+	} else if l.idSet[id] != nil {
+		return "", NewValidateError(0, duplicateTransactionError(id))
+	}
+
+	// copy postings and tags to avoid modifying the caller's transaction
+	postings := make([]Posting, len(txn.Postings))
+	copy(postings, txn.Postings)
+	firstTags := make(map[string]string, len(postings[0].Tags)+1)
+	for key, value := range postings[0].Tags {
+		firstTags[key] = value
+	}
+	firstTags[idTag] = id
+	postings[0].Tags = firstTags
+	txn.Postings = postings
+
+	if err := l.addTransactions([]Transaction{txn}); err != nil {
+		if l.idSet[id] == nil {
+			return "", err
+		}
+		// the transaction was added, but caused a balance assertion to fail
+		return id, err
+	}
+	return id, nil
+}
+
+// makeManualID generates an ID for a manually entered transaction from its date, payee, and postings.
+// The same transaction always generates the same ID, unless 'exists' reports it is already taken.
+func makeManualID(txn Transaction, exists func(id string) bool) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n", txn.Date.UTC().Format(DateFormat), txn.Payee)
+	for _, p := range txn.Postings {
+		fmt.Fprintf(hash, "%s\n%s\n%s\n", p.Account, p.Amount.String(), p.Currency)
+	}
+	baseID := manualIDPrefix + hex.EncodeToString(hash.Sum(nil))[:16]
+	id := baseID
+	for i := 2; exists(id); i++ {
+		id = fmt.Sprintf("%s-%d", baseID, i)
+	}
+	return id
+}
+
+// RemoveTransaction removes the transaction where ID is 'id'
+// Returns an Error if removing the transaction causes balance assertions to fail, but the transaction is still removed.
+func (l *Ledger) RemoveTransaction(id string) error {
+	if id == OpeningBalanceID {
+		return NewValidateError(0, errors.New("Opening balances can't be removed, update them with /api/v1/updateOpeningBalance"))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	existingTxn := l.idSet[id]
+	if existingTxn == nil {
+		return errors.New("Transaction not found by ID: " + id)
+	}
+	if existingTxn.Reconciled() {
+		return NewValidateError(0, errors.New("Transaction is reconciled and must be unlocked before removing: "+id))
+	}
+
+	previousFailures := l.failedAssertionKeys()
+
+	for ix, txn := range l.transactions {
+		if txn == existingTxn {
+			l.transactions = append(l.transactions[:ix:ix], l.transactions[ix+1:]...)
+			break
+		}
+	}
+	for txnID, txn := range l.idSet {
+		if txn == existingTxn {
+			delete(l.idSet, txnID)
+		}
+	}
+	l.markRemoved(existingTxn)
+	return l.newAssertionsErrorSince(previousFailures)
 }
 
 // RenameAccount replaces 'oldName' prefixes with a 'newName' prefix
@@ -220,22 +512,115 @@ func (l *Ledger) RenameAccount(oldName, newName, oldID, newID string) int {
 	}
 
 	for _, txn := range l.transactions {
+		previousCount := count
 		for posting := range txn.Postings {
 			postingTransform(&txn.Postings[posting])
 		}
+		if count != previousCount {
+			l.markChanged(txn)
+		}
 	}
 	return count
 }
 
-// Balances returns a cumulative balance sheet for all accounts over the given time period.
-// Current interval is monthly.
-func (l *Ledger) Balances() (start, end *time.Time, balances map[string][]decimal.Decimal) {
+// BalanceOptions customizes which postings are included in balances and the series returned
+type BalanceOptions struct {
+	// Virtual includes virtual and balanced virtual postings. Only real postings are included by default.
+	Virtual bool
+	// Interval is the length of time for each balance in a series. Defaults to monthly.
+	Interval Interval
+	// Change returns each interval's change in balance, rather than the cumulative balance at the end of each interval
+	Change bool
+	// Start and End limit a series to the intervals containing them. Default to the first and last transaction dates.
+	// Cumulative balances still include all postings before Start.
+	Start, End time.Time
+}
+
+func (o BalanceOptions) includes(p Posting) bool {
+	return o.Virtual || !p.Kind.IsVirtual()
+}
+
+func (o BalanceOptions) interval() Interval {
+	if o.Interval == "" {
+		return IntervalMonth
+	}
+	return o.Interval
+}
+
+// Balances returns a balance sheet series for all accounts over the given time period.
+// Amounts of all commodities are summed together, see BalancesByCommodity to separate them.
+// Series are cumulative and monthly by default, see BalanceOptions.
+func (l *Ledger) Balances(options BalanceOptions) (start, end *time.Time, balances map[string][]decimal.Decimal) {
+	start, end, commodityBalances := l.BalancesByCommodity(options)
+	if commodityBalances == nil {
+		return
+	}
+	balances = make(map[string][]decimal.Decimal, len(commodityBalances))
+	for account, commodities := range commodityBalances {
+		balances[account] = SumCommodities(commodities)
+	}
+	return
+}
+
+// BalancesValuedIn returns a balance sheet series for all accounts over the given time period.
+// All commodities are converted into 'valueIn' using market prices at the end of each interval.
+// Series are cumulative and monthly by default, see BalanceOptions.
+func (l *Ledger) BalancesValuedIn(valueIn string, options BalanceOptions) (start, end *time.Time, balances map[string][]decimal.Decimal, err error) {
+	start, end, commodityBalances := l.BalancesByCommodity(options)
+	if commodityBalances == nil {
+		return
+	}
+	interval := options.interval()
+	firstInterval := interval.Start(*start)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	balances = make(map[string][]decimal.Decimal, len(commodityBalances))
+	for account, commodities := range commodityBalances {
+		var values []decimal.Decimal
+		for _, series := range commodities {
+			values = make([]decimal.Decimal, len(series))
+			break
+		}
+		amounts := make(map[string]decimal.Decimal, len(commodities))
+		for i := range values {
+			for commodity, series := range commodities {
+				amounts[commodity] = series[i]
+			}
+			intervalEnd := interval.Add(firstInterval, i+1).AddDate(0, 0, -1)
+			values[i], err = l.value(amounts, valueIn, intervalEnd)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		balances[account] = values
+	}
+	return
+}
+
+// SumCommodities adds together each commodity's balances at every interval
+func SumCommodities(commodityBalances map[string][]decimal.Decimal) []decimal.Decimal {
+	var sums []decimal.Decimal
+	for _, amounts := range commodityBalances {
+		if sums == nil {
+			sums = make([]decimal.Decimal, len(amounts))
+		}
+		for i := range amounts {
+			sums[i] = sums[i].Add(amounts[i])
+		}
+	}
+	return sums
+}
+
+// BalancesByCommodity returns a balance sheet series for all accounts over the given time period, separated by commodity.
+// The result maps account names to commodities to balances at each interval.
+// Series are cumulative and monthly by default, see BalanceOptions.
+func (l *Ledger) BalancesByCommodity(options BalanceOptions) (start, end *time.Time, balances map[string]map[string][]decimal.Decimal) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 	if len(l.transactions) == 0 {
 		return
 	}
-	balances = make(map[string][]decimal.Decimal)
+	balances = make(map[string]map[string][]decimal.Decimal)
 	start, end = timePtr(l.transactions[0].Date), timePtr(l.transactions[0].Date)
 	for _, txn := range l.transactions {
 		if txn.Date.Before(*start) {
@@ -245,28 +630,54 @@ func (l *Ledger) Balances() (start, end *time.Time, balances map[string][]decima
 			end = timePtr(txn.Date)
 		}
 	}
+	if !options.Start.IsZero() {
+		start = timePtr(options.Start)
+	}
+	if !options.End.IsZero() {
+		end = timePtr(options.End)
+	}
 
-	getMonthNum := func(t time.Time) int {
-		return int(t.Month()) - 1 + 12*t.Year()
+	interval := options.interval()
+	intervals := interval.index(*start, *end) + 1
+	if intervals < 1 {
+		return
 	}
-	startMonthNum := getMonthNum(*start)
-	intervals := getMonthNum(*end) - startMonthNum + 1
 
 	for _, txn := range l.transactions {
-		index := getMonthNum(txn.Date) - startMonthNum
+		if txn.Date.After(*end) {
+			continue
+		}
+		index := interval.index(*start, txn.Date)
+		if index < 0 {
+			if options.Change {
+				continue
+			}
+			// carry earlier postings into the first cumulative balance
+			index = 0
+		}
 		for _, p := range txn.Postings {
+			if !options.includes(p) {
+				continue
+			}
 			if _, ok := balances[p.Account]; !ok {
-				balances[p.Account] = make([]decimal.Decimal, intervals)
+				balances[p.Account] = make(map[string][]decimal.Decimal)
+			}
+			if _, ok := balances[p.Account][p.Currency]; !ok {
+				balances[p.Account][p.Currency] = make([]decimal.Decimal, intervals)
 			}
-			balances[p.Account][index] = balances[p.Account][index].Add(p.Amount)
+			balances[p.Account][p.Currency][index] = balances[p.Account][p.Currency][index].Add(p.Amount)
 		}
 	}
 
-	// convert to cumulative sum
-	for _, amounts := range balances {
-		for i := range amounts {
-			if i != 0 {
-				amounts[i] = amounts[i].Add(amounts[i-1])
+	if !options.Change {
+		// convert to cumulative sum
+		for _, commodities := range balances {
+			for _, amounts := range commodities {
+				for i := range amounts {
+					if i != 0 {
+						amounts[i] = amounts[i].Add(amounts[i-1])
+					}
+				}
 			}
 		}
 	}
@@ -277,26 +688,48 @@ func timePtr(t time.Time) *time.Time {
 	return &t
 }
 
-// AccountBalance returns the cumulative sum of all postings for 'account' between start and end times
-func (l *Ledger) AccountBalance(account string, start, end time.Time) decimal.Decimal {
+// AccountBalance returns the cumulative sum of all postings for 'account' between start and end times.
+// Amounts of all commodities are summed together, see AccountBalanceByCommodity to separate them.
+func (l *Ledger) AccountBalance(account string, start, end time.Time, options BalanceOptions) decimal.Decimal {
+	var sum decimal.Decimal
+	for _, amount := range l.AccountBalanceByCommodity(account, start, end, options) {
+		sum = sum.Add(amount)
+	}
+	return sum
+}
+
+// AccountBalanceByCommodity returns the cumulative sum of all postings for 'account' between start and end times for each commodity
+func (l *Ledger) AccountBalanceByCommodity(account string, start, end time.Time, options BalanceOptions) map[string]decimal.Decimal {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
-	var sum decimal.Decimal
+	sums := make(map[string]decimal.Decimal)
 	account = strings.ToLower(account)
 	for _, txn := range l.transactions {
 		if !txn.Date.Before(start) && !txn.Date.After(end) {
 			for _, p := range txn.Postings {
-				if strings.HasPrefix(p.Account, account) {
-					sum = sum.Add(p.Amount)
+				if options.includes(p) && strings.HasPrefix(p.Account, account) {
+					sums[p.Currency] = sums[p.Currency].Add(p.Amount)
 				}
 			}
 		}
 	}
-	return sum
+	return sums
 }
 
-// LeftOverAccountBalances retrieves balances for any accounts or account prefixes not found in 'accounts' between start and end times
+// LeftOverAccountBalances retrieves balances for any accounts or account prefixes not found in 'accounts' between start and end times. Virtual postings are excluded.
+// Amounts of all commodities are summed together, see LeftOverAccountBalancesByCommodity to separate them.
 func (l *Ledger) LeftOverAccountBalances(start, end time.Time, accounts ...string) map[string]decimal.Decimal {
+	leftOver := make(map[string]decimal.Decimal)
+	for account, amounts := range l.LeftOverAccountBalancesByCommodity(start, end, accounts...) {
+		for _, amount := range amounts {
+			leftOver[account] = leftOver[account].Add(amount)
+		}
+	}
+	return leftOver
+}
+
+// LeftOverAccountBalancesByCommodity retrieves balances for each commodity of any accounts or account prefixes not found in 'accounts' between start and end times. Virtual postings are excluded.
+func (l *Ledger) LeftOverAccountBalancesByCommodity(start, end time.Time, accounts ...string) map[string]map[string]decimal.Decimal {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 	accountEntries := make([][]string, 0, len(accounts))
@@ -305,13 +738,19 @@ func (l *Ledger) LeftOverAccountBalances(start, end time.Time, accounts ...strin
 	}
 	lookup := newAccountNode(accountEntries)
 
-	leftOver := make(map[string]decimal.Decimal)
+	leftOver := make(map[string]map[string]decimal.Decimal)
 	for _, txn := range l.transactions {
 		if !txn.Date.Before(start) && !txn.Date.After(end) {
 			for _, p := range txn.Postings {
+				if p.Kind.IsVirtual() {
+					continue
+				}
 				lowerAccount := strings.ToLower(p.Account)
 				if !lookup.HasPrefixTo(strings.Split(lowerAccount, ":")) {
-					leftOver[lowerAccount] = leftOver[lowerAccount].Add(p.Amount)
+					if leftOver[lowerAccount] == nil {
+						leftOver[lowerAccount] = make(map[string]decimal.Decimal)
+					}
+					leftOver[lowerAccount][p.Currency] = leftOver[lowerAccount][p.Currency].Add(p.Amount)
 				}
 			}
 		}
@@ -320,21 +759,26 @@ func (l *Ledger) LeftOverAccountBalances(start, end time.Time, accounts ...strin
 }
 
 // UpdateTransaction replaces a transaction where ID is 'id' with 'transaction'
-// The new transaction must be valid
+// The new transaction must be valid. Returns an Error if balance assertions fail, but the transaction is still updated.
 func (l *Ledger) UpdateTransaction(id string, transaction Transaction) error {
 	if id == OpeningBalanceID {
 		return NewValidateError(0, errors.New("Update opening balances with /api/v1/updateOpeningBalance"))
 	}
-	return l.updateTransaction(id, transaction)
+	return l.updateTransaction(id, transaction, false)
 }
 
-func (l *Ledger) updateTransaction(id string, transaction Transaction) error {
+// updateTransaction replaces the transaction with ID 'id'. Reconciled transactions are only updated if 'unlocked' is true.
+// Returns an Error if the update causes balance assertions to fail, but the transaction is still updated.
+func (l *Ledger) updateTransaction(id string, transaction Transaction, unlocked bool) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	existingTxn := l.idSet[id]
 	if existingTxn == nil {
 		return errors.New("Transaction not found by ID: " + id)
 	}
+	if !unlocked && existingTxn.Reconciled() {
+		return NewValidateError(0, errors.New("Transaction is reconciled and must be unlocked before updating: "+id))
+	}
 
 	txnCopy := *existingTxn
 	if !transaction.Date.IsZero() {
@@ -343,6 +787,16 @@ func (l *Ledger) updateTransaction(id string, transaction Transaction) error {
 	if transaction.Comment != "" {
 		txnCopy.Comment = transaction.Comment
 	}
+	if transaction.AuxDate != nil {
+		auxDate := transaction.AuxDate.UTC()
+		txnCopy.AuxDate = &auxDate
+	}
+	if transaction.Code != "" {
+		txnCopy.Code = transaction.Code
+	}
+	if transaction.Status != StatusUnmarked {
+		txnCopy.Status = transaction.Status
+	}
 	if len(transaction.Postings) > 0 {
 		if !isOpeningTransaction(transaction) {
 			var field string
@@ -367,8 +821,81 @@ func (l *Ledger) updateTransaction(id string, transaction Transaction) error {
 		return err
 	}
 
+	previousFailures := l.failedAssertionKeys()
 	*existingTxn = txnCopy
+	l.markChanged(existingTxn)
 	l.transactions.Sort()
+	return l.newAssertionsErrorSince(previousFailures)
+}
+
+// ReconcileTransactions marks the postings in 'account' or its sub-accounts of the transactions with the given IDs as cleared and reconciled against the statement dated 'statementDate'
+// Postings in other accounts are unchanged, so reconciling one side of a transfer doesn't reconcile the other.
+// No transactions are changed if any ID is not found or has no postings in 'account'.
+func (l *Ledger) ReconcileTransactions(account string, ids []string, statementDate time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inAccount := func(p Posting) bool {
+		return !p.Kind.IsVirtual() && (p.Account == account || strings.HasPrefix(p.Account, account+":"))
+	}
+	txns := make([]*Transaction, 0, len(ids))
+	for _, id := range ids {
+		txn := l.idSet[id]
+		if txn == nil {
+			return errors.New("Transaction not found by ID: " + id)
+		}
+		found := false
+		for _, p := range txn.Postings {
+			found = found || inAccount(p)
+		}
+		if !found {
+			return errors.Errorf("Transaction %s has no postings in account %q", id, account)
+		}
+		txns = append(txns, txn)
+	}
+	for _, txn := range txns {
+		txn.Postings = append([]Posting(nil), txn.Postings...)
+		for i, p := range txn.Postings {
+			if !inAccount(p) {
+				continue
+			}
+			tags := make(map[string]string, len(p.Tags)+1)
+			for key, value := range p.Tags {
+				tags[key] = value
+			}
+			tags[reconciledTag] = statementDate.Format(DateFormat)
+			txn.Postings[i].Tags = tags
+			txn.Postings[i].Status = StatusCleared
+		}
+		l.markChanged(txn)
+	}
+	return nil
+}
+
+// UnlockTransaction removes the reconciled mark from a transaction, allowing it to be updated again
+func (l *Ledger) UnlockTransaction(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	txn := l.idSet[id]
+	if txn == nil {
+		return errors.New("Transaction not found by ID: " + id)
+	}
+	if !txn.Reconciled() {
+		return nil
+	}
+	txn.Postings = append([]Posting(nil), txn.Postings...)
+	for i, p := range txn.Postings {
+		if !p.Reconciled() {
+			continue
+		}
+		tags := make(map[string]string, len(p.Tags))
+		for key, value := range p.Tags {
+			if key != reconciledTag {
+				tags[key] = value
+			}
+		}
+		txn.Postings[i].Tags = tags
+	}
+	l.markChanged(txn)
 	return nil
 }
 
@@ -379,10 +906,11 @@ func (l *Ledger) UpdateAccount(oldAccount, newAccount string) error {
 	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	for t := range l.transactions {
-		for p := range l.transactions[t].Postings {
-			if l.transactions[t].Postings[p].Account == oldAccount {
-				l.transactions[t].Postings[p].Account = newAccount
+	for _, txn := range l.transactions {
+		for p := range txn.Postings {
+			if txn.Postings[p].Account == oldAccount {
+				txn.Postings[p].Account = newAccount
+				l.markChanged(txn)
 			}
 		}
 	}
@@ -417,14 +945,15 @@ func (l *Ledger) UpdateOpeningBalance(opening Transaction) error {
 	// only allow some fields in the update for now
 	newOpening := Transaction{
 		Date:     opening.Date,
-		Payee:    "* Opening Balance",
+		Payee:    "Opening Balance",
+		Status:   StatusCleared,
 		Postings: opening.Postings,
 	}
 
 	if l.idSet[OpeningBalanceID] == nil {
 		return l.AddTransactions([]Transaction{newOpening})
 	}
-	return l.updateTransaction(OpeningBalanceID, newOpening)
+	return l.updateTransaction(OpeningBalanceID, newOpening, true)
 }
 
 func isOpeningTransaction(txn Transaction) bool {
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -117,10 +118,8 @@ func TestNewFromReader(t *testing.T) {
 				},
 			},
 		}
-		assert.Equal(t, &Ledger{
-			transactions: Transactions{txn},
-			idSet:        map[string]*Transaction{"A": txn, "B": txn, "C": txn},
-		}, ldg)
+		assert.Equal(t, Transactions{txn}, ldg.transactions)
+		assert.Equal(t, map[string]*Transaction{"A": txn, "B": txn, "C": txn}, ldg.idSet)
 	})
 
 	t.Run("bad transaction", func(t *testing.T) {
@@ -210,6 +209,63 @@ func TestLedgerValidate(t *testing.T) {
 			},
 			expectedErr: "Transaction is not balanced - postings do not sum to zero:",
 		},
+		{
+			description: "balance assertions",
+			txns: []Transaction{
+				{Postings: []Posting{
+					{Account: "account 1", Amount: *decFloat(1), Balance: decFloat(1), Currency: usd},
+					{Account: "account 1", Amount: *decFloat(2), Balance: decFloat(2), Currency: "EUR"},
+					{Account: "equity", Amount: *decFloat(-1), Currency: usd},
+					{Account: "equity", Amount: *decFloat(-2), Currency: "EUR"},
+				}},
+				{Postings: []Posting{
+					{Account: "account 1", Amount: *decFloat(-0.5), Balance: decFloat(0.5), Currency: usd},
+					{Account: "expenses", Amount: *decFloat(0.5), Currency: usd},
+				}},
+			},
+		},
+		{
+			description: "failed balance assertion",
+			txns: []Transaction{
+				{Tags: makeIDTag(OpeningBalanceID), Postings: []Posting{
+					{Account: "account 1", Amount: *decFloat(1), Currency: usd},
+					{Account: "equity", Amount: *decFloat(-1), Currency: usd},
+				}},
+				{Date: parseDate(t, "2020/01/02"), Payee: "some payee", Postings: []Posting{
+					{Account: "account 1", Amount: *decFloat(-0.5), Balance: decFloat(1), Currency: usd},
+					{Account: "expenses", Amount: *decFloat(0.5), Currency: usd},
+				}},
+			},
+			expectedErr: `Failed to validate ledger at transaction index #1: Balance assertion failed for account "account 1" in transaction 2020/01/02 "some payee": expected $ 1, actual $ 0.5`,
+		},
+		{
+			description: "balance assertions without an opening balance",
+			txns: []Transaction{
+				{Tags: makeIDTag(OpeningBalanceID), Postings: []Posting{
+					{Account: "account 1", Amount: *decFloat(1), Currency: usd},
+					{Account: "equity", Amount: *decFloat(-1), Currency: usd},
+				}},
+				{Date: parseDate(t, "2020/01/02"), Postings: []Posting{
+					{Account: "account 2", Amount: *decFloat(-0.5), Balance: decFloat(100), Currency: usd},
+					{Account: "expenses", Amount: *decFloat(0.5), Currency: usd},
+				}},
+			},
+			expectedErr: `Failed to validate ledger at transaction index #1: Balance assertion failed for account "account 2" in transaction 2020/01/02 "": expected $ 100, actual $ -0.5`,
+		},
+		{
+			description: "skip virtual postings in balance assertions",
+			txns: []Transaction{
+				{Tags: makeIDTag(OpeningBalanceID), Postings: []Posting{
+					{Account: "account 1", Amount: *decFloat(1), Currency: usd},
+					{Account: "equity", Amount: *decFloat(-1), Currency: usd},
+				}},
+				{Date: parseDate(t, "2020/01/02"), Postings: []Posting{
+					{Account: "account 1", Amount: *decFloat(-0.5), Balance: decFloat(0.5), Currency: usd},
+					{Account: "expenses", Amount: *decFloat(0.5), Currency: usd},
+					{Account: "account 1", Amount: *decFloat(5), Balance: decFloat(100), Currency: usd, Kind: VirtualPosting},
+				}},
+			},
+		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
 			ldg, ldgErr := New(tc.txns)
@@ -225,6 +281,41 @@ func TestLedgerValidate(t *testing.T) {
 	}
 }
 
+func TestLedgerValidateAssertionError(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{Tags: makeIDTag(OpeningBalanceID), Postings: []Posting{
+			{Account: "account 1", Amount: *decFloat(1), Balance: decFloat(2), Currency: usd},
+			{Account: "equity", Amount: *decFloat(-1), Balance: decFloat(0), Currency: usd},
+		}},
+	})
+	require.NoError(t, err)
+	err = ldg.Validate()
+	require.IsType(t, Error{}, err)
+	validateErr := err.(Error)
+	require.Len(t, validateErr.Assertions, 2)
+	assertion := validateErr.Assertions[0]
+	assert.Equal(t, "account 1", assertion.Account)
+	assert.Equal(t, usd, assertion.Currency)
+	assert.Equal(t, "2", assertion.Expected.String())
+	assert.Equal(t, "1", assertion.Actual.String())
+	assert.Equal(t, ldg.transactions[0].Postings, assertion.Transaction.Postings)
+	assert.Contains(t, err.Error(), "(and 1 more failed balance assertions)")
+}
+
+func TestTransactionsBetween(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{Payee: "before", Date: parseDate(t, "2020/01/01")},
+		{Payee: "start", Date: parseDate(t, "2020/01/02")},
+		{Payee: "end", Date: parseDate(t, "2020/01/03")},
+		{Payee: "after", Date: parseDate(t, "2020/01/04")},
+	})
+	require.NoError(t, err)
+	txns := ldg.TransactionsBetween(parseDate(t, "2020/01/02"), parseDate(t, "2020/01/03"))
+	require.Len(t, txns, 2)
+	assert.Equal(t, "start", txns[0].Payee)
+	assert.Equal(t, "end", txns[1].Payee)
+}
+
 func TestFirstTransactionTime(t *testing.T) {
 	end := time.Now()
 	start := end.Add(-1 * time.Hour)
@@ -262,6 +353,19 @@ func TestAddTransactions(t *testing.T) {
 	txn1 := &Transaction{Payee: "woot woot", Postings: somePostings, Tags: makeIDTag("a")}
 	txn2 := &Transaction{Payee: "the dough", Postings: somePostings, Tags: makeIDTag("b")}
 	brokenTxn := &Transaction{Payee: "broken transaction", Postings: nil, Tags: makeIDTag("c")}
+	makeAssertedTxn := func(date string, amount, balance float64, id string) *Transaction {
+		return &Transaction{
+			Date: parseDate(t, date),
+			Postings: []Posting{
+				{Account: "some bank", Amount: *decFloat(amount), Balance: decFloat(balance), Currency: usd},
+				{Account: "some business", Amount: *decFloat(-amount), Currency: usd},
+			},
+			Tags: makeIDTag(id),
+		}
+	}
+	assertedTxn1 := makeAssertedTxn("2020/01/01", 1, 1, OpeningBalanceID)
+	failedAssertionTxn := makeAssertedTxn("2020/01/02", 1, 5, "e")
+	assertedTxn2 := makeAssertedTxn("2020/01/03", 1, 3, "f")
 	for _, tc := range []struct {
 		description  string
 		txns         Transactions
@@ -301,6 +405,19 @@ func TestAddTransactions(t *testing.T) {
 			expectedTxns: Transactions{txn1, txn2},
 			expectedErr:  true,
 		},
+		{
+			description:  "add txns despite failed balance assertions",
+			txns:         Transactions{assertedTxn1},
+			newTxns:      Transactions{failedAssertionTxn},
+			expectedTxns: Transactions{assertedTxn1, failedAssertionTxn},
+			expectedErr:  true,
+		},
+		{
+			description:  "only report new failed balance assertions",
+			txns:         Transactions{assertedTxn1, failedAssertionTxn},
+			newTxns:      Transactions{assertedTxn2},
+			expectedTxns: Transactions{assertedTxn1, failedAssertionTxn, assertedTxn2},
+		},
 		{
 			description:  "no validate error if txns started invalid",
 			txns:         Transactions{brokenTxn},
@@ -338,7 +455,8 @@ func TestUpdateOpeningBalance(t *testing.T) {
 	makeOpeningTxn := func(date string, postings ...Posting) *Transaction {
 		return &Transaction{
 			Date:     parseDate(t, date),
-			Payee:    "* Opening Balance",
+			Payee:    "Opening Balance",
+			Status:   StatusCleared,
 			Postings: postings,
 		}
 	}
@@ -569,6 +687,155 @@ func TestUpdateTransaction(t *testing.T) {
 	}
 }
 
+func TestCreateTransaction(t *testing.T) {
+	ldg, err := New(nil)
+	require.NoError(t, err)
+	txn := Transaction{
+		Date:  parseDate(t, "2019/01/02"),
+		Payee: "cash purchase",
+		Postings: []Posting{
+			{Account: "expenses:food", Amount: *decFloat(1.25), Currency: usd},
+			{Account: "assets:cash", Amount: *decFloat(-1.25), Currency: usd},
+		},
+	}
+	id, err := ldg.CreateTransaction(txn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, manualIDPrefix))
+	assert.Nil(t, txn.Postings[0].Tags, "Caller's transaction must not be modified")
+	created, found := ldg.Transaction(id)
+	require.True(t, found)
+	assert.Equal(t, id, created.Postings[0].ID())
+	assert.Equal(t, id, makeManualID(txn, func(string) bool { return false }), "IDs must be stable")
+
+	sameID, err := ldg.CreateTransaction(txn)
+	require.NoError(t, err)
+	assert.Equal(t, id+"-2", sameID, "Identical transactions must receive a unique ID")
+	assert.Equal(t, 2, ldg.Size())
+
+	txn.Postings[0].Tags = makeIDTag("my-id")
+	id, err = ldg.CreateTransaction(txn)
+	require.NoError(t, err)
+	assert.Equal(t, "my-id", id)
+	_, err = ldg.CreateTransaction(txn)
+	assert.IsType(t, Error{}, err, "Duplicate IDs are a validation error")
+
+	_, err = ldg.CreateTransaction(Transaction{Date: txn.Date, Postings: txn.Postings[:1]})
+	assert.IsType(t, Error{}, err)
+	_, err = ldg.CreateTransaction(Transaction{Postings: txn.Postings})
+	assert.Error(t, err, "Date is required")
+}
+
+func TestRemoveTransaction(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{
+			Date: parseDate(t, "2019/01/01"),
+			Tags: makeIDTag(OpeningBalanceID),
+			Postings: []Posting{
+				{Account: "assets", Amount: *decFloat(1), Currency: usd},
+				{Account: "equity", Amount: *decFloat(-1), Currency: usd},
+			},
+		},
+		{
+			Date:  parseDate(t, "2019/01/02"),
+			Payee: "first",
+			Tags:  makeIDTag("txn-1"),
+			Postings: []Posting{
+				{Account: "assets", Amount: *decFloat(1.5), Currency: usd, Tags: makeIDTag("posting-1")},
+				{Account: "revenues", Amount: *decFloat(-1.5), Currency: usd},
+			},
+		},
+		{
+			Date:  parseDate(t, "2019/01/03"),
+			Payee: "second",
+			Postings: []Posting{
+				{Account: "assets", Amount: *decFloat(-1), Currency: usd, Balance: decFloat(1.5), Tags: makeIDTag("posting-2")},
+				{Account: "expenses", Amount: *decFloat(1), Currency: usd},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Error(t, ldg.RemoveTransaction("non-existent"))
+	assert.IsType(t, Error{}, ldg.RemoveTransaction(OpeningBalanceID))
+
+	err = ldg.RemoveTransaction("posting-1")
+	require.IsType(t, Error{}, err, "Removing the first txn should fail the second's balance assertion")
+	assert.Len(t, err.(Error).Assertions, 1)
+	assert.Equal(t, 2, ldg.Size(), "Transaction should still be removed")
+	assert.Nil(t, ldg.idSet["txn-1"])
+	assert.Nil(t, ldg.idSet["posting-1"])
+	assert.NotNil(t, ldg.idSet["posting-2"])
+
+	assert.NoError(t, ldg.RemoveTransaction("posting-2"))
+	assert.Equal(t, 1, ldg.Size())
+	assert.Len(t, ldg.idSet, 1)
+}
+
+func TestReconcileTransactions(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{
+			Date:  parseDate(t, "2019/01/02"),
+			Payee: "first",
+			Postings: []Posting{
+				{Account: "assets", Amount: *decFloat(1.5), Currency: usd, Tags: makeIDTag("posting-1")},
+				{Account: "revenues", Amount: *decFloat(-1.5), Currency: usd},
+			},
+		},
+		{
+			Date:  parseDate(t, "2019/01/03"),
+			Payee: "second",
+			Postings: []Posting{
+				{Account: "assets", Amount: *decFloat(-1), Currency: usd, Tags: makeIDTag("posting-2")},
+				{Account: "expenses", Amount: *decFloat(1), Currency: usd},
+			},
+		},
+		{
+			Date:  parseDate(t, "2019/01/04"),
+			Payee: "transfer",
+			Postings: []Posting{
+				{Account: "assets:checking", Amount: *decFloat(-5), Currency: usd, Tags: makeIDTag("posting-3")},
+				{Account: "liabilities:card", Amount: *decFloat(5), Currency: usd},
+			},
+		},
+	})
+	require.NoError(t, err)
+	statementDate := parseDate(t, "2019/01/31")
+
+	assert.EqualError(t, ldg.ReconcileTransactions("assets", []string{"posting-1", "non-existent"}, statementDate), "Transaction not found by ID: non-existent")
+	txn, _ := ldg.Transaction("posting-1")
+	assert.False(t, txn.Reconciled(), "No transactions should be reconciled if any are not found")
+	assert.EqualError(t, ldg.ReconcileTransactions("liabilities", []string{"posting-1"}, statementDate), `Transaction posting-1 has no postings in account "liabilities"`)
+
+	require.NoError(t, ldg.ReconcileTransactions("assets", []string{"posting-1"}, statementDate))
+	txn, _ = ldg.Transaction("posting-1")
+	assert.True(t, txn.Reconciled())
+	assert.Equal(t, StatusUnmarked, txn.Status)
+	assert.Equal(t, StatusCleared, txn.Postings[0].Status)
+	assert.Equal(t, "2019/01/31", txn.Postings[0].Tags[reconciledTag])
+	assert.False(t, txn.Postings[1].Reconciled())
+	assert.Contains(t, ldg.String(), "    * assets   $ 1.5 ; id: posting-1, reconciled: 2019/01/31\n    revenues  $ -1.5\n")
+
+	err = ldg.UpdateTransaction("posting-1", Transaction{Payee: "updated"})
+	assert.IsType(t, Error{}, err, "Reconciled transactions must not be updated")
+	err = ldg.RemoveTransaction("posting-1")
+	assert.IsType(t, Error{}, err, "Reconciled transactions must not be removed")
+	assert.NoError(t, ldg.UpdateTransaction("posting-2", Transaction{Comment: "updated"}))
+
+	require.NoError(t, ldg.ReconcileTransactions("assets", []string{"posting-3"}, statementDate))
+	txn, _ = ldg.Transaction("posting-3")
+	assert.True(t, txn.Postings[0].Reconciled())
+	assert.False(t, txn.Postings[1].Reconciled(), "Reconciling one side of a transfer should not reconcile the other account")
+	assert.Equal(t, StatusUnmarked, txn.Postings[1].Status)
+
+	assert.Error(t, ldg.UnlockTransaction("non-existent"))
+	require.NoError(t, ldg.UnlockTransaction("posting-1"))
+	txn, _ = ldg.Transaction("posting-1")
+	assert.False(t, txn.Reconciled())
+	assert.Equal(t, StatusCleared, txn.Postings[0].Status)
+	assert.NoError(t, ldg.UpdateTransaction("posting-1", Transaction{Comment: "updated"}))
+	assert.NoError(t, ldg.UnlockTransaction("posting-1"), "Unlocking an unreconciled transaction is a no-op")
+}
+
 func compareUpdate(t *testing.T, original, update Transaction) {
 	if update.Payee == "" {
 		original.Payee = ""
@@ -750,7 +1017,8 @@ func TestRenameAccount(t *testing.T) {
 			assert.Equal(t, tc.expectCount, count)
 			expectLdg, err := New(tc.expectTxns)
 			require.NoError(t, err)
-			assert.Equal(t, expectLdg, ldg)
+			assert.Equal(t, expectLdg.transactions, ldg.transactions)
+			assert.Equal(t, expectLdg.idSet, ldg.idSet)
 		})
 	}
 }
@@ -778,7 +1046,7 @@ func TestBalances(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	start, end, balances := ldg.Balances()
+	start, end, balances := ldg.Balances(BalanceOptions{})
 	assert.Equal(t, time.Time{}.AddDate(0, 0, 1), *start)
 	assert.Equal(t, time.Time{}.AddDate(0, 1, 3), *end)
 
@@ -799,6 +1067,163 @@ func TestBalances(t *testing.T) {
 	}, floatBalances)
 }
 
+func TestBalancesIntervals(t *testing.T) {
+	makeTxn := func(date string, amount float64) Transaction {
+		return Transaction{
+			Date:  parseDate(t, date),
+			Payee: "some payee",
+			Postings: []Posting{
+				{Account: "assets:Bank 1", Amount: *decFloat(amount)},
+				{Account: "revenues:work", Amount: *decFloat(-amount)},
+			},
+		}
+	}
+	ldg, err := New([]Transaction{
+		makeTxn("2020/01/01", 1.5),   // Wednesday
+		makeTxn("2020/01/06", 2.5),   // next Monday
+		makeTxn("2020/01/12", 10.5),  // Sunday, same week
+		makeTxn("2020/04/01", 100.5), // next quarter
+	})
+	require.NoError(t, err)
+
+	toStrings := func(amounts []decimal.Decimal) []string {
+		var values []string
+		for _, amount := range amounts {
+			values = append(values, amount.String())
+		}
+		return values
+	}
+
+	for _, tc := range []struct {
+		description string
+		options     BalanceOptions
+		start, end  string
+		expect      []string
+	}{
+		{
+			description: "monthly by default",
+			expect:      []string{"14.5", "14.5", "14.5", "115"},
+		},
+		{
+			description: "weekly",
+			options:     BalanceOptions{Interval: IntervalWeek, End: parseDate(t, "2020/01/20")},
+			end:         "2020/01/20",
+			expect:      []string{"1.5", "14.5", "14.5", "14.5"},
+		},
+		{
+			description: "quarterly changes",
+			options:     BalanceOptions{Interval: IntervalQuarter, Change: true},
+			expect:      []string{"14.5", "100.5"},
+		},
+		{
+			description: "yearly",
+			options:     BalanceOptions{Interval: IntervalYear},
+			expect:      []string{"115"},
+		},
+		{
+			description: "start window includes earlier balances",
+			options:     BalanceOptions{Interval: IntervalDay, Start: parseDate(t, "2020/01/11"), End: parseDate(t, "2020/01/13")},
+			start:       "2020/01/11",
+			end:         "2020/01/13",
+			expect:      []string{"4", "14.5", "14.5"},
+		},
+		{
+			description: "start window changes exclude earlier postings",
+			options:     BalanceOptions{Interval: IntervalDay, Change: true, Start: parseDate(t, "2020/01/11"), End: parseDate(t, "2020/01/13")},
+			start:       "2020/01/11",
+			end:         "2020/01/13",
+			expect:      []string{"0", "10.5", "0"},
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			start, end, balances := ldg.Balances(tc.options)
+			expectStart, expectEnd := parseDate(t, "2020/01/01"), parseDate(t, "2020/04/01")
+			if tc.start != "" {
+				expectStart = parseDate(t, tc.start)
+			}
+			if tc.end != "" {
+				expectEnd = parseDate(t, tc.end)
+			}
+			assert.Equal(t, expectStart, *start)
+			assert.Equal(t, expectEnd, *end)
+			assert.Equal(t, tc.expect, toStrings(balances["assets:Bank 1"]))
+		})
+	}
+}
+
+func TestBalancesValuedInIntervals(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{
+			Date:  parseDate(t, "2020/01/06"),
+			Payee: "exchange",
+			Postings: []Posting{
+				{Account: "assets:Bank 2", Amount: *decFloat(10), Currency: "EUR"},
+				{Account: "assets:Bank 1", Amount: *decFloat(-11), Currency: usd},
+			},
+		},
+	})
+	require.NoError(t, err)
+	ldg.AddPrices([]Price{
+		{Date: parseDate(t, "2020/01/06"), Commodity: "EUR", Amount: *decFloat(1.1), Currency: usd},
+		{Date: parseDate(t, "2020/01/13"), Commodity: "EUR", Amount: *decFloat(1.2), Currency: usd},
+	})
+	_, _, balances, err := ldg.BalancesValuedIn(usd, BalanceOptions{Interval: IntervalWeek, End: parseDate(t, "2020/01/19")})
+	require.NoError(t, err)
+	require.Len(t, balances["assets:Bank 2"], 2)
+	assert.Equal(t, "11", balances["assets:Bank 2"][0].String())
+	assert.Equal(t, "12", balances["assets:Bank 2"][1].String(), "Values should use prices at the end of each week")
+}
+
+func TestBalancesByCommodity(t *testing.T) {
+	makeTxn := func(date time.Time, account string, num float64, commodity string) Transaction {
+		return Transaction{
+			Date:  date,
+			Payee: "some payee",
+			Postings: []Posting{
+				{Account: "assets:something", Amount: *decFloat(-num), Currency: commodity},
+				{Account: account, Amount: *decFloat(num), Currency: commodity},
+			},
+		}
+	}
+	var date time.Time
+	ldg, err := New([]Transaction{
+		makeTxn(date, "food", 1, usd),
+		makeTxn(date.Add(oneDay), "food", 2, "EUR"),
+		makeTxn(date.Add(oneMonth+oneDay), "food", 3, "EUR"),
+	})
+	require.NoError(t, err)
+
+	_, _, balances := ldg.BalancesByCommodity(BalanceOptions{})
+	floatBalances := make(map[string]map[string][]float64, len(balances))
+	for account, commodities := range balances {
+		floatBalances[account] = make(map[string][]float64)
+		for commodity, values := range commodities {
+			for _, value := range values {
+				floatValue, exact := value.Float64()
+				require.True(t, exact)
+				floatBalances[account][commodity] = append(floatBalances[account][commodity], floatValue)
+			}
+		}
+	}
+	assert.Equal(t, map[string]map[string][]float64{
+		"food": {
+			usd:   {1, 1},
+			"EUR": {2, 5},
+		},
+		"assets:something": {
+			usd:   {-1, -1},
+			"EUR": {-2, -5},
+		},
+	}, floatBalances)
+
+	_, _, summed := ldg.Balances(BalanceOptions{})
+	assert.Equal(t, "6", summed["food"][1].String())
+
+	accountBalances := ldg.AccountBalanceByCommodity("food", time.Time{}, date.Add(2*oneMonth), BalanceOptions{})
+	assert.Equal(t, "1", accountBalances[usd].String())
+	assert.Equal(t, "5", accountBalances["EUR"].String())
+}
+
 func TestAccountBalance(t *testing.T) {
 	var date time.Time
 	makeTxn := func(account string, num float64, increment time.Duration) Transaction {
@@ -823,18 +1248,45 @@ func TestAccountBalance(t *testing.T) {
 	require.NoError(t, err)
 
 	// all txns
-	balDecimal := ldg.AccountBalance("food", time.Time{}, date)
+	balDecimal := ldg.AccountBalance("food", time.Time{}, date, BalanceOptions{})
 	bal, exact := balDecimal.Float64()
 	require.True(t, exact)
 	assert.EqualValues(t, 35, bal)
 
 	// skip first day
-	balDecimal = ldg.AccountBalance("food", time.Time{}.AddDate(0, 0, 2), date)
+	balDecimal = ldg.AccountBalance("food", time.Time{}.AddDate(0, 0, 2), date, BalanceOptions{})
 	bal, exact = balDecimal.Float64()
 	require.True(t, exact)
 	assert.EqualValues(t, 25, bal)
 }
 
+func TestBalancesVirtual(t *testing.T) {
+	date := parseDate(t, "2020/01/01")
+	ldg, err := New([]Transaction{
+		{
+			Date: date,
+			Postings: []Posting{
+				{Account: "expenses:food", Amount: *decFloat(1), Currency: usd},
+				{Account: "assets:bank", Amount: *decFloat(-1), Currency: usd},
+				{Account: "budget:food", Amount: *decFloat(-1), Currency: usd, Kind: BalancedVirtualPosting},
+				{Account: "budget", Amount: *decFloat(1), Currency: usd, Kind: BalancedVirtualPosting},
+				{Account: "assets:bank:goal", Amount: *decFloat(5), Currency: usd, Kind: VirtualPosting},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, _, balances := ldg.Balances(BalanceOptions{})
+	assert.Len(t, balances, 2)
+	assert.NotContains(t, balances, "budget:food")
+	_, _, balances = ldg.Balances(BalanceOptions{Virtual: true})
+	assert.Len(t, balances, 5)
+	assert.Equal(t, "-1", balances["budget:food"][0].String())
+
+	assert.Equal(t, "-1", ldg.AccountBalance("assets:bank", date, date, BalanceOptions{}).String())
+	assert.Equal(t, "4", ldg.AccountBalance("assets:bank", date, date, BalanceOptions{Virtual: true}).String())
+}
+
 func TestLeftOverAccountBalances(t *testing.T) {
 	makeTxn := func(account string, num float64) Transaction {
 		return Transaction{
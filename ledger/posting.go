@@ -9,24 +9,45 @@ import (
 )
 
 const (
+	// usd is the default commodity for new transactions
 	usd = "$"
 
 	// OpeningBalanceID is an ID used only for the ledger's opening balances transaction (in an equity: posting)
 	OpeningBalanceID = "Opening-Balance"
 )
 
+// PostingKind distinguishes real postings from virtual postings
+type PostingKind string
+
+const (
+	// RealPosting is a normal posting, which must balance with the other real postings
+	RealPosting PostingKind = ""
+	// VirtualPosting is written as '(account)' and does not need to balance
+	VirtualPosting PostingKind = "virtual"
+	// BalancedVirtualPosting is written as '[account]' and must balance with the other balanced virtual postings
+	BalancedVirtualPosting PostingKind = "balanced-virtual"
+)
+
+// IsVirtual returns true for both virtual and balanced virtual postings
+func (k PostingKind) IsVirtual() bool {
+	return k != RealPosting
+}
+
 type Posting struct {
-	Account  string
-	Amount   decimal.Decimal
-	Balance  *decimal.Decimal `json:",omitempty"`
-	Comment  string           `json:",omitempty"`
-	Currency string
-	Tags     map[string]string `json:",omitempty"`
+	Account      string
+	Amount       decimal.Decimal
+	Balance      *decimal.Decimal `json:",omitempty"`
+	Comment      string           `json:",omitempty"`
+	CommentLines []string         `json:",omitempty"`
+	Cost         *Cost            `json:",omitempty"`
+	Currency     string
+	Kind         PostingKind       `json:",omitempty"`
+	Status       TransactionStatus `json:",omitempty"`
+	Tags         map[string]string `json:",omitempty"`
 }
 
 func NewPostingFromString(line string) (Posting, error) {
 	var posting Posting
-	// TODO support more than USD
 	// comment / tags
 	tokens := strings.SplitN(line, ";", 2)
 	line = strings.TrimSpace(tokens[0])
@@ -34,9 +55,25 @@ func NewPostingFromString(line string) (Posting, error) {
 		posting.Comment, posting.Tags = parseTags(strings.TrimSpace(tokens[1]))
 	}
 
-	// account
+	// status / account
 	tokens = strings.SplitN(line, "  ", 2)
 	posting.Account = strings.TrimSpace(tokens[0])
+	for _, status := range []TransactionStatus{StatusCleared, StatusPending} {
+		if strings.HasPrefix(posting.Account, status.symbol()) {
+			posting.Status = status
+			posting.Account = strings.TrimSpace(strings.TrimPrefix(posting.Account, status.symbol()))
+			break
+		}
+	}
+	switch {
+	case strings.HasPrefix(posting.Account, "(") && strings.HasSuffix(posting.Account, ")"):
+		posting.Kind = VirtualPosting
+	case strings.HasPrefix(posting.Account, "[") && strings.HasSuffix(posting.Account, "]"):
+		posting.Kind = BalancedVirtualPosting
+	}
+	if posting.Kind.IsVirtual() {
+		posting.Account = strings.TrimSpace(posting.Account[1 : len(posting.Account)-1])
+	}
 	if posting.Account == "" {
 		return posting, fmt.Errorf("An account name must be specified: '%s'", line)
 	}
@@ -47,60 +84,103 @@ func NewPostingFromString(line string) (Posting, error) {
 
 	// amount / balance
 	tokens = strings.SplitN(line, "=", 2)
+	amount := tokens[0]
+	if costIndex := strings.IndexRune(amount, '@'); costIndex != -1 {
+		cost, err := parseCost(amount[costIndex:])
+		if err != nil {
+			return posting, errors.Wrap(err, "Invalid cost")
+		}
+		posting.Cost = cost
+		amount = amount[:costIndex]
+	}
 	var err error
-	posting.Amount, err = parseAmount(strings.TrimSpace(tokens[0]))
+	posting.Amount, posting.Currency, err = parseAmount(amount)
 	if err != nil {
 		return posting, errors.Wrap(err, "Invalid amount")
 	}
-	posting.Currency = usd
+	if posting.Cost != nil && posting.Cost.Currency == posting.Currency {
+		return posting, errors.Errorf("Cost commodity must be different from the amount's commodity: %q", posting.Currency)
+	}
 	if len(tokens) == 2 {
-		var balance decimal.Decimal
-		balance, err = parseAmount(strings.TrimSpace(tokens[1]))
+		balance, balanceCommodity, err := parseAmount(tokens[1])
 		posting.Balance = &balance
 		if err != nil {
 			return posting, errors.Wrap(err, "Invalid balance")
 		}
+		if balanceCommodity != posting.Currency {
+			return posting, errors.Errorf("Balance commodity %q must match amount commodity %q", balanceCommodity, posting.Currency)
+		}
 	}
 	return posting, nil
 }
 
-func parseAmount(amount string) (decimal.Decimal, error) {
-	amount = strings.TrimPrefix(amount, usd)
-	amount = strings.TrimSpace(amount)
-	// TODO support thousands delimiter other than ','
-	amount = strings.ReplaceAll(amount, ",", "")
-	return decimal.NewFromString(amount)
-}
-
 func (p Posting) ID() string {
 	return p.Tags[idTag]
 }
 
+// Reconciled returns true if the posting was reconciled against a statement for its account
+func (p Posting) Reconciled() bool {
+	_, reconciled := p.Tags[reconciledTag]
+	return reconciled
+}
+
 func stringPad(s string, amount int) string {
 	formatString := fmt.Sprintf("%%%ds", amount)
 	return fmt.Sprintf(formatString, s)
 }
 
 func (p Posting) FormatTable(accountLen, amountLen int) string {
-	amount := fmt.Sprintf("%s %s", p.Currency, p.Amount.String())
-	amount = stringPad(amount, amountLen+len(p.Currency)+1)
+	amount := formatAmount(p.Amount, p.Currency, amountLen)
+	if p.Cost != nil {
+		amount += " " + p.Cost.String()
+	}
 	var balance string
 	if p.Balance != nil {
-		balance = fmt.Sprintf(" = %s %s", p.Currency, p.Balance.String())
+		balance = " = " + formatAmount(*p.Balance, p.Currency, 1)
 	}
 	return fmt.Sprintf(
 		"%s  %s%s%s",
-		stringPad(p.Account, accountLen),
+		stringPad(p.formatAccount(), accountLen),
 		amount,
 		balance,
 		serializeComment(p.Comment, p.Tags),
 	)
 }
 
+// formatAccount returns the account name, wrapped in parentheses or brackets for virtual postings and prefixed by the posting's status
+func (p Posting) formatAccount() string {
+	account := p.Account
+	switch p.Kind {
+	case VirtualPosting:
+		account = "(" + account + ")"
+	case BalancedVirtualPosting:
+		account = "[" + account + "]"
+	}
+	if symbol := p.Status.symbol(); symbol != "" {
+		account = symbol + " " + account
+	}
+	return account
+}
+
 func (p Posting) String() string {
 	return p.FormatTable(1, 1)
 }
 
+// balanceAmount returns the amount and commodity used to balance a transaction. Uses the cost if one is set.
+func (p Posting) balanceAmount() (decimal.Decimal, string) {
+	if p.Cost == nil {
+		return p.Amount, p.Currency
+	}
+	if p.Cost.Total {
+		total := p.Cost.Amount.Abs()
+		if p.Amount.IsNegative() {
+			total = total.Neg()
+		}
+		return total, p.Cost.Currency
+	}
+	return p.Amount.Mul(p.Cost.Amount), p.Cost.Currency
+}
+
 // IsOpeningBalance returns true if this matches an "opening balance" posting, false otherwise
 func (p Posting) IsOpeningBalance() bool {
 	return p.ID() == OpeningBalanceID
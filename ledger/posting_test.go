@@ -32,6 +32,52 @@ func TestNewPostingFromString(t *testing.T) {
 				Tags:     map[string]string{"what's": "up?"},
 			},
 		},
+		{
+			description: "virtual posting",
+			str:         "(assets:savings:goal)  $ 10.5",
+			posting: Posting{
+				Account:  "assets:savings:goal",
+				Amount:   *decFloat(10.5),
+				Currency: usd,
+				Kind:     VirtualPosting,
+			},
+		},
+		{
+			description: "balanced virtual posting",
+			str:         "[budget:food]  $ -10.5",
+			posting: Posting{
+				Account:  "budget:food",
+				Amount:   *decFloat(-10.5),
+				Currency: usd,
+				Kind:     BalancedVirtualPosting,
+			},
+		},
+		{
+			description: "cleared posting",
+			str:         "* assets:Bank1  $ 1.25",
+			posting: Posting{
+				Account:  "assets:Bank1",
+				Amount:   *decFloat(1.25),
+				Currency: usd,
+				Status:   StatusCleared,
+			},
+		},
+		{
+			description: "pending virtual posting",
+			str:         "! (assets:savings)  $ 1.25",
+			posting: Posting{
+				Account:  "assets:savings",
+				Amount:   *decFloat(1.25),
+				Currency: usd,
+				Kind:     VirtualPosting,
+				Status:   StatusPending,
+			},
+		},
+		{
+			description: "empty virtual account",
+			str:         "()  $ -10",
+			shouldErr:   true,
+		},
 		{
 			description: "missing tags",
 			str:         "assets:Bank1  $ 1.25 = $ 101.25 ; hey there",
@@ -103,6 +149,21 @@ func TestNewPostingFromString(t *testing.T) {
 			shouldErr:     true,
 			missingAmount: true,
 		},
+		{
+			description: "suffix commodity",
+			str:         "assets:Bank1  1.25 EUR = 101.25 EUR",
+			posting: Posting{
+				Account:  "assets:Bank1",
+				Amount:   *decFloat(1.25),
+				Balance:  decFloat(101.25),
+				Currency: "EUR",
+			},
+		},
+		{
+			description: "mismatched balance commodity",
+			str:         "assets:Bank1  1.25 EUR = $ 101.25",
+			shouldErr:   true,
+		},
 		{
 			description: "thousands separator",
 			str:         "assets:Bank1  $ 1,234.56",
@@ -149,6 +210,27 @@ func TestPostingString(t *testing.T) {
 			},
 			str: "assets:Bank 1  $ 1.25 = $ 101.25 ; hey there what's: up?",
 		},
+		{
+			description: "suffix commodity",
+			posting: Posting{
+				Account:  "assets:Bank 1",
+				Amount:   *decFloat(-1.25),
+				Balance:  decFloat(10),
+				Currency: "CAD",
+			},
+			str: "assets:Bank 1  -1.25 CAD = 10 CAD",
+		},
+		{
+			description: "cleared virtual posting",
+			posting: Posting{
+				Account:  "assets:savings",
+				Amount:   *decFloat(1.25),
+				Currency: "$",
+				Kind:     VirtualPosting,
+				Status:   StatusCleared,
+			},
+			str: "* (assets:savings)  $ 1.25",
+		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
 			assert.Equal(t, tc.str, tc.posting.String())
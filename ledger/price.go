@@ -0,0 +1,154 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const pricePrefix = "P "
+
+// Price is a market price directive, recording the value of one unit of Commodity in another commodity on Date
+type Price struct {
+	Date      time.Time
+	Commodity string
+	Amount    decimal.Decimal
+	Currency  string
+}
+
+// Cost is the price paid in another commodity for a posting's amount
+type Cost struct {
+	Amount   decimal.Decimal
+	Currency string
+	// Total is true if Amount is the total cost (@@), otherwise Amount is the cost per unit (@)
+	Total bool `json:",omitempty"`
+}
+
+// parsePriceLine parses a price directive, like 'P 2020/01/01 EUR $ 1.10'
+func parsePriceLine(line string) (Price, error) {
+	var price Price
+	line = strings.TrimSpace(strings.SplitN(line, ";", 2)[0])
+	line = strings.TrimSpace(strings.TrimPrefix(line, strings.TrimSpace(pricePrefix)))
+	tokens := strings.SplitN(line, " ", 2)
+	if len(tokens) != 2 {
+		return price, errors.Errorf("Price directives must have a date, commodity, and amount: %q", line)
+	}
+	var err error
+	price.Date, err = time.Parse(DateFormat, tokens[0])
+	if err != nil {
+		return price, err
+	}
+	price.Commodity, line, err = readCommodity(strings.TrimSpace(tokens[1]))
+	if err != nil {
+		return price, errors.Wrap(err, "Invalid price commodity")
+	}
+	price.Amount, price.Currency, err = parseAmount(line)
+	if err != nil {
+		return price, errors.Wrap(err, "Invalid price amount")
+	}
+	if price.Commodity == price.Currency {
+		return price, errors.Errorf("Price commodity must be different from its amount's commodity: %q", line)
+	}
+	return price, nil
+}
+
+func (p Price) String() string {
+	return fmt.Sprintf(
+		"%s%s %s %s",
+		pricePrefix,
+		p.Date.Format(DateFormat),
+		formatCommodity(p.Commodity),
+		formatAmount(p.Amount, p.Currency, 1),
+	)
+}
+
+// parseCost parses the cost portion of a posting, like '@ $ 1.10' or '@@ $ 110'
+func parseCost(cost string) (*Cost, error) {
+	total := strings.HasPrefix(cost, "@@")
+	cost = strings.TrimLeft(cost, "@")
+	amount, currency, err := parseAmount(cost)
+	if err != nil {
+		return nil, err
+	}
+	return &Cost{Amount: amount, Currency: currency, Total: total}, nil
+}
+
+func (c Cost) String() string {
+	symbol := "@"
+	if c.Total {
+		symbol = "@@"
+	}
+	return symbol + " " + formatAmount(c.Amount, c.Currency, 1)
+}
+
+// sortPrices sorts prices by date
+func sortPrices(prices []Price) {
+	sort.SliceStable(prices, func(a, b int) bool {
+		return prices[a].Date.Before(prices[b].Date)
+	})
+}
+
+// Prices returns all market prices in this ledger, sorted by date
+func (l *Ledger) Prices() []Price {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	prices := make([]Price, len(l.prices))
+	copy(prices, l.prices)
+	return prices
+}
+
+// AddPrices adds the given market prices to the ledger
+func (l *Ledger) AddPrices(prices []Price) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prices = append(l.prices, prices...)
+	sortPrices(l.prices)
+	l.addedPrices = append(l.addedPrices, prices...)
+	sortPrices(l.addedPrices)
+}
+
+// rate returns the most recent exchange rate from one commodity to another, on or before date
+// Assumes at least a read lock is held
+func (l *Ledger) rate(from, to string, date time.Time) (decimal.Decimal, bool) {
+	for i := len(l.prices) - 1; i >= 0; i-- {
+		price := l.prices[i]
+		if price.Date.After(date) {
+			continue
+		}
+		switch {
+		case price.Commodity == from && price.Currency == to:
+			return price.Amount, true
+		case price.Commodity == to && price.Currency == from && !price.Amount.IsZero():
+			return decimal.New(1, 0).DivRound(price.Amount, 16), true
+		}
+	}
+	return decimal.Zero, false
+}
+
+// Value converts and sums 'amounts' of each commodity into the 'valueIn' commodity, using the most recent prices on or before 'date'
+func (l *Ledger) Value(amounts map[string]decimal.Decimal, valueIn string, date time.Time) (decimal.Decimal, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.value(amounts, valueIn, date)
+}
+
+func (l *Ledger) value(amounts map[string]decimal.Decimal, valueIn string, date time.Time) (decimal.Decimal, error) {
+	var sum decimal.Decimal
+	for _, commodity := range sortedCommodities(amounts) {
+		amount := amounts[commodity]
+		if commodity == valueIn || amount.IsZero() {
+			sum = sum.Add(amount)
+			continue
+		}
+		rate, found := l.rate(commodity, valueIn, date)
+		if !found {
+			return decimal.Zero, errors.Errorf("No price found to convert %q into %q on %s", commodity, valueIn, date.Format(DateFormat))
+		}
+		sum = sum.Add(amount.Mul(rate))
+	}
+	return sum, nil
+}
@@ -0,0 +1,178 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriceLine(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		line        string
+		price       Price
+		shouldErr   bool
+	}{
+		{
+			description: "prefix commodity",
+			line:        "P 2020/01/01 EUR $1.10",
+			price: Price{
+				Date:      parseDate(t, "2020/01/01"),
+				Commodity: "EUR",
+				Amount:    *decFloat(1.10),
+				Currency:  usd,
+			},
+		},
+		{
+			description: "quoted commodity and comment",
+			line:        `P 2020/01/01 "ABC 123" 2.5 CAD ; hi`,
+			price: Price{
+				Date:      parseDate(t, "2020/01/01"),
+				Commodity: "ABC 123",
+				Amount:    *decFloat(2.5),
+				Currency:  "CAD",
+			},
+		},
+		{
+			description: "missing amount",
+			line:        "P 2020/01/01 EUR",
+			shouldErr:   true,
+		},
+		{
+			description: "bad date",
+			line:        "P 2020-01 EUR $1.10",
+			shouldErr:   true,
+		},
+		{
+			description: "same commodity",
+			line:        "P 2020/01/01 EUR 1 EUR",
+			shouldErr:   true,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			price, err := parsePriceLine(tc.line)
+			if tc.shouldErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.price, price)
+		})
+	}
+}
+
+func TestPriceString(t *testing.T) {
+	price := Price{
+		Date:      parseDate(t, "2020/01/01"),
+		Commodity: "EUR",
+		Amount:    *decFloat(1.1),
+		Currency:  usd,
+	}
+	assert.Equal(t, "P 2020/01/01 EUR $ 1.1", price.String())
+}
+
+func TestPostingCost(t *testing.T) {
+	for _, tc := range []struct {
+		description     string
+		str             string
+		cost            string
+		balanceAmount   string
+		balanceCurrency string
+	}{
+		{
+			description:     "unit cost",
+			str:             "assets:Bank  100 EUR @ $1.10",
+			cost:            "@ $ 1.1",
+			balanceAmount:   "110",
+			balanceCurrency: usd,
+		},
+		{
+			description:     "total cost",
+			str:             "assets:Bank  -100 EUR @@ $ 110",
+			cost:            "@@ $ 110",
+			balanceAmount:   "-110",
+			balanceCurrency: usd,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			posting, err := NewPostingFromString(tc.str)
+			require.NoError(t, err)
+			require.NotNil(t, posting.Cost)
+			assert.Equal(t, tc.cost, posting.Cost.String())
+			amount, currency := posting.balanceAmount()
+			assert.Equal(t, tc.balanceAmount, amount.String())
+			assert.Equal(t, tc.balanceCurrency, currency)
+
+			reparsed, err := NewPostingFromString(posting.String())
+			require.NoError(t, err)
+			assert.Equal(t, posting, reparsed)
+		})
+	}
+
+	_, err := NewPostingFromString("assets:Bank  100 EUR @ 1.1 EUR")
+	assert.Error(t, err, "Cost must be in a different commodity")
+}
+
+func TestNewFromReaderPrices(t *testing.T) {
+	ldg, err := NewFromReader(bytes.NewBufferString(`P 2020/02/01 EUR $1.20
+P 2020/01/01 EUR $1.10
+
+2020/01/02 currency exchange
+	assets:Bank 2  100 EUR @ $1.10
+	assets:Bank 1
+`))
+	require.NoError(t, err)
+	prices := ldg.Prices()
+	require.Len(t, prices, 2)
+	assert.Equal(t, parseDate(t, "2020/01/01"), prices[0].Date)
+	assert.Equal(t, parseDate(t, "2020/02/01"), prices[1].Date)
+
+	txn := ldg.transactions[0]
+	assert.Equal(t, "-110", txn.Postings[1].Amount.String())
+	assert.Equal(t, usd, txn.Postings[1].Currency)
+
+	ldg.AddPrices([]Price{
+		{Date: parseDate(t, "2020/03/01"), Commodity: "EUR", Amount: *decFloat(1.3), Currency: usd},
+	})
+	assert.Equal(t, `P 2020/03/01 EUR $ 1.3
+
+P 2020/02/01 EUR $1.20
+P 2020/01/01 EUR $1.10
+
+2020/01/02 currency exchange
+	assets:Bank 2  100 EUR @ $1.10
+	assets:Bank 1
+`, ldg.String())
+}
+
+func TestValue(t *testing.T) {
+	ldg, err := New(nil)
+	require.NoError(t, err)
+	ldg.AddPrices([]Price{
+		{Date: parseDate(t, "2020/01/01"), Commodity: "EUR", Amount: *decFloat(1.10), Currency: usd},
+		{Date: parseDate(t, "2020/02/01"), Commodity: "EUR", Amount: *decFloat(1.20), Currency: usd},
+		{Date: parseDate(t, "2020/01/01"), Commodity: usd, Amount: *decFloat(1.25), Currency: "CAD"},
+	})
+
+	amounts := map[string]decimal.Decimal{
+		usd:   *decFloat(1),
+		"EUR": *decFloat(10),
+	}
+	value, err := ldg.Value(amounts, usd, parseDate(t, "2020/01/15"))
+	require.NoError(t, err)
+	assert.Equal(t, "12", value.String())
+
+	value, err = ldg.Value(amounts, usd, parseDate(t, "2020/02/15"))
+	require.NoError(t, err)
+	assert.Equal(t, "13", value.String())
+
+	value, err = ldg.Value(map[string]decimal.Decimal{"CAD": *decFloat(5)}, usd, parseDate(t, "2020/01/15"))
+	require.NoError(t, err)
+	assert.Equal(t, "4", value.String())
+
+	_, err = ldg.Value(amounts, usd, parseDate(t, "2019/12/31"))
+	assert.Error(t, err, "No price before the first price directive")
+}
@@ -13,8 +13,16 @@ type QueryOptions struct {
 	Start    time.Time `form:"start"`
 	End      time.Time `form:"end"`
 	Accounts []string  `form:"accounts[]"`
+	// Status filters by transaction status: cleared, pending, or unmarked
+	Status string `form:"status"`
+	// Code filters by transaction code
+	Code string `form:"code"`
+	// AuxDate uses transactions' auxiliary dates, when present, to filter by Start and End
+	AuxDate bool `form:"auxDate"`
 }
 
+const unmarkedStatusQuery = "unmarked"
+
 // QueryResult is a paginated search result containing relevant transactions
 type QueryResult struct {
 	Count        int
@@ -29,7 +37,8 @@ type transactionScore struct {
 }
 
 // Query searches the ledger and paginates the results
-func (l *Ledger) Query(options QueryOptions, page, results int) QueryResult {
+// The search may contain free text and fields, like 'payee:costco amount:>50 -uncategorized'. See searchQuery for details.
+func (l *Ledger) Query(options QueryOptions, page, results int) (QueryResult, error) {
 	if page < 1 || results < 1 {
 		panic("Page and results must >= 1")
 	}
@@ -37,43 +46,95 @@ func (l *Ledger) Query(options QueryOptions, page, results int) QueryResult {
 		// default End time is in the future
 		options.End = time.Now().AddDate(0, 0, 1)
 	}
+	search, err := parseSearch(options.Search)
+	if err != nil {
+		return QueryResult{}, err
+	}
 
 	l.mu.RLock()
 	defer l.mu.RUnlock()
-	txns := make(Transactions, 0, len(l.transactions))
-	if openingBalTxn := l.idSet[OpeningBalanceID]; openingBalTxn != nil {
+	var excluded map[*Transaction]int
+	if len(search.excludedTerms) > 0 {
+		excluded = l.index.scores(search.excludedTerms)
+	}
+	openingBalTxn := l.idSet[OpeningBalanceID]
+	matches := func(txn *Transaction) bool {
 		// skip opening balance for queries
+		return txn != openingBalTxn && excluded[txn] == 0 && matchesOptions(txn, options) && search.matches(txn, options)
+	}
+
+	if len(search.terms) == 0 {
+		txns := make(Transactions, 0, len(l.transactions))
 		for _, txn := range l.transactions {
-			if txn != openingBalTxn && matchesOptions(txn, options) {
-				txns = append(txns, txn)
-			}
-		}
-	} else {
-		for _, txn := range l.transactions {
-			if matchesOptions(txn, options) {
+			if matches(txn) {
 				txns = append(txns, txn)
 			}
 		}
+		start, end := paginateFromEnd(page, results, len(txns))
+		return QueryResult{
+			Count:        len(txns),
+			Page:         page,
+			Results:      results,
+			Transactions: dereferenceTransactions(txns[start:end]),
+		}, nil
 	}
 
-	size := len(txns)
-	if options.Search != "" {
-		txns, size = searchTxns(options.Search, txns, page, results)
-	} else {
-		start, end := paginateFromEnd(page, results, size)
-		txns = txns[start:end]
+	scores := l.index.scores(search.terms)
+	txnScores := make([]transactionScore, 0, len(scores))
+	for _, txn := range l.transactions {
+		if score := scores[txn]; score > 0 && matches(txn) {
+			txnScores = append(txnScores, transactionScore{Score: score, Transaction: txn})
+		}
+	}
+	// stable sort keeps ledger order for equal scores
+	sort.SliceStable(txnScores, func(a, b int) bool {
+		return txnScores[a].Score < txnScores[b].Score
+	})
+	start, end := paginateFromEnd(page, results, len(txnScores))
+	txns := make([]Transaction, 0, end-start)
+	for _, score := range txnScores[start:end] {
+		txns = append(txns, *score.Transaction)
 	}
-
 	return QueryResult{
-		Count:        size,
+		Count:        len(txnScores),
 		Page:         page,
 		Results:      results,
-		Transactions: dereferenceTransactions(txns),
+		Transactions: txns,
+	}, nil
+}
+
+// matches returns true if the transaction matches all field filters
+func (s searchQuery) matches(txn *Transaction, options QueryOptions) bool {
+	date := txn.Date
+	if options.AuxDate && txn.AuxDate != nil {
+		date = *txn.AuxDate
+	}
+	for _, filter := range s.filters {
+		if !filter(txn, date) {
+			return false
+		}
 	}
+	return true
 }
 
 func matchesOptions(txn *Transaction, options QueryOptions) bool {
-	if txn.Date.Before(options.Start) || txn.Date.After(options.End) {
+	date := txn.Date
+	if options.AuxDate && txn.AuxDate != nil {
+		date = *txn.AuxDate
+	}
+	if date.Before(options.Start) || date.After(options.End) {
+		return false
+	}
+	if options.Status != "" {
+		status := TransactionStatus(options.Status)
+		if options.Status == unmarkedStatusQuery {
+			status = StatusUnmarked
+		}
+		if txn.Status != status {
+			return false
+		}
+	}
+	if options.Code != "" && txn.Code != options.Code {
 		return false
 	}
 	if len(options.Accounts) > 0 {
@@ -103,32 +164,3 @@ func paginateFromEnd(page, results, size int) (start, end int) {
 	end = math.MaxInt(end, 0)
 	return
 }
-
-func searchTxns(search string, txns Transactions, page, results int) (searchTxns Transactions, size int) {
-	// this search is wildly inefficient, hopefully we move onto a DB with proper indexes
-
-	// map initial txn index to score
-	txnScores := make([]transactionScore, 0, len(txns))
-	for _, txn := range txns {
-		score := txn.matches(search)
-		if score > 0 {
-			txnScores = append(txnScores, transactionScore{
-				Score:       score,
-				Transaction: txn,
-			})
-		}
-	}
-	size = len(txnScores)
-	sort.Slice(txnScores, func(a, b int) bool {
-		return txnScores[a].Score < txnScores[b].Score
-	})
-	searchTxns = make([]*Transaction, 0, results)
-	start, end := paginateFromEnd(page, results, size)
-	for _, score := range txnScores[start:end] {
-		if len(searchTxns) == results {
-			break
-		}
-		searchTxns = append(searchTxns, score.Transaction)
-	}
-	return searchTxns, size
-}
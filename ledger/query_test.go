@@ -112,15 +112,168 @@ func TestQuery(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "filter status and code",
+			txns: []Transaction{
+				{Payee: "unmarked", Code: "1"},
+				{Payee: "cleared", Code: "1", Status: StatusCleared},
+				{Payee: "cleared", Code: "2", Status: StatusCleared},
+				{Payee: "pending", Code: "1", Status: StatusPending},
+			},
+			options: QueryOptions{Status: "cleared", Code: "1"},
+			page:    1,
+			results: 10,
+			expect: QueryResult{
+				Count:        1,
+				Page:         1,
+				Results:      10,
+				Transactions: []Transaction{{Payee: "cleared", Code: "1", Status: StatusCleared}},
+			},
+		},
+		{
+			description: "filter unmarked status",
+			txns: []Transaction{
+				{Payee: "unmarked"},
+				{Payee: "cleared", Status: StatusCleared},
+			},
+			options: QueryOptions{Status: "unmarked"},
+			page:    1,
+			results: 10,
+			expect: QueryResult{
+				Count:        1,
+				Page:         1,
+				Results:      10,
+				Transactions: []Transaction{{Payee: "unmarked"}},
+			},
+		},
+		{
+			description: "filter aux dates",
+			txns: []Transaction{
+				{Date: parseDate(t, "2020/01/01"), AuxDate: timePtr(parseDate(t, "2020/01/03")), Payee: "cleared later"},
+				{Date: parseDate(t, "2020/01/02"), Payee: "no aux date"},
+				{Date: parseDate(t, "2020/01/04"), Payee: "too late"},
+			},
+			options: QueryOptions{
+				Start:   parseDate(t, "2020/01/02"),
+				End:     parseDate(t, "2020/01/03"),
+				AuxDate: true,
+			},
+			page:    1,
+			results: 10,
+			expect: QueryResult{
+				Count:   2,
+				Page:    1,
+				Results: 10,
+				Transactions: []Transaction{
+					{Date: parseDate(t, "2020/01/01"), AuxDate: timePtr(parseDate(t, "2020/01/03")), Payee: "cleared later"},
+					{Date: parseDate(t, "2020/01/02"), Payee: "no aux date"},
+				},
+			},
+		},
+		{
+			description: "search fields and exclude terms",
+			txns: []Transaction{
+				{Date: parseDate(t, "2020/03/01"), Payee: "costco", Postings: []Posting{{Account: "expenses:food", Amount: *decFloat(60.5)}}},
+				{Date: parseDate(t, "2020/03/02"), Payee: "costco", Postings: []Posting{{Account: "uncategorized", Amount: *decFloat(70.5)}}},
+				{Date: parseDate(t, "2020/03/03"), Payee: "costco", Postings: []Posting{{Account: "expenses:food", Amount: *decFloat(10.5)}}},
+				{Date: parseDate(t, "2020/06/01"), Payee: "costco", Postings: []Posting{{Account: "expenses:food", Amount: *decFloat(80.5)}}},
+			},
+			options: QueryOptions{Search: "payee:costco amount:>50 date:2020-03..2020-05 -uncategorized"},
+			page:    1,
+			results: 10,
+			expect: QueryResult{
+				Count:   1,
+				Page:    1,
+				Results: 10,
+				Transactions: []Transaction{
+					{Date: parseDate(t, "2020/03/01"), Payee: "costco", Postings: []Posting{{Account: "expenses:food", Amount: *decFloat(60.5)}}},
+				},
+			},
+		},
+		{
+			description: "search terms with fields",
+			txns: []Transaction{
+				{Date: parseDate(t, "2020/03/01"), Payee: "coffee", Postings: []Posting{{Account: "expenses:food"}}},
+				{Date: parseDate(t, "2020/03/02"), Payee: "coffee shop", Comment: "coffee", Postings: []Posting{{Account: "expenses:food"}}},
+				{Date: parseDate(t, "2020/03/03"), Payee: "coffee", Postings: []Posting{{Account: "expenses:travel"}}},
+			},
+			options: QueryOptions{Search: "coffee account:food"},
+			page:    1,
+			results: 10,
+			expect: QueryResult{
+				Count:   2,
+				Page:    1,
+				Results: 10,
+				Transactions: []Transaction{
+					{Date: parseDate(t, "2020/03/01"), Payee: "coffee", Postings: []Posting{{Account: "expenses:food"}}},
+					{Date: parseDate(t, "2020/03/02"), Payee: "coffee shop", Comment: "coffee", Postings: []Posting{{Account: "expenses:food"}}},
+				},
+			},
+		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
 			ldg, err := New(tc.txns)
 			require.NoError(t, err)
-			assert.Equal(t, tc.expect, ldg.Query(tc.options, tc.page, tc.results))
+			result, err := ldg.Query(tc.options, tc.page, tc.results)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, result)
 		})
 	}
 }
 
+func TestQueryInvalidSearch(t *testing.T) {
+	ldg, err := New(nil)
+	require.NoError(t, err)
+	_, err = ldg.Query(QueryOptions{Search: "amount:>lots"}, 1, 10)
+	assert.Error(t, err)
+}
+
+func TestQueryIndexUpdates(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{
+			Date:  parseDate(t, "2020/01/01"),
+			Payee: "coffee",
+			Postings: []Posting{
+				{Account: "assets:Bank 1", Amount: *decFloat(-1.5), Currency: usd, Tags: makeIDTag("A")},
+				{Account: "uncategorized", Amount: *decFloat(1.5), Currency: usd},
+			},
+		},
+	})
+	require.NoError(t, err)
+	search := func(search string) int {
+		result, err := ldg.Query(QueryOptions{Search: search}, 1, 10)
+		require.NoError(t, err)
+		return result.Count
+	}
+	assert.Equal(t, 1, search("coffee"))
+
+	require.NoError(t, ldg.UpdateTransaction("A", Transaction{Comment: "morning latte"}))
+	assert.Equal(t, 1, search("latte"))
+
+	require.NoError(t, ldg.AddTransactions([]Transaction{
+		{
+			Date:  parseDate(t, "2020/01/02"),
+			Payee: "tea",
+			Postings: []Posting{
+				{Account: "assets:Bank 1", Amount: *decFloat(-2.5), Currency: usd, Tags: makeIDTag("B")},
+				{Account: "uncategorized", Amount: *decFloat(2.5), Currency: usd},
+			},
+		},
+	}))
+	assert.Equal(t, 1, search("tea"))
+	assert.Equal(t, 2, search("bank"))
+
+	ldg.RenameAccount("assets:Bank 1", "assets:Credit Union", "", "")
+	assert.Equal(t, 0, search("bank"))
+	assert.Equal(t, 2, search("union"))
+
+	require.NoError(t, ldg.UpdateAccount("uncategorized", "expenses:drinks"))
+	assert.Equal(t, 2, search("drinks"))
+
+	require.NoError(t, ldg.RemoveTransaction("B"))
+	assert.Equal(t, 0, search("tea"))
+}
+
 func TestPaginateFromEnd(t *testing.T) {
 	for _, tc := range []struct {
 		page, results, size int
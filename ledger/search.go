@@ -0,0 +1,255 @@
+package ledger
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Search fields filter transactions precisely, like 'payee:costco' or 'amount:>50'
+const (
+	payeeSearchField   = "payee"
+	amountSearchField  = "amount"
+	accountSearchField = "account"
+	tagSearchField     = "tag"
+	dateSearchField    = "date"
+)
+
+// searchQuery is a parsed transaction search, like 'payee:costco amount:>50 account:expenses:food tag:trip date:2020-03..2020-05 -uncategorized'
+// Free text terms match any of payee, comment, code, date, or posting accounts. Fields filter transactions precisely.
+// Prefixing a term or field with '-' excludes matching transactions.
+type searchQuery struct {
+	terms         []string
+	excludedTerms []string
+	filters       []txnFilter
+}
+
+// txnFilter returns true if the transaction matches. 'date' is the transaction's date to use for date filters, see QueryOptions.AuxDate
+type txnFilter func(txn *Transaction, date time.Time) bool
+
+// parseSearch parses a search string into free text terms and field filters
+func parseSearch(search string) (searchQuery, error) {
+	var query searchQuery
+	for _, token := range splitSearch(search) {
+		negate := false
+		if len(token) > 1 && token[0] == '-' {
+			negate = true
+			token = token[1:]
+		}
+
+		fieldValue := strings.SplitN(token, ":", 2)
+		var filter txnFilter
+		if len(fieldValue) == 2 && fieldValue[1] != "" {
+			var err error
+			filter, err = parseSearchField(strings.ToLower(fieldValue[0]), fieldValue[1])
+			if err != nil {
+				return searchQuery{}, err
+			}
+		}
+
+		switch {
+		case filter != nil && negate:
+			query.filters = append(query.filters, func(txn *Transaction, date time.Time) bool {
+				return !filter(txn, date)
+			})
+		case filter != nil:
+			query.filters = append(query.filters, filter)
+		case negate:
+			query.excludedTerms = append(query.excludedTerms, strings.ToLower(token))
+		default:
+			query.terms = append(query.terms, strings.ToLower(token))
+		}
+	}
+	return query, nil
+}
+
+// splitSearch splits a search string on whitespace, except inside double quotes. Quotes are removed.
+func splitSearch(search string) []string {
+	var tokens []string
+	var token strings.Builder
+	inQuotes := false
+	for _, r := range search {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case unicode.IsSpace(r) && !inQuotes:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// parseSearchField returns a filter for the field's value, or nil if 'field' isn't a search field
+func parseSearchField(field, value string) (txnFilter, error) {
+	switch field {
+	case payeeSearchField:
+		value = strings.ToLower(value)
+		return func(txn *Transaction, _ time.Time) bool {
+			return strings.Contains(strings.ToLower(txn.Payee), value)
+		}, nil
+	case accountSearchField:
+		value = strings.ToLower(value)
+		return func(txn *Transaction, _ time.Time) bool {
+			for _, p := range txn.Postings {
+				if strings.Contains(strings.ToLower(p.Account), value) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case tagSearchField:
+		return parseTagFilter(value), nil
+	case amountSearchField:
+		return parseAmountFilter(value)
+	case dateSearchField:
+		return parseDateFilter(value)
+	default:
+		return nil, nil
+	}
+}
+
+// parseTagFilter matches transactions or postings with the tag 'name', or 'name=value' to also match the tag's value
+func parseTagFilter(value string) txnFilter {
+	tokens := strings.SplitN(value, "=", 2)
+	name := strings.ToLower(tokens[0])
+	hasTag := func(tags map[string]string) bool {
+		for key, tagValue := range tags {
+			if strings.ToLower(key) == name && (len(tokens) == 1 || strings.EqualFold(tagValue, tokens[1])) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(txn *Transaction, _ time.Time) bool {
+		if hasTag(txn.Tags) {
+			return true
+		}
+		for _, p := range txn.Postings {
+			if hasTag(p.Tags) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// parseAmountFilter matches transactions with any posting's absolute amount satisfying the comparison, like '>50', '<=10.5', '20', or the inclusive range '10..20'
+func parseAmountFilter(value string) (txnFilter, error) {
+	parseAmount := func(s string) (decimal.Decimal, error) {
+		amount, err := decimal.NewFromString(s)
+		return amount, errors.Wrapf(err, "Invalid amount in search: %q", value)
+	}
+
+	var matches func(amount decimal.Decimal) bool
+	if tokens := strings.SplitN(value, "..", 2); len(tokens) == 2 {
+		min, err := parseAmount(tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		max, err := parseAmount(tokens[1])
+		if err != nil {
+			return nil, err
+		}
+		matches = func(amount decimal.Decimal) bool {
+			return amount.GreaterThanOrEqual(min) && amount.LessThanOrEqual(max)
+		}
+	} else {
+		var operator string
+		for _, op := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(value, op) {
+				operator = op
+				break
+			}
+		}
+		target, err := parseAmount(strings.TrimPrefix(value, operator))
+		if err != nil {
+			return nil, err
+		}
+		matches = func(amount decimal.Decimal) bool {
+			switch operator {
+			case ">=":
+				return amount.GreaterThanOrEqual(target)
+			case "<=":
+				return amount.LessThanOrEqual(target)
+			case ">":
+				return amount.GreaterThan(target)
+			case "<":
+				return amount.LessThan(target)
+			default:
+				return amount.Equal(target)
+			}
+		}
+	}
+
+	return func(txn *Transaction, _ time.Time) bool {
+		for _, p := range txn.Postings {
+			if matches(p.Amount.Abs()) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// parseDateFilter matches transactions within a date or date range, like '2020', '2020-03', '2020-03-15', or '2020-03..2020-05'
+// Ranges include the entire end period, so '2020-03..2020-05' includes all of May. Either side of a range may be omitted.
+func parseDateFilter(value string) (txnFilter, error) {
+	var start, end time.Time
+	if tokens := strings.SplitN(value, "..", 2); len(tokens) == 2 {
+		if tokens[0] != "" {
+			var err error
+			start, _, err = parseSearchPeriod(tokens[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+		if tokens[1] != "" {
+			var err error
+			_, end, err = parseSearchPeriod(tokens[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		var err error
+		start, end, err = parseSearchPeriod(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func(_ *Transaction, date time.Time) bool {
+		return !date.Before(start) && (end.IsZero() || date.Before(end))
+	}, nil
+}
+
+// parseSearchPeriod parses a year, month, or day into the start of that period and the start of the next one
+func parseSearchPeriod(value string) (start, end time.Time, err error) {
+	normalized := strings.Replace(value, "/", "-", -1)
+	for _, period := range []struct {
+		format              string
+		years, months, days int
+	}{
+		{format: "2006-01-02", days: 1},
+		{format: "2006-1-2", days: 1},
+		{format: "2006-01", months: 1},
+		{format: "2006-1", months: 1},
+		{format: "2006", years: 1},
+	} {
+		start, err = time.Parse(period.format, normalized)
+		if err == nil {
+			return start, start.AddDate(period.years, period.months, period.days), nil
+		}
+	}
+	return time.Time{}, time.Time{}, errors.Errorf("Invalid date in search, must be in the form YYYY, YYYY-MM, or YYYY-MM-DD: %q", value)
+}
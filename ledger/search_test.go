@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSearch(t *testing.T) {
+	assert.Equal(t, []string{"payee:whole foods", "-uncategorized", "a b"}, splitSearch(`  payee:"whole foods"	-uncategorized "a b" `))
+	assert.Empty(t, splitSearch(" "))
+}
+
+func TestParseSearch(t *testing.T) {
+	query, err := parseSearch("Coffee -Uncategorized expenses:food payee:x -tag:trip")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"coffee", "expenses:food"}, query.terms, "Unknown fields should be free text")
+	assert.Equal(t, []string{"uncategorized"}, query.excludedTerms)
+	assert.Len(t, query.filters, 2)
+
+	for _, search := range []string{
+		"amount:>abc",
+		"amount:1..",
+		"date:2020-13",
+		"date:yesterday..2020",
+	} {
+		_, err := parseSearch(search)
+		assert.Error(t, err, search)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	txn := &Transaction{
+		Date:  parseDate(t, "2020/03/15"),
+		Payee: "Costco Wholesale",
+		Tags:  map[string]string{"trip": "Japan"},
+		Postings: []Posting{
+			{Account: "expenses:food:groceries", Amount: *decFloat(60.5), Currency: usd},
+			{Account: "assets:Bank 1", Amount: *decFloat(-60.5), Currency: usd, Tags: map[string]string{"receipt": "yes"}},
+		},
+	}
+	for _, tc := range []struct {
+		search string
+		match  bool
+	}{
+		{search: "payee:costco", match: true},
+		{search: "payee:walmart", match: false},
+		{search: "-payee:costco", match: false},
+		{search: "account:expenses:food", match: true},
+		{search: "account:Bank", match: true},
+		{search: "account:expenses:travel", match: false},
+		{search: "amount:>50", match: true},
+		{search: "amount:>=60.5", match: true},
+		{search: "amount:<60", match: false},
+		{search: "amount:<=60.5", match: true},
+		{search: "amount:60.5", match: true},
+		{search: "amount:=61", match: false},
+		{search: "amount:10..100", match: true},
+		{search: "amount:100..200", match: false},
+		{search: "tag:trip", match: true},
+		{search: "tag:trip=japan", match: true},
+		{search: "tag:trip=peru", match: false},
+		{search: "tag:receipt", match: true},
+		{search: "tag:missing", match: false},
+		{search: "date:2020", match: true},
+		{search: "date:2020-03", match: true},
+		{search: "date:2020/03/15", match: true},
+		{search: "date:2020-03-16", match: false},
+		{search: "date:2020-01..2020-03", match: true},
+		{search: "date:2020-04..", match: false},
+		{search: "date:..2020-02", match: false},
+		{search: "payee:costco amount:>50 date:2020-03..2020-05", match: true},
+		{search: "payee:costco amount:>100", match: false},
+	} {
+		t.Run(tc.search, func(t *testing.T) {
+			query, err := parseSearch(tc.search)
+			require.NoError(t, err)
+			assert.Equal(t, tc.match, query.matches(txn, QueryOptions{}))
+		})
+	}
+}
+
+func TestSearchDateFilterAuxDate(t *testing.T) {
+	auxDate := parseDate(t, "2020/04/01")
+	txn := &Transaction{Date: parseDate(t, "2020/03/31"), AuxDate: &auxDate}
+	query, err := parseSearch("date:2020-04")
+	require.NoError(t, err)
+	assert.False(t, query.matches(txn, QueryOptions{}))
+	assert.True(t, query.matches(txn, QueryOptions{AuxDate: true}))
+}
+
+func TestParseSearchPeriod(t *testing.T) {
+	start, end, err := parseSearchPeriod("2020-02")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), end)
+}
@@ -1,8 +1,7 @@
 package ledger
 
 import (
-	"bytes"
-	"io/ioutil"
+	"sync"
 	"time"
 
 	sErrors "github.com/johnstarich/sage/errors"
@@ -28,6 +27,8 @@ type Store struct {
 	syncPromptRequest *atomic.Value
 	syncing           *atomic.Bool
 	lastSyncErr       *atomic.Error
+	// batches counts in-progress calls to Batch, which defer writing to disk
+	batches atomic.Int32
 
 	syncFile   func() error
 	syncLedger func(start, end time.Time, download downloader, processTxns txnMutator, ldg *Ledger, logger *zap.Logger, prompter prompter.Prompter) error
@@ -35,13 +36,7 @@ type Store struct {
 
 // NewStore creates a Ledger Store from the given file
 func NewStore(file vcs.File, logger *zap.Logger) (*Store, error) {
-	ledgerBytes, err := file.Read()
-	if err != nil {
-		return nil, errors.Wrap(err, "Error reading ledger file")
-	}
-	r := ioutil.NopCloser(bytes.NewBuffer(ledgerBytes))
-
-	ldg, err := NewFromReader(r)
+	ldg, err := NewFromFile(file)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +58,8 @@ func NewStore(file vcs.File, logger *zap.Logger) (*Store, error) {
 
 type downloader func(start, end time.Time, prompter prompter.Prompter) ([]Transaction, error)
 
-type txnMutator func(txns []Transaction)
+// txnMutator processes downloaded transactions before they're added to the ledger, returning the transactions to add
+type txnMutator func(txns []Transaction) []Transaction
 
 // StartSync asynchronously downloads and processes new transactions between the start and end dates
 // If a partial failure occurs during the sync, writes to disk anyway
@@ -79,28 +75,48 @@ func (s *Store) StartSync(start, end time.Time, download downloader, processTxns
 }
 
 func (s *Store) sync(start, end time.Time, download downloader, processTxns txnMutator) error {
-	ledgerErr := s.syncLedger(start, end, download, processTxns, s.Ledger, s.logger, s.prompter)
-	if _, ok := ledgerErr.(Error); ledgerErr != nil && !ok {
-		return ledgerErr
-	}
-
-	if fileErr := s.syncFile(); fileErr != nil {
-		return errors.Wrap(fileErr, "Error writing ledger to disk")
-	}
-	// save partial errors only if there isn't a more important failure
-	return ledgerErr
+	return s.Batch(func() error {
+		return s.syncLedger(start, end, download, processTxns, s.Ledger, s.logger, s.prompter)
+	})
 }
 
+// syncLedgerFile writes and commits only the changed ledger files: 'file' and any changed included files
 func syncLedgerFile(ldg *Ledger, file vcs.File) func() error {
+	var mu sync.Mutex
 	return func() error {
-		err := file.Write([]byte(ldg.String()))
-		return errors.Wrap(err, "Error writing ledger to disk")
+		// serialize writes, so older contents can't overwrite newer ones
+		mu.Lock()
+		defer mu.Unlock()
+
+		writes := ldg.takeChanges()
+		for i, write := range writes {
+			var err error
+			switch {
+			case write.include == nil && write.appended:
+				err = errors.Wrap(file.Append(write.contents), "Error writing ledger to disk")
+			case write.include == nil:
+				err = errors.Wrap(file.Write(write.contents), "Error writing ledger to disk")
+			default:
+				err = errors.Wrap(write.include.file.Write(write.contents), "Error writing included ledger file to disk")
+			}
+			if err != nil {
+				for _, unwritten := range writes[i:] {
+					ldg.markUnwritten(unwritten.include)
+				}
+				return err
+			}
+		}
+		return nil
 	}
 }
 
 func syncLedger(start, end time.Time, download downloader, processTxns txnMutator, ldg *Ledger, logger *zap.Logger, prompter prompter.Prompter) error {
 	if err := ldg.Validate(); err != nil {
-		return errors.Wrap(err, "Existing ledger is not valid")
+		if !IsAssertionError(err) {
+			return errors.Wrap(err, "Existing ledger is not valid")
+		}
+		// only balance assertions failed, AddTransactions reports any new failures
+		logger.Warn("Existing ledger has failed balance assertions", zap.Error(err))
 	}
 
 	const syncBuffer = 2 * day
@@ -136,7 +152,7 @@ func syncLedger(start, end time.Time, download downloader, processTxns txnMutato
 	}
 	allTxns = filteredTxns
 
-	processTxns(allTxns)
+	allTxns = processTxns(allTxns)
 
 	if err := ldg.AddTransactions(allTxns); err != nil {
 		logger.Warn("Failed to add transactions to ledger", zap.Error(err))
@@ -211,58 +227,132 @@ func (s *Store) Resync(download downloader, processTxns txnMutator) {
 	s.StartSync(s.Ledger.FirstTransactionTime(), now, download, processTxns)
 }
 
+// writeFile writes and commits changes to disk, unless a Batch is in progress
+func (s *Store) writeFile() error {
+	if s.batches.Load() > 0 {
+		return nil
+	}
+	return s.syncFile()
+}
+
+// writeFileAfter writes the ledger file after a ledger change returned 'err'
+// An Error means the change was still applied, like failed balance assertions, so the file is written before returning it.
+func (s *Store) writeFileAfter(err error) error {
+	switch err.(type) {
+	case Error:
+		return pipe.OpFuncs{
+			s.writeFile,
+			func() error { return err },
+		}.Do()
+	case nil:
+		return s.writeFile()
+	default:
+		return err
+	}
+}
+
+// Batch runs 'fn', deferring all writes to disk until it returns. Then writes and commits all changes at once.
+// Changes made by 'fn' are written even if it returns an error. Other writes during the batch are also deferred until it completes.
+// Returns the error from 'fn' first, unless it's an Error like failed balance assertions and the write failed.
+func (s *Store) Batch(fn func() error) error {
+	s.batches.Inc()
+	err := fn()
+	s.batches.Dec()
+	writeErr := s.writeFile()
+	if _, isLedgerErr := err.(Error); isLedgerErr && writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return err
+	}
+	return writeErr
+}
+
 // RenameAccount wraps ledger.RenameAccount and syncs changes to disk
 func (s *Store) RenameAccount(oldName, newName, oldID, newID string) (int, error) {
 	updatedCount := s.Ledger.RenameAccount(oldName, newName, oldID, newID)
-	return updatedCount, s.syncFile()
+	return updatedCount, s.writeFile()
 }
 
 // UpdateAccount wraps ledger.UpdateAccount and syncs changes to disk
 func (s *Store) UpdateAccount(oldAccount, newAccount string) error {
 	return pipe.OpFuncs{
 		func() error { return s.Ledger.UpdateAccount(oldAccount, newAccount) },
-		s.syncFile,
+		s.writeFile,
 	}.Do()
 }
 
 // AddTransactions wraps ledger.AddTransactions and syncs changes to disk
 func (s *Store) AddTransactions(txns []Transaction) error {
-	return pipe.OpFuncs{
-		func() error { return s.Ledger.AddTransactions(txns) },
-		s.syncFile,
+	return s.writeFileAfter(s.Ledger.AddTransactions(txns))
+}
+
+// CreateTransaction wraps ledger.CreateTransaction and syncs changes to disk
+func (s *Store) CreateTransaction(txn Transaction) (string, error) {
+	id, err := s.Ledger.CreateTransaction(txn)
+	if id == "" {
+		return "", err
+	}
+	return id, pipe.OpFuncs{
+		s.writeFile, // sync file even if balance assertions failed
+		func() error { return err },
 	}.Do()
 }
 
+// RemoveTransaction wraps ledger.RemoveTransaction and syncs changes to disk
+func (s *Store) RemoveTransaction(id string) error {
+	return s.writeFileAfter(s.Ledger.RemoveTransaction(id))
+}
+
 // UpdateTransaction wraps ledger.UpdateTransaction and syncs changes to disk
 func (s *Store) UpdateTransaction(id string, txn Transaction) error {
-	return pipe.OpFuncs{
-		func() error { return s.Ledger.UpdateTransaction(id, txn) },
-		s.syncFile,
-	}.Do()
+	return s.writeFileAfter(s.Ledger.UpdateTransaction(id, txn))
 }
 
 // UpdateTransactions wraps ledger.UpdateTransactions and syncs changes to disk
 func (s *Store) UpdateTransactions(txns map[string]Transaction) error {
-	var ledgerErrs, errs sErrors.Errors
-	for id, txn := range txns {
-		switch err := s.Ledger.UpdateTransaction(id, txn).(type) {
-		case Error:
-			ledgerErrs.AddErr(err)
-		default:
-			errs.AddErr(err)
+	var ledgerErrs sErrors.Errors
+	// sync file even if there are validation errors
+	err := s.Batch(func() error {
+		var errs sErrors.Errors
+		for id, txn := range txns {
+			switch err := s.Ledger.UpdateTransaction(id, txn).(type) {
+			case Error:
+				ledgerErrs.AddErr(err)
+			default:
+				errs.AddErr(err)
+			}
 		}
-	}
+		return errs.ErrOrNil()
+	})
 	return pipe.OpFuncs{
-		errs.ErrOrNil,
-		s.syncFile,          // sync file even if there are validation errors
+		func() error { return err },
 		ledgerErrs.ErrOrNil, // return least critical errors last
 	}.Do()
 }
 
-// UpdateOpeningBalance wraps ledger.UpdateOpeningBalance and syncs changes to disk
-func (s *Store) UpdateOpeningBalance(opening Transaction) error {
+// ReconcileTransactions wraps ledger.ReconcileTransactions and syncs changes to disk
+func (s *Store) ReconcileTransactions(account string, ids []string, statementDate time.Time) error {
 	return pipe.OpFuncs{
-		func() error { return s.Ledger.UpdateOpeningBalance(opening) },
-		s.syncFile,
+		func() error { return s.Ledger.ReconcileTransactions(account, ids, statementDate) },
+		s.writeFile,
 	}.Do()
 }
+
+// UnlockTransaction wraps ledger.UnlockTransaction and syncs changes to disk
+func (s *Store) UnlockTransaction(id string) error {
+	return pipe.OpFuncs{
+		func() error { return s.Ledger.UnlockTransaction(id) },
+		s.writeFile,
+	}.Do()
+}
+
+// MergeDuplicate wraps ledger.MergeDuplicate and syncs changes to disk
+func (s *Store) MergeDuplicate(existingID string, duplicate Transaction) error {
+	return s.writeFileAfter(s.Ledger.MergeDuplicate(existingID, duplicate))
+}
+
+// UpdateOpeningBalance wraps ledger.UpdateOpeningBalance and syncs changes to disk
+func (s *Store) UpdateOpeningBalance(opening Transaction) error {
+	return s.writeFileAfter(s.Ledger.UpdateOpeningBalance(opening))
+}
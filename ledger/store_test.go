@@ -52,15 +52,30 @@ func TestNewStore(t *testing.T) {
 }
 
 type mockFile struct {
+	path     string
 	buf      bytes.Buffer
 	writeErr error
 	readErr  error
+	files    map[string]*mockFile
+	writes   int
+	appends  int
 }
 
 func (m *mockFile) Write(b []byte) error {
 	if m.writeErr != nil {
 		return m.writeErr
 	}
+	m.writes++
+	m.buf.Reset()
+	_, err := m.buf.Write(b)
+	return err
+}
+
+func (m *mockFile) Append(b []byte) error {
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	m.appends++
 	_, err := m.buf.Write(b)
 	return err
 }
@@ -69,6 +84,20 @@ func (m *mockFile) Read() ([]byte, error) {
 	return m.buf.Bytes(), m.readErr
 }
 
+func (m *mockFile) Path() string {
+	return m.path
+}
+
+func (m *mockFile) Relative(path string) vcs.File {
+	if m.files[path] == nil {
+		if m.files == nil {
+			m.files = make(map[string]*mockFile)
+		}
+		m.files[path] = &mockFile{path: path, files: m.files}
+	}
+	return m.files[path]
+}
+
 func TestNewStoreDeps(t *testing.T) {
 	t.Run("happy path", func(t *testing.T) {
 		logger := zaptest.NewLogger(t)
@@ -116,13 +145,13 @@ func TestSync(t *testing.T) {
 		{
 			description:   "file sync error",
 			syncFileErr:   errors.New("some error"),
-			expectSyncErr: "Error writing ledger to disk: some error",
+			expectSyncErr: "some error",
 		},
 		{
 			description:   "ledger sync validation error hidden if file sync error",
 			syncLedgerErr: NewValidateError(0, errors.New("some validation error")),
 			syncFileErr:   errors.New("some file error"),
-			expectSyncErr: "Error writing ledger to disk: some file error",
+			expectSyncErr: "some file error",
 		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
@@ -148,9 +177,10 @@ func TestSync(t *testing.T) {
 				return nil, nil
 			}
 			ranProcessTxns := false
-			processTxns := func(txns []Transaction) {
+			processTxns := func(txns []Transaction) []Transaction {
 				ranProcessTxns = true
 				assert.Equal(t, someTxns, txns)
+				return txns
 			}
 
 			store.StartSync(inputStart, inputEnd, download, processTxns)
@@ -193,17 +223,16 @@ func TestSyncMutex(t *testing.T) {
 	}
 	var someTime time.Time
 	for i := 0; i < 100; i++ {
-		store.StartSync(someTime, someTime, func(start, end time.Time, prompt prompter.Prompter) ([]Transaction, error) { return nil, nil }, func([]Transaction) {})
+		store.StartSync(someTime, someTime, func(start, end time.Time, prompt prompter.Prompter) ([]Transaction, error) { return nil, nil }, func(txns []Transaction) []Transaction { return txns })
 	}
 	wait <- true
 	assert.EqualValues(t, 1, syncCount.Load())
 }
 
 func TestSyncLedgerFile(t *testing.T) {
-	ldg, err := New(nil)
-	require.NoError(t, err)
-
 	t.Run("successful write", func(t *testing.T) {
+		ldg, err := New(nil)
+		require.NoError(t, err)
 		file := &mockFile{}
 		syncFile := syncLedgerFile(ldg, file)
 		assert.NoError(t, syncFile())
@@ -211,11 +240,74 @@ func TestSyncLedgerFile(t *testing.T) {
 	})
 
 	t.Run("failed write", func(t *testing.T) {
+		ldg, err := New(nil)
+		require.NoError(t, err)
 		file := &mockFile{writeErr: errors.New("some error")}
 		syncFile := syncLedgerFile(ldg, file)
-		err := syncFile()
+		err = syncFile()
 		require.Error(t, err)
 		assert.Equal(t, "Error writing ledger to disk: some error", err.Error())
+
+		file.writeErr = nil
+		assert.NoError(t, syncFile())
+		assert.Equal(t, 1, file.writes, "Failed writes should be retried")
+	})
+
+	t.Run("only changed files are written", func(t *testing.T) {
+		file := &mockFile{}
+		file.buf.WriteString(`include 2019.journal
+
+2020/01/02 coffee ; id: B
+    expenses:food   $ 3
+    assets:Bank 1
+`)
+		journal2019 := file.Relative("2019.journal").(*mockFile)
+		journal2019.buf.WriteString(`2019/01/02 some burger place ; id: A
+    expenses:food   $ 1.25
+    assets:Bank 1
+`)
+		ldg, err := NewFromFile(file)
+		require.NoError(t, err)
+		syncFile := syncLedgerFile(ldg, file)
+		require.NoError(t, syncFile())
+		assert.Zero(t, file.writes)
+		assert.Zero(t, journal2019.writes)
+
+		require.NoError(t, ldg.UpdateTransaction("A", Transaction{Comment: "updated"}))
+		require.NoError(t, syncFile())
+		assert.Zero(t, file.writes)
+		assert.Equal(t, 1, journal2019.writes)
+
+		require.NoError(t, ldg.AddTransactions([]Transaction{
+			{
+				Date:  parseDate(t, "2020/01/03"),
+				Payee: "tea",
+				Postings: []Posting{
+					{Account: "expenses:food", Amount: *decFloat(2.5), Currency: usd},
+					{Account: "assets:Bank 1", Amount: *decFloat(-2.5), Currency: usd},
+				},
+			},
+		}))
+		require.NoError(t, syncFile())
+		assert.Zero(t, file.writes)
+		assert.Equal(t, 1, file.appends, "New transactions at the end of the file should be appended")
+		assert.Equal(t, 1, journal2019.writes)
+		assert.Equal(t, `include 2019.journal
+
+2020/01/02 coffee ; id: B
+    expenses:food   $ 3
+    assets:Bank 1
+
+2020/01/03 tea
+    expenses:food   $ 2.5
+    assets:Bank 1  $ -2.5
+
+`, file.buf.String())
+
+		require.NoError(t, ldg.UpdateTransaction("B", Transaction{Comment: "updated"}))
+		require.NoError(t, syncFile())
+		assert.Equal(t, 1, file.writes)
+		assert.Contains(t, file.buf.String(), "2020/01/03 tea\n", "Written transactions should be kept as-is")
 	})
 }
 
@@ -350,9 +442,10 @@ func TestSyncLedger(t *testing.T) {
 				return txns, err
 			}
 			ranProcessTxns := false
-			processTxns := func(txns []Transaction) {
+			processTxns := func(txns []Transaction) []Transaction {
 				ranProcessTxns = true
 				assert.Equal(t, afterDate(tc.start, flatten(tc.downloadTxns...)), txns)
+				return txns
 			}
 			ldg, err := New(tc.initialTxns)
 			require.NoError(t, err)
@@ -428,8 +521,9 @@ func TestSyncRecent(t *testing.T) {
 				ranDownload.Store(true)
 				return nil, nil
 			}
-			processTxns := func([]Transaction) {
+			processTxns := func(txns []Transaction) []Transaction {
 				ranProcess.Store(true)
+				return txns
 			}
 			ldg, err := New(tc.txns)
 			require.NoError(t, err)
@@ -469,8 +563,9 @@ func TestResync(t *testing.T) {
 		ranDownload.Store(true)
 		return nil, nil
 	}
-	processTxns := func([]Transaction) {
+	processTxns := func(txns []Transaction) []Transaction {
 		ranProcess.Store(true)
+		return txns
 	}
 	ldg, err := New([]Transaction{
 		someTxn("2020/01/01"),
@@ -494,6 +589,38 @@ func TestResync(t *testing.T) {
 	assert.True(t, ranProcess.Load())
 }
 
+func TestStoreBatch(t *testing.T) {
+	syncCount := 0
+	store := starterStore(t)
+	store.syncFile = func() error {
+		syncCount++
+		return nil
+	}
+	err := store.Batch(func() error {
+		_ = store.AddTransactions(nil)
+		_ = store.UpdateAccount("x", "y")
+		return store.Batch(func() error {
+			return store.UpdateAccount("y", "z")
+		})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, syncCount, "Batched changes should be written once")
+
+	err = store.Batch(func() error {
+		return errors.New("some error")
+	})
+	assert.EqualError(t, err, "some error")
+	assert.Equal(t, 2, syncCount, "Changes should be written after an error")
+
+	store.syncFile = func() error {
+		return errors.New("some write error")
+	}
+	err = store.Batch(func() error {
+		return NewValidateError(0, errors.New("some validation error"))
+	})
+	assert.EqualError(t, err, "some write error", "Write errors should take priority over ledger errors")
+}
+
 func TestStoreRenameAccount(t *testing.T) {
 	ranSync := false
 	syncFile := func() error {
@@ -552,6 +679,122 @@ func TestStoreUpdateTransaction(t *testing.T) {
 	assert.True(t, ranSync)
 }
 
+func TestStoreFailedBalanceAssertions(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{
+			Date: parseDate(t, "2020/01/01"),
+			Postings: []Posting{
+				{Account: "assets", Amount: *decFloat(10), Currency: usd},
+				{Account: "equity", Amount: *decFloat(-10), Currency: usd, Tags: map[string]string{idTag: OpeningBalanceID}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	syncs := 0
+	store := starterStore(t)
+	store.Ledger = ldg
+	store.syncFile = func() error {
+		syncs++
+		return nil
+	}
+	makeTxn := func(balance float64) Transaction {
+		return Transaction{
+			Date: parseDate(t, "2020/01/02"),
+			Postings: []Posting{
+				{Account: "expenses", Amount: *decFloat(1), Currency: usd, Tags: map[string]string{idTag: "my-txn"}},
+				{Account: "assets", Amount: *decFloat(-1), Balance: decFloat(balance), Currency: usd},
+			},
+		}
+	}
+
+	err = store.AddTransactions([]Transaction{makeTxn(1)})
+	require.IsType(t, Error{}, err)
+	assert.Len(t, err.(Error).Assertions, 1)
+	assert.Equal(t, 2, store.Size(), "Transaction should be added despite failed assertion")
+	assert.Equal(t, 1, syncs, "File should be written despite failed assertion")
+
+	assert.NoError(t, store.UpdateTransaction("my-txn", makeTxn(9)))
+	assert.Equal(t, 2, syncs)
+
+	err = store.UpdateTransaction("my-txn", makeTxn(2))
+	require.IsType(t, Error{}, err)
+	assert.Equal(t, 3, syncs, "File should be written despite failed assertion")
+}
+
+func TestStoreCreateTransaction(t *testing.T) {
+	ranSync := false
+	store := starterStore(t)
+	store.syncFile = func() error {
+		ranSync = true
+		return nil
+	}
+	_, err := store.CreateTransaction(Transaction{})
+	assert.Error(t, err)
+	assert.False(t, ranSync, "Invalid transactions should not sync")
+
+	id, err := store.CreateTransaction(Transaction{
+		Date: time.Now(),
+		Postings: []Posting{
+			{Account: "assets", Amount: *decFloat(1), Currency: usd},
+			{Account: "expenses", Amount: *decFloat(-1), Currency: usd},
+		},
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, id)
+	assert.True(t, ranSync)
+}
+
+func TestStoreRemoveTransaction(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{
+			Postings: []Posting{
+				{Account: "assets", Tags: map[string]string{idTag: "my-txn"}},
+				{Account: "expenses"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	ranSync := false
+	store := starterStore(t)
+	store.Ledger = ldg
+	store.syncFile = func() error {
+		ranSync = true
+		return nil
+	}
+	assert.Error(t, store.RemoveTransaction("non-existent"))
+	assert.False(t, ranSync)
+	assert.NoError(t, store.RemoveTransaction("my-txn"))
+	assert.True(t, ranSync)
+}
+
+func TestStoreReconcileTransactions(t *testing.T) {
+	ldg, err := New([]Transaction{
+		{
+			Postings: []Posting{
+				{Account: "assets", Tags: map[string]string{idTag: "my-txn"}},
+				{Account: "expenses"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	syncs := 0
+	store := starterStore(t)
+	store.Ledger = ldg
+	store.syncFile = func() error {
+		syncs++
+		return nil
+	}
+	assert.Error(t, store.ReconcileTransactions("assets", []string{"non-existent"}, time.Now()))
+	assert.Equal(t, 0, syncs)
+	assert.NoError(t, store.ReconcileTransactions("assets", []string{"my-txn"}, time.Now()))
+	assert.Equal(t, 1, syncs)
+
+	assert.Error(t, store.UnlockTransaction("non-existent"))
+	assert.Equal(t, 1, syncs)
+	assert.NoError(t, store.UnlockTransaction("my-txn"))
+	assert.Equal(t, 2, syncs)
+}
+
 func TestUpdateTransactions(t *testing.T) {
 	txn1 := Transaction{Payee: "some payee", Postings: []Posting{
 		{Account: "assets", Amount: *decFloat(10), Tags: map[string]string{idTag: "txn1"}},
@@ -588,7 +831,7 @@ func TestUpdateTransactions(t *testing.T) {
 			txns: map[string]Transaction{
 				"txn100": {},
 			},
-			expectSync: false,
+			expectSync: true,
 			expectErr:  true,
 		},
 		{
@@ -597,7 +840,7 @@ func TestUpdateTransactions(t *testing.T) {
 				"txn1":   {Postings: []Posting{{Account: "something"}}},
 				"txn100": {},
 			},
-			expectSync: false,
+			expectSync: true,
 			expectErr:  true,
 		},
 	} {
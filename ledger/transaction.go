@@ -2,12 +2,15 @@ package ledger
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/johnstarich/sage/math"
+	"github.com/johnstarich/sage/vcs"
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
 )
@@ -15,128 +18,394 @@ import (
 const (
 	idTag      = "id"
 	DateFormat = "2006/01/02"
+	// manualIDPrefix prefixes IDs generated for manually entered transactions
+	manualIDPrefix = "manual-"
+	// reconciledTag marks a reconciled posting, its value is the statement date it was reconciled against
+	reconciledTag = "reconciled"
 )
 
 var (
 	missingAmountErr = fmt.Errorf("A transaction's postings may only have one missing amount, and it must be the last posting")
 )
 
+// TransactionStatus is the clearing status of a transaction
+type TransactionStatus string
+
+const (
+	// StatusUnmarked is the default status for transactions
+	StatusUnmarked TransactionStatus = ""
+	// StatusPending marks a transaction with '!'
+	StatusPending TransactionStatus = "pending"
+	// StatusCleared marks a transaction with '*'
+	StatusCleared TransactionStatus = "cleared"
+)
+
+// symbol returns the ledger file symbol for this status
+func (s TransactionStatus) symbol() string {
+	switch s {
+	case StatusPending:
+		return "!"
+	case StatusCleared:
+		return "*"
+	default:
+		return ""
+	}
+}
+
 // Transaction is a strict(er) representation of a ledger transaction. The extra restrictions are used to verify correctness more easily.
 type Transaction struct {
-	Comment  string `json:",omitempty"`
-	Date     time.Time
-	Payee    string
-	Postings []Posting
-	Tags     map[string]string `json:",omitempty"`
+	// AuxDate is the auxiliary or effective date, like the date a payment cleared
+	AuxDate      *time.Time `json:",omitempty"`
+	Code         string     `json:",omitempty"`
+	Comment      string     `json:",omitempty"`
+	CommentLines []string   `json:",omitempty"`
+	Date         time.Time
+	Payee        string
+	Postings     []Posting
+	Status       TransactionStatus `json:",omitempty"`
+	Tags         map[string]string `json:",omitempty"`
 }
 
 type Transactions []*Transaction
 
-func readAllTransactions(scanner *bufio.Scanner) ([]Transaction, error) {
-	var transactions []Transaction
+// journal is the parsed contents of a ledger file and its included files
+type journal struct {
+	transactions []Transaction
+	prices       []Price
+	accounts     []AccountDeclaration
+	blocks       []fileBlock
+}
+
+func readAllTransactions(scanner *bufio.Scanner) (journal, error) {
+	var j journal
+	var err error
+	j.blocks, err = j.readFile(scanner, nil, nil, directiveState{})
+	return j, err
+}
+
+// readFile reads all lines from 'scanner' into the journal and returns the file's blocks.
+// 'file' is used to resolve include directives, or nil if includes aren't supported. 'includeChain' contains the keys of all including files.
+func (j *journal) readFile(scanner *bufio.Scanner, file vcs.File, includeChain []string, directives directiveState) ([]fileBlock, error) {
+	var blocks []fileBlock
 	type readerState struct {
-		txn             Transaction
-		readingPostings bool
-		missingAmount   bool
-		sum             decimal.Decimal
+		txn              Transaction
+		text             string
+		account          *AccountDeclaration
+		readingPostings  bool
+		readingDirective bool
+		readingComment   bool
+		missingAmount    bool
 	}
 
 	var state readerState
 
 	endTxn := func() error {
 		if !state.readingPostings {
+			state = readerState{}
 			return nil
 		}
 		if len(state.txn.Postings) < 2 {
 			return fmt.Errorf("A transaction must have at least two postings:\n%s", state.txn.String())
 		}
-		var total decimal.Decimal
-		for _, p := range state.txn.Postings[:len(state.txn.Postings)-1] {
-			total = total.Sub(p.Amount)
-		}
-		lastPosting := &state.txn.Postings[len(state.txn.Postings)-1]
-		if !lastPosting.Amount.Equal(total) {
+		if !state.txn.Balanced() {
 			return fmt.Errorf("Detected unbalanced transaction:\n%s", state.txn.String())
 		}
 		// valid txn
-		transactions = append(transactions, state.txn)
+		j.transactions = append(j.transactions, state.txn)
+		blocks = append(blocks, fileBlock{
+			text:       state.text,
+			txnIndex:   len(j.transactions) - 1,
+			directives: directives.copy(),
+		})
 		state = readerState{}
 		return nil
 	}
 
+	scanner.Split(scanRawLines)
 	for scanner.Scan() {
-		line := scanner.Text()
+		rawLine := scanner.Text()
+		line := strings.TrimRight(rawLine, "\r\n")
 		trimLine := strings.TrimSpace(line)
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
 		switch {
-		case trimLine == "" || trimLine[0] == ';':
-			// is blank line
-			if err := endTxn(); err != nil {
-				return nil, err
+		case state.readingComment:
+			// is inside a comment block
+			blocks = appendRawBlock(blocks, rawLine)
+			if trimLine == "end comment" {
+				state.readingComment = false
 			}
-		case !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t"):
+		case trimLine == "":
+			// is blank line
 			if err := endTxn(); err != nil {
 				return nil, err
 			}
-			// is txn payee line
-			err := parsePayeeLine(&state.txn, line)
-			if err != nil {
-				return nil, err
+			blocks = appendRawBlock(blocks, rawLine)
+		case indented && state.readingPostings:
+			state.text += rawLine
+			if trimLine[0] == ';' {
+				// is comment line inside a txn, kept with the posting before it so it's written again if the txn changes
+				comment := strings.TrimSpace(strings.TrimPrefix(trimLine, ";"))
+				if postings := state.txn.Postings; len(postings) > 0 {
+					postings[len(postings)-1].CommentLines = append(postings[len(postings)-1].CommentLines, comment)
+				} else {
+					state.txn.CommentLines = append(state.txn.CommentLines, comment)
+				}
+				continue
 			}
-			state.readingPostings = true
-		case state.readingPostings:
 			// is posting line
 			if state.missingAmount {
 				return nil, fmt.Errorf("Missing amount is only allowed on the last posting.")
 			}
 			posting, err := NewPostingFromString(line)
+			posting.Account = directives.account(posting.Account)
 			switch {
 			case err == missingAmountErr:
 				state.missingAmount = true
-				posting.Amount = state.sum
-				posting.Currency = usd
+				state.txn.Postings = append(state.txn.Postings, inferMissingAmounts(posting, state.txn.Postings)...)
 			case err != nil:
 				return nil, err
 			default:
-				state.sum = state.sum.Sub(posting.Amount)
+				state.txn.Postings = append(state.txn.Postings, posting)
 			}
-			state.txn.Postings = append(state.txn.Postings, posting)
-		default:
+		case indented && state.account != nil && trimLine[0] == ';':
+			// is account directive's comment
+			state.account.addComment(trimLine)
+			blocks = appendRawBlock(blocks, rawLine)
+		case indented && (state.readingDirective || trimLine[0] == ';'):
+			// is a directive's sub-directive or an indented comment
+			blocks = appendRawBlock(blocks, rawLine)
+		case indented:
 			return nil, fmt.Errorf("Unknown line format detected: %s", line)
+		default:
+			if err := endTxn(); err != nil {
+				return nil, err
+			}
+			if err := directives.parseDirective(line); err != nil {
+				return nil, err
+			}
+			switch {
+			case isCommentStart(line[0]):
+				// is top-level comment line
+				blocks = appendRawBlock(blocks, rawLine)
+			case trimLine == "comment":
+				// is start of comment block
+				state.readingComment = true
+				blocks = appendRawBlock(blocks, rawLine)
+			case unicode.IsDigit(rune(line[0])):
+				// is txn payee line
+				err := parsePayeeLine(&state.txn, line, directives.year)
+				if err != nil {
+					return nil, err
+				}
+				state.text = rawLine
+				state.readingPostings = true
+			case strings.HasPrefix(line, pricePrefix):
+				// is market price line
+				price, err := parsePriceLine(line)
+				if err != nil {
+					return nil, err
+				}
+				j.prices = append(j.prices, price)
+				state.readingDirective = true
+				blocks = appendRawBlock(blocks, rawLine)
+			case strings.HasPrefix(line, accountDirective+" "):
+				// is account declaration
+				account, err := parseAccountDirective(line)
+				if err != nil {
+					return nil, err
+				}
+				account.Account = directives.account(account.Account)
+				j.accounts = append(j.accounts, account)
+				state.account = &j.accounts[len(j.accounts)-1]
+				state.readingDirective = true
+				blocks = appendRawBlock(blocks, rawLine)
+			case strings.HasPrefix(line, includeDirective+" "):
+				// is included file
+				include, err := j.readInclude(line, file, includeChain, directives)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, fileBlock{text: rawLine, txnIndex: -1, include: include})
+			case isDirective(line):
+				// is another directive, preserved as-is
+				state.readingDirective = true
+				blocks = appendRawBlock(blocks, rawLine)
+			default:
+				return nil, fmt.Errorf("Unknown directive or line format detected: %s", line)
+			}
 		}
 	}
-	err := endTxn()
-	return transactions, err
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := endTxn(); err != nil {
+		return nil, err
+	}
+	return blocks, nil
 }
 
-func parsePayeeLine(txn *Transaction, line string) error {
+// readInclude reads the file included by the include directive 'line', relative to 'file'
+func (j *journal) readInclude(line string, file vcs.File, includeChain []string, directives directiveState) (*includedFile, error) {
+	path, err := parseIncludeDirective(line)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, errors.Errorf("Include directives are only supported when reading a ledger from a file: %q", line)
+	}
+	parentKey := ""
+	if len(includeChain) > 0 {
+		parentKey = includeChain[len(includeChain)-1]
+	}
+	key := includeKey(parentKey, path)
+	for _, includingKey := range includeChain {
+		if includingKey == key {
+			return nil, errors.Errorf("Include cycle detected: %s", strings.Join(append(includeChain, key), " -> "))
+		}
+	}
+
+	includeFile := file.Relative(path)
+	contents, err := includeFile.Read()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading included file %q", path)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	// directives in an included file only apply to that file
+	blocks, err := j.readFile(scanner, includeFile, append(includeChain, key), directives.copy())
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading included file %q", path)
+	}
+	return &includedFile{
+		file:   includeFile,
+		blocks: blocks,
+	}, nil
+}
+
+// inferMissingAmounts balances 'postings' of the same kind with 'missing'. Creates one posting per unbalanced commodity.
+func inferMissingAmounts(missing Posting, postings []Posting) []Posting {
+	sums := make(map[string]decimal.Decimal)
+	if missing.Kind != VirtualPosting {
+		sums = sumByCommodity(postingsOfKind(postings, missing.Kind))
+	}
+	var inferred []Posting
+	for _, commodity := range sortedCommodities(sums) {
+		if !sums[commodity].IsZero() {
+			p := missing
+			p.Amount = sums[commodity].Neg()
+			p.Currency = commodity
+			inferred = append(inferred, p)
+		}
+	}
+	if len(inferred) == 0 {
+		missing.Amount = decimal.Zero
+		missing.Currency = usd
+		if len(postings) > 0 {
+			missing.Currency = postings[0].Currency
+		}
+		inferred = append(inferred, missing)
+	}
+	return inferred
+}
+
+// parsePayeeLine parses a txn's first line, like '2020/01/02=2020/01/04 * (1234) Grocery ; comment'
+// If set, 'defaultYear' is used for dates without a year, like '01/02'
+func parsePayeeLine(txn *Transaction, line string, defaultYear int) error {
 	tokens := strings.SplitN(line, ";", 2)
 	line = strings.TrimSpace(tokens[0])
 	if len(tokens) == 2 {
 		txn.Comment, txn.Tags = parseTags(strings.TrimSpace(tokens[1]))
 	}
 	tokens = strings.SplitN(line, " ", 2)
-	date := strings.TrimSpace(tokens[0])
+	dates := strings.SplitN(strings.TrimSpace(tokens[0]), "=", 2)
+	line = ""
 	if len(tokens) == 2 {
-		txn.Payee = strings.TrimSpace(tokens[1])
+		line = strings.TrimSpace(tokens[1])
 	}
+
 	var err error
-	txn.Date, err = time.Parse(DateFormat, date)
+	txn.Date, err = parsePartialDate(dates[0], defaultYear)
 	if err != nil {
 		return err
 	}
+	if len(dates) == 2 {
+		auxDate, err := parsePartialDate(dates[1], txn.Date.Year())
+		if err != nil {
+			return errors.Wrap(err, "Invalid auxiliary date")
+		}
+		txn.AuxDate = &auxDate
+	}
+
+	switch {
+	case strings.HasPrefix(line, StatusCleared.symbol()):
+		txn.Status = StatusCleared
+	case strings.HasPrefix(line, StatusPending.symbol()):
+		txn.Status = StatusPending
+	}
+	line = strings.TrimSpace(strings.TrimPrefix(line, txn.Status.symbol()))
+
+	if strings.HasPrefix(line, "(") {
+		if end := strings.IndexRune(line, ')'); end != -1 {
+			txn.Code = strings.TrimSpace(line[1:end])
+			line = strings.TrimSpace(line[end+1:])
+		}
+	}
+	txn.Payee = line
 	return nil
 }
 
-func parseTags(comment string) (string, map[string]string) {
+// parsePartialDate parses a date, using 'defaultYear' if set and the date has no year
+func parsePartialDate(date string, defaultYear int) (time.Time, error) {
+	if defaultYear != 0 && strings.Count(date, "/") == 1 {
+		date = fmt.Sprintf("%04d/%s", defaultYear, date)
+	}
+	return time.Parse(DateFormat, date)
+}
+
+var (
+	commentEscaper   = strings.NewReplacer(`\`, `\\`, ":", `\:`)
+	commentUnescaper = strings.NewReplacer(`\\`, `\`, `\:`, ":")
+)
+
+// escapeComment escapes colons in a comment, so ledger doesn't parse the comment as tags
+func escapeComment(comment string) string {
 	if !strings.ContainsRune(comment, ':') {
-		return comment, nil
+		return comment
+	}
+	return commentEscaper.Replace(comment)
+}
+
+// unescapeComment reverses escapeComment. Comments without escaped colons are returned as-is, since escapeComment didn't change them.
+func unescapeComment(comment string) string {
+	if !strings.Contains(comment, `\:`) {
+		return comment
+	}
+	return commentUnescaper.Replace(comment)
+}
+
+// tagsStart returns the index of the first colon not escaped by escapeComment, or -1 if there isn't one
+func tagsStart(comment string) int {
+	for i := 0; i < len(comment); i++ {
+		switch comment[i] {
+		case '\\':
+			i++
+		case ':':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseTags(comment string) (string, map[string]string) {
+	colon := tagsStart(comment)
+	if colon == -1 {
+		return unescapeComment(comment), nil
 	}
 
 	tags := make(map[string]string)
-	commentEnd := strings.LastIndexByte(comment[:strings.IndexRune(comment, ':')], ' ')
+	commentEnd := strings.LastIndexByte(comment[:colon], ' ')
 	var newComment string
 	if commentEnd != -1 {
-		newComment = strings.TrimSpace(comment[:commentEnd])
+		newComment = unescapeComment(strings.TrimSpace(comment[:commentEnd]))
 	}
 	tagStrings := strings.Split(comment[commentEnd+1:], ",")
 	for _, tagString := range tagStrings {
@@ -150,7 +419,9 @@ func parseTags(comment string) (string, map[string]string) {
 	return newComment, tags
 }
 
+// serializeComment formats a comment and its tags for the end of a line. Colons in the comment are escaped, so they aren't read back as tags.
 func serializeComment(comment string, tags map[string]string) string {
+	comment = escapeComment(comment)
 	if len(tags) > 0 {
 		tagStrings := make([]string, 0, len(tags))
 		for k, v := range tags {
@@ -168,16 +439,52 @@ func serializeComment(comment string, tags map[string]string) string {
 	return comment
 }
 
+// IsReservedTag returns true if the tag is managed by Sage, like a transaction's ID, and must not be set by users or rules
+func IsReservedTag(name string) bool {
+	switch name {
+	case idTag, reconciledTag, duplicateIDsTag:
+		return true
+	default:
+		return false
+	}
+}
+
 func (t Transaction) ID() string {
 	return t.Tags[idTag]
 }
 
-func (t Transaction) Balanced() bool {
-	var sum decimal.Decimal
+// Reconciled returns true if any of the transaction's postings were reconciled against a statement. Reconciled transactions must be unlocked before updating or removing them.
+func (t Transaction) Reconciled() bool {
 	for _, p := range t.Postings {
-		sum = sum.Add(p.Amount)
+		if p.Reconciled() {
+			return true
+		}
 	}
-	return sum.IsZero()
+	return false
+}
+
+// Balanced returns true if the postings for each commodity sum to zero.
+// Real and balanced virtual postings must balance separately, virtual postings are not checked.
+func (t Transaction) Balanced() bool {
+	for _, kind := range []PostingKind{RealPosting, BalancedVirtualPosting} {
+		for _, sum := range sumByCommodity(postingsOfKind(t.Postings, kind)) {
+			if !sum.IsZero() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// postingsOfKind returns only the postings of the given kind
+func postingsOfKind(postings []Posting, kind PostingKind) []Posting {
+	var kindPostings []Posting
+	for _, p := range postings {
+		if p.Kind == kind {
+			kindPostings = append(kindPostings, p)
+		}
+	}
+	return kindPostings
 }
 
 func (t Transaction) Validate() error {
@@ -194,55 +501,44 @@ func (t Transaction) String() string {
 	postings := make([]string, 0, len(t.Postings))
 	accountLen, amountLen := 0, 0
 	for _, posting := range t.Postings {
-		accountLen = math.MaxInt(accountLen, len(posting.Account))
+		accountLen = math.MaxInt(accountLen, len(posting.formatAccount()))
 		amountLen = math.MaxInt(amountLen, len(posting.Amount.String()))
 	}
 	for _, posting := range t.Postings {
-		postings = append(postings, posting.FormatTable(-accountLen, amountLen))
+		postings = append(postings, posting.FormatTable(-accountLen, amountLen)+strings.TrimSuffix("\n"+formatCommentLines(posting.CommentLines), "\n"))
+	}
+	date := fmt.Sprintf("%4d/%02d/%02d", t.Date.Year(), t.Date.Month(), t.Date.Day())
+	if t.AuxDate != nil {
+		date += "=" + t.AuxDate.Format(DateFormat)
+	}
+	payee := t.Payee
+	if t.Code != "" {
+		payee = fmt.Sprintf("(%s) %s", t.Code, payee)
+	}
+	if t.Status != StatusUnmarked {
+		payee = t.Status.symbol() + " " + payee
 	}
 	return fmt.Sprintf(
-		"%4d/%02d/%02d %s%s\n    %s\n",
-		t.Date.Year(),
-		t.Date.Month(),
-		t.Date.Day(),
-		t.Payee,
+		"%s %s%s\n%s    %s\n",
+		date,
+		payee,
 		serializeComment(t.Comment, t.Tags),
+		formatCommentLines(t.CommentLines),
 		strings.Join(postings, "\n    "),
 	)
 }
 
+// formatCommentLines formats indented comment lines inside a transaction, each ending in a newline
+func formatCommentLines(lines []string) string {
+	var s strings.Builder
+	for _, line := range lines {
+		s.WriteString("    ; " + line + "\n")
+	}
+	return s.String()
+}
+
 func (txns Transactions) Sort() {
 	sort.SliceStable(txns, func(a, b int) bool {
 		return txns[a].Date.Before(txns[b].Date)
 	})
 }
-
-func (t Transaction) matches(search string) int {
-	payee := strings.ToLower(t.Payee)
-	comment := strings.ToLower(t.Comment)
-	date := strings.ToLower(t.Date.Format("Monday 2 January 2006"))
-	postings := make([]string, 0, len(t.Postings))
-	for _, p := range t.Postings {
-		postings = append(postings, strings.ToLower(p.Account))
-	}
-
-	score := 0
-
-	for _, token := range strings.Split(strings.ToLower(search), " ") {
-		if strings.Contains(payee, token) {
-			score++
-		}
-		if strings.Contains(comment, token) {
-			score++
-		}
-		if strings.Contains(date, token) {
-			score++
-		}
-		for _, p := range postings {
-			if strings.Contains(p, token) {
-				score++
-			}
-		}
-	}
-	return score
-}
@@ -87,6 +87,73 @@ func TestReadAllTransactions(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "status, code, and aux date",
+			input: `
+2020/01/02=01/04 * (1234) Grocery ; some comment
+	expenses:food   $ 1.25
+	assets:Bank 1
+2020/01/03 ! Pending payee
+	expenses:food   $ 1.25
+	assets:Bank 1
+			`,
+			transactions: []Transaction{
+				{
+					AuxDate: timePtr(parseDate(t, "2020/01/04")),
+					Code:    "1234",
+					Comment: "some comment",
+					Date:    parseDate(t, "2020/01/02"),
+					Payee:   "Grocery",
+					Status:  StatusCleared,
+					Postings: []Posting{
+						{Account: "expenses:food", Amount: *decFloat(1.25), Currency: usd},
+						{Account: "assets:Bank 1", Amount: *decFloat(-1.25), Currency: usd},
+					},
+				},
+				{
+					Date:   parseDate(t, "2020/01/03"),
+					Payee:  "Pending payee",
+					Status: StatusPending,
+					Postings: []Posting{
+						{Account: "expenses:food", Amount: *decFloat(1.25), Currency: usd},
+						{Account: "assets:Bank 1", Amount: *decFloat(-1.25), Currency: usd},
+					},
+				},
+			},
+		},
+		{
+			description: "virtual postings",
+			input: `
+2019/01/02 envelopes
+	expenses:food   $ 1.25
+	assets:Bank 1  $ -1.25
+	(assets:savings:goal)  $ 10.5
+	[budget:food]  $ -1.25
+	[budget:available]
+			`,
+			transactions: []Transaction{
+				{
+					Date:  parseDate(t, "2019/01/02"),
+					Payee: "envelopes",
+					Postings: []Posting{
+						{Account: "expenses:food", Amount: *decFloat(1.25), Currency: usd},
+						{Account: "assets:Bank 1", Amount: *decFloat(-1.25), Currency: usd},
+						{Account: "assets:savings:goal", Amount: *decFloat(10.5), Currency: usd, Kind: VirtualPosting},
+						{Account: "budget:food", Amount: *decFloat(-1.25), Currency: usd, Kind: BalancedVirtualPosting},
+						{Account: "budget:available", Amount: *decFloat(1.25), Currency: usd, Kind: BalancedVirtualPosting},
+					},
+				},
+			},
+		},
+		{
+			description: "invalid aux date",
+			input: `
+2020/01/02=Jan * Grocery
+	expenses:food   $ 1.25
+	assets:Bank 1
+			`,
+			shouldErr: true,
+		},
 		{
 			description: "not enough postings x1",
 			input: `
@@ -156,6 +223,58 @@ func TestReadAllTransactions(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "multiple commodities",
+			input: `
+2019/01/02 currency exchange
+	assets:Bank 1  $ -110.5
+	assets:Bank 2  100.25 EUR
+	equity:conversion  $ 110.5
+	equity:conversion  -100.25 EUR
+			`,
+			transactions: []Transaction{
+				{
+					Date:  parseDate(t, "2019/01/02"),
+					Payee: "currency exchange",
+					Postings: []Posting{
+						{Account: "assets:Bank 1", Amount: *decFloat(-110.5), Currency: usd},
+						{Account: "assets:Bank 2", Amount: *decFloat(100.25), Currency: "EUR"},
+						{Account: "equity:conversion", Amount: *decFloat(110.5), Currency: usd},
+						{Account: "equity:conversion", Amount: *decFloat(-100.25), Currency: "EUR"},
+					},
+				},
+			},
+		},
+		{
+			description: "missing amount with multiple commodities",
+			input: `
+2019/01/02 currency exchange
+	assets:Bank 1  $ -110.5
+	assets:Bank 2  100.25 EUR
+	equity:conversion
+			`,
+			transactions: []Transaction{
+				{
+					Date:  parseDate(t, "2019/01/02"),
+					Payee: "currency exchange",
+					Postings: []Posting{
+						{Account: "assets:Bank 1", Amount: *decFloat(-110.5), Currency: usd},
+						{Account: "assets:Bank 2", Amount: *decFloat(100.25), Currency: "EUR"},
+						{Account: "equity:conversion", Amount: *decFloat(110.5), Currency: usd},
+						{Account: "equity:conversion", Amount: *decFloat(-100.25), Currency: "EUR"},
+					},
+				},
+			},
+		},
+		{
+			description: "unbalanced commodities",
+			input: `
+2019/01/02 currency exchange
+	assets:Bank 1  $ -110.5
+	assets:Bank 2  110.5 EUR
+			`,
+			shouldErr: true,
+		},
 		{
 			description: "unbalanced transaction",
 			input: `
@@ -252,14 +371,14 @@ func TestReadAllTransactions(t *testing.T) {
 		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
-			txns, err := readAllTransactions(scanFromStr(tc.input))
+			j, err := readAllTransactions(scanFromStr(tc.input))
 			if tc.shouldErr {
 				assert.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
-			require.Len(t, txns, len(tc.transactions))
-			assert.Equal(t, tc.transactions, txns)
+			require.Len(t, j.transactions, len(tc.transactions))
+			assert.Equal(t, tc.transactions, j.transactions)
 		})
 	}
 }
@@ -306,6 +425,18 @@ func TestParseTags(t *testing.T) {
 			comment:     "key1: value, key2",
 			tags:        nil,
 		},
+		{
+			description: "escaped colons",
+			input:       `POS DEBIT\: 1234 id: abc`,
+			comment:     "POS DEBIT: 1234",
+			tags:        map[string]string{"id": "abc"},
+		},
+		{
+			description: "escaped colons and backslashes without tags",
+			input:       `C\:\\path`,
+			comment:     `C:\path`,
+			tags:        nil,
+		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
 			comment, tags := parseTags(tc.input)
@@ -342,6 +473,47 @@ func TestTransactionString(t *testing.T) {
 				`    assets:Bank 1  $ -1.25`,
 			),
 		},
+		{
+			description: "status, code, and aux date",
+			txn: Transaction{
+				AuxDate: timePtr(parseDate(t, "2019/01/07")),
+				Code:    "1234",
+				Date:    parseDate(t, "2019/01/05"),
+				Payee:   "somebody",
+				Postings: []Posting{
+					{Account: "expenses:food", Amount: *decFloat(1.25), Currency: usd},
+					{Account: "assets:Bank 1", Amount: *decFloat(-1.25), Currency: usd},
+				},
+				Status: StatusPending,
+			},
+			str: prep(
+				`2019/01/05=2019/01/07 ! (1234) somebody`,
+				`    expenses:food   $ 1.25`,
+				`    assets:Bank 1  $ -1.25`,
+			),
+		},
+		{
+			description: "virtual postings",
+			txn: Transaction{
+				Date:  parseDate(t, "2019/01/05"),
+				Payee: "somebody",
+				Postings: []Posting{
+					{Account: "expenses:food", Amount: *decFloat(1.25), Currency: usd},
+					{Account: "assets:Bank 1", Amount: *decFloat(-1.25), Currency: usd},
+					{Account: "budget:food", Amount: *decFloat(-1.25), Currency: usd, Kind: BalancedVirtualPosting},
+					{Account: "budget", Amount: *decFloat(1.25), Currency: usd, Kind: BalancedVirtualPosting},
+					{Account: "goal", Amount: *decFloat(5), Currency: usd, Kind: VirtualPosting},
+				},
+			},
+			str: prep(
+				`2019/01/05 somebody`,
+				`    expenses:food   $ 1.25`,
+				`    assets:Bank 1  $ -1.25`,
+				`    [budget:food]  $ -1.25`,
+				`    [budget]        $ 1.25`,
+				`    (goal)             $ 5`,
+			),
+		},
 		{
 			description: "no comment or tags",
 			txn: Transaction{
@@ -405,6 +577,61 @@ func TestTransactionBalanced(t *testing.T) {
 			},
 			balanced: true,
 		},
+		{
+			description: "unbalanced virtual postings",
+			txn: Transaction{
+				Postings: []Posting{
+					{Amount: *decFloat(1.25)},
+					{Amount: *decFloat(-1.25)},
+					{Amount: *decFloat(10), Kind: VirtualPosting},
+				},
+			},
+			balanced: true,
+		},
+		{
+			description: "balanced virtual postings",
+			txn: Transaction{
+				Postings: []Posting{
+					{Amount: *decFloat(1.25)},
+					{Amount: *decFloat(-1.25)},
+					{Amount: *decFloat(10), Kind: BalancedVirtualPosting},
+					{Amount: *decFloat(-10), Kind: BalancedVirtualPosting},
+				},
+			},
+			balanced: true,
+		},
+		{
+			description: "unbalanced balanced virtual postings",
+			txn: Transaction{
+				Postings: []Posting{
+					{Amount: *decFloat(1.25)},
+					{Amount: *decFloat(-1.25), Kind: BalancedVirtualPosting},
+				},
+			},
+			balanced: false,
+		},
+		{
+			description: "balanced commodities",
+			txn: Transaction{
+				Postings: []Posting{
+					{Amount: *decFloat(1.25), Currency: usd},
+					{Amount: *decFloat(-1), Currency: "EUR"},
+					{Amount: *decFloat(-1.25), Currency: usd},
+					{Amount: *decFloat(1), Currency: "EUR"},
+				},
+			},
+			balanced: true,
+		},
+		{
+			description: "unbalanced commodities",
+			txn: Transaction{
+				Postings: []Posting{
+					{Amount: *decFloat(1.25), Currency: usd},
+					{Amount: *decFloat(-1.25), Currency: "EUR"},
+				},
+			},
+			balanced: false,
+		},
 		{
 			description: "one unbalanced posting",
 			txn: Transaction{
@@ -435,6 +662,18 @@ func TestTransactionBalanced(t *testing.T) {
 			},
 			balanced: false,
 		},
+		{
+			description: "multiple postings with one unbalanced commodity",
+			txn: Transaction{
+				Postings: []Posting{
+					{Amount: *decFloat(100), Currency: usd},
+					{Amount: *decFloat(-100), Currency: usd},
+					{Amount: *decFloat(100.25), Currency: "EUR"},
+					{Amount: *decFloat(-100), Currency: "EUR"},
+				},
+			},
+			balanced: false,
+		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
 			assert.Equal(t, tc.balanced, tc.txn.Balanced())
@@ -468,3 +707,26 @@ func TestTransactionValidate(t *testing.T) {
 		assert.True(t, strings.HasPrefix(err.Error(), "Transaction is not balanced - postings do not sum to zero:"))
 	})
 }
+
+func TestIsReservedTag(t *testing.T) {
+	for _, tag := range []string{"id", "reconciled", "duplicates"} {
+		assert.True(t, IsReservedTag(tag), tag)
+	}
+	assert.False(t, IsReservedTag("trip"))
+}
+
+func TestSerializeCommentRoundTrip(t *testing.T) {
+	for _, comment := range []string{
+		"hey there",
+		"POS DEBIT: 1234 store: 5",
+		`C:\path`,
+		`back\slash`,
+	} {
+		t.Run(comment, func(t *testing.T) {
+			tags := map[string]string{"id": "abc"}
+			readComment, readTags := parseTags(strings.TrimPrefix(serializeComment(comment, tags), " ; "))
+			assert.Equal(t, comment, readComment)
+			assert.Equal(t, tags, readTags)
+		})
+	}
+}
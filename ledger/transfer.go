@@ -0,0 +1,161 @@
+package ledger
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// TransferWindow is the maximum time between both sides of a transfer, like a checking account payment and the matching credit card credit
+	TransferWindow = 5 * day
+	// uncategorizedAccount is the balancing account of imported transactions, same as model.Uncategorized
+	uncategorizedAccount = "uncategorized"
+	// transfersAccount is the default rules' category for transfers, like credit card payments
+	transfersAccount = "expenses:transfers"
+)
+
+// MergeTransfers pairs transfers between the given accounts and merges each pair into one transaction with both accounts' postings, keeping both IDs.
+// A transfer is a pair of transactions for different accounts with opposite amounts in the same currency, dated within TransferWindow of each other.
+// New transactions are first paired with each other, then remaining ones are merged into unreconciled transactions already in the ledger.
+// Existing transactions are only merged if they aren't categorized yet, so a user's categories are never replaced.
+// Returns the new transactions, excluding any merged into the ledger.
+func (l *Ledger) MergeTransfers(txns []Transaction, accounts []string) []Transaction {
+	return l.mergeTransfers(txns, accounts, true)
+}
+
+// MergeNewTransfers is like MergeTransfers, but only merges new transactions with each other. Transactions in the ledger are not changed.
+func (l *Ledger) MergeNewTransfers(txns []Transaction, accounts []string) []Transaction {
+	return l.mergeTransfers(txns, accounts, false)
+}
+
+func (l *Ledger) mergeTransfers(txns []Transaction, accounts []string, mergeExisting bool) []Transaction {
+	accountSet := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		accountSet[account] = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// merged transactions are dropped from the result, paired transactions were merged with another
+	merged := make([]bool, len(txns))
+	paired := make([]bool, len(txns))
+	var candidates []int
+	for i, txn := range txns {
+		// skip transactions already in the ledger, they'll be dropped as duplicates
+		if isTransferCandidate(txn, accountSet) && l.idSet[txn.Postings[0].ID()] == nil {
+			candidates = append(candidates, i)
+		}
+	}
+
+	// pair the closest dated transactions first
+	type pair struct {
+		a, b     int
+		distance time.Duration
+	}
+	var pairs []pair
+	for ix, i := range candidates {
+		for _, j := range candidates[ix+1:] {
+			if isTransferPair(txns[i], txns[j]) {
+				pairs = append(pairs, pair{a: i, b: j, distance: absDuration(txns[i].Date.Sub(txns[j].Date))})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool {
+		return pairs[a].distance < pairs[b].distance
+	})
+	for _, p := range pairs {
+		if paired[p.a] || paired[p.b] {
+			continue
+		}
+		first, second := p.a, p.b
+		if txns[second].Date.Before(txns[first].Date) {
+			first, second = second, first
+		}
+		txns[first] = mergeTransfer(txns[first], txns[second])
+		paired[p.a], paired[p.b] = true, true
+		merged[second] = true
+	}
+
+	for _, i := range candidates {
+		if paired[i] || !mergeExisting {
+			continue
+		}
+		var match *Transaction
+		var matchDistance time.Duration
+		for _, existing := range l.transactions {
+			if existing.Reconciled() ||
+				!isTransferCandidate(*existing, accountSet) ||
+				!isTransferPlaceholder(existing.Postings[1].Account) ||
+				!isTransferPair(*existing, txns[i]) {
+				continue
+			}
+			if distance := absDuration(existing.Date.Sub(txns[i].Date)); match == nil || distance < matchDistance {
+				match, matchDistance = existing, distance
+			}
+		}
+		if match != nil {
+			*match = mergeTransfer(*match, txns[i])
+			if id := txns[i].Postings[0].ID(); id != "" {
+				l.idSet[id] = match
+			}
+			l.markChanged(match)
+			merged[i] = true
+		}
+	}
+
+	result := make([]Transaction, 0, len(txns))
+	for i, txn := range txns {
+		if !merged[i] {
+			result = append(result, txn)
+		}
+	}
+	return result
+}
+
+// isTransferCandidate returns true if 'txn' is one side of a possible transfer: a non-zero posting to one of 'accounts' balanced by a single posting to another kind of account
+func isTransferCandidate(txn Transaction, accounts map[string]bool) bool {
+	if len(txn.Postings) != 2 {
+		return false
+	}
+	first, second := txn.Postings[0], txn.Postings[1]
+	return accounts[first.Account] &&
+		!accounts[second.Account] &&
+		!first.Kind.IsVirtual() &&
+		!second.Kind.IsVirtual() &&
+		!first.Amount.IsZero()
+}
+
+// isTransferPlaceholder returns true if 'account' only stands in for the other side of a transfer, like 'uncategorized' or 'expenses:transfers'
+func isTransferPlaceholder(account string) bool {
+	lastColon := strings.LastIndexByte(account, ':')
+	return strings.EqualFold(account[lastColon+1:], uncategorizedAccount) ||
+		account == transfersAccount ||
+		strings.HasPrefix(account, transfersAccount+":")
+}
+
+// isTransferPair returns true if 'a' and 'b' are opposite sides of a transfer between different accounts. Assumes both are transfer candidates.
+func isTransferPair(a, b Transaction) bool {
+	postingA, postingB := a.Postings[0], b.Postings[0]
+	return postingA.Account != postingB.Account &&
+		postingA.Currency == postingB.Currency &&
+		postingA.Amount.Equal(postingB.Amount.Neg()) &&
+		absDuration(a.Date.Sub(b.Date)) <= TransferWindow
+}
+
+// mergeTransfer returns 'txn' with its balancing posting replaced by the other side's first posting
+func mergeTransfer(txn, other Transaction) Transaction {
+	otherPosting := other.Postings[0]
+	if otherPosting.Comment == "" && other.Payee != txn.Payee {
+		otherPosting.Comment = other.Payee
+	}
+	txn.Postings = []Posting{txn.Postings[0], otherPosting}
+	return txn
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,143 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeTransfers(t *testing.T) {
+	const (
+		checking = "assets:Bank:****1234"
+		card     = "liabilities:Card:****5678"
+		savings  = "assets:Bank:****9012"
+	)
+	accounts := []string{checking, card, savings}
+	sideTxn := func(id, date, payee, account string, amount float64, category string) Transaction {
+		return Transaction{
+			Date:  parseDate(t, date),
+			Payee: payee,
+			Postings: []Posting{
+				{Account: account, Amount: *decFloat(amount), Currency: usd, Tags: makeIDTag(id)},
+				{Account: category, Amount: *decFloat(-amount), Currency: usd},
+			},
+		}
+	}
+	postingIDs := func(txn Transaction) []string {
+		var ids []string
+		for _, p := range txn.Postings {
+			ids = append(ids, p.Account+" "+p.ID())
+		}
+		return ids
+	}
+
+	t.Run("merge new transactions", func(t *testing.T) {
+		ldg, err := New(nil)
+		require.NoError(t, err)
+		differentCurrency := sideTxn("savings-1", "2020/01/04", "Different currency", savings, 4.25, "revenues:uncategorized")
+		differentCurrency.Postings[0].Currency = "EUR"
+		differentCurrency.Postings[1].Currency = "EUR"
+		txns := ldg.MergeTransfers([]Transaction{
+			sideTxn("card-1", "2020/01/03", "Payment received", card, 100.5, "revenues:uncategorized"),
+			sideTxn("checking-1", "2020/01/01", "Card autopay", checking, -100.5, "expenses:uncategorized"),
+			sideTxn("checking-2", "2020/01/02", "Coffee", checking, -4.25, "expenses:food"),
+			sideTxn("checking-3", "2020/01/20", "Too late", checking, -100.5, "expenses:uncategorized"),
+			differentCurrency,
+		}, accounts)
+
+		require.Len(t, txns, 4)
+		assert.Equal(t, "Card autopay", txns[0].Payee, "The earlier transaction should be kept")
+		assert.Equal(t, []string{checking + " checking-1", card + " card-1"}, postingIDs(txns[0]))
+		assert.Equal(t, "Payment received", txns[0].Postings[1].Comment)
+		assert.True(t, txns[0].Balanced())
+		for i, payee := range []string{"Coffee", "Too late", "Different currency"} {
+			assert.Equal(t, payee, txns[i+1].Payee)
+			assert.Len(t, txns[i+1].Postings, 2)
+			assert.Empty(t, txns[i+1].Postings[1].Tags)
+		}
+	})
+
+	t.Run("picks the closest date", func(t *testing.T) {
+		ldg, err := New(nil)
+		require.NoError(t, err)
+		txns := ldg.MergeTransfers([]Transaction{
+			sideTxn("checking-1", "2020/01/01", "Payment", checking, -10, "expenses:uncategorized"),
+			sideTxn("checking-2", "2020/01/04", "Payment", checking, -10, "expenses:uncategorized"),
+			sideTxn("card-1", "2020/01/05", "Payment", card, 10, "revenues:uncategorized"),
+		}, accounts)
+		require.Len(t, txns, 2)
+		assert.Equal(t, []string{checking + " checking-1", "expenses:uncategorized "}, postingIDs(txns[0]))
+		assert.Equal(t, []string{checking + " checking-2", card + " card-1"}, postingIDs(txns[1]))
+		assert.Empty(t, txns[1].Postings[1].Comment, "Same payee should not be added as a comment")
+	})
+
+	t.Run("merge into existing transactions", func(t *testing.T) {
+		ldg, err := New([]Transaction{
+			sideTxn("checking-1", "2020/01/01", "Card autopay", checking, -100.5, "expenses:uncategorized"),
+			sideTxn("checking-2", "2020/01/02", "Reconciled", checking, -20, "expenses:uncategorized"),
+		})
+		require.NoError(t, err)
+		require.NoError(t, ldg.ReconcileTransactions(checking, []string{"checking-2"}, parseDate(t, "2020/01/31")))
+
+		txns := ldg.MergeTransfers([]Transaction{
+			sideTxn("checking-1", "2020/01/01", "Card autopay", checking, -100.5, "expenses:uncategorized"),
+			sideTxn("card-1", "2020/01/03", "Payment received", card, 100.5, "revenues:uncategorized"),
+			sideTxn("card-2", "2020/01/03", "Payment received", card, 20, "revenues:uncategorized"),
+		}, accounts)
+		require.Len(t, txns, 2, "Should not merge a re-downloaded transaction or a reconciled one")
+		assert.Equal(t, []string{checking + " checking-1", "expenses:uncategorized "}, postingIDs(txns[0]))
+		assert.Equal(t, []string{card + " card-2", "revenues:uncategorized "}, postingIDs(txns[1]))
+
+		existing, found := ldg.Transaction("card-1")
+		require.True(t, found, "Merged ID should be found in the ledger")
+		assert.Equal(t, []string{checking + " checking-1", card + " card-1"}, postingIDs(existing))
+		assert.Contains(t, ldg.String(), card)
+		require.NoError(t, ldg.AddTransactions(txns))
+		assert.Equal(t, 3, ldg.Size())
+	})
+
+	t.Run("categorized existing transactions are left alone", func(t *testing.T) {
+		ldg, err := New([]Transaction{
+			sideTxn("checking-1", "2020/01/01", "Grocery store", checking, -50, "expenses:groceries"),
+			sideTxn("checking-2", "2020/01/02", "Card autopay", checking, -30, "expenses:transfers:credit card payments"),
+		})
+		require.NoError(t, err)
+
+		txns := ldg.MergeTransfers([]Transaction{
+			sideTxn("card-1", "2020/01/03", "Refund", card, 50, "revenues:uncategorized"),
+			sideTxn("card-2", "2020/01/03", "Payment received", card, 30, "revenues:uncategorized"),
+		}, accounts)
+		require.Len(t, txns, 1)
+		assert.Equal(t, []string{card + " card-1", "revenues:uncategorized "}, postingIDs(txns[0]))
+
+		existing, found := ldg.Transaction("checking-1")
+		require.True(t, found)
+		assert.Equal(t, []string{checking + " checking-1", "expenses:groceries "}, postingIDs(existing), "Categorized transactions should not change")
+		existing, found = ldg.Transaction("checking-2")
+		require.True(t, found)
+		assert.Equal(t, []string{checking + " checking-2", card + " card-2"}, postingIDs(existing), "Transfer placeholders should be merged")
+	})
+
+	t.Run("merge only new transactions", func(t *testing.T) {
+		ldg, err := New([]Transaction{
+			sideTxn("checking-1", "2020/01/01", "Card autopay", checking, -100.5, "expenses:uncategorized"),
+		})
+		require.NoError(t, err)
+
+		txns := ldg.MergeNewTransfers([]Transaction{
+			sideTxn("card-1", "2020/01/03", "Payment received", card, 100.5, "revenues:uncategorized"),
+			sideTxn("checking-2", "2020/01/04", "Transfer", checking, -20, "expenses:uncategorized"),
+			sideTxn("savings-1", "2020/01/05", "Transfer", savings, 20, "revenues:uncategorized"),
+		}, accounts)
+		require.Len(t, txns, 2)
+		assert.Equal(t, []string{card + " card-1", "revenues:uncategorized "}, postingIDs(txns[0]))
+		assert.Equal(t, []string{checking + " checking-2", savings + " savings-1"}, postingIDs(txns[1]))
+
+		existing, found := ldg.Transaction("checking-1")
+		require.True(t, found)
+		assert.Equal(t, []string{checking + " checking-1", "expenses:uncategorized "}, postingIDs(existing), "Existing transactions should not change")
+		_, found = ldg.Transaction("card-1")
+		assert.False(t, found)
+	})
+}
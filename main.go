@@ -14,8 +14,11 @@ import (
 	_ "github.com/johnstarich/sage/client/direct/drivers"
 	_ "github.com/johnstarich/sage/client/web/drivers"
 	"github.com/johnstarich/sage/consts"
+	"github.com/johnstarich/sage/duplicate"
+	"github.com/johnstarich/sage/inbox"
 	"github.com/johnstarich/sage/ledger"
 	"github.com/johnstarich/sage/plaindb"
+	"github.com/johnstarich/sage/reconcile"
 	"github.com/johnstarich/sage/redactor"
 	"github.com/johnstarich/sage/rules"
 	"github.com/johnstarich/sage/server"
@@ -25,14 +28,14 @@ import (
 	"go.uber.org/zap"
 )
 
-func loadRules(fileName string) (rules.Rules, error) {
+func loadRules(fileName string) (*rules.Store, error) {
 	rulesFile, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0600)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Error opening rules file '%s'", fileName)
 	}
 	defer rulesFile.Close()
-	r, err := rules.NewCSVRulesFromReader(rulesFile)
-	return r, errors.Wrapf(err, "Error reading rules from file '%s'", fileName)
+	r, csvImport, err := rules.NewCSVRulesFromReader(rulesFile)
+	return rules.NewStore(r, csvImport), errors.Wrapf(err, "Error reading rules from file '%s'", fileName)
 }
 
 func getLogger() (*zap.Logger, error) {
@@ -47,12 +50,15 @@ func start(
 	db plaindb.DB,
 	ldgStore *ledger.Store,
 	accountStore *client.AccountStore,
+	duplicateStore *duplicate.Store,
+	inboxStore *inbox.Store,
+	reconcileStore *reconcile.Store,
 	rulesFile vcs.File, rulesStore *rules.Store,
 	logger *zap.Logger,
 	options server.Options,
 ) error {
 	if !isServer {
-		sync.Sync(ldgStore, accountStore, rulesStore, false)
+		sync.Sync(logger, ldgStore, accountStore, rulesStore, duplicateStore, inboxStore, false)
 		for {
 			// TODO add CLI prompt support
 			syncing, _, err := ldgStore.SyncStatus()
@@ -63,7 +69,7 @@ func start(
 		}
 	}
 	gin.SetMode(gin.ReleaseMode)
-	err := server.Run(db, ldgStore, accountStore, rulesFile, rulesStore, logger, options)
+	err := server.Run(db, ldgStore, accountStore, duplicateStore, inboxStore, reconcileStore, rulesFile, rulesStore, logger, options)
 	if err != nil {
 		logger.Error("Server run failed", zap.Error(err))
 	}
@@ -106,6 +112,7 @@ func handleErrors(db *plaindb.DB) (usageErr bool, err error) {
 	dbDirName := flagSet.String("data", "", "Required: Path to a database directory")
 	requestVersion := flagSet.Bool("version", false, "Print the version and exit")
 	serverPassword := flagSet.String("password", "", "A password to lock the web UI and API")
+	review := flagSet.Bool("review", false, "Stages downloaded transactions in an inbox for review before adding them to the ledger")
 	if err := flagSet.Parse(os.Args[1:]); err != nil {
 		return true, err
 	}
@@ -141,6 +148,21 @@ func handleErrors(db *plaindb.DB) (usageErr bool, err error) {
 		return false, err
 	}
 
+	duplicateStore, err := duplicate.NewStore(*db)
+	if err != nil {
+		return false, err
+	}
+
+	inboxStore, err := inbox.NewStore(*db, *review)
+	if err != nil {
+		return false, err
+	}
+
+	reconcileStore, err := reconcile.NewStore(*db)
+	if err != nil {
+		return false, err
+	}
+
 	logger, err := getLogger()
 	if err != nil {
 		return false, err
@@ -151,14 +173,13 @@ func handleErrors(db *plaindb.DB) (usageErr bool, err error) {
 		return false, err
 	}
 
-	r, err := loadRules(*rulesFileName)
+	rulesStore, err := loadRules(*rulesFileName)
 	if err != nil {
 		return false, err
 	}
-	rulesStore := rules.NewStore(r)
 	rulesFile := repo.File(*rulesFileName)
 
-	return false, start(*isServer, *db, ldgStore, accountStore, rulesFile, rulesStore, logger, server.Options{
+	return false, start(*isServer, *db, ldgStore, accountStore, duplicateStore, inboxStore, reconcileStore, rulesFile, rulesStore, logger, server.Options{
 		Address:  fmt.Sprintf("0.0.0.0:%d", port),
 		AutoSync: !*noSyncLoop,
 		Password: redactor.String(*serverPassword),
@@ -0,0 +1,148 @@
+// Package reconcile reconciles ledger accounts against bank statements
+package reconcile
+
+import (
+	"strings"
+	"time"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const idDateFormat = "2006-01-02"
+
+// Reconciliation matches an account's transactions against a statement's ending balance
+type Reconciliation struct {
+	Account          string
+	StatementDate    time.Time
+	StatementBalance decimal.Decimal
+	// TransactionIDs are the transactions ticked off against the statement
+	TransactionIDs []string `json:",omitempty"`
+	// Finished is set once the ticked transactions are reconciled in the ledger
+	Finished bool `json:",omitempty"`
+}
+
+// Ledger reads and reconciles transactions, like a *ledger.Store
+type Ledger interface {
+	TransactionsBetween(start, end time.Time) []ledger.Transaction
+	ReconcileTransactions(account string, ids []string, statementDate time.Time) error
+}
+
+// Status is a reconciliation's progress against the ledger
+type Status struct {
+	Reconciliation
+	ID string
+	// ClearedBalance is the account's balance from opening balances, previously reconciled transactions, and ticked off transactions
+	ClearedBalance decimal.Decimal
+	// Difference is the statement balance minus the cleared balance. The reconciliation can finish once it's zero.
+	Difference decimal.Decimal
+	// Transactions are the account's transactions up to the statement date which are not yet reconciled
+	Transactions []ledger.Transaction
+}
+
+// New returns a reconciliation for an asset or liability account's statement
+func New(account string, statementDate time.Time, statementBalance decimal.Decimal) (Reconciliation, error) {
+	topLevel := strings.ToLower(strings.SplitN(account, ":", 2)[0])
+	if topLevel != model.AssetAccount && topLevel != model.LiabilityAccount {
+		return Reconciliation{}, errors.Errorf("Only %s and %s accounts can be reconciled: %q", model.AssetAccount, model.LiabilityAccount, account)
+	}
+	if statementDate.IsZero() {
+		return Reconciliation{}, errors.New("Statement date is required")
+	}
+	year, month, day := statementDate.Date()
+	return Reconciliation{
+		Account:          account,
+		StatementDate:    time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+		StatementBalance: statementBalance,
+	}, nil
+}
+
+// ID returns the reconciliation's unique ID, made of its account and statement date
+func (r Reconciliation) ID() string {
+	return r.Account + "@" + r.StatementDate.Format(idDateFormat)
+}
+
+func (r Reconciliation) matchesAccount(account string) bool {
+	return account == r.Account || strings.HasPrefix(account, r.Account+":")
+}
+
+// setCleared ticks off the transactions if 'cleared' is true, otherwise unticks them
+func (r *Reconciliation) setCleared(txnIDs []string, cleared bool) error {
+	if r.Finished {
+		return errors.New("Reconciliation is already finished: " + r.ID())
+	}
+	ticked := make(map[string]bool, len(r.TransactionIDs))
+	for _, id := range r.TransactionIDs {
+		ticked[id] = true
+	}
+	for _, id := range txnIDs {
+		if cleared && !ticked[id] {
+			r.TransactionIDs = append(r.TransactionIDs, id)
+		}
+		ticked[id] = cleared
+	}
+	txnIDs = r.TransactionIDs[:0]
+	for _, id := range r.TransactionIDs {
+		if ticked[id] {
+			txnIDs = append(txnIDs, id)
+		}
+	}
+	r.TransactionIDs = txnIDs
+	return nil
+}
+
+// Status returns the reconciliation's cleared balance and the transactions remaining to reconcile
+func (r Reconciliation) Status(ldg Ledger) Status {
+	ticked := make(map[string]bool, len(r.TransactionIDs))
+	for _, id := range r.TransactionIDs {
+		ticked[id] = true
+	}
+	status := Status{
+		Reconciliation: r,
+		ID:             r.ID(),
+	}
+	for _, txn := range ldg.TransactionsBetween(time.Time{}, r.StatementDate) {
+		var amount decimal.Decimal
+		matches, reconciled := false, true
+		for _, p := range txn.Postings {
+			if !p.Kind.IsVirtual() && r.matchesAccount(p.Account) {
+				amount = amount.Add(p.Amount)
+				matches = true
+				reconciled = reconciled && p.Reconciled()
+			}
+		}
+		if !matches {
+			continue
+		}
+		switch {
+		case reconciled || isOpeningBalance(txn):
+			status.ClearedBalance = status.ClearedBalance.Add(amount)
+		case ticked[transactionID(txn)]:
+			status.ClearedBalance = status.ClearedBalance.Add(amount)
+			status.Transactions = append(status.Transactions, txn)
+		default:
+			status.Transactions = append(status.Transactions, txn)
+		}
+	}
+	status.Difference = r.StatementBalance.Sub(status.ClearedBalance)
+	return status
+}
+
+// transactionID returns the ID used to identify 'txn', the first posting's ID
+func transactionID(txn ledger.Transaction) string {
+	if len(txn.Postings) == 0 {
+		return ""
+	}
+	return txn.Postings[0].ID()
+}
+
+func isOpeningBalance(txn ledger.Transaction) bool {
+	for _, p := range txn.Postings {
+		if p.IsOpeningBalance() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package reconcile
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJournal = `
+2019/12/31 Opening Balances
+    assets:Bank:Checking   $100.00
+    equity:Opening Balances  ; id: Opening-Balance
+
+2020/01/02 Coffee
+    assets:Bank:Checking   $-4.25  ; id: txn-1
+    expenses:Food
+
+2020/01/10 Paycheck
+    assets:Bank:Checking   $1000.10  ; id: txn-2
+    revenues:Salary
+
+2020/01/20 Rent
+    assets:Bank:Checking   $-500.00  ; id: txn-3
+    expenses:Rent
+
+2020/02/01 Groceries
+    assets:Bank:Checking   $-20.00  ; id: txn-4
+    expenses:Food
+`
+
+func parseLedger(t *testing.T) *ledger.Ledger {
+	ldg, err := ledger.NewFromReader(strings.NewReader(testJournal))
+	require.NoError(t, err)
+	return ldg
+}
+
+func parseDate(t *testing.T, s string) time.Time {
+	date, err := time.Parse(ledger.DateFormat, s)
+	require.NoError(t, err)
+	return date
+}
+
+func dec(t *testing.T, s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	require.NoError(t, err)
+	return d
+}
+
+func transactionIDs(txns []ledger.Transaction) []string {
+	ids := make([]string, len(txns))
+	for i, txn := range txns {
+		ids[i] = transactionID(txn)
+	}
+	return ids
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("expenses:Food", parseDate(t, "2020/01/31"), decimal.Zero)
+	assert.EqualError(t, err, `Only assets and liabilities accounts can be reconciled: "expenses:Food"`)
+	_, err = New("assets:Bank", time.Time{}, decimal.Zero)
+	assert.EqualError(t, err, "Statement date is required")
+
+	r, err := New("liabilities:Credit Card", time.Date(2020, 1, 31, 12, 30, 0, 0, time.UTC), dec(t, "10.5"))
+	require.NoError(t, err)
+	assert.Equal(t, parseDate(t, "2020/01/31"), r.StatementDate)
+	assert.Equal(t, "liabilities:Credit Card@2020-01-31", r.ID())
+}
+
+func TestSetCleared(t *testing.T) {
+	r := Reconciliation{TransactionIDs: []string{"a"}}
+	require.NoError(t, r.setCleared([]string{"b", "c", "a"}, true))
+	assert.Equal(t, []string{"a", "b", "c"}, r.TransactionIDs)
+	require.NoError(t, r.setCleared([]string{"b", "d"}, false))
+	assert.Equal(t, []string{"a", "c"}, r.TransactionIDs)
+
+	r.Finished = true
+	assert.Error(t, r.setCleared([]string{"d"}, true))
+}
+
+func TestStatus(t *testing.T) {
+	ldg := parseLedger(t)
+	r, err := New("assets:Bank", parseDate(t, "2020/01/31"), dec(t, "595.85"))
+	require.NoError(t, err)
+
+	status := r.Status(ldg)
+	assert.Equal(t, "assets:Bank@2020-01-31", status.ID)
+	assert.Equal(t, "100", status.ClearedBalance.String(), "Opening balances are always cleared")
+	assert.Equal(t, "495.85", status.Difference.String())
+	assert.Equal(t, []string{"txn-1", "txn-2", "txn-3"}, transactionIDs(status.Transactions))
+
+	require.NoError(t, r.setCleared([]string{"txn-1", "txn-2"}, true))
+	status = r.Status(ldg)
+	assert.Equal(t, "1095.85", status.ClearedBalance.String())
+	assert.Equal(t, "-500", status.Difference.String())
+	assert.Equal(t, []string{"txn-1", "txn-2", "txn-3"}, transactionIDs(status.Transactions))
+
+	require.NoError(t, r.setCleared([]string{"txn-3"}, true))
+	status = r.Status(ldg)
+	assert.True(t, status.Difference.IsZero())
+
+	require.NoError(t, ldg.ReconcileTransactions(r.Account, r.TransactionIDs, r.StatementDate))
+	next, err := New("assets:Bank", parseDate(t, "2020/02/29"), dec(t, "575.85"))
+	require.NoError(t, err)
+	status = next.Status(ldg)
+	assert.Equal(t, "595.85", status.ClearedBalance.String(), "Reconciled transactions are cleared")
+	assert.Equal(t, []string{"txn-4"}, transactionIDs(status.Transactions))
+}
+
+func TestStatusTransfer(t *testing.T) {
+	ldg, err := ledger.NewFromReader(strings.NewReader(`
+2020/01/05 Card payment
+    assets:Bank:Checking   $-50.00  ; id: txn-1
+    liabilities:Card   $50.00
+`))
+	require.NoError(t, err)
+	checking, err := New("assets:Bank", parseDate(t, "2020/01/31"), dec(t, "-50"))
+	require.NoError(t, err)
+	require.NoError(t, ldg.ReconcileTransactions(checking.Account, []string{"txn-1"}, checking.StatementDate))
+	assert.Equal(t, "-50", checking.Status(ldg).ClearedBalance.String())
+
+	card, err := New("liabilities:Card", parseDate(t, "2020/01/31"), dec(t, "50"))
+	require.NoError(t, err)
+	status := card.Status(ldg)
+	assert.True(t, status.ClearedBalance.IsZero(), "Reconciling one account should not clear the other side of a transfer")
+	assert.Equal(t, []string{"txn-1"}, transactionIDs(status.Transactions))
+}
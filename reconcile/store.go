@@ -0,0 +1,167 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johnstarich/sage/pipe"
+	"github.com/johnstarich/sage/plaindb"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Store manages reconciliations
+type Store struct {
+	mu     sync.Mutex
+	bucket plaindb.Bucket
+}
+
+// NewStore returns the reconciliations bucket
+func NewStore(db plaindb.DB) (*Store, error) {
+	bucket, err := db.Bucket("reconciliations", "1", &storeUpgrader{})
+	return &Store{
+		bucket: bucket,
+	}, err
+}
+
+type storeUpgrader struct{}
+
+func (u *storeUpgrader) Parse(dataVersion, id string, data json.RawMessage) (interface{}, error) {
+	switch dataVersion {
+	case "1":
+		var reconciliation Reconciliation
+		err := json.Unmarshal(data, &reconciliation)
+		return reconciliation, err
+	default:
+		return nil, errors.Errorf("Unsupported version: %q", dataVersion)
+	}
+}
+
+func (u *storeUpgrader) Upgrade(dataVersion, id string, data interface{}) (newVersion string, newData interface{}, err error) {
+	return dataVersion, data, nil
+}
+
+// Get returns the reconciliation with ID 'id'
+func (s *Store) Get(id string) (Reconciliation, bool, error) {
+	var reconciliation Reconciliation
+	found, err := s.bucket.Get(id, &reconciliation)
+	return reconciliation, found, err
+}
+
+func (s *Store) get(id string) (Reconciliation, error) {
+	reconciliation, found, err := s.Get(id)
+	if err == nil && !found {
+		err = errors.New("Reconciliation not found: " + id)
+	}
+	return reconciliation, err
+}
+
+// List returns all reconciliations for 'account' sorted by statement date, or all reconciliations if 'account' is empty
+func (s *Store) List(account string) ([]Reconciliation, error) {
+	var reconciliation Reconciliation
+	var reconciliations []Reconciliation
+	err := s.bucket.Iter(&reconciliation, func(string) bool {
+		if account == "" || reconciliation.Account == account {
+			reconciliations = append(reconciliations, reconciliation)
+		}
+		return true
+	})
+	sort.Slice(reconciliations, func(a, b int) bool {
+		if reconciliations[a].StatementDate.Equal(reconciliations[b].StatementDate) {
+			return reconciliations[a].Account < reconciliations[b].Account
+		}
+		return reconciliations[a].StatementDate.Before(reconciliations[b].StatementDate)
+	})
+	return reconciliations, err
+}
+
+// Start begins reconciling an account's statement. Starting an unfinished reconciliation again updates its statement balance.
+func (s *Store) Start(account string, statementDate time.Time, statementBalance decimal.Decimal) (Reconciliation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reconciliation, err := New(account, statementDate, statementBalance)
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	existing, found, err := s.Get(reconciliation.ID())
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	if found {
+		if existing.Finished {
+			return Reconciliation{}, errors.New("Reconciliation is already finished: " + existing.ID())
+		}
+		reconciliation.TransactionIDs = existing.TransactionIDs
+	}
+	return reconciliation, s.bucket.Put(reconciliation.ID(), reconciliation)
+}
+
+// SetCleared ticks off transactions against the statement if 'cleared' is true, otherwise unticks them
+func (s *Store) SetCleared(id string, txnIDs []string, cleared bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reconciliation Reconciliation
+	return pipe.OpFuncs{
+		func() error {
+			var err error
+			reconciliation, err = s.get(id)
+			return err
+		},
+		func() error {
+			return reconciliation.setCleared(txnIDs, cleared)
+		},
+		func() error {
+			return s.bucket.Put(id, reconciliation)
+		},
+	}.Do()
+}
+
+// Finish reconciles the ticked off transactions in the ledger. The cleared balance must match the statement balance.
+func (s *Store) Finish(id string, ldg Ledger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reconciliation Reconciliation
+	return pipe.OpFuncs{
+		func() error {
+			var err error
+			reconciliation, err = s.get(id)
+			return err
+		},
+		func() error {
+			if reconciliation.Finished {
+				return errors.New("Reconciliation is already finished: " + id)
+			}
+			if status := reconciliation.Status(ldg); !status.Difference.IsZero() {
+				return errors.Errorf("Reconciliation does not match the statement balance, difference is %s", status.Difference)
+			}
+			return nil
+		},
+		func() error {
+			return ldg.ReconcileTransactions(reconciliation.Account, reconciliation.TransactionIDs, reconciliation.StatementDate)
+		},
+		func() error {
+			reconciliation.Finished = true
+			return s.bucket.Put(id, reconciliation)
+		},
+	}.Do()
+}
+
+// Remove deletes an unfinished reconciliation
+func (s *Store) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return pipe.OpFuncs{
+		func() error {
+			reconciliation, err := s.get(id)
+			if err == nil && reconciliation.Finished {
+				err = errors.New("Finished reconciliations cannot be removed: " + id)
+			}
+			return err
+		},
+		func() error {
+			return s.bucket.Put(id, nil)
+		},
+	}.Do()
+}
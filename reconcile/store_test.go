@@ -0,0 +1,105 @@
+package reconcile
+
+import (
+	"testing"
+
+	"github.com/johnstarich/sage/plaindb"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockDBStore(t *testing.T) *Store {
+	db := plaindb.NewMockDB(plaindb.MockConfig{FileReader: func(fileName string) ([]byte, error) {
+		return []byte(`{}`), nil
+	}})
+	store, err := NewStore(db)
+	require.NoError(t, err)
+	return store
+}
+
+func TestStoreStart(t *testing.T) {
+	store := mockDBStore(t)
+	_, err := store.Start("expenses", parseDate(t, "2020/01/31"), decimal.Zero)
+	assert.Error(t, err)
+
+	r, err := store.Start("assets:Bank", parseDate(t, "2020/01/31"), dec(t, "1"))
+	require.NoError(t, err)
+	require.NoError(t, store.SetCleared(r.ID(), []string{"txn-1"}, true))
+
+	r, err = store.Start("assets:Bank", parseDate(t, "2020/01/31"), dec(t, "2"))
+	require.NoError(t, err)
+	assert.Equal(t, "2", r.StatementBalance.String())
+	assert.Equal(t, []string{"txn-1"}, r.TransactionIDs, "Restarting should keep ticked transactions")
+
+	stored, found, err := store.Get(r.ID())
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, r, stored)
+}
+
+func TestStoreList(t *testing.T) {
+	store := mockDBStore(t)
+	_, err := store.Start("assets:Bank", parseDate(t, "2020/02/29"), decimal.Zero)
+	require.NoError(t, err)
+	_, err = store.Start("liabilities:Card", parseDate(t, "2020/01/31"), decimal.Zero)
+	require.NoError(t, err)
+	_, err = store.Start("assets:Bank", parseDate(t, "2020/01/31"), decimal.Zero)
+	require.NoError(t, err)
+
+	ids := func(reconciliations []Reconciliation) []string {
+		var ids []string
+		for _, r := range reconciliations {
+			ids = append(ids, r.ID())
+		}
+		return ids
+	}
+	all, err := store.List("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"assets:Bank@2020-01-31", "liabilities:Card@2020-01-31", "assets:Bank@2020-02-29"}, ids(all))
+
+	bank, err := store.List("assets:Bank")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"assets:Bank@2020-01-31", "assets:Bank@2020-02-29"}, ids(bank))
+}
+
+func TestStoreFinish(t *testing.T) {
+	ldg := parseLedger(t)
+	store := mockDBStore(t)
+	assert.EqualError(t, store.Finish("non-existent", ldg), "Reconciliation not found: non-existent")
+
+	r, err := store.Start("assets:Bank:Checking", parseDate(t, "2020/01/31"), dec(t, "595.85"))
+	require.NoError(t, err)
+	require.NoError(t, store.SetCleared(r.ID(), []string{"txn-1", "txn-2"}, true))
+	assert.EqualError(t, store.Finish(r.ID(), ldg), "Reconciliation does not match the statement balance, difference is -500")
+
+	require.NoError(t, store.SetCleared(r.ID(), []string{"txn-3"}, true))
+	require.NoError(t, store.Finish(r.ID(), ldg))
+	for _, id := range []string{"txn-1", "txn-2", "txn-3"} {
+		txn, found := ldg.Transaction(id)
+		require.True(t, found)
+		assert.True(t, txn.Reconciled(), id)
+	}
+	txn, _ := ldg.Transaction("txn-4")
+	assert.False(t, txn.Reconciled())
+
+	r, _, err = store.Get(r.ID())
+	require.NoError(t, err)
+	assert.True(t, r.Finished)
+	assert.Error(t, store.Finish(r.ID(), ldg))
+	assert.Error(t, store.SetCleared(r.ID(), []string{"txn-4"}, true))
+	_, err = store.Start(r.Account, r.StatementDate, r.StatementBalance)
+	assert.Error(t, err)
+	assert.EqualError(t, store.Remove(r.ID()), "Finished reconciliations cannot be removed: "+r.ID())
+}
+
+func TestStoreRemove(t *testing.T) {
+	store := mockDBStore(t)
+	assert.Error(t, store.Remove("non-existent"))
+	r, err := store.Start("assets:Bank", parseDate(t, "2020/01/31"), decimal.Zero)
+	require.NoError(t, err)
+	require.NoError(t, store.Remove(r.ID()))
+	_, found, err := store.Get(r.ID())
+	require.NoError(t, err)
+	assert.False(t, found)
+}
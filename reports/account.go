@@ -0,0 +1,103 @@
+package reports
+
+import (
+	"strings"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+)
+
+// accountType is an account's category for reports
+type accountType int
+
+const (
+	// uncategorizedType is any account without a recognized type, like model.Uncategorized
+	uncategorizedType accountType = iota
+	assetType
+	// cashType is an asset account holding cash, like a bank account
+	cashType
+	liabilityType
+	equityType
+	revenueType
+	expenseType
+)
+
+func (t accountType) isAsset() bool {
+	return t == assetType || t == cashType
+}
+
+func (t accountType) isIncome() bool {
+	return t == revenueType || t == expenseType || t == uncategorizedType
+}
+
+// accountTypes classifies accounts by their declared types, then by top-level account names
+type accountTypes struct {
+	declared map[string]accountType
+	hasCash  bool
+}
+
+func newAccountTypes(declarations []ledger.AccountDeclaration) accountTypes {
+	types := accountTypes{declared: make(map[string]accountType)}
+	for _, decl := range declarations {
+		if accountType, ok := parseAccountType(decl.Type()); ok {
+			types.declared[strings.ToLower(decl.Account)] = accountType
+			types.hasCash = types.hasCash || accountType == cashType
+		}
+	}
+	return types
+}
+
+// parseAccountType parses hledger-style account types, like 'A', 'Asset', or 'Cash'
+func parseAccountType(s string) (accountType, bool) {
+	switch strings.ToLower(s) {
+	case "a", "asset", "assets":
+		return assetType, true
+	case "c", "cash":
+		return cashType, true
+	case "l", "liability", "liabilities":
+		return liabilityType, true
+	case "e", "equity":
+		return equityType, true
+	case "r", "revenue", "revenues", "income":
+		return revenueType, true
+	case "x", "expense", "expenses":
+		return expenseType, true
+	default:
+		return uncategorizedType, false
+	}
+}
+
+// typeOf returns the account's type, inherited from the closest declared parent account if necessary
+func (a accountTypes) typeOf(account string) accountType {
+	account = strings.ToLower(account)
+	for name := account; name != ""; {
+		if accountType, ok := a.declared[name]; ok {
+			return accountType
+		}
+		end := strings.LastIndexByte(name, ':')
+		if end == -1 {
+			break
+		}
+		name = name[:end]
+	}
+
+	topLevel := strings.SplitN(account, ":", 2)[0]
+	switch topLevel {
+	case model.AssetAccount, "asset":
+		if a.hasCash {
+			return assetType
+		}
+		// without declared cash accounts, all assets are treated as cash
+		return cashType
+	case model.LiabilityAccount, "liability":
+		return liabilityType
+	case "equity":
+		return equityType
+	case model.RevenueAccount, "revenue", "income":
+		return revenueType
+	case model.ExpenseAccount, "expense":
+		return expenseType
+	default:
+		return uncategorizedType
+	}
+}
@@ -0,0 +1,116 @@
+package reports
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// RegisterOptions select the account and dates for a register
+type RegisterOptions struct {
+	// Account is an account name or prefix. All sub-accounts are included.
+	Account string
+	// Start and End limit the register's entries, inclusive. Either may be zero for no limit.
+	Start, End time.Time
+}
+
+// Register lists an account's postings in date order with a running balance
+type Register struct {
+	Account string
+	// OpeningBalances are the account's balances by commodity before the first entry, starting from the ledger's opening balances
+	OpeningBalances map[string]decimal.Decimal
+	Entries         []RegisterEntry
+}
+
+// RegisterEntry is a posting in a register
+type RegisterEntry struct {
+	Date          time.Time
+	TransactionID string
+	Payee         string
+	Account       string
+	Amount        decimal.Decimal
+	Currency      string
+	// Balance is the computed running balance in Currency of all the register's accounts after this posting
+	Balance decimal.Decimal
+	// BankBalance is the balance reported by the institution after this posting, if one was supplied
+	BankBalance *decimal.Decimal `json:",omitempty"`
+}
+
+func (o RegisterOptions) matchesAccount(account string) bool {
+	return account == o.Account || strings.HasPrefix(account, o.Account+":")
+}
+
+// AccountRegister returns the postings for an account and its sub-accounts with running balances. Virtual postings are excluded.
+func AccountRegister(ldg *ledger.Ledger, options RegisterOptions) (Register, error) {
+	if options.Account == "" {
+		return Register{}, errors.New("Register account is required")
+	}
+	if !options.Start.IsZero() && !options.End.IsZero() && options.End.Before(options.Start) {
+		return Register{}, errors.New("Register end date must not be before the start date")
+	}
+	end := options.End
+	if end.IsZero() {
+		end = ldg.LastTransactionTime()
+	}
+
+	register := Register{
+		Account:         options.Account,
+		OpeningBalances: make(map[string]decimal.Decimal),
+	}
+	if opening, found := ldg.OpeningBalances(); found {
+		for _, p := range opening.Postings {
+			if !p.Kind.IsVirtual() && options.matchesAccount(p.Account) {
+				register.OpeningBalances[p.Currency] = register.OpeningBalances[p.Currency].Add(p.Amount)
+			}
+		}
+	}
+
+	txns := ldg.TransactionsBetween(time.Time{}, end)
+	sort.SliceStable(txns, func(a, b int) bool {
+		return txns[a].Date.Before(txns[b].Date)
+	})
+	balances := make(map[string]decimal.Decimal, len(register.OpeningBalances))
+	for currency, amount := range register.OpeningBalances {
+		balances[currency] = amount
+	}
+	for _, txn := range txns {
+		if isOpeningBalance(txn) {
+			continue
+		}
+		beforeStart := txn.Date.Before(options.Start)
+		for _, p := range txn.Postings {
+			if p.Kind.IsVirtual() || !options.matchesAccount(p.Account) {
+				continue
+			}
+			balances[p.Currency] = balances[p.Currency].Add(p.Amount)
+			if beforeStart {
+				register.OpeningBalances[p.Currency] = balances[p.Currency]
+				continue
+			}
+			register.Entries = append(register.Entries, RegisterEntry{
+				Date:          txn.Date,
+				TransactionID: txn.Postings[0].ID(),
+				Payee:         txn.Payee,
+				Account:       p.Account,
+				Amount:        p.Amount,
+				Currency:      p.Currency,
+				Balance:       balances[p.Currency],
+				BankBalance:   p.Balance,
+			})
+		}
+	}
+	return register, nil
+}
+
+func isOpeningBalance(txn ledger.Transaction) bool {
+	for _, p := range txn.Postings {
+		if p.IsOpeningBalance() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package reports
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const registerJournal = `
+2019/12/31 Opening Balances
+    assets:Bank:Checking   $100.50
+    assets:Bank:Savings   $20.00
+    equity:Opening Balances  ; id: Opening-Balance
+
+2020/01/02 Coffee
+    expenses:Food   $4.25  ; id: txn-1
+    assets:Bank:Checking   $-4.25 = $96.25
+
+2020/01/10 Transfer
+    assets:Bank:Savings   $50.00  ; id: txn-2
+    assets:Bank:Checking
+
+2020/02/01 Paycheck
+    assets:Bank:Checking   $1000.10 = $1046.35  ; id: txn-3
+    revenues:Salary
+
+2020/02/03 Budget
+    (assets:Bank:Checking)   $-10.00  ; id: txn-4
+    expenses:Budget   $0
+`
+
+func TestAccountRegister(t *testing.T) {
+	ldg := parseLedger(t, registerJournal)
+	dec := func(s string) *decimal.Decimal {
+		d, err := decimal.NewFromString(s)
+		require.NoError(t, err)
+		return &d
+	}
+	entryStrings := func(register Register) []string {
+		var entries []string
+		for _, entry := range register.Entries {
+			entries = append(entries, entry.Date.Format("2006/01/02")+" "+entry.TransactionID+" "+entry.Account+" "+entry.Amount.String()+" "+entry.Balance.String())
+		}
+		return entries
+	}
+
+	t.Run("single account", func(t *testing.T) {
+		register, err := AccountRegister(ldg, RegisterOptions{Account: "assets:Bank:Checking"})
+		require.NoError(t, err)
+		assert.Equal(t, "100.5", register.OpeningBalances["$"].String())
+		assert.Equal(t, []string{
+			"2020/01/02 txn-1 assets:Bank:Checking -4.25 96.25",
+			"2020/01/10 txn-2 assets:Bank:Checking -50 46.25",
+			"2020/02/01 txn-3 assets:Bank:Checking 1000.1 1046.35",
+		}, entryStrings(register))
+		assert.Equal(t, dec("96.25").String(), register.Entries[0].BankBalance.String())
+		assert.Nil(t, register.Entries[1].BankBalance)
+		assert.Equal(t, dec("1046.35").String(), register.Entries[2].BankBalance.String())
+	})
+
+	t.Run("account prefix", func(t *testing.T) {
+		register, err := AccountRegister(ldg, RegisterOptions{Account: "assets:Bank"})
+		require.NoError(t, err)
+		assert.Equal(t, "120.5", register.OpeningBalances["$"].String())
+		assert.Equal(t, []string{
+			"2020/01/02 txn-1 assets:Bank:Checking -4.25 116.25",
+			"2020/01/10 txn-2 assets:Bank:Savings 50 166.25",
+			"2020/01/10 txn-2 assets:Bank:Checking -50 116.25",
+			"2020/02/01 txn-3 assets:Bank:Checking 1000.1 1116.35",
+		}, entryStrings(register))
+	})
+
+	t.Run("does not match partial account names", func(t *testing.T) {
+		register, err := AccountRegister(ldg, RegisterOptions{Account: "assets:Ba"})
+		require.NoError(t, err)
+		assert.Empty(t, register.Entries)
+	})
+
+	t.Run("start and end dates", func(t *testing.T) {
+		register, err := AccountRegister(ldg, RegisterOptions{
+			Account: "assets:Bank:Checking",
+			Start:   parseDate(t, "2020/01/05"),
+			End:     parseDate(t, "2020/01/31"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "96.25", register.OpeningBalances["$"].String())
+		assert.Equal(t, []string{
+			"2020/01/10 txn-2 assets:Bank:Checking -50 46.25",
+		}, entryStrings(register))
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := AccountRegister(ldg, RegisterOptions{})
+		assert.EqualError(t, err, "Register account is required")
+		_, err = AccountRegister(ldg, RegisterOptions{
+			Account: "assets",
+			Start:   parseDate(t, "2020/02/01"),
+			End:     parseDate(t, "2020/01/01"),
+		})
+		assert.EqualError(t, err, "Register end date must not be before the start date")
+	})
+}
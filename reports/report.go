@@ -0,0 +1,221 @@
+// Package reports produces accounting reports from a ledger, like income statements, balance sheets, and cash flow statements
+package reports
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Options configure a report's periods and amounts
+type Options struct {
+	// Start and End are the report's period, inclusive. Balance sheets report balances at End.
+	Start, End time.Time
+	// Compare adds this many previous periods of the same length as comparison columns
+	Compare int
+	// ValueIn converts all commodities into this currency using market prices at the end of each period. Otherwise commodities are summed together.
+	ValueIn string
+}
+
+// Period is a report column's date range, inclusive
+type Period struct {
+	Start, End time.Time
+}
+
+// Report is a financial report with a column of amounts for each period. The first period is the requested one, followed by comparison periods.
+type Report struct {
+	Title    string
+	Periods  []Period
+	Sections []Section
+	// Totals are summary rows, like net income
+	Totals []Total
+}
+
+// Section is a group of accounts in a report, like revenues or expenses
+type Section struct {
+	Name   string
+	Lines  []Line
+	Totals []decimal.Decimal
+}
+
+// Line is an account in a report's account hierarchy with an amount for each period. Amounts include all sub-accounts.
+type Line struct {
+	Account  string
+	Amounts  []decimal.Decimal
+	Children []Line `json:",omitempty"`
+}
+
+// Total is a summary row in a report
+type Total struct {
+	Name    string
+	Amounts []decimal.Decimal
+}
+
+const maxComparePeriods = 120
+
+// periods returns the requested period followed by any comparison periods
+func (o Options) periods() ([]Period, error) {
+	if o.Start.IsZero() || o.End.IsZero() {
+		return nil, errors.New("Report start and end dates are required")
+	}
+	if o.End.Before(o.Start) {
+		return nil, errors.New("Report end date must not be before the start date")
+	}
+	if o.Compare < 0 || o.Compare > maxComparePeriods {
+		return nil, errors.Errorf("Comparison periods must be between 0 and %d", maxComparePeriods)
+	}
+	periods := []Period{{Start: dateOnly(o.Start), End: dateOnly(o.End)}}
+	for i := 0; i < o.Compare; i++ {
+		periods = append(periods, periods[len(periods)-1].previous())
+	}
+	return periods, nil
+}
+
+// previous returns the period of the same length immediately before 'p'. Periods of whole calendar months move back by months, otherwise by days.
+func (p Period) previous() Period {
+	end := p.Start.AddDate(0, 0, -1)
+	if p.Start.Day() == 1 && p.End.AddDate(0, 0, 1).Day() == 1 {
+		months := monthNum(p.End) - monthNum(p.Start) + 1
+		return Period{Start: p.Start.AddDate(0, -months, 0), End: end}
+	}
+	days := int(p.End.Sub(p.Start)/(24*time.Hour)) + 1
+	return Period{Start: p.Start.AddDate(0, 0, -days), End: end}
+}
+
+func monthNum(t time.Time) int {
+	return int(t.Month()) - 1 + 12*t.Year()
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// accountAmounts sums amounts for each account and commodity
+type accountAmounts map[string]map[string]decimal.Decimal
+
+func (a accountAmounts) add(account, currency string, amount decimal.Decimal) {
+	if a[account] == nil {
+		a[account] = make(map[string]decimal.Decimal)
+	}
+	a[account][currency] = a[account][currency].Add(amount)
+}
+
+// values combines each account's commodities into one amount. If 'valueIn' is set, commodities are valued in it at 'date', otherwise they're summed.
+func (a accountAmounts) values(ldg *ledger.Ledger, valueIn string, date time.Time) (map[string]decimal.Decimal, error) {
+	values := make(map[string]decimal.Decimal, len(a))
+	for account, commodities := range a {
+		if valueIn != "" {
+			value, err := ldg.Value(commodities, valueIn, date)
+			if err != nil {
+				return nil, err
+			}
+			values[account] = value
+			continue
+		}
+		var sum decimal.Decimal
+		for _, amount := range commodities {
+			sum = sum.Add(amount)
+		}
+		values[account] = sum
+	}
+	return values, nil
+}
+
+// sectionBuilder accumulates account amounts into a section's account hierarchy
+type sectionBuilder struct {
+	name    string
+	negate  bool
+	periods int
+	root    *lineNode
+}
+
+type lineNode struct {
+	account  string
+	amounts  []decimal.Decimal
+	children map[string]*lineNode
+}
+
+func newSection(name string, negate bool, periods int) *sectionBuilder {
+	return &sectionBuilder{
+		name:    name,
+		negate:  negate,
+		periods: periods,
+		root:    newLineNode("", periods),
+	}
+}
+
+func newLineNode(account string, periods int) *lineNode {
+	return &lineNode{
+		account:  account,
+		amounts:  make([]decimal.Decimal, periods),
+		children: make(map[string]*lineNode),
+	}
+}
+
+// add adds 'amount' to 'account' and all of its parent accounts for the period at index 'period'
+func (s *sectionBuilder) add(account string, period int, amount decimal.Decimal) {
+	if s.negate {
+		amount = amount.Neg()
+	}
+	node := s.root
+	node.amounts[period] = node.amounts[period].Add(amount)
+	components := strings.Split(account, ":")
+	for i := range components {
+		name := strings.Join(components[:i+1], ":")
+		child := node.children[name]
+		if child == nil {
+			child = newLineNode(name, s.periods)
+			node.children[name] = child
+		}
+		child.amounts[period] = child.amounts[period].Add(amount)
+		node = child
+	}
+}
+
+func (s *sectionBuilder) isEmpty() bool {
+	return len(s.root.children) == 0
+}
+
+func (s *sectionBuilder) totals() []decimal.Decimal {
+	return s.root.amounts
+}
+
+func (s *sectionBuilder) section() Section {
+	return Section{
+		Name:   s.name,
+		Lines:  s.root.lines(),
+		Totals: s.root.amounts,
+	}
+}
+
+// lines returns the node's children as report lines, sorted by account name
+func (n *lineNode) lines() []Line {
+	if len(n.children) == 0 {
+		return nil
+	}
+	lines := make([]Line, 0, len(n.children))
+	for _, child := range n.children {
+		lines = append(lines, Line{
+			Account:  child.account,
+			Amounts:  child.amounts,
+			Children: child.lines(),
+		})
+	}
+	sort.Slice(lines, func(a, b int) bool {
+		return lines[a].Account < lines[b].Account
+	})
+	return lines
+}
+
+// subtract returns a - b for each period
+func subtract(a, b []decimal.Decimal) []decimal.Decimal {
+	result := make([]decimal.Decimal, len(a))
+	for i := range a {
+		result[i] = a[i].Sub(b[i])
+	}
+	return result
+}
@@ -0,0 +1,200 @@
+package reports
+
+import (
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+)
+
+// Report titles
+const (
+	IncomeStatementTitle = "Income Statement"
+	BalanceSheetTitle    = "Balance Sheet"
+	CashFlowTitle        = "Cash Flow Statement"
+)
+
+// IncomeStatement reports revenues and expenses by account for each period, and the resulting net income
+func IncomeStatement(ldg *ledger.Ledger, options Options) (Report, error) {
+	periods, err := options.periods()
+	if err != nil {
+		return Report{}, err
+	}
+	types := newAccountTypes(ldg.AccountDeclarations())
+	revenues := newSection("Revenues", true, len(periods))
+	expenses := newSection("Expenses", false, len(periods))
+	uncategorized := newSection("Uncategorized", false, len(periods))
+
+	for i, period := range periods {
+		amounts := make(accountAmounts)
+		for _, txn := range ldg.TransactionsBetween(period.Start, period.End) {
+			for _, p := range txn.Postings {
+				if !p.Kind.IsVirtual() && types.typeOf(p.Account).isIncome() {
+					amounts.add(p.Account, p.Currency, p.Amount)
+				}
+			}
+		}
+		values, err := amounts.values(ldg, options.ValueIn, period.End)
+		if err != nil {
+			return Report{}, err
+		}
+		for account, value := range values {
+			switch types.typeOf(account) {
+			case revenueType:
+				revenues.add(account, i, value)
+			case expenseType:
+				expenses.add(account, i, value)
+			default:
+				uncategorized.add(account, i, value)
+			}
+		}
+	}
+
+	report := Report{
+		Title:    IncomeStatementTitle,
+		Periods:  periods,
+		Sections: []Section{revenues.section(), expenses.section()},
+	}
+	netIncome := subtract(revenues.totals(), expenses.totals())
+	if !uncategorized.isEmpty() {
+		report.Sections = append(report.Sections, uncategorized.section())
+		netIncome = subtract(netIncome, uncategorized.totals())
+	}
+	report.Totals = []Total{{Name: "Net Income", Amounts: netIncome}}
+	return report, nil
+}
+
+// BalanceSheet reports assets, liabilities, and equity balances at the end of each period
+func BalanceSheet(ldg *ledger.Ledger, options Options) (Report, error) {
+	periods, err := options.periods()
+	if err != nil {
+		return Report{}, err
+	}
+	types := newAccountTypes(ldg.AccountDeclarations())
+	assets := newSection("Assets", false, len(periods))
+	liabilities := newSection("Liabilities", true, len(periods))
+	equity := newSection("Equity", true, len(periods))
+	retainedEarnings := make([]decimal.Decimal, len(periods))
+
+	for i, period := range periods {
+		amounts := make(accountAmounts)
+		for _, txn := range ldg.TransactionsBetween(time.Time{}, period.End) {
+			for _, p := range txn.Postings {
+				if !p.Kind.IsVirtual() {
+					amounts.add(p.Account, p.Currency, p.Amount)
+				}
+			}
+		}
+		values, err := amounts.values(ldg, options.ValueIn, period.End)
+		if err != nil {
+			return Report{}, err
+		}
+		for account, value := range values {
+			switch accountType := types.typeOf(account); {
+			case accountType.isAsset():
+				assets.add(account, i, value)
+			case accountType == liabilityType:
+				liabilities.add(account, i, value)
+			case accountType == equityType:
+				equity.add(account, i, value)
+			default:
+				retainedEarnings[i] = retainedEarnings[i].Sub(value)
+			}
+		}
+	}
+
+	return Report{
+		Title:    BalanceSheetTitle,
+		Periods:  periods,
+		Sections: []Section{assets.section(), liabilities.section(), equity.section()},
+		Totals: []Total{
+			{Name: "Net Worth", Amounts: subtract(assets.totals(), liabilities.totals())},
+			{Name: "Retained Earnings", Amounts: retainedEarnings},
+		},
+	}, nil
+}
+
+// CashFlow reports the movement of cash in each period, grouped by operating, investing, and financing activities.
+// Cash accounts are declared with the type 'Cash'. If none are declared, all asset accounts are cash.
+func CashFlow(ldg *ledger.Ledger, options Options) (Report, error) {
+	periods, err := options.periods()
+	if err != nil {
+		return Report{}, err
+	}
+	types := newAccountTypes(ldg.AccountDeclarations())
+	operating := newSection("Operating Activities", false, len(periods))
+	investing := newSection("Investing Activities", false, len(periods))
+	financing := newSection("Financing Activities", false, len(periods))
+	beginningCash := make([]decimal.Decimal, len(periods))
+	netChange := make([]decimal.Decimal, len(periods))
+	endingCash := make([]decimal.Decimal, len(periods))
+
+	for i, period := range periods {
+		beginning := make(accountAmounts)
+		ending := make(accountAmounts)
+		flows := make(accountAmounts)
+		for _, txn := range ldg.TransactionsBetween(time.Time{}, period.End) {
+			inPeriod := !txn.Date.Before(period.Start)
+			touchesCash := false
+			for _, p := range txn.Postings {
+				if !p.Kind.IsVirtual() && types.typeOf(p.Account) == cashType {
+					touchesCash = true
+					ending.add(p.Account, p.Currency, p.Amount)
+					if !inPeriod {
+						beginning.add(p.Account, p.Currency, p.Amount)
+					}
+				}
+			}
+			if !inPeriod || !touchesCash {
+				continue
+			}
+			for _, p := range txn.Postings {
+				if !p.Kind.IsVirtual() && types.typeOf(p.Account) != cashType {
+					// cash moves in the opposite direction of the other postings
+					flows.add(p.Account, p.Currency, p.Amount.Neg())
+				}
+			}
+		}
+
+		beginningValues, err := beginning.values(ldg, options.ValueIn, period.Start.AddDate(0, 0, -1))
+		if err != nil {
+			return Report{}, err
+		}
+		endingValues, err := ending.values(ldg, options.ValueIn, period.End)
+		if err != nil {
+			return Report{}, err
+		}
+		flowValues, err := flows.values(ldg, options.ValueIn, period.End)
+		if err != nil {
+			return Report{}, err
+		}
+		for _, value := range beginningValues {
+			beginningCash[i] = beginningCash[i].Add(value)
+		}
+		for _, value := range endingValues {
+			endingCash[i] = endingCash[i].Add(value)
+		}
+		for account, value := range flowValues {
+			netChange[i] = netChange[i].Add(value)
+			switch accountType := types.typeOf(account); {
+			case accountType.isIncome():
+				operating.add(account, i, value)
+			case accountType.isAsset():
+				investing.add(account, i, value)
+			default:
+				financing.add(account, i, value)
+			}
+		}
+	}
+
+	return Report{
+		Title:    CashFlowTitle,
+		Periods:  periods,
+		Sections: []Section{operating.section(), investing.section(), financing.section()},
+		Totals: []Total{
+			{Name: "Beginning Cash", Amounts: beginningCash},
+			{Name: "Net Change in Cash", Amounts: netChange},
+			{Name: "Ending Cash", Amounts: endingCash},
+		},
+	}, nil
+}
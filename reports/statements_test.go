@@ -0,0 +1,291 @@
+package reports
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJournal = `
+account assets:Savings  ; type: Asset
+account assets:Checking  ; type: Cash
+account income  ; type: Revenue
+
+2019/12/15 Opening
+    assets:Checking   $1000.50
+    equity:Opening Balances
+
+2020/01/01 Paycheck
+    assets:Checking   $2000.25
+    income:Salary
+
+2020/01/05 Groceries
+    expenses:Food:Groceries   $120.10
+    liabilities:Credit Card
+
+2020/01/20 Pay credit card
+    liabilities:Credit Card   $120.10
+    assets:Checking
+
+2020/02/01 Paycheck
+    assets:Checking   $2000.25
+    income:Salary
+
+2020/02/03 Restaurant
+    expenses:Food:Restaurants   $45.30
+    assets:Checking
+
+2020/02/10 Save
+    assets:Savings   $500.00
+    assets:Checking
+
+2020/02/11 Mystery
+    uncategorized   $10.10
+    assets:Checking
+`
+
+func parseLedger(t *testing.T, journal string) *ledger.Ledger {
+	ldg, err := ledger.NewFromReader(strings.NewReader(journal))
+	require.NoError(t, err)
+	return ldg
+}
+
+func parseDate(t *testing.T, s string) time.Time {
+	date, err := time.Parse("2006/01/02", s)
+	require.NoError(t, err)
+	return date
+}
+
+func amountStrings(amounts []decimal.Decimal) []string {
+	strs := make([]string, len(amounts))
+	for i, amount := range amounts {
+		strs[i] = amount.String()
+	}
+	return strs
+}
+
+func findSection(t *testing.T, report Report, name string) Section {
+	for _, section := range report.Sections {
+		if section.Name == name {
+			return section
+		}
+	}
+	require.Failf(t, "Section not found", "Section %q not in report", name)
+	return Section{}
+}
+
+func findTotal(t *testing.T, report Report, name string) []string {
+	for _, total := range report.Totals {
+		if total.Name == name {
+			return amountStrings(total.Amounts)
+		}
+	}
+	require.Failf(t, "Total not found", "Total %q not in report", name)
+	return nil
+}
+
+func TestOptionsPeriods(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		options     Options
+		expected    []Period
+		expectErr   string
+	}{
+		{
+			description: "missing dates",
+			expectErr:   "Report start and end dates are required",
+		},
+		{
+			description: "end before start",
+			options:     Options{Start: parseDate(t, "2020/02/01"), End: parseDate(t, "2020/01/01")},
+			expectErr:   "Report end date must not be before the start date",
+		},
+		{
+			description: "too many comparisons",
+			options:     Options{Start: parseDate(t, "2020/01/01"), End: parseDate(t, "2020/01/31"), Compare: -1},
+			expectErr:   "Comparison periods must be between 0 and 120",
+		},
+		{
+			description: "whole months",
+			options:     Options{Start: parseDate(t, "2020/03/01"), End: parseDate(t, "2020/03/31"), Compare: 2},
+			expected: []Period{
+				{Start: parseDate(t, "2020/03/01"), End: parseDate(t, "2020/03/31")},
+				{Start: parseDate(t, "2020/02/01"), End: parseDate(t, "2020/02/29")},
+				{Start: parseDate(t, "2020/01/01"), End: parseDate(t, "2020/01/31")},
+			},
+		},
+		{
+			description: "whole quarter",
+			options:     Options{Start: parseDate(t, "2020/04/01"), End: parseDate(t, "2020/06/30"), Compare: 1},
+			expected: []Period{
+				{Start: parseDate(t, "2020/04/01"), End: parseDate(t, "2020/06/30")},
+				{Start: parseDate(t, "2020/01/01"), End: parseDate(t, "2020/03/31")},
+			},
+		},
+		{
+			description: "days",
+			options:     Options{Start: parseDate(t, "2020/03/10"), End: parseDate(t, "2020/03/19"), Compare: 1},
+			expected: []Period{
+				{Start: parseDate(t, "2020/03/10"), End: parseDate(t, "2020/03/19")},
+				{Start: parseDate(t, "2020/02/29"), End: parseDate(t, "2020/03/09")},
+			},
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			periods, err := tc.options.periods()
+			if tc.expectErr != "" {
+				assert.EqualError(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, periods)
+		})
+	}
+}
+
+func TestAccountTypes(t *testing.T) {
+	types := newAccountTypes([]ledger.AccountDeclaration{
+		{Account: "assets:Checking", Tags: map[string]string{"type": "C"}},
+		{Account: "income", Tags: map[string]string{"type": "Revenue"}},
+		{Account: "assets:Loan", Tags: map[string]string{"type": "Liability"}},
+		{Account: "assets:Other", Tags: map[string]string{"type": "not a type"}},
+	})
+	for account, expected := range map[string]accountType{
+		"assets:Checking":         cashType,
+		"assets:checking:sub":     cashType,
+		"assets:Savings":          assetType,
+		"assets:Loan":             liabilityType,
+		"assets:Other":            assetType,
+		"income:Salary":           revenueType,
+		"revenues:Interest":       revenueType,
+		"expenses:Food":           expenseType,
+		"liabilities:Credit Card": liabilityType,
+		"equity:Opening Balances": equityType,
+		"uncategorized":           uncategorizedType,
+		"something:else:entirely": uncategorizedType,
+	} {
+		assert.Equal(t, expected, types.typeOf(account), account)
+	}
+
+	assert.Equal(t, cashType, newAccountTypes(nil).typeOf("assets:Savings"), "All assets are cash without declared cash accounts")
+}
+
+func TestIncomeStatement(t *testing.T) {
+	ldg := parseLedger(t, testJournal)
+	report, err := IncomeStatement(ldg, Options{
+		Start:   parseDate(t, "2020/02/01"),
+		End:     parseDate(t, "2020/02/29"),
+		Compare: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, IncomeStatementTitle, report.Title)
+	assert.Equal(t, []Period{
+		{Start: parseDate(t, "2020/02/01"), End: parseDate(t, "2020/02/29")},
+		{Start: parseDate(t, "2020/01/01"), End: parseDate(t, "2020/01/31")},
+	}, report.Periods)
+
+	revenues := findSection(t, report, "Revenues")
+	assert.Equal(t, []string{"2000.25", "2000.25"}, amountStrings(revenues.Totals))
+	require.Len(t, revenues.Lines, 1)
+	assert.Equal(t, "income", revenues.Lines[0].Account)
+	require.Len(t, revenues.Lines[0].Children, 1)
+	assert.Equal(t, "income:Salary", revenues.Lines[0].Children[0].Account)
+
+	expenses := findSection(t, report, "Expenses")
+	assert.Equal(t, []string{"45.3", "120.1"}, amountStrings(expenses.Totals))
+	require.Len(t, expenses.Lines, 1)
+	food := expenses.Lines[0].Children[0]
+	assert.Equal(t, "expenses:Food", food.Account)
+	require.Len(t, food.Children, 2)
+	assert.Equal(t, "expenses:Food:Groceries", food.Children[0].Account)
+	assert.Equal(t, []string{"0", "120.1"}, amountStrings(food.Children[0].Amounts))
+	assert.Equal(t, "expenses:Food:Restaurants", food.Children[1].Account)
+	assert.Equal(t, []string{"45.3", "0"}, amountStrings(food.Children[1].Amounts))
+
+	uncategorized := findSection(t, report, "Uncategorized")
+	assert.Equal(t, []string{"10.1", "0"}, amountStrings(uncategorized.Totals))
+
+	assert.Equal(t, []string{"1944.85", "1880.15"}, findTotal(t, report, "Net Income"))
+}
+
+func TestIncomeStatementValuedIn(t *testing.T) {
+	ldg := parseLedger(t, `
+P 2020/01/01 EUR $1.50
+
+2020/01/10 Trip
+    expenses:Travel   10.10 EUR
+    assets:Checking
+`)
+	report, err := IncomeStatement(ldg, Options{
+		Start:   parseDate(t, "2020/01/01"),
+		End:     parseDate(t, "2020/01/31"),
+		ValueIn: "$",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-15.15"}, findTotal(t, report, "Net Income"))
+
+	_, err = IncomeStatement(ldg, Options{
+		Start:   parseDate(t, "2020/01/01"),
+		End:     parseDate(t, "2020/01/31"),
+		ValueIn: "GBP",
+	})
+	assert.Error(t, err)
+}
+
+func TestBalanceSheet(t *testing.T) {
+	ldg := parseLedger(t, testJournal)
+	report, err := BalanceSheet(ldg, Options{
+		Start:   parseDate(t, "2020/02/01"),
+		End:     parseDate(t, "2020/02/29"),
+		Compare: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, BalanceSheetTitle, report.Title)
+
+	assets := findSection(t, report, "Assets")
+	assert.Equal(t, []string{"4825.5", "2880.65"}, amountStrings(assets.Totals))
+	require.Len(t, assets.Lines, 1)
+	require.Len(t, assets.Lines[0].Children, 2)
+	assert.Equal(t, "assets:Checking", assets.Lines[0].Children[0].Account)
+	assert.Equal(t, []string{"4325.5", "2880.65"}, amountStrings(assets.Lines[0].Children[0].Amounts))
+	assert.Equal(t, "assets:Savings", assets.Lines[0].Children[1].Account)
+	assert.Equal(t, []string{"500", "0"}, amountStrings(assets.Lines[0].Children[1].Amounts))
+
+	liabilities := findSection(t, report, "Liabilities")
+	assert.Equal(t, []string{"0", "0"}, amountStrings(liabilities.Totals))
+
+	equity := findSection(t, report, "Equity")
+	assert.Equal(t, []string{"1000.5", "1000.5"}, amountStrings(equity.Totals))
+
+	assert.Equal(t, []string{"4825.5", "2880.65"}, findTotal(t, report, "Net Worth"))
+	assert.Equal(t, []string{"3825", "1880.15"}, findTotal(t, report, "Retained Earnings"))
+}
+
+func TestCashFlow(t *testing.T) {
+	ldg := parseLedger(t, testJournal)
+	report, err := CashFlow(ldg, Options{
+		Start:   parseDate(t, "2020/02/01"),
+		End:     parseDate(t, "2020/02/29"),
+		Compare: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, CashFlowTitle, report.Title)
+
+	operating := findSection(t, report, "Operating Activities")
+	assert.Equal(t, []string{"1944.85", "2000.25"}, amountStrings(operating.Totals))
+	investing := findSection(t, report, "Investing Activities")
+	assert.Equal(t, []string{"-500", "0"}, amountStrings(investing.Totals))
+	require.Len(t, investing.Lines, 1)
+	assert.Equal(t, "assets:Savings", investing.Lines[0].Children[0].Account)
+	financing := findSection(t, report, "Financing Activities")
+	assert.Equal(t, []string{"0", "-120.1"}, amountStrings(financing.Totals))
+
+	assert.Equal(t, []string{"2880.65", "1000.5"}, findTotal(t, report, "Beginning Cash"))
+	assert.Equal(t, []string{"1444.85", "1880.15"}, findTotal(t, report, "Net Change in Cash"))
+	assert.Equal(t, []string{"4325.5", "2880.65"}, findTotal(t, report, "Ending Cash"))
+}
@@ -0,0 +1,276 @@
+package rules
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// andConditionPrefix joins a condition with the one before it, like hledger's '&' condition lines
+	andConditionPrefix = "&"
+	// fieldConditionPrefix starts a condition on one field, like hledger's '%field REGEX' conditions
+	fieldConditionPrefix = "%"
+	// rangeSeparator separates the inclusive min and max of a range condition, either may be empty
+	rangeSeparator = ".."
+)
+
+// condition matches one part of a transaction
+type condition interface {
+	match(txn ledger.Transaction) bool
+}
+
+// conditionGroups match if all conditions in any group match
+type conditionGroups [][]condition
+
+func (c conditionGroups) match(txn ledger.Transaction) bool {
+	for _, group := range c {
+		groupMatches := true
+		for _, cond := range group {
+			if !cond.match(txn) {
+				groupMatches = false
+				break
+			}
+		}
+		if groupMatches {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlainCondition returns true if the condition is only a regex for ledgerMatchLine
+func isPlainCondition(line string) bool {
+	return !strings.HasPrefix(line, andConditionPrefix) && !strings.HasPrefix(line, fieldConditionPrefix)
+}
+
+// parseConditionGroups parses condition lines into groups. Each line starts a new group, unless it starts with '&'.
+func parseConditionGroups(lines []string) (conditionGroups, error) {
+	var groups conditionGroups
+	for _, line := range lines {
+		and := strings.HasPrefix(line, andConditionPrefix)
+		if and {
+			if len(groups) == 0 {
+				return nil, errors.Errorf("First rule condition must not start with %q: %q", andConditionPrefix, line)
+			}
+			line = strings.TrimSpace(strings.TrimPrefix(line, andConditionPrefix))
+		}
+		cond, err := parseCondition(line)
+		if err != nil {
+			return nil, err
+		}
+		if and {
+			groups[len(groups)-1] = append(groups[len(groups)-1], cond)
+		} else {
+			groups = append(groups, []condition{cond})
+		}
+	}
+	return groups, nil
+}
+
+func parseCondition(line string) (condition, error) {
+	if !strings.HasPrefix(line, fieldConditionPrefix) {
+		pattern, err := regexp.Compile("(?i)" + line)
+		return regexCondition{Field: "", Pattern: pattern}, err
+	}
+	tokens := strings.SplitN(strings.TrimPrefix(line, fieldConditionPrefix), " ", 2)
+	if len(tokens) != 2 || strings.TrimSpace(tokens[1]) == "" {
+		return nil, errors.Errorf("Rule field condition must have both field and value: '%s'", line)
+	}
+	field, value := strings.ToLower(tokens[0]), strings.TrimSpace(tokens[1])
+	isRange := strings.Contains(value, rangeSeparator)
+	switch {
+	case field == "amount" && isRange:
+		return parseAmountRange(value)
+	case field == "date" && isRange:
+		return parseDateRange(value)
+	case field == "weekday" && isRange:
+		return parseWeekdayRange(value)
+	case field == "sign":
+		switch strings.ToLower(value) {
+		case "positive", "+":
+			return signCondition{Negative: false}, nil
+		case "negative", "-":
+			return signCondition{Negative: true}, nil
+		default:
+			return nil, errors.Errorf("Rule sign condition must be 'positive' or 'negative': '%s'", value)
+		}
+	case field == "tag":
+		tokens := strings.SplitN(value, "=", 2)
+		tag := tagCondition{Name: strings.TrimSpace(tokens[0])}
+		if len(tokens) == 2 {
+			pattern, err := regexp.Compile("(?i)" + strings.TrimSpace(tokens[1]))
+			if err != nil {
+				return nil, err
+			}
+			tag.Value = pattern
+		}
+		return tag, nil
+	}
+	if _, known := fieldValues[field]; !known || field == "" {
+		return nil, errors.Errorf("Unrecognized rule condition field: '%s'", field)
+	}
+	pattern, err := regexp.Compile("(?i)" + value)
+	return regexCondition{Field: field, Pattern: pattern}, err
+}
+
+// fieldValues returns the text of each field that can be matched with a regex. Assumes the transaction has at least one posting.
+var fieldValues = map[string]func(ledger.Transaction) string{
+	"":            ledgerMatchLine,
+	"date":        func(txn ledger.Transaction) string { return txn.Date.Format(ledger.DateFormat) },
+	"weekday":     func(txn ledger.Transaction) string { return txn.Date.Weekday().String() },
+	"description": func(txn ledger.Transaction) string { return txn.Payee },
+	"payee":       func(txn ledger.Transaction) string { return txn.Payee },
+	"amount":      func(txn ledger.Transaction) string { return txn.Postings[0].Amount.String() },
+	"currency":    func(txn ledger.Transaction) string { return txn.Postings[0].Currency },
+	"account":     func(txn ledger.Transaction) string { return txn.Postings[0].Account },
+	"memo":        func(txn ledger.Transaction) string { return txn.Postings[0].Comment },
+	"balance": func(txn ledger.Transaction) string {
+		if txn.Postings[0].Balance == nil {
+			return ""
+		}
+		return txn.Postings[0].Balance.String()
+	},
+}
+
+// regexCondition matches a field's text, or the whole match line if Field is empty
+type regexCondition struct {
+	Field   string
+	Pattern *regexp.Regexp
+}
+
+func (r regexCondition) match(txn ledger.Transaction) bool {
+	return r.Pattern.MatchString(fieldValues[r.Field](txn))
+}
+
+// amountRange matches the first posting's amount between Min and Max, inclusive
+type amountRange struct {
+	Min, Max *decimal.Decimal
+}
+
+func parseAmountRange(value string) (condition, error) {
+	tokens := strings.SplitN(value, rangeSeparator, 2)
+	var r amountRange
+	for i, bound := range []**decimal.Decimal{&r.Min, &r.Max} {
+		token := strings.TrimSpace(tokens[i])
+		if token == "" {
+			continue
+		}
+		amount, err := decimal.NewFromString(token)
+		if err != nil {
+			return nil, errors.Errorf("Invalid rule amount range: '%s'", value)
+		}
+		*bound = &amount
+	}
+	if r.Min != nil && r.Max != nil && r.Max.LessThan(*r.Min) {
+		return nil, errors.Errorf("Rule amount range max must not be less than min: '%s'", value)
+	}
+	return r, nil
+}
+
+func (r amountRange) match(txn ledger.Transaction) bool {
+	amount := txn.Postings[0].Amount
+	return (r.Min == nil || !amount.LessThan(*r.Min)) && (r.Max == nil || !amount.GreaterThan(*r.Max))
+}
+
+// dateRange matches dates between Min and Max, inclusive. Dates are formatted with ledger.DateFormat.
+type dateRange struct {
+	Min, Max string
+}
+
+func parseDateRange(value string) (condition, error) {
+	tokens := strings.SplitN(value, rangeSeparator, 2)
+	r := dateRange{Min: strings.TrimSpace(tokens[0]), Max: strings.TrimSpace(tokens[1])}
+	for _, bound := range []string{r.Min, r.Max} {
+		if bound == "" {
+			continue
+		}
+		if _, err := time.Parse(ledger.DateFormat, bound); err != nil {
+			return nil, errors.Errorf("Invalid rule date range, dates must be formatted like %s: '%s'", ledger.DateFormat, value)
+		}
+	}
+	if r.Min != "" && r.Max != "" && r.Max < r.Min {
+		return nil, errors.Errorf("Rule date range max must not be before min: '%s'", value)
+	}
+	return r, nil
+}
+
+func (r dateRange) match(txn ledger.Transaction) bool {
+	date := txn.Date.Format(ledger.DateFormat)
+	return (r.Min == "" || date >= r.Min) && (r.Max == "" || date <= r.Max)
+}
+
+// weekdayRange matches weekdays from Start through End, inclusive. Wraps around the end of the week, like 'sat..sun'.
+type weekdayRange struct {
+	Start, End time.Weekday
+}
+
+func parseWeekdayRange(value string) (condition, error) {
+	tokens := strings.SplitN(value, rangeSeparator, 2)
+	var r weekdayRange
+	for i, bound := range []*time.Weekday{&r.Start, &r.End} {
+		weekday, ok := parseWeekday(tokens[i])
+		if !ok {
+			return nil, errors.Errorf("Invalid rule weekday range, weekdays must be names like 'mon' or 'monday': '%s'", value)
+		}
+		*bound = weekday
+	}
+	return r, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) < 2 {
+		return 0, false
+	}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.HasPrefix(strings.ToLower(weekday.String()), name) {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
+func (r weekdayRange) match(txn ledger.Transaction) bool {
+	weekday := txn.Date.Weekday()
+	if r.Start <= r.End {
+		return r.Start <= weekday && weekday <= r.End
+	}
+	return weekday >= r.Start || weekday <= r.End
+}
+
+// signCondition matches the sign of the first posting's amount. Zero amounts never match.
+type signCondition struct {
+	Negative bool
+}
+
+func (s signCondition) match(txn ledger.Transaction) bool {
+	amount := txn.Postings[0].Amount
+	if s.Negative {
+		return amount.IsNegative()
+	}
+	return amount.IsPositive()
+}
+
+// tagCondition matches transactions with the tag Name on the transaction or any posting. If Value is set, the tag's value must match too.
+type tagCondition struct {
+	Name  string
+	Value *regexp.Regexp
+}
+
+func (t tagCondition) match(txn ledger.Transaction) bool {
+	tagMaps := []map[string]string{txn.Tags}
+	for _, p := range txn.Postings {
+		tagMaps = append(tagMaps, p.Tags)
+	}
+	for _, tags := range tagMaps {
+		if value, ok := tags[t.Name]; ok && (t.Value == nil || t.Value.MatchString(value)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionMatch(t *testing.T) {
+	// 2020/01/04 is a Saturday
+	date, err := time.Parse(ledger.DateFormat, "2020/01/04")
+	require.NoError(t, err)
+	balance := decimal.NewFromFloat(100)
+	txn := ledger.Transaction{
+		Date:  date,
+		Payee: "Hank's Burgers",
+		Tags:  map[string]string{"trip": "Paris"},
+		Postings: []ledger.Posting{
+			{Account: "liabilities:Card:****1234", Amount: decimal.NewFromFloat(-12.5), Balance: &balance, Currency: usd, Comment: "POS purchase 1234", Tags: map[string]string{"id": "some-id"}},
+			{Account: "uncategorized", Amount: decimal.NewFromFloat(12.5), Currency: usd},
+		},
+	}
+
+	for _, tc := range []struct {
+		condition   string
+		shouldMatch bool
+	}{
+		{`burgers`, true},
+		{`%description ^hank`, true},
+		{`%payee ^burgers`, false},
+		{`%memo pos purchase`, true},
+		{`%memo atm`, false},
+		{`%account ^liabilities:card`, true},
+		{`%account ^assets:`, false},
+		{`%currency \$`, true},
+		{`%balance ^100$`, true},
+		{`%amount -20..-10`, true},
+		{`%amount -10..`, false},
+		{`%amount ..-12.5`, true},
+		{`%amount ^-12`, true},
+		{`%sign negative`, true},
+		{`%sign positive`, false},
+		{`%date 2020/01/01..2020/01/31`, true},
+		{`%date 2020/01/05..`, false},
+		{`%date ^2020/01`, true},
+		{`%weekday sat..sun`, true},
+		{`%weekday mon..fri`, false},
+		{`%weekday saturday`, true},
+		{`%tag trip`, true},
+		{`%tag trip=^paris$`, true},
+		{`%tag trip=london`, false},
+		{`%tag id`, true},
+		{`%tag receipt`, false},
+	} {
+		t.Run(tc.condition, func(t *testing.T) {
+			groups, err := parseConditionGroups([]string{tc.condition})
+			require.NoError(t, err)
+			assert.Equal(t, tc.shouldMatch, groups.match(txn))
+		})
+	}
+}
+
+func TestConditionGroups(t *testing.T) {
+	txn := ledger.Transaction{
+		Payee: "Coffee shop",
+		Postings: []ledger.Posting{
+			{Amount: decimal.NewFromFloat(-4)},
+			{Amount: decimal.NewFromFloat(4)},
+		},
+	}
+	for _, tc := range []struct {
+		description string
+		conditions  []string
+		shouldMatch bool
+	}{
+		{"and both match", []string{"coffee", "& %amount -5..0"}, true},
+		{"and one fails", []string{"coffee", "& %amount ..-5"}, false},
+		{"or second group matches", []string{"tea", "coffee & ignored", "%sign negative"}, true},
+		{"and binds to previous line only", []string{"tea", "%sign negative", "& %amount ..-10"}, false},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			groups, err := parseConditionGroups(tc.conditions)
+			require.NoError(t, err)
+			assert.Equal(t, tc.shouldMatch, groups.match(txn))
+		})
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	for _, tc := range []struct {
+		condition string
+		err       string
+	}{
+		{"& coffee", `First rule condition must not start with "&": "& coffee"`},
+		{"%amount", "Rule field condition must have both field and value: '%amount'"},
+		{"%color blue", "Unrecognized rule condition field: 'color'"},
+		{"%amount ten..20", "Invalid rule amount range: 'ten..20'"},
+		{"%amount 20..10", "Rule amount range max must not be less than min: '20..10'"},
+		{"%date 2020-01-01..", "Invalid rule date range, dates must be formatted like 2006/01/02: '2020-01-01..'"},
+		{"%date 2020/02/01..2020/01/01", "Rule date range max must not be before min: '2020/02/01..2020/01/01'"},
+		{"%weekday m..fri", "Invalid rule weekday range, weekdays must be names like 'mon' or 'monday': 'm..fri'"},
+		{"%sign zero", "Rule sign condition must be 'positive' or 'negative': 'zero'"},
+	} {
+		t.Run(tc.condition, func(t *testing.T) {
+			_, err := parseConditionGroups([]string{tc.condition})
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// csvImportFields are the hledger transaction fields which can be assigned for CSV imports.
+// Other fields, like account2, are rule actions applied to the imported transactions. See isCSVImportAssignment for description and comment.
+var csvImportFields = map[string]bool{
+	"amount":     true,
+	"amount-in":  true,
+	"amount-out": true,
+	"balance":    true,
+	"code":       true,
+	"currency":   true,
+	"date":       true,
+	"date2":      true,
+	"status":     true,
+}
+
+// defaultCSVDateFormats are tried in order when no date-format directive is set
+var defaultCSVDateFormats = []string{"2006-1-2", "2006/1/2", "2006.1.2"}
+
+var csvInterpolation = regexp.MustCompile(`%([A-Za-z0-9_-]+)`)
+
+// CSVImportRules converts CSV statements into transactions, following the import settings of an hledger CSV rules file.
+// Supports the directives skip, separator, fields, date-format, decimal-mark, and assignments of csvImportFields, description, and comment, at the top level or in if blocks.
+// Parsed with the file's rules by NewCSVRulesFromReader.
+type CSVImportRules struct {
+	skip        int
+	separator   rune
+	decimalMark rune
+	fields      []string
+	dateFormat  string
+	directives  []string
+	assignments map[string]string
+	blocks      []csvImportBlock
+}
+
+// csvImportBlock assigns fields for CSV records matching any of its conditions
+type csvImportBlock struct {
+	conditions  []string
+	matchRecord *regexp.Regexp
+	assignments map[string]string
+}
+
+func newCSVImportRules() *CSVImportRules {
+	return &CSVImportRules{
+		separator:   ',',
+		decimalMark: '.',
+		assignments: make(map[string]string),
+	}
+}
+
+func splitDirective(line string) (key, value string) {
+	tokens := strings.SplitN(line, " ", 2)
+	key = strings.TrimSpace(tokens[0])
+	if len(tokens) == 2 {
+		value = strings.TrimSpace(tokens[1])
+	}
+	return key, value
+}
+
+// isCSVImportAssignment returns true if assigning 'value' to 'key' sets a field of imported CSV records, rather than being a rule action.
+// Description and comment are import fields at the top level, like hledger, and in if blocks when they reference CSV fields like '%2'.
+// Referencing only their original value, like 'description %description (work)', is a rule action instead.
+func isCSVImportAssignment(key, value string, inIf bool) bool {
+	if csvImportFields[key] {
+		return true
+	}
+	if key != "description" && key != "comment" {
+		return false
+	}
+	if !inIf {
+		return true
+	}
+	for _, match := range csvInterpolation.FindAllStringSubmatch(value, -1) {
+		if !strings.EqualFold(match[1], key) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCSVImportDirective returns true if 'key' is a directive parsed by parseDirective
+func isCSVImportDirective(key string) bool {
+	switch key {
+	case "skip", "separator", "decimal-mark", "fields", "date-format", "newest-first":
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *CSVImportRules) parseDirective(key, value string) error {
+	switch key {
+	case "skip":
+		if value == "" {
+			c.skip = 1
+			break
+		}
+		skip, err := strconv.Atoi(value)
+		if err != nil || skip < 0 {
+			return errors.Errorf("Skip must be a non-negative integer: '%s'", value)
+		}
+		c.skip = skip
+	case "separator":
+		switch strings.ToUpper(value) {
+		case "TAB":
+			c.separator = '\t'
+		case "SPACE":
+			c.separator = ' '
+		default:
+			if utf8.RuneCountInString(value) != 1 {
+				return errors.Errorf("Separator must be a single character, TAB, or SPACE: '%s'", value)
+			}
+			c.separator, _ = utf8.DecodeRuneInString(value)
+		}
+	case "decimal-mark":
+		if value != "." && value != "," {
+			return errors.Errorf("Decimal mark must be '.' or ',': '%s'", value)
+		}
+		c.decimalMark = rune(value[0])
+	case "fields":
+		c.fields = nil
+		for _, field := range strings.Split(value, ",") {
+			c.fields = append(c.fields, strings.TrimSpace(field))
+		}
+	case "date-format":
+		if value == "" {
+			return errors.New("Date format must not be empty")
+		}
+		c.dateFormat = convertStrftime(value)
+	case "newest-first":
+		// transactions are sorted when added to the ledger
+	default:
+		return errors.Errorf("Unrecognized directive: '%s'", key)
+	}
+	c.directives = append(c.directives, strings.TrimSpace(key+" "+value))
+	return nil
+}
+
+// addBlock adds field assignments for CSV records matching any of 'conditions'
+func (c *CSVImportRules) addBlock(conditions []string, assignments map[string]string) error {
+	conditions, pattern, err := validateConditions(conditions)
+	if err != nil {
+		return err
+	}
+	c.blocks = append(c.blocks, csvImportBlock{
+		conditions:  conditions,
+		matchRecord: pattern,
+		assignments: assignments,
+	})
+	return nil
+}
+
+func (c *CSVImportRules) String() string {
+	var buf strings.Builder
+	for _, directive := range c.directives {
+		buf.WriteString(directive)
+		buf.WriteRune('\n')
+	}
+	writeAssignments(&buf, c.assignments, "")
+	if buf.Len() > 0 {
+		buf.WriteRune('\n')
+	}
+	for _, block := range c.blocks {
+		buf.WriteString("if\n")
+		for _, cond := range block.conditions {
+			buf.WriteString(cond)
+			buf.WriteRune('\n')
+		}
+		writeAssignments(&buf, block.assignments, "  ")
+		buf.WriteRune('\n')
+	}
+	return buf.String()
+}
+
+// writeAssignments writes field assignments sorted by field name
+func writeAssignments(buf *strings.Builder, assignments map[string]string, indent string) {
+	keys := make([]string, 0, len(assignments))
+	for key := range assignments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buf.WriteString(indent + key + " " + assignments[key] + "\n")
+	}
+}
+
+// convertStrftime converts an hledger (strftime) date format into a Go time layout
+func convertStrftime(format string) string {
+	replacer := strings.NewReplacer(
+		"%Y", "2006",
+		"%y", "06",
+		"%m", "1",
+		"%-m", "1",
+		"%d", "2",
+		"%-d", "2",
+		"%e", "_2",
+		"%b", "Jan",
+		"%h", "Jan",
+		"%B", "January",
+		"%H", "15",
+		"%-H", "15",
+		"%I", "3",
+		"%-I", "3",
+		"%M", "4",
+		"%-M", "4",
+		"%S", "5",
+		"%-S", "5",
+		"%p", "PM",
+		"%%", "%",
+	)
+	return replacer.Replace(format)
+}
+
+// Transactions reads a CSV statement for 'account' and converts each record into a transaction
+func (c *CSVImportRules) Transactions(reader io.Reader, account string) ([]ledger.Transaction, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = c.separator
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read CSV")
+	}
+	if c.skip >= len(records) {
+		return nil, nil
+	}
+
+	occurrences := make(map[string]int)
+	txns := make([]ledger.Transaction, 0, len(records)-c.skip)
+	for i, record := range records[c.skip:] {
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		txn, err := c.transaction(record, account, occurrences)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid CSV record %d", c.skip+i+1)
+		}
+		txns = append(txns, txn)
+	}
+	return txns, nil
+}
+
+// transaction converts one CSV record for 'account1' into a transaction
+func (c *CSVImportRules) transaction(record []string, account1 string, occurrences map[string]int) (ledger.Transaction, error) {
+	columns := make(map[string]string, len(record))
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		columns[strconv.Itoa(i+1)] = value
+		if i < len(c.fields) && c.fields[i] != "" {
+			columns[strings.ToLower(c.fields[i])] = value
+		}
+	}
+
+	assignments := make(map[string]string, len(c.assignments))
+	for key, value := range c.assignments {
+		assignments[key] = value
+	}
+	recordLine := strings.Join(record, ",")
+	for _, block := range c.blocks {
+		if block.matchRecord.MatchString(recordLine) {
+			for key, value := range block.assignments {
+				assignments[key] = value
+			}
+		}
+	}
+
+	field := func(name string) string {
+		if value, assigned := assignments[name]; assigned {
+			return strings.TrimSpace(csvInterpolation.ReplaceAllStringFunc(value, func(match string) string {
+				return columns[strings.ToLower(match[1:])]
+			}))
+		}
+		return columns[name]
+	}
+
+	var txn ledger.Transaction
+	date, err := c.parseDate(field("date"))
+	if err != nil {
+		return txn, err
+	}
+	txn.Date = date
+	if date2 := field("date2"); date2 != "" {
+		auxDate, err := c.parseDate(date2)
+		if err != nil {
+			return txn, err
+		}
+		txn.AuxDate = &auxDate
+	}
+	txn.Payee = field("description")
+	txn.Code = field("code")
+	switch field("status") {
+	case "*":
+		txn.Status = ledger.StatusCleared
+	case "!":
+		txn.Status = ledger.StatusPending
+	}
+
+	currency := field("currency")
+	var amount decimal.Decimal
+	if amountStr := field("amount"); amountStr != "" {
+		var amountCurrency string
+		amount, amountCurrency, err = c.parseAmount(amountStr)
+		if err != nil {
+			return txn, err
+		}
+		if currency == "" {
+			currency = amountCurrency
+		}
+	} else {
+		amountIn, amountOut := field("amount-in"), field("amount-out")
+		if amountIn == "" && amountOut == "" {
+			return txn, errors.New("Record must have an amount, amount-in, or amount-out")
+		}
+		for i, value := range []string{amountIn, amountOut} {
+			if value == "" {
+				continue
+			}
+			parsed, amountCurrency, err := c.parseAmount(value)
+			if err != nil {
+				return txn, err
+			}
+			if i == 0 {
+				amount = amount.Add(parsed.Abs())
+			} else {
+				amount = amount.Sub(parsed.Abs())
+			}
+			if currency == "" {
+				currency = amountCurrency
+			}
+		}
+	}
+
+	var balance *decimal.Decimal
+	if balanceStr := field("balance"); balanceStr != "" {
+		parsed, _, err := c.parseAmount(balanceStr)
+		if err != nil {
+			return txn, err
+		}
+		balance = &parsed
+	}
+
+	// identical records, like two coffees on the same day, are distinguished by their order in the statement
+	recordKey := account1 + "\x00" + strings.Join(record, "\x00")
+	occurrences[recordKey]++
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", recordKey, occurrences[recordKey])))
+
+	txn.Postings = []ledger.Posting{
+		{
+			Account:  account1,
+			Amount:   amount,
+			Balance:  balance,
+			Currency: currency,
+			Comment:  field("comment"),
+			Tags:     map[string]string{"id": fmt.Sprintf("csv-%x", hash[:12])},
+		},
+		{
+			Account:  model.Uncategorized,
+			Amount:   amount.Neg(),
+			Currency: currency,
+		},
+	}
+	return txn, nil
+}
+
+func (c *CSVImportRules) parseDate(value string) (time.Time, error) {
+	formats := defaultCSVDateFormats
+	if c.dateFormat != "" {
+		formats = []string{c.dateFormat}
+	}
+	for _, format := range formats {
+		if date, err := time.Parse(format, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("Invalid date: '%s'", value)
+}
+
+// parseAmount parses amounts like "$1,234.56", "-5", "(5.00)", or "5 EUR", returning any currency symbol found
+func (c *CSVImportRules) parseAmount(value string) (decimal.Decimal, string, error) {
+	var number, currency strings.Builder
+	negative := false
+	for _, r := range value {
+		switch {
+		case unicode.IsDigit(r):
+			number.WriteRune(r)
+		case r == c.decimalMark:
+			number.WriteRune('.')
+		case r == '-' || r == '(':
+			negative = !negative
+		case r == ')' || r == '+' || unicode.IsSpace(r) || r == '.' || r == ',':
+			// skip signs, whitespace, and digit group separators
+		default:
+			currency.WriteRune(r)
+		}
+	}
+	amount, err := decimal.NewFromString(number.String())
+	if err != nil {
+		return decimal.Zero, "", errors.Errorf("Invalid amount: '%s'", value)
+	}
+	if negative {
+		amount = amount.Neg()
+	}
+	return amount, currency.String(), nil
+}
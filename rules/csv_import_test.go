@@ -0,0 +1,268 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCSVRulesFromReaderImport(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		rules       string
+		expectErr   bool
+	}{
+		{
+			description: "directives and if blocks",
+			rules: `
+# comment
+skip 1
+separator ;
+fields date, description, amount
+date-format %m/%d/%Y
+currency $
+
+if
+COFFEE
+  account2 expenses:Food
+  status *
+`,
+		},
+		{
+			description: "unknown directive",
+			rules:       `include other.rules`,
+			expectErr:   true,
+		},
+		{
+			description: "invalid skip",
+			rules:       `skip -1`,
+			expectErr:   true,
+		},
+		{
+			description: "invalid separator",
+			rules:       `separator ab`,
+			expectErr:   true,
+		},
+		{
+			description: "directive in if",
+			rules: `
+if coffee
+  skip 1
+`,
+			expectErr: true,
+		},
+		{
+			description: "if without assignments",
+			rules:       `if coffee`,
+			expectErr:   true,
+		},
+		{
+			description: "unknown field in if",
+			rules: `
+if coffee
+  category expenses:Food
+`,
+			expectErr: true,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			_, _, err := NewCSVRulesFromReader(strings.NewReader(tc.rules))
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewCSVRulesFromReaderDescriptionAssignments(t *testing.T) {
+	parsedRules, csvImport, err := NewCSVRulesFromReader(strings.NewReader(`
+fields date, name, amount
+description %2
+comment imported
+
+if COFFEE
+  comment %name
+
+if TEA
+  description %description (tea)
+`))
+	require.NoError(t, err)
+	require.Len(t, parsedRules, 1, "Only the assignment without CSV fields should be a rule")
+	assert.Equal(t, []string{"TEA"}, parsedRules[0].(csvRule).Conditions)
+	assert.Equal(t, map[string]string{"description": "%2", "comment": "imported"}, csvImport.assignments)
+	require.Len(t, csvImport.blocks, 1)
+	assert.Equal(t, map[string]string{"comment": "%name"}, csvImport.blocks[0].assignments)
+}
+
+func TestCSVImportString(t *testing.T) {
+	const rulesText = `skip 1
+fields date, description, amount
+date-format %m/%d/%Y
+currency $
+
+if
+COFFEE
+  status *
+
+if
+COFFEE
+  account2 expenses:Food
+
+`
+	parsedRules, csvImport, err := NewCSVRulesFromReader(strings.NewReader(rulesText))
+	require.NoError(t, err)
+	require.Len(t, parsedRules, 1)
+	store := NewStore(parsedRules, csvImport)
+	assert.Equal(t, rulesText, store.String())
+
+	_, reparsed, err := NewCSVRulesFromReader(strings.NewReader(store.String()))
+	require.NoError(t, err)
+	assert.Equal(t, csvImport, reparsed)
+}
+
+func TestCSVImportTransactions(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		rules       string
+		account     string
+		csv         string
+		expectTxns  []string
+		expectErr   bool
+	}{
+		{
+			description: "amount field",
+			rules: `
+skip
+fields date, description, amount, balance
+date-format %m/%d/%Y
+currency $
+
+if COFFEE
+  status *
+  account2 expenses:Food
+`,
+			csv: `Date,Description,Amount,Balance
+01/02/2020,"COFFEE, INC",-4.25,"1,095.75"
+1/3/2020,Paycheck,"$1,000.10",2095.85
+`,
+			expectTxns: []string{
+				`2020/01/02 * COFFEE, INC
+    assets:Bank:Checking  $ -4.25 = $ 1095.75
+    uncategorized          $ 4.25
+`,
+				`2020/01/03 Paycheck
+    assets:Bank:Checking   $ 1000.1 = $ 2095.85
+    uncategorized         $ -1000.1
+`,
+			},
+		},
+		{
+			description: "amount in and out with interpolation",
+			account:     "assets:Bank:Savings",
+			rules: `
+skip 2
+separator TAB
+fields date, description, memo, deposit, withdrawal
+code %memo
+amount-in %deposit
+amount-out %5
+decimal-mark ,
+`,
+			csv: "Statement\nDate\tPayee\tMemo\tDeposit\tWithdrawal\n" +
+				"2020-01-02\tShop\tGroceries\t\t12,50 EUR\n" +
+				"2020-01-03\tEmployer\tSalary\t1.000,00 EUR\t\n",
+			expectTxns: []string{
+				`2020/01/02 (Groceries) Shop
+    assets:Bank:Savings  -12.5 EUR
+    uncategorized         12.5 EUR
+`,
+				`2020/01/03 (Salary) Employer
+    assets:Bank:Savings   1000 EUR
+    uncategorized        -1000 EUR
+`,
+			},
+		},
+		{
+			description: "description and comment interpolation",
+			rules: `
+fields date, name, reference, amount
+currency $
+description %name %reference
+comment ref %3
+
+if COFFEE
+  comment coffee %reference
+`,
+			csv: "2020/01/02,COFFEE,123,-4.25\n2020/01/03,Paycheck,456,100\n",
+			expectTxns: []string{
+				`2020/01/02 COFFEE 123
+    assets:Bank:Checking  $ -4.25 ; coffee 123
+    uncategorized          $ 4.25
+`,
+				`2020/01/03 Paycheck 456
+    assets:Bank:Checking   $ 100 ; ref 456
+    uncategorized         $ -100
+`,
+			},
+		},
+		{
+			description: "invalid date",
+			rules:       `fields date, description, amount`,
+			csv:         "January 2nd,Coffee,-4.25\n",
+			expectErr:   true,
+		},
+		{
+			description: "missing amount",
+			rules:       `fields date, description`,
+			csv:         "2020/01/02,Coffee\n",
+			expectErr:   true,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			_, csvImport, err := NewCSVRulesFromReader(strings.NewReader(tc.rules))
+			require.NoError(t, err)
+			account := "assets:Bank:Checking"
+			if tc.account != "" {
+				account = tc.account
+			}
+			txns, err := csvImport.Transactions(strings.NewReader(tc.csv), account)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var txnStrings []string
+			for _, txn := range txns {
+				delete(txn.Postings[0].Tags, "id")
+				txnStrings = append(txnStrings, txn.String())
+			}
+			assert.Equal(t, tc.expectTxns, txnStrings)
+		})
+	}
+}
+
+func TestCSVImportTransactionIDs(t *testing.T) {
+	_, csvImport, err := NewCSVRulesFromReader(strings.NewReader(`fields date, description, amount`))
+	require.NoError(t, err)
+	const csv = `2020-01-02,Coffee,-4.25
+2020-01-02,Coffee,-4.25
+`
+	txns, err := csvImport.Transactions(strings.NewReader(csv), "assets:Bank")
+	require.NoError(t, err)
+	require.Len(t, txns, 2)
+	id := func(txn ledger.Transaction) string {
+		return txn.Postings[0].ID()
+	}
+	assert.NotEqual(t, id(txns[0]), id(txns[1]), "Identical records should have different IDs")
+	assert.True(t, strings.HasPrefix(id(txns[0]), "csv-"))
+
+	reimported, err := csvImport.Transactions(strings.NewReader(csv), "assets:Bank")
+	require.NoError(t, err)
+	assert.Equal(t, id(txns[0]), id(reimported[0]), "IDs should be stable across imports")
+	assert.Equal(t, id(txns[1]), id(reimported[1]))
+}
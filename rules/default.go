@@ -269,8 +269,11 @@ type category struct {
 	Category string
 }
 
-// assumes only 2 postings
+// assumes at most 2 postings, split transactions with more postings keep their categories
 func (c category) Match(txn ledger.Transaction) bool {
+	if len(txn.Postings) > 2 {
+		return false
+	}
 	if c.PayeeContains != nil && !c.PayeeContains.MatchString(txn.Payee) {
 		return false
 	}
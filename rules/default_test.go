@@ -115,6 +115,18 @@ func TestCategoryMatch(t *testing.T) {
 			},
 			expectMatch: false,
 		},
+		{
+			description: "split transaction",
+			category: category{
+				Zero:     true,
+				Positive: true,
+				Negative: true,
+			},
+			txn: ledger.Transaction{
+				Postings: []ledger.Posting{{}, {}, {}},
+			},
+			expectMatch: false,
+		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
 			assert.Equal(t, tc.expectMatch, tc.category.Match(tc.txn))
@@ -5,39 +5,126 @@ import (
 	"encoding/json"
 	"io"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/johnstarich/sage/client/model"
 	"github.com/johnstarich/sage/ledger"
 	"github.com/pkg/errors"
 )
 
+const transactionTagsSeparator = ","
+
 type csvRule struct {
 	Conditions []string // used for formatting purposes
 	matchLine  *regexp.Regexp
+	// groups is set if any conditions are field conditions or joined with '&', and replaces matchLine
+	groups conditionGroups
 
 	account1, Account2 string
 	comment            string
+
+	Payee              string            `json:",omitempty"`
+	TransactionComment string            `json:",omitempty"`
+	Tags               map[string]string `json:",omitempty"`
+	Transfer           string            `json:",omitempty"`
+	Splits             []SplitPosting    `json:",omitempty"`
+	SplitRemainder     string            `json:",omitempty"`
 }
 
+// Actions are the changes a rule makes to matching transactions
+type Actions struct {
+	// Account1 and Account2 replace the first and second postings' accounts
+	Account1, Account2 string
+	// Comment replaces the first posting's comment. '%comment' is replaced with the original comment.
+	Comment string
+	// Payee replaces the transaction's payee. '%description' is replaced with the original payee.
+	Payee string
+	// TransactionComment replaces the transaction's comment
+	TransactionComment string
+	// Tags are added to the transaction's tags
+	Tags map[string]string
+	// Transfer marks the transaction as a transfer with an asset or liability account, replacing the second posting's account.
+	// Intended for accounts that aren't synced or imported, like manual accounts. Transfers between synced accounts are merged automatically.
+	Transfer string
+	// Splits replace the second posting with a posting for each split, and SplitRemainder receives the rest of its amount.
+	// Only applies to transactions with exactly 2 postings.
+	Splits         []SplitPosting
+	SplitRemainder string
+}
+
+// NewCSVRule creates a rule which sets account1, account2, and comment on transactions matching any of the conditions
 func NewCSVRule(account1, account2, comment string, conditions ...string) (Rule, error) {
-	conditions, pattern, err := validateConditions(conditions)
+	return NewRule(Actions{Account1: account1, Account2: account2, Comment: comment}, conditions...)
+}
+
+// NewRule creates a rule which applies actions to transactions matching the conditions.
+// Each condition is a regex for the transaction, or a field condition like '%amount 10..20'. Conditions starting with '&' must match along with the previous condition.
+func NewRule(actions Actions, conditions ...string) (Rule, error) {
+	conditions, pattern, groups, err := validateRuleConditions(conditions)
 	if err != nil {
 		return csvRule{}, err
 	}
 	rule := csvRule{
-		Conditions: conditions,
-		matchLine:  pattern,
-		account1:   strings.TrimSpace(account1),
-		Account2:   strings.TrimSpace(account2),
-		comment:    strings.TrimSpace(comment),
+		Conditions:         conditions,
+		matchLine:          pattern,
+		groups:             groups,
+		account1:           strings.TrimSpace(actions.Account1),
+		Account2:           strings.TrimSpace(actions.Account2),
+		comment:            strings.TrimSpace(actions.Comment),
+		Payee:              strings.TrimSpace(actions.Payee),
+		TransactionComment: strings.TrimSpace(actions.TransactionComment),
+		Transfer:           strings.TrimSpace(actions.Transfer),
+		SplitRemainder:     strings.TrimSpace(actions.SplitRemainder),
+	}
+	for _, split := range actions.Splits {
+		split.Account = strings.TrimSpace(split.Account)
+		rule.Splits = append(rule.Splits, split)
+	}
+	if len(actions.Tags) > 0 {
+		rule.Tags = make(map[string]string, len(actions.Tags))
+		for key, value := range actions.Tags {
+			rule.Tags[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
 	}
-	if rule.account1 == "" && rule.Account2 == "" && rule.comment == "" {
-		return nil, errors.New("Invalid rule: No category selected")
+	if err := rule.validateActions(); err != nil {
+		return nil, err
 	}
 	return rule, nil
 }
 
+func (c csvRule) validateActions() error {
+	if c.account1 == "" && c.Account2 == "" && c.comment == "" && c.Payee == "" && c.TransactionComment == "" && len(c.Tags) == 0 && c.Transfer == "" && c.SplitRemainder == "" && len(c.Splits) == 0 {
+		return errors.New("Invalid rule: No category selected")
+	}
+	if c.Transfer != "" {
+		if c.Account2 != "" {
+			return errors.New("Invalid rule: Transfers can't also set account2")
+		}
+		if !strings.HasPrefix(c.Transfer, model.AssetAccount+":") && !strings.HasPrefix(c.Transfer, model.LiabilityAccount+":") {
+			return errors.Errorf("Invalid rule: Transfer account must be an asset or liability account: %q", c.Transfer)
+		}
+	}
+	if c.SplitRemainder != "" || len(c.Splits) > 0 {
+		if c.Account2 != "" || c.Transfer != "" {
+			return errors.New("Invalid rule: Splits can't also set account2 or transfer")
+		}
+		if err := validateSplits(c.Splits, c.SplitRemainder); err != nil {
+			return err
+		}
+	}
+	for key, value := range c.Tags {
+		if ledger.IsReservedTag(key) {
+			return errors.Errorf("Invalid rule: Tag %q is reserved", key)
+		}
+		if key == "" || strings.ContainsAny(key, ":, ") || strings.Contains(value, transactionTagsSeparator) {
+			return errors.Errorf("Invalid rule: Invalid tag %q: %q", key, value)
+		}
+	}
+	return nil
+}
+
 func validateConditions(conditions []string) (cleanedConditions []string, re *regexp.Regexp, err error) {
 	cleanedConditions = make([]string, 0, len(conditions))
 	for _, c := range conditions {
@@ -54,6 +141,21 @@ func validateConditions(conditions []string) (cleanedConditions []string, re *re
 	return cleanedConditions, pattern, err
 }
 
+// validateRuleConditions validates conditions for a ledger transaction rule. Returns condition groups if any conditions aren't plain regexes.
+func validateRuleConditions(conditions []string) (cleanedConditions []string, re *regexp.Regexp, groups conditionGroups, err error) {
+	cleanedConditions, re, err = validateConditions(conditions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, c := range cleanedConditions {
+		if !isPlainCondition(c) {
+			groups, err = parseConditionGroups(cleanedConditions)
+			return cleanedConditions, re, groups, err
+		}
+	}
+	return cleanedConditions, re, nil, nil
+}
+
 // TODO add memoization?
 // NOTE: assumes the transaction has at least one posting (i.e. a valid txn)
 func ledgerMatchLine(txn ledger.Transaction) string {
@@ -71,20 +173,47 @@ func ledgerMatchLine(txn ledger.Transaction) string {
 }
 
 func (c csvRule) Match(txn ledger.Transaction) bool {
+	if c.groups != nil {
+		return c.groups.match(txn)
+	}
 	return c.matchLine.MatchString(ledgerMatchLine(txn))
 }
 
 func (c csvRule) Apply(txn *ledger.Transaction) {
+	// split transactions have more than 2 postings, so only change the second posting of unsplit transactions
+	isSplit := len(txn.Postings) > 2
 	if c.account1 != "" {
 		txn.Postings[0].Account = c.account1
 	}
-	if c.Account2 != "" {
+	if c.Account2 != "" && !isSplit {
 		txn.Postings[1].Account = c.Account2
 	}
 	if c.comment != "" {
 		comment := strings.ReplaceAll(c.comment, "%comment", txn.Postings[0].Comment)
 		txn.Postings[0].Comment = comment
 	}
+	if c.Payee != "" {
+		txn.Payee = strings.ReplaceAll(c.Payee, "%description", txn.Payee)
+	}
+	if c.TransactionComment != "" {
+		txn.Comment = c.TransactionComment
+	}
+	if len(c.Tags) > 0 {
+		tags := make(map[string]string, len(txn.Tags)+len(c.Tags))
+		for key, value := range txn.Tags {
+			tags[key] = value
+		}
+		for key, value := range c.Tags {
+			tags[key] = value
+		}
+		txn.Tags = tags
+	}
+	if c.Transfer != "" && !isSplit {
+		txn.Postings[1].Account = c.Transfer
+	}
+	if c.SplitRemainder != "" {
+		applySplits(txn, c.Splits, c.SplitRemainder)
+	}
 }
 
 type csvRuleJSON csvRule
@@ -95,9 +224,10 @@ func (c *csvRule) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	*c = csvRule(jsonRule)
-	conditions, pattern, err := validateConditions(c.Conditions)
+	conditions, pattern, groups, err := validateRuleConditions(c.Conditions)
 	c.Conditions = conditions
 	c.matchLine = pattern
+	c.groups = groups
 	return err
 }
 
@@ -127,20 +257,61 @@ func (c csvRule) String() string {
 	indent("account1", c.account1)
 	indent("account2", c.Account2)
 	indent("comment", c.comment)
+	indent("description", c.Payee)
+	indent("txncomment", c.TransactionComment)
+	indent("tags", formatTags(c.Tags))
+	indent("transfer", c.Transfer)
+	for _, split := range c.Splits {
+		indent("split", split.String())
+	}
+	if c.SplitRemainder != "" {
+		indent("split", c.SplitRemainder+" "+splitRemainder)
+	}
 
 	return buf.String()
 }
 
+// formatTags formats tags like 'name: value, other: value', sorted by name
+func formatTags(tags map[string]string) string {
+	tagStrings := make([]string, 0, len(tags))
+	for key, value := range tags {
+		tagStrings = append(tagStrings, strings.TrimSpace(key+": "+value))
+	}
+	sort.Strings(tagStrings)
+	return strings.Join(tagStrings, transactionTagsSeparator+" ")
+}
+
+// parseTags parses tags formatted by formatTags. Tags without values may omit the colon.
+func parseTags(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, transactionTagsSeparator) {
+		keyValue := strings.SplitN(tag, ":", 2)
+		key := strings.TrimSpace(keyValue[0])
+		if len(keyValue) == 2 {
+			tags[key] = strings.TrimSpace(keyValue[1])
+		} else {
+			tags[key] = ""
+		}
+	}
+	return tags
+}
+
 type readerState struct {
-	foundIf            bool
-	foundExpressions   bool
-	account1, account2 string
-	comment            string
-	conditions         []string
+	foundIf          bool
+	foundExpressions bool
+	foundActions     bool
+	actions          Actions
+	conditions       []string
+	// importAssignments are CSV import field assignments, like 'amount-in %3'
+	importAssignments map[string]string
 }
 
-func NewCSVRulesFromReader(reader io.Reader) (Rules, error) {
+// NewCSVRulesFromReader parses an hledger CSV rules file. Returns the rules for categorizing transactions and the settings for importing CSV statements.
+// Top-level directives like 'fields' and 'skip', and assignments of CSV import fields like 'amount' are import settings, even inside if blocks.
+// Description and comment assignments are import settings at the top level, or in if blocks if they reference CSV fields like '%2'. Other assignments are rule actions.
+func NewCSVRulesFromReader(reader io.Reader) (Rules, *CSVImportRules, error) {
 	var rules Rules
+	csvImport := newCSVImportRules()
 	scanner := bufio.NewScanner(reader)
 
 	var state readerState
@@ -153,41 +324,54 @@ func NewCSVRulesFromReader(reader io.Reader) (Rules, error) {
 			// nothing found
 			return nil
 		}
-		rule, err := NewCSVRule(state.account1, state.account2, state.comment, state.conditions...)
-		if err != nil {
-			return err
+		if state.foundActions {
+			rule, err := NewRule(state.actions, state.conditions...)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		if len(state.importAssignments) > 0 {
+			if !state.foundIf {
+				for key, value := range state.importAssignments {
+					csvImport.assignments[key] = value
+				}
+			} else if err := csvImport.addBlock(state.conditions, state.importAssignments); err != nil {
+				return err
+			}
 		}
-		rules = append(rules, rule)
 		state = readerState{}
 		return nil
 	}
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			// remove blank lines
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			// remove blank lines and comments
 			continue
 		}
 
+		var err error
 		switch {
 		case line == "if" || strings.HasPrefix(line, "if "):
-			if err := foundIf(&state, line, endRule); err != nil {
-				return nil, err
-			}
+			err = foundIf(&state, line, endRule)
 		case state.foundIf && !strings.HasPrefix(line, " "):
 			state.conditions = append(state.conditions, line)
 		default:
-			err := foundExpression(&state, line)
-			if err != nil {
-				return nil, err
-			}
+			err = foundExpression(&state, csvImport, line)
 		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
 	}
 	if err := endRule(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return rules, nil
+	return rules, csvImport, nil
 }
 
 func foundIf(state *readerState, line string, endRule func() error) error {
@@ -207,25 +391,55 @@ func foundIf(state *readerState, line string, endRule func() error) error {
 	return nil
 }
 
-func foundExpression(state *readerState, line string) error {
+func foundExpression(state *readerState, csvImport *CSVImportRules, line string) error {
 	if state.foundIf && len(state.conditions) == 0 {
 		return errors.New("Started expressions but no conditions were found")
 	}
 	state.foundExpressions = true
 	line = strings.TrimSpace(line)
-	tokens := strings.SplitN(line, " ", 2)
-	if len(tokens) != 2 {
+	key, value := splitDirective(line)
+	if isCSVImportDirective(key) {
+		if state.foundIf {
+			return errors.Errorf("Directive '%s' must not be inside an if block", key)
+		}
+		return csvImport.parseDirective(key, value)
+	}
+	if value == "" {
 		return errors.Errorf("Rule transform line must have both key and value: '%s'", line)
 	}
-	key, value := tokens[0], tokens[1]
-	value = strings.TrimSpace(value)
+	if isCSVImportAssignment(key, value, state.foundIf) {
+		if state.importAssignments == nil {
+			state.importAssignments = make(map[string]string)
+		}
+		state.importAssignments[key] = value
+		return nil
+	}
+	state.foundActions = true
 	switch key {
 	case "account1":
-		state.account1 = value
+		state.actions.Account1 = value
 	case "account2":
-		state.account2 = value
+		state.actions.Account2 = value
 	case "comment":
-		state.comment = value
+		state.actions.Comment = value
+	case "description":
+		state.actions.Payee = value
+	case "txncomment":
+		state.actions.TransactionComment = value
+	case "tags":
+		state.actions.Tags = parseTags(value)
+	case "transfer":
+		state.actions.Transfer = value
+	case "split":
+		split, isRemainder, err := parseSplit(value)
+		if err != nil {
+			return err
+		}
+		if isRemainder {
+			state.actions.SplitRemainder = split.Account
+		} else {
+			state.actions.Splits = append(state.actions.Splits, split)
+		}
 	default:
 		return errors.Errorf("Unrecognized rule key: '%s'", key)
 	}
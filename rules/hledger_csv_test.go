@@ -366,7 +366,7 @@ account3 some account
 	} {
 		t.Run(tc.description, func(t *testing.T) {
 			buf := bytes.NewBufferString(tc.input)
-			rules, err := NewCSVRulesFromReader(buf)
+			rules, _, err := NewCSVRulesFromReader(buf)
 			if tc.err {
 				t.Logf("Result: %+v", rules)
 				require.Error(t, err)
@@ -386,3 +386,110 @@ account3 some account
 		})
 	}
 }
+
+func TestRuleActions(t *testing.T) {
+	rule, err := NewRule(Actions{
+		Payee:              "Hank's: %description",
+		TransactionComment: "lunch",
+		Tags:               map[string]string{"trip": "Paris", "reviewed": ""},
+		Transfer:           "assets:Cash",
+	}, "burgers", "& %sign negative")
+	require.NoError(t, err)
+
+	txn := ledger.Transaction{
+		Payee: "HANKS BURGERS 1234",
+		Tags:  map[string]string{"trip": "London", "other": "tag"},
+		Postings: []ledger.Posting{
+			{Account: "assets:Bank:****1234", Amount: decimal.NewFromFloat(-20), Currency: usd},
+			{Account: "uncategorized", Amount: decimal.NewFromFloat(20), Currency: usd},
+		},
+	}
+	require.True(t, rule.Match(txn))
+	rule.Apply(&txn)
+	assert.Equal(t, "Hank's: HANKS BURGERS 1234", txn.Payee)
+	assert.Equal(t, "lunch", txn.Comment)
+	assert.Equal(t, map[string]string{"trip": "Paris", "reviewed": "", "other": "tag"}, txn.Tags)
+	assert.Equal(t, "assets:Cash", txn.Postings[1].Account)
+	assert.Equal(t, "assets:Bank:****1234", txn.Postings[0].Account)
+
+	txn.Postings[0].Amount = decimal.NewFromFloat(20)
+	assert.False(t, rule.Match(txn))
+}
+
+func TestNewRuleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		actions     Actions
+		conditions  []string
+		err         string
+	}{
+		{
+			description: "no actions",
+			err:         "Invalid rule: No category selected",
+		},
+		{
+			description: "transfer and account2",
+			actions:     Actions{Account2: "expenses:Food", Transfer: "assets:Cash"},
+			err:         "Invalid rule: Transfers can't also set account2",
+		},
+		{
+			description: "transfer to expense",
+			actions:     Actions{Transfer: "expenses:Food"},
+			err:         `Invalid rule: Transfer account must be an asset or liability account: "expenses:Food"`,
+		},
+		{
+			description: "bad tag",
+			actions:     Actions{Tags: map[string]string{"trip": "Paris, London"}},
+			err:         `Invalid rule: Invalid tag "trip": "Paris, London"`,
+		},
+		{
+			description: "reserved tag",
+			actions:     Actions{Tags: map[string]string{"id": "some-id"}},
+			err:         `Invalid rule: Tag "id" is reserved`,
+		},
+		{
+			description: "bad condition",
+			actions:     Actions{Payee: "Hank's"},
+			conditions:  []string{"%color blue"},
+			err:         "Unrecognized rule condition field: 'color'",
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			_, err := NewRule(tc.actions, tc.conditions...)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestRulesRoundTrip(t *testing.T) {
+	const rulesText = `if
+burgers
+& %amount ..0
+%memo lunch
+  account2 expenses:Food
+  description Hank's Burgers
+  txncomment lunch
+  tags reviewed:, trip: Paris
+
+if
+%account ^assets:Bank
+& %weekday sat..sun
+& %tag receipt
+  transfer assets:Cash
+
+`
+	parsedRules, _, err := NewCSVRulesFromReader(strings.NewReader(rulesText))
+	require.NoError(t, err)
+	store := NewStore(parsedRules, nil)
+	assert.Equal(t, rulesText, store.String())
+
+	reparsedRules, _, err := NewCSVRulesFromReader(strings.NewReader(store.String()))
+	require.NoError(t, err)
+	assert.Equal(t, parsedRules, reparsedRules)
+
+	data, err := store.MarshalJSON()
+	require.NoError(t, err)
+	var jsonRules Rules
+	require.NoError(t, jsonRules.UnmarshalJSON(data))
+	assert.Equal(t, rulesText, jsonRules.String())
+}
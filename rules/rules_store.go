@@ -4,19 +4,29 @@ import (
 	"encoding/json"
 	"sync"
 
+	"github.com/johnstarich/sage/client/model"
 	"github.com/johnstarich/sage/ledger"
 	"github.com/pkg/errors"
 )
 
 // Store enables manipulation of rules in memory
 type Store struct {
-	rules Rules
-	mu    sync.RWMutex
+	rules     Rules
+	csvImport *CSVImportRules
+	mu        sync.RWMutex
 }
 
-// NewStore creates a rules store from the given rules
-func NewStore(rules Rules) *Store {
-	return &Store{rules: rules}
+// NewStore creates a rules store from the given rules and CSV import settings. If csvImport is nil, CSV imports use the default settings.
+func NewStore(rules Rules, csvImport *CSVImportRules) *Store {
+	return &Store{rules: rules, csvImport: csvImport}
+}
+
+// CSVImport returns the settings for importing CSV statements
+func (s *Store) CSVImport() *CSVImportRules {
+	if s.csvImport == nil {
+		return newCSVImportRules()
+	}
+	return s.csvImport
 }
 
 // MarshalJSON returns JSON-encoded rules
@@ -46,10 +56,27 @@ func (s *Store) ApplyAll(txns []ledger.Transaction) {
 	}
 }
 
+// ApplyUncategorized runs ApplyAll on only the uncategorized transactions, so categories from a statement or its sync aren't replaced.
+func (s *Store) ApplyUncategorized(txns []ledger.Transaction) {
+	for i := range txns {
+		if isUncategorized(txns[i]) {
+			s.ApplyAll(txns[i : i+1])
+		}
+	}
+}
+
+// isUncategorized returns true if 'txn' has only an account posting and an uncategorized balancing posting
+func isUncategorized(txn ledger.Transaction) bool {
+	return len(txn.Postings) == 2 && txn.Postings[1].Account == model.Uncategorized
+}
+
 func (s *Store) String() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.rules.String()
+	if s.csvImport == nil {
+		return s.rules.String()
+	}
+	return s.csvImport.String() + s.rules.String()
 }
 
 // Replace replaces the current rules with newRules
@@ -10,13 +10,13 @@ import (
 
 func TestNewStore(t *testing.T) {
 	rules := Rules{csvRule{comment: "hi"}}
-	assert.Equal(t, &Store{rules: rules}, NewStore(rules))
+	assert.Equal(t, &Store{rules: rules}, NewStore(rules, nil))
 }
 
 func TestMarhsalJSON(t *testing.T) {
 	store := NewStore(Rules{
 		csvRule{Conditions: []string{"Hank's burgers"}, Account2: "expenses:burgers"},
-	})
+	}, nil)
 
 	data, err := store.MarshalJSON()
 	require.NoError(t, err)
@@ -26,7 +26,7 @@ func TestMarhsalJSON(t *testing.T) {
 func TestStoreApply(t *testing.T) {
 	rule, err := NewCSVRule("", "expenses:burgers", "", "Hank's burgers")
 	require.NoError(t, err)
-	store := NewStore(Rules{rule})
+	store := NewStore(Rules{rule}, nil)
 	txn := ledger.Transaction{
 		Payee: "Hank's burgers",
 		Postings: []ledger.Posting{
@@ -41,7 +41,7 @@ func TestStoreApply(t *testing.T) {
 func TestStoreApplyAll(t *testing.T) {
 	rule, err := NewCSVRule("", "expenses:burgers", "", "Hank's burgers")
 	require.NoError(t, err)
-	store := NewStore(Rules{rule})
+	store := NewStore(Rules{rule}, nil)
 	txns := []ledger.Transaction{
 		{
 			Payee: "Hank's burgers",
@@ -59,7 +59,7 @@ func TestStoreString(t *testing.T) {
 	rule, err := NewCSVRule("", "expenses:burgers", "", "Hank's burgers")
 	require.NoError(t, err)
 	rules := Rules{rule}
-	store := NewStore(rules)
+	store := NewStore(rules, nil)
 	assert.Equal(t, rules.String(), store.String())
 }
 
@@ -67,7 +67,7 @@ func TestStoreReplace(t *testing.T) {
 	rule, err := NewCSVRule("", "expenses:burgers", "", "Hank's burgers")
 	require.NoError(t, err)
 	rules := Rules{rule}
-	store := NewStore(rules)
+	store := NewStore(rules, nil)
 	store.Replace(Rules{})
 	assert.Equal(t, Rules{}, store.rules)
 }
@@ -76,7 +76,7 @@ func TestStoreAccounts(t *testing.T) {
 	rule, err := NewCSVRule("", "expenses:burgers", "", "Hank's burgers")
 	require.NoError(t, err)
 	rules := Rules{rule}
-	store := NewStore(rules)
+	store := NewStore(rules, nil)
 	assert.Equal(t, []string{"expenses:burgers"}, store.Accounts())
 }
 
@@ -87,7 +87,7 @@ func TestUpdate(t *testing.T) {
 				Conditions: []string{"some condition"},
 				Account2:   "some account",
 			},
-		})
+		}, nil)
 		someRule := csvRule{
 			Conditions: []string{"some other condition"},
 			Account2:   "some other account",
@@ -98,7 +98,7 @@ func TestUpdate(t *testing.T) {
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		store := NewStore(Rules{})
+		store := NewStore(Rules{}, nil)
 		err := store.Update(0, csvRule{})
 		require.Error(t, err)
 		assert.Equal(t, "Rule not found", err.Error())
@@ -111,7 +111,7 @@ func TestRemove(t *testing.T) {
 			csvRule{Conditions: []string{"some condition"}, Account2: "some account"},
 			csvRule{Conditions: []string{"some second condition"}, Account2: "some second account"},
 			csvRule{Conditions: []string{"some third condition"}, Account2: "some third account"},
-		})
+		}, nil)
 		err := store.Remove(1)
 		require.NoError(t, err)
 		assert.Equal(t, Rules{
@@ -121,7 +121,7 @@ func TestRemove(t *testing.T) {
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		store := NewStore(Rules{})
+		store := NewStore(Rules{}, nil)
 		err := store.Remove(0)
 		require.Error(t, err)
 		assert.Equal(t, "Rule not found", err.Error())
@@ -131,7 +131,7 @@ func TestRemove(t *testing.T) {
 func TestAdd(t *testing.T) {
 	store := NewStore(Rules{
 		csvRule{Conditions: []string{"some condition"}, Account2: "some account"},
-	})
+	}, nil)
 	someRule := csvRule{
 		Conditions: []string{"some other condition"},
 		Account2:   "some other account",
@@ -147,14 +147,14 @@ func TestAdd(t *testing.T) {
 func TestGet(t *testing.T) {
 	t.Run("happy path", func(t *testing.T) {
 		someRule := csvRule{Conditions: []string{"some other condition"}, Account2: "some other account"}
-		store := NewStore(Rules{someRule})
+		store := NewStore(Rules{someRule}, nil)
 		rule, err := store.Get(0)
 		require.NoError(t, err)
 		assert.Equal(t, someRule, rule)
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		store := NewStore(nil)
+		store := NewStore(nil, nil)
 		_, err := store.Get(0)
 		require.Error(t, err)
 		assert.Equal(t, "Rule not found", err.Error())
@@ -164,7 +164,7 @@ func TestGet(t *testing.T) {
 func TestStoreMatches(t *testing.T) {
 	rule, err := NewCSVRule("", "burgers", "", "some condition")
 	require.NoError(t, err)
-	store := NewStore(Rules{rule})
+	store := NewStore(Rules{rule}, nil)
 	results := store.Matches(&ledger.Transaction{
 		Payee:    "some condition and extra text",
 		Postings: []ledger.Posting{{}, {}},
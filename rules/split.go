@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"strings"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// splitRemainder is used in place of an amount for the split remainder's account, like 'split revenues:Salary remainder'
+	splitRemainder = "remainder"
+	// minSplitDecimals is the minimum number of decimal places for amounts from split percentages
+	minSplitDecimals = 2
+)
+
+var oneHundred = decimal.New(100, 0)
+
+// SplitPosting is one part of a split transaction
+type SplitPosting struct {
+	Account string
+	// Amount is the posting's amount. If Percent is set, Amount is a percentage of the split posting's amount instead.
+	Amount  decimal.Decimal
+	Percent bool `json:",omitempty"`
+}
+
+func (s SplitPosting) String() string {
+	if s.Percent {
+		return s.Account + " " + s.Amount.String() + "%"
+	}
+	return s.Account + " " + s.Amount.String()
+}
+
+// parseSplit parses a split rule value, like 'expenses:Taxes 300' or 'assets:401k -5%'. If the amount is 'remainder', returns isRemainder.
+func parseSplit(value string) (split SplitPosting, isRemainder bool, err error) {
+	separator := strings.LastIndexByte(value, ' ')
+	if separator == -1 {
+		return SplitPosting{}, false, errors.Errorf("Rule split must have both account and amount: '%s'", value)
+	}
+	split.Account = strings.TrimSpace(value[:separator])
+	amount := strings.TrimSpace(value[separator+1:])
+	if amount == splitRemainder {
+		return split, true, nil
+	}
+	split.Percent = strings.HasSuffix(amount, "%")
+	split.Amount, err = decimal.NewFromString(strings.TrimSuffix(amount, "%"))
+	if err != nil {
+		return SplitPosting{}, false, errors.Errorf("Invalid rule split amount: '%s'", value)
+	}
+	return split, false, nil
+}
+
+func validateSplits(splits []SplitPosting, remainder string) error {
+	if len(splits) == 0 && remainder == "" {
+		return nil
+	}
+	if len(splits) == 0 {
+		return errors.New("Invalid rule: Splits must have at least one account and amount besides the remainder")
+	}
+	if remainder == "" {
+		return errors.New("Invalid rule: Splits must have a remainder account")
+	}
+	for _, split := range splits {
+		if split.Account == "" {
+			return errors.New("Invalid rule: Split accounts must not be empty")
+		}
+		if split.Amount.IsZero() {
+			return errors.Errorf("Invalid rule: Split amounts must not be zero: %q", split.Account)
+		}
+	}
+	return nil
+}
+
+// applySplits replaces the second posting with a posting for each split and the remainder.
+// Only splits transactions with exactly 2 postings, so already split transactions are skipped.
+// The remainder posting receives the balance of the original posting, so the transaction stays balanced.
+func applySplits(txn *ledger.Transaction, splits []SplitPosting, remainder string) {
+	if len(txn.Postings) != 2 || txn.Postings[1].Cost != nil || txn.Postings[1].Amount.IsZero() {
+		return
+	}
+	original := txn.Postings[1]
+	decimals := -original.Amount.Exponent()
+	if decimals < minSplitDecimals {
+		decimals = minSplitDecimals
+	}
+
+	postings := []ledger.Posting{txn.Postings[0]}
+	remaining := original.Amount
+	for _, split := range splits {
+		amount := split.Amount
+		if split.Percent {
+			amount = original.Amount.Mul(split.Amount).Div(oneHundred).Round(decimals)
+		}
+		if amount.IsZero() {
+			continue
+		}
+		remaining = remaining.Sub(amount)
+		postings = append(postings, ledger.Posting{
+			Account:  split.Account,
+			Amount:   amount,
+			Currency: original.Currency,
+			Kind:     original.Kind,
+		})
+	}
+	if !remaining.IsZero() {
+		postings = append(postings, ledger.Posting{
+			Account:  remainder,
+			Amount:   remaining,
+			Comment:  original.Comment,
+			Currency: original.Currency,
+			Kind:     original.Kind,
+			Tags:     original.Tags,
+		})
+	}
+	txn.Postings = postings
+}
@@ -0,0 +1,225 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/johnstarich/sage/ledger"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postingStrings(postings []ledger.Posting) []string {
+	var results []string
+	for _, p := range postings {
+		results = append(results, p.Account+" "+p.Amount.String())
+	}
+	return results
+}
+
+func TestSplitRules(t *testing.T) {
+	rules, _, err := NewCSVRulesFromReader(strings.NewReader(`
+if
+acme payroll
+  split expenses:Taxes:Federal 300
+  split assets:401k 150
+  split expenses:Insurance 45.50
+  split revenues:Salary remainder
+
+if
+mortgage
+  split expenses:Home:Interest 40%
+  split expenses:Home:Escrow 25%
+  split liabilities:Mortgage remainder
+`))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		description string
+		txn         ledger.Transaction
+		expected    []string
+	}{
+		{
+			description: "paycheck",
+			txn: ledger.Transaction{
+				Payee: "ACME Payroll",
+				Postings: []ledger.Posting{
+					{Account: "assets:Bank:****1234", Amount: decimal.NewFromFloat(1000), Currency: usd, Tags: map[string]string{"id": "paycheck"}},
+					{Account: "uncategorized", Amount: decimal.NewFromFloat(-1000), Currency: usd},
+				},
+			},
+			expected: []string{
+				"assets:Bank:****1234 1000",
+				"expenses:Taxes:Federal 300",
+				"assets:401k 150",
+				"expenses:Insurance 45.5",
+				"revenues:Salary -1495.5",
+			},
+		},
+		{
+			description: "mortgage percentages round to cents",
+			txn: ledger.Transaction{
+				Payee: "Mortgage payment",
+				Postings: []ledger.Posting{
+					{Account: "assets:Bank:****1234", Amount: decimal.NewFromFloat(-1234.57), Currency: usd},
+					{Account: "uncategorized", Amount: decimal.NewFromFloat(1234.57), Currency: usd},
+				},
+			},
+			expected: []string{
+				"assets:Bank:****1234 -1234.57",
+				"expenses:Home:Interest 493.83",
+				"expenses:Home:Escrow 308.64",
+				"liabilities:Mortgage 432.1",
+			},
+		},
+		{
+			description: "already split",
+			txn: ledger.Transaction{
+				Payee: "Mortgage payment",
+				Postings: []ledger.Posting{
+					{Account: "assets:Bank:****1234", Amount: decimal.NewFromFloat(-100), Currency: usd},
+					{Account: "expenses:Home:Interest", Amount: decimal.NewFromFloat(40), Currency: usd},
+					{Account: "liabilities:Mortgage", Amount: decimal.NewFromFloat(60), Currency: usd},
+				},
+			},
+			expected: []string{
+				"assets:Bank:****1234 -100",
+				"expenses:Home:Interest 40",
+				"liabilities:Mortgage 60",
+			},
+		},
+		{
+			description: "splits cover the whole amount",
+			txn: ledger.Transaction{
+				Payee: "ACME Payroll",
+				Postings: []ledger.Posting{
+					{Account: "assets:Bank:****1234", Amount: decimal.NewFromFloat(-495.5), Currency: usd},
+					{Account: "uncategorized", Amount: decimal.NewFromFloat(495.5), Currency: usd},
+				},
+			},
+			expected: []string{
+				"assets:Bank:****1234 -495.5",
+				"expenses:Taxes:Federal 300",
+				"assets:401k 150",
+				"expenses:Insurance 45.5",
+			},
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			txn := tc.txn
+			rules.Apply(&txn)
+			assert.Equal(t, tc.expected, postingStrings(txn.Postings))
+			assert.NoError(t, txn.Validate())
+			for _, p := range txn.Postings {
+				assert.Equal(t, usd, p.Currency)
+			}
+			assert.Equal(t, tc.txn.Postings[0], txn.Postings[0])
+		})
+	}
+}
+
+func TestSplitRulesString(t *testing.T) {
+	const rulesText = `if
+acme payroll
+  split expenses:Taxes:Federal Income Tax 300
+  split assets:401k -5%
+  split revenues:Salary remainder
+
+`
+	rules, _, err := NewCSVRulesFromReader(strings.NewReader(rulesText))
+	require.NoError(t, err)
+	assert.Equal(t, rulesText, NewStore(rules, nil).String())
+	rule := rules[0].(csvRule)
+	assert.Equal(t, []SplitPosting{
+		{Account: "expenses:Taxes:Federal Income Tax", Amount: decimal.New(300, 0)},
+		{Account: "assets:401k", Amount: decimal.New(-5, 0), Percent: true},
+	}, rule.Splits)
+	assert.Equal(t, "revenues:Salary", rule.SplitRemainder)
+}
+
+func TestSplitRuleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		description string
+		input       string
+		actions     Actions
+		err         string
+	}{
+		{
+			description: "missing amount",
+			input:       "split expenses:Taxes",
+			err:         "Rule split must have both account and amount: 'expenses:Taxes'",
+		},
+		{
+			description: "invalid amount",
+			input:       "split expenses:Taxes ten%",
+			err:         "Invalid rule split amount: 'expenses:Taxes ten%'",
+		},
+		{
+			description: "missing remainder",
+			actions:     Actions{Splits: []SplitPosting{{Account: "expenses:Taxes", Amount: decimal.New(1, 0)}}},
+			err:         "Invalid rule: Splits must have a remainder account",
+		},
+		{
+			description: "only remainder",
+			actions:     Actions{SplitRemainder: "revenues:Salary"},
+			err:         "Invalid rule: Splits must have at least one account and amount besides the remainder",
+		},
+		{
+			description: "zero amount",
+			actions:     Actions{Splits: []SplitPosting{{Account: "expenses:Taxes"}}, SplitRemainder: "revenues:Salary"},
+			err:         `Invalid rule: Split amounts must not be zero: "expenses:Taxes"`,
+		},
+		{
+			description: "empty account",
+			actions:     Actions{Splits: []SplitPosting{{Amount: decimal.New(1, 0)}}, SplitRemainder: "revenues:Salary"},
+			err:         "Invalid rule: Split accounts must not be empty",
+		},
+		{
+			description: "split and account2",
+			actions:     Actions{Account2: "expenses:Food", Splits: []SplitPosting{{Account: "expenses:Taxes", Amount: decimal.New(1, 0)}}, SplitRemainder: "revenues:Salary"},
+			err:         "Invalid rule: Splits can't also set account2 or transfer",
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			var err error
+			if tc.input != "" {
+				_, _, err = NewCSVRulesFromReader(strings.NewReader(tc.input))
+			} else {
+				_, err = NewRule(tc.actions)
+			}
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestSplitThenCategoryRule(t *testing.T) {
+	rules, _, err := NewCSVRulesFromReader(strings.NewReader(`
+if
+acme payroll
+  split expenses:Taxes 300
+  split revenues:Salary remainder
+
+if
+payroll
+  account2 revenues:Other
+
+if
+acme
+  transfer assets:Savings
+`))
+	require.NoError(t, err)
+	txn := ledger.Transaction{
+		Payee: "ACME Payroll",
+		Postings: []ledger.Posting{
+			{Account: "assets:Bank:****1234", Amount: decimal.NewFromFloat(1000), Currency: usd},
+			{Account: "uncategorized", Amount: decimal.NewFromFloat(-1000), Currency: usd},
+		},
+	}
+	rules.Apply(&txn)
+	assert.Equal(t, []string{
+		"assets:Bank:****1234 1000",
+		"expenses:Taxes 300",
+		"revenues:Salary -1300",
+	}, postingStrings(txn.Postings), "Later account2 and transfer rules must not change split postings")
+}
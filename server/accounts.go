@@ -5,6 +5,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/johnstarich/sage/client"
@@ -13,6 +14,7 @@ import (
 	"github.com/johnstarich/sage/client/web"
 	"github.com/johnstarich/sage/ledger"
 	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
@@ -29,6 +31,33 @@ func abortWithClientError(c *gin.Context, status int, err error) {
 	})
 }
 
+// balanceWarnings returns failed balance assertions in 'err' as warnings. The ledger change was still applied, so they are not an error.
+// Returns 'err' if it failed for any other reason.
+func balanceWarnings(c *gin.Context, err error) ([]string, error) {
+	if !ledger.IsAssertionError(err) {
+		return nil, err
+	}
+	ledgerErr := err.(ledger.Error)
+	warnings := make([]string, 0, len(ledgerErr.Assertions))
+	for _, assertion := range ledgerErr.Assertions {
+		warnings = append(warnings, assertion.Error())
+	}
+	logger := c.MustGet(loggerKey).(*zap.Logger)
+	logger.Warn("Ledger changed, but balance assertions failed", zap.Strings("warnings", warnings))
+	return warnings, nil
+}
+
+// statusWithWarnings responds with no content, unless there are warnings to return
+func statusWithWarnings(c *gin.Context, warnings []string) {
+	if len(warnings) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"Warnings": warnings,
+	})
+}
+
 func readAndValidateAccount(r io.Reader, accountStore *client.AccountStore) (originalAccountID string, account model.Account, err error) {
 	b, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -196,6 +225,61 @@ func removeAccount(accountStore *client.AccountStore) gin.HandlerFunc {
 	}
 }
 
+func addValuation(accountStore *client.AccountStore, ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var valuation struct {
+			AccountID   string `binding:"required"`
+			Date        time.Time
+			Value       decimal.Decimal
+			Currency    string
+			Counterpart string
+		}
+		if err := c.BindJSON(&valuation); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		var account model.Account
+		exists, err := accountStore.Get(valuation.AccountID, &account)
+		if err != nil {
+			abortWithClientError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !exists {
+			abortWithClientError(c, http.StatusNotFound, errors.Errorf("Account not found with ID: %q", valuation.AccountID))
+			return
+		}
+		if _, isManual := account.(*model.ManualAccount); !isManual {
+			abortWithClientError(c, http.StatusBadRequest, errors.Errorf("Valuations can only be added to manual accounts: %q", valuation.AccountID))
+			return
+		}
+		if valuation.Currency == "" {
+			valuation.Currency = "$"
+		}
+
+		txn, err := client.ValuationTransaction(ldgStore.Ledger, account, valuation.Date, valuation.Value, valuation.Currency, valuation.Counterpart)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		id, err := ldgStore.CreateTransaction(txn)
+		warnings, err := balanceWarnings(c, err)
+		switch err.(type) {
+		case ledger.Error:
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		case nil: // skip
+		default:
+			abortWithClientError(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"ID":       id,
+			"Warnings": warnings,
+		})
+	}
+}
+
 func verifyAccount(accountStore *client.AccountStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		_, account, err := readAndValidateAccount(c.Request.Body, accountStore)
@@ -297,6 +381,7 @@ func getDirectConnectDrivers() gin.HandlerFunc {
 			URL         string
 			Bank        bool
 			CreditCard  bool
+			Investment  bool
 		}
 		results := make([]driverResult, 0, len(drivers))
 		for _, driver := range drivers {
@@ -313,6 +398,8 @@ func getDirectConnectDrivers() gin.HandlerFunc {
 					d.CreditCard = true
 				case direct.MessageBank:
 					d.Bank = true
+				case direct.MessageInvestment:
+					d.Investment = true
 				}
 			}
 			results = append(results, d)
@@ -65,13 +65,41 @@ func addMonths(t time.Time, months int) time.Time {
 	return time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
 }
 
-func getEverythingElseSum(accounts budget.Accounts, ldgStore *ledger.Store, start, end time.Time) decimal.Decimal {
-	leftOverAccounts := ldgStore.LeftOverAccountBalances(start, end, everythingElseAccounts(accounts)...)
+// accountBalance returns the balance of 'account' between start and end. If valueIn is set, all commodities are converted into it at the end time.
+func accountBalance(ldgStore *ledger.Store, account string, start, end time.Time, valueIn string) (decimal.Decimal, error) {
+	if valueIn == "" {
+		return ldgStore.AccountBalance(account, start, end, ledger.BalanceOptions{}), nil
+	}
+	return ldgStore.Value(ldgStore.AccountBalanceByCommodity(account, start, end, ledger.BalanceOptions{}), valueIn, end)
+}
+
+// leftOverAccountBalances returns balances for accounts not found in 'accounts'. If valueIn is set, all commodities are converted into it at the end time.
+func leftOverAccountBalances(ldgStore *ledger.Store, start, end time.Time, valueIn string, accounts ...string) (map[string]decimal.Decimal, error) {
+	if valueIn == "" {
+		return ldgStore.LeftOverAccountBalances(start, end, accounts...), nil
+	}
+	commodityBalances := ldgStore.LeftOverAccountBalancesByCommodity(start, end, accounts...)
+	leftOver := make(map[string]decimal.Decimal, len(commodityBalances))
+	for account, amounts := range commodityBalances {
+		value, err := ldgStore.Value(amounts, valueIn, end)
+		if err != nil {
+			return nil, err
+		}
+		leftOver[account] = value
+	}
+	return leftOver, nil
+}
+
+func getEverythingElseSum(accounts budget.Accounts, ldgStore *ledger.Store, start, end time.Time, valueIn string) (decimal.Decimal, error) {
+	leftOverAccounts, err := leftOverAccountBalances(ldgStore, start, end, valueIn, everythingElseAccounts(accounts)...)
+	if err != nil {
+		return decimal.Zero, err
+	}
 	var balance decimal.Decimal
 	for _, amount := range leftOverAccounts {
 		balance = balance.Add(amount.Abs()) // flip sign of revenues so nothing cancels out
 	}
-	return balance
+	return balance, nil
 }
 
 func getBudgets(db plaindb.DB, ldgStore *ledger.Store) gin.HandlerFunc {
@@ -99,7 +127,7 @@ func getBudgets(db plaindb.DB, ldgStore *ledger.Store) gin.HandlerFunc {
 			}
 			allMonthlyBudgets = append(allMonthlyBudgets, month)
 		}
-		budgetResults, err := calculateBudgetBalances(allMonthlyBudgets, ldgStore, start, end)
+		budgetResults, err := calculateBudgetBalances(allMonthlyBudgets, ldgStore, start, end, c.Query("valueIn"))
 		if err != nil {
 			abortWithClientError(c, http.StatusInternalServerError, err)
 			return
@@ -116,7 +144,8 @@ func getBudgets(db plaindb.DB, ldgStore *ledger.Store) gin.HandlerFunc {
 	}
 }
 
-func calculateBudgetBalances(allMonthlyBudgets []budget.Accounts, ldgStore *ledger.Store, start, end time.Time) ([][]monthlyBudget, error) {
+// calculateBudgetBalances returns each month's budgets and balances. If valueIn is set, all commodities are converted into it at the end of each month.
+func calculateBudgetBalances(allMonthlyBudgets []budget.Accounts, ldgStore *ledger.Store, start, end time.Time, valueIn string) ([][]monthlyBudget, error) {
 	budgetResults := make([][]monthlyBudget, 0, 12)
 	for monthOffset, accounts := range allMonthlyBudgets {
 		monthStart := addMonths(start, monthOffset)
@@ -125,16 +154,20 @@ func calculateBudgetBalances(allMonthlyBudgets []budget.Accounts, ldgStore *ledg
 		monthResults := make([]monthlyBudget, 0, len(accounts)+1)
 		for account, budgetAmt := range accounts {
 			var balance decimal.Decimal
+			var err error
 			if isBuiltinBudget(account) {
 				switch strings.ToLower(account) {
 				case everythingElseBudget:
 					foundEverythingElse = true
-					balance = getEverythingElseSum(accounts, ldgStore, monthStart, monthEnd)
+					balance, err = getEverythingElseSum(accounts, ldgStore, monthStart, monthEnd, valueIn)
 				default:
 					return nil, errors.Errorf("Invalid builtin account: %s", account)
 				}
 			} else {
-				balance = ldgStore.AccountBalance(account, monthStart, monthEnd)
+				balance, err = accountBalance(ldgStore, account, monthStart, monthEnd, valueIn)
+			}
+			if err != nil {
+				return nil, err
 			}
 			if strings.HasPrefix(account, model.RevenueAccount+":") || account == model.RevenueAccount {
 				balance = balance.Neg()
@@ -147,9 +180,13 @@ func calculateBudgetBalances(allMonthlyBudgets []budget.Accounts, ldgStore *ledg
 		}
 
 		if !foundEverythingElse {
+			balance, err := getEverythingElseSum(accounts, ldgStore, monthStart, monthEnd, valueIn)
+			if err != nil {
+				return nil, err
+			}
 			monthResults = append(monthResults, monthlyBudget{
 				Account: everythingElseBudget,
-				Balance: getEverythingElseSum(accounts, ldgStore, monthStart, monthEnd),
+				Balance: balance,
 			})
 		}
 		budgetResults = append(budgetResults, monthResults)
@@ -201,7 +238,11 @@ func getBudget(db plaindb.DB, ldgStore *ledger.Store) gin.HandlerFunc {
 			return
 		}
 
-		balance := ldgStore.AccountBalance(account, start, end)
+		balance, err := accountBalance(ldgStore, account, start, end, c.Query("valueIn"))
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
 		if strings.HasPrefix(account, model.RevenueAccount+":") {
 			balance = balance.Neg()
 		}
@@ -318,7 +359,11 @@ func getEverythingElseBudgetDetails(db plaindb.DB, ldgStore *ledger.Store) gin.H
 			return
 		}
 
-		leftOverAccounts := ldgStore.LeftOverAccountBalances(start, end, everythingElseAccounts(accounts)...)
+		leftOverAccounts, err := leftOverAccountBalances(ldgStore, start, end, c.Query("valueIn"), everythingElseAccounts(accounts)...)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
 		var sum decimal.Decimal
 		for account, balance := range leftOverAccounts {
 			if strings.HasPrefix(account, model.RevenueAccount+":") {
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnstarich/sage/duplicate"
+	"github.com/johnstarich/sage/ledger"
+)
+
+func getDuplicates(store *duplicate.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		duplicates, err := store.List()
+		if err != nil {
+			abortWithClientError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"Duplicates": duplicates,
+		})
+	}
+}
+
+func resolveDuplicate(store *duplicate.Store, ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			ID     string           `binding:"required"`
+			Action duplicate.Action `binding:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		warnings, err := balanceWarnings(c, store.Resolve(body.ID, body.Action, ldgStore))
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		statusWithWarnings(c, warnings)
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnstarich/sage/holdings"
+	"github.com/johnstarich/sage/ledger"
+)
+
+// getHoldings serves the open lots and values of each account's commodities, with optional query parameters 'account' and 'date'
+func getHoldings(ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		options := holdings.Options{Account: c.Query("account")}
+		if date := c.Query("date"); date != "" {
+			var err error
+			options.Date, err = time.Parse(time.RFC3339, date)
+			if err != nil {
+				abortWithClientError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"Holdings": holdings.Holdings(ldgStore.Ledger, options),
+		})
+	}
+}
+
+// getGains serves realized and unrealized gains with options from the query parameters 'account', 'start', and 'end'. Defaults to the current year.
+func getGains(ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start, end, err := getStartEndTimes(c.Query("start"), c.Query("end"), startOfYear)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		gains, err := holdings.GainsReport(ldgStore.Ledger, holdings.GainsOptions{
+			Account: c.Query("account"),
+			Start:   start,
+			End:     end,
+		})
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, gains)
+	}
+}
+
+func startOfYear(end time.Time) time.Time {
+	return time.Date(end.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnstarich/sage/inbox"
+	"github.com/johnstarich/sage/ledger"
+)
+
+func getInbox(store *inbox.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		txns, err := store.List()
+		if err != nil {
+			abortWithClientError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"Enabled":      store.Enabled(),
+			"Transactions": txns,
+		})
+	}
+}
+
+func updateInbox(store *inbox.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			Transactions []ledger.Transaction `binding:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := store.Update(body.Transactions); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func approveInbox(store *inbox.Store, ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			IDs []string `binding:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		warnings, err := balanceWarnings(c, store.Approve(body.IDs, ldgStore))
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		statusWithWarnings(c, warnings)
+	}
+}
+
+func rejectInbox(store *inbox.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			IDs []string `binding:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := store.Reject(body.IDs); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
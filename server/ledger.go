@@ -2,8 +2,10 @@ package server
 
 import (
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -12,7 +14,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/johnstarich/sage/client"
 	"github.com/johnstarich/sage/client/model"
+	"github.com/johnstarich/sage/duplicate"
 	sErrors "github.com/johnstarich/sage/errors"
+	"github.com/johnstarich/sage/inbox"
 	"github.com/johnstarich/sage/ledger"
 	"github.com/johnstarich/sage/prompter"
 	"github.com/johnstarich/sage/rules"
@@ -53,10 +57,11 @@ func submitSyncPrompt(ldgStore *ledger.Store) gin.HandlerFunc {
 	}
 }
 
-func syncLedger(ldgStore *ledger.Store, accountStore *client.AccountStore, rulesStore *rules.Store) gin.HandlerFunc {
+func syncLedger(ldgStore *ledger.Store, accountStore *client.AccountStore, rulesStore *rules.Store, duplicateStore *duplicate.Store, inboxStore *inbox.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		_, syncFromStart := c.GetQuery("fromLedgerStart")
-		sync.Sync(ldgStore, accountStore, rulesStore, syncFromStart)
+		logger := c.MustGet(loggerKey).(*zap.Logger)
+		sync.Sync(logger, ldgStore, accountStore, rulesStore, duplicateStore, inboxStore, syncFromStart)
 		c.Status(http.StatusAccepted)
 	}
 }
@@ -103,8 +108,13 @@ func getTransactions(ldgStore *ledger.Store, accountStore *client.AccountStore)
 			return
 		}
 
+		queryResult, err := ldgStore.Query(options, page, results)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
 		result := transactionsResponse{
-			QueryResult:  ldgStore.Query(options, page, results),
+			QueryResult:  queryResult,
 			AccountIDMap: make(map[string]string),
 		}
 		// attempt to make asset and liability accounts more descriptive
@@ -129,6 +139,7 @@ func getTransactions(ldgStore *ledger.Store, accountStore *client.AccountStore)
 // BalanceResponse is the response type for fetching account balances
 type BalanceResponse struct {
 	Start, End         *time.Time
+	Interval           ledger.Interval
 	OpeningBalanceDate *time.Time
 	Messages           []AccountMessage
 	Accounts           []AccountResponse
@@ -141,7 +152,9 @@ type AccountResponse struct {
 	AccountType    string
 	OpeningBalance *decimal.Decimal
 	Balances       []decimal.Decimal
-	Institution    string `json:",omitempty"`
+	// Commodities contains balances over time for each commodity in this account
+	Commodities map[string][]decimal.Decimal `json:",omitempty"`
+	Institution string                       `json:",omitempty"`
 }
 
 // AccountMessage contains important information for an account
@@ -153,12 +166,23 @@ type AccountMessage struct {
 
 type txnToAccountMap map[string]map[string]model.Account
 
+// manualAccountsKey holds manual accounts in a txnToAccountMap, keyed by their full ledger account names. Institution names are never empty.
+const manualAccountsKey = ""
+
 // newAccountIDMap returns a mapping from an institution's description, then account ID suffix (without '*'s), and finally to the source account
 func newAccountIDMap(accountStore *client.AccountStore) (txnToAccountMap, error) {
 	// inst name -> account ID suffix -> account
 	accountIDMap := make(txnToAccountMap)
 	var clientAccount model.Account
 	err := accountStore.Iter(&clientAccount, func(id string) bool {
+		if _, isManual := clientAccount.(*model.ManualAccount); isManual {
+			// manual account names aren't redacted, so map the full name instead
+			if accountIDMap[manualAccountsKey] == nil {
+				accountIDMap[manualAccountsKey] = make(map[string]model.Account)
+			}
+			accountIDMap[manualAccountsKey][model.LedgerAccountName(clientAccount)] = clientAccount
+			return true
+		}
 		instName := clientAccount.Institution().Org()
 		if len(id) > model.RedactSuffixLength {
 			id = id[len(id)-model.RedactSuffixLength:]
@@ -173,6 +197,9 @@ func newAccountIDMap(accountStore *client.AccountStore) (txnToAccountMap, error)
 }
 
 func (t txnToAccountMap) Find(accountName string) (account model.Account, found bool) {
+	if clientAccount, found := t[manualAccountsKey][accountName]; found {
+		return clientAccount, true
+	}
 	components := strings.Split(accountName, ":")
 	if len(components) == 0 {
 		return nil, false
@@ -197,7 +224,20 @@ func (t txnToAccountMap) Find(accountName string) (account model.Account, found
 
 func getBalances(ldgStore *ledger.Store, accountStore *client.AccountStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		resp, err := getBalancesResponse(ldgStore, accountStore, c.QueryArray(accountTypesQuery))
+		options, err := parseBalanceOptions(c)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		var valuedBalances map[string][]decimal.Decimal
+		if valueIn := c.Query("valueIn"); valueIn != "" {
+			_, _, valuedBalances, err = ldgStore.BalancesValuedIn(valueIn, options)
+			if err != nil {
+				abortWithClientError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+		resp, err := getBalancesResponse(ldgStore, accountStore, c.QueryArray(accountTypesQuery), options, valuedBalances)
 		if err != nil {
 			abortWithClientError(c, http.StatusInternalServerError, err)
 			return
@@ -206,11 +246,42 @@ func getBalances(ldgStore *ledger.Store, accountStore *client.AccountStore) gin.
 	}
 }
 
-func getBalancesResponse(ldgStore *ledger.Store, accountStore *client.AccountStore, accountTypesQueryArray []string) (interface{}, error) {
-	start, end, balanceMap := ldgStore.Balances()
+// parseBalanceOptions reads balance options from the query parameters 'virtual', 'interval', 'change', 'start', and 'end'
+func parseBalanceOptions(c *gin.Context) (ledger.BalanceOptions, error) {
+	options := ledger.BalanceOptions{
+		Virtual: c.Query("virtual") == "true",
+		Change:  c.Query("change") == "true",
+	}
+	var err error
+	options.Interval, err = ledger.ParseInterval(c.Query("interval"))
+	if err != nil {
+		return options, err
+	}
+	if start := c.Query("start"); start != "" {
+		options.Start, err = time.Parse(time.RFC3339, start)
+		if err != nil {
+			return options, err
+		}
+	}
+	if end := c.Query("end"); end != "" {
+		options.End, err = time.Parse(time.RFC3339, end)
+		if err != nil {
+			return options, err
+		}
+	}
+	if !options.Start.IsZero() && !options.End.IsZero() && options.End.Before(options.Start) {
+		return options, errors.New("End must not be before start")
+	}
+	return options, nil
+}
+
+// getBalancesResponse returns balances for each account. If valuedBalances is set, they're used in place of the summed commodity balances.
+func getBalancesResponse(ldgStore *ledger.Store, accountStore *client.AccountStore, accountTypesQueryArray []string, options ledger.BalanceOptions, valuedBalances map[string][]decimal.Decimal) (interface{}, error) {
+	start, end, balanceMap := ldgStore.BalancesByCommodity(options)
 	resp := BalanceResponse{
-		Start: start,
-		End:   end,
+		Start:    start,
+		End:      end,
+		Interval: options.Interval,
 	}
 	accountIDMap, err := newAccountIDMap(accountStore)
 	if err != nil {
@@ -244,11 +315,15 @@ func getBalancesResponse(ldgStore *ledger.Store, accountStore *client.AccountSto
 		return nil
 	}
 
-	for accountName, balances := range balanceMap {
+	for accountName, commodities := range balanceMap {
 		account := AccountResponse{
 			ID:             accountName,
 			OpeningBalance: findOpeningBalance(accountName),
-			Balances:       balances,
+			Balances:       ledger.SumCommodities(commodities),
+			Commodities:    commodities,
+		}
+		if valuedBalances != nil {
+			account.Balances = valuedBalances[accountName]
 		}
 		if extractAccount(&account, accountName, accountTypes, accountIDMap.Find) {
 			resp.Accounts = append(resp.Accounts, account)
@@ -311,6 +386,9 @@ func extractAccount(account *AccountResponse, accountName string, filterAccountT
 	default:
 		account.ID = format.Remaining
 		account.Account = account.ID
+		if clientAccount, found := getAccount(accountName); found {
+			account.Account = clientAccount.Description()
+		}
 	}
 	return true
 }
@@ -328,6 +406,10 @@ func getOpeningBalanceMessages(ldgStore *ledger.Store, accounts []model.Account)
 		}
 	}
 	for _, account := range accounts {
+		if account.Type() != model.AssetAccount && account.Type() != model.LiabilityAccount {
+			// only manual accounts can have other types, which don't need opening balances
+			continue
+		}
 		id := model.LedgerAccountName(account)
 		if !openingBalAccounts[id] {
 			messages = append(messages, AccountMessage{
@@ -342,7 +424,7 @@ func getOpeningBalanceMessages(ldgStore *ledger.Store, accounts []model.Account)
 
 func getExpenseAndRevenueAccounts(ldgStore *ledger.Store, rulesStore *rules.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_, _, balanceMap := ldgStore.Balances()
+		_, _, balanceMap := ldgStore.Balances(ledger.BalanceOptions{})
 		accounts := make(map[string]bool, len(balanceMap)+1)
 		accounts[model.Uncategorized] = true
 		for account := range balanceMap {
@@ -397,7 +479,8 @@ func updateTransaction(ldgStore *ledger.Store) gin.HandlerFunc {
 			abortWithClientError(c, http.StatusBadRequest, err)
 			return
 		}
-		switch err := ldgStore.UpdateTransaction(id, txn).(type) {
+		warnings, err := balanceWarnings(c, ldgStore.UpdateTransaction(id, txn))
+		switch err.(type) {
 		case ledger.Error:
 			abortWithClientError(c, http.StatusBadRequest, err)
 			return
@@ -407,7 +490,57 @@ func updateTransaction(ldgStore *ledger.Store) gin.HandlerFunc {
 			return
 		}
 
-		c.Status(http.StatusNoContent)
+		statusWithWarnings(c, warnings)
+	}
+}
+
+func addTransaction(ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var txn ledger.Transaction
+		if err := c.BindJSON(&txn); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		id, err := ldgStore.CreateTransaction(txn)
+		warnings, err := balanceWarnings(c, err)
+		switch err.(type) {
+		case ledger.Error:
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		case nil: // skip
+		default:
+			abortWithClientError(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"ID":       id,
+			"Warnings": warnings,
+		})
+	}
+}
+
+func deleteTransaction(ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var txnJSON struct {
+			ID string `binding:"required"`
+		}
+		if err := c.BindJSON(&txnJSON); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		warnings, err := balanceWarnings(c, ldgStore.RemoveTransaction(txnJSON.ID))
+		switch err.(type) {
+		case ledger.Error:
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		case nil: // skip
+		default:
+			abortWithClientError(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		statusWithWarnings(c, warnings)
 	}
 }
 
@@ -448,25 +581,42 @@ func updateOpeningBalance(ldgStore *ledger.Store, accountStore *client.AccountSt
 			return
 		}
 
-		var total decimal.Decimal
+		totals := make(map[string]decimal.Decimal)
+		var commodities []string
 		for i := range opening.Postings {
 			format, err := model.ParseLedgerFormat(opening.Postings[i].Account)
 			if err != nil || format.AccountType == "" {
 				abortWithClientError(c, http.StatusBadRequest, errors.Wrap(err, "Invalid ledger account ID"))
 				return
 			}
-			total = total.Sub(opening.Postings[i].Amount)
-			opening.Postings[i].Currency = "$"
+			if opening.Postings[i].Currency == "" {
+				opening.Postings[i].Currency = "$"
+			}
+			currency := opening.Postings[i].Currency
+			if _, exists := totals[currency]; !exists {
+				commodities = append(commodities, currency)
+			}
+			totals[currency] = totals[currency].Sub(opening.Postings[i].Amount)
+		}
+		if len(commodities) == 0 {
+			commodities = append(commodities, "$")
 		}
 
-		opening.Postings = append(opening.Postings, ledger.Posting{
-			Account:  "equity:Opening Balances",
-			Amount:   total,
-			Currency: "$",
-			Tags:     map[string]string{"id": ledger.OpeningBalanceID},
-		})
+		for i, currency := range commodities {
+			equity := ledger.Posting{
+				Account:  "equity:Opening Balances",
+				Amount:   totals[currency],
+				Currency: currency,
+			}
+			if i == 0 {
+				// only one posting may hold the opening balance ID
+				equity.Tags = map[string]string{"id": ledger.OpeningBalanceID}
+			}
+			opening.Postings = append(opening.Postings, equity)
+		}
 
-		switch err := ldgStore.UpdateOpeningBalance(opening).(type) {
+		warnings, err := balanceWarnings(c, ldgStore.UpdateOpeningBalance(opening))
+		switch err.(type) {
 		case ledger.Error:
 			abortWithClientError(c, http.StatusBadRequest, err)
 			return
@@ -476,38 +626,139 @@ func updateOpeningBalance(ldgStore *ledger.Store, accountStore *client.AccountSt
 			return
 		}
 
-		c.Status(http.StatusNoContent)
+		statusWithWarnings(c, warnings)
+	}
+}
+
+// importReader reads a statement file for importFile. 'query' has the request's query params, for formats which need more details.
+type importReader func(r io.Reader, query url.Values) ([]model.Account, []ledger.Transaction, error)
+
+// ignoreQuery returns an importReader for formats which only need the file, like client.ReadOFX
+func ignoreQuery(readFile func(io.Reader) ([]model.Account, []ledger.Transaction, error)) importReader {
+	return func(r io.Reader, _ url.Values) ([]model.Account, []ledger.Transaction, error) {
+		return readFile(r)
 	}
 }
 
-func importOFXFile(ldgStore *ledger.Store, accountStore *client.AccountStore, rulesStore *rules.Store) gin.HandlerFunc {
+// readQIF reads a QIF file. QIF files don't identify their institution, so it's set with the 'institution' query param.
+// Files without account headers must set the 'account' query param to the account's ID.
+func readQIF(r io.Reader, query url.Values) ([]model.Account, []ledger.Transaction, error) {
+	return client.ReadQIF(r, query.Get("institution"), query.Get("account"))
+}
+
+// importFile imports a statement file read by 'readFile', like readQIF, and adds any new accounts
+func importFile(
+	readFile importReader,
+	ldgStore *ledger.Store,
+	accountStore *client.AccountStore,
+	rulesStore *rules.Store,
+	duplicateStore *duplicate.Store,
+) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		logger := c.MustGet(loggerKey).(*zap.Logger)
-		skeletonAccounts, txns, err := client.ReadOFX(c.Request.Body)
+		skeletonAccounts, txns, err := readFile(c.Request.Body, c.Request.URL.Query())
 		if err != nil {
 			abortWithClientError(c, http.StatusBadRequest, err)
 			return
 		}
-		rulesStore.ApplyAll(txns)
-		switch err := ldgStore.AddTransactions(txns).(type) {
-		case ledger.Error:
-			abortWithClientError(c, http.StatusBadRequest, err)
+		var skeletonAccountNames []string
+		for _, account := range skeletonAccounts {
+			skeletonAccountNames = append(skeletonAccountNames, model.LedgerAccountName(account))
+		}
+		warnings, ok := addImportedTransactions(c, ldgStore, accountStore, rulesStore, duplicateStore, txns, skeletonAccountNames)
+		if !ok {
 			return
-		case nil: // skip
-		default:
+		}
+		addSkeletonAccounts(logger, accountStore, skeletonAccounts)
+		statusWithWarnings(c, warnings)
+	}
+}
+
+// addSkeletonAccounts adds bare-bones accounts found in an imported file, skipping any that already exist
+func addSkeletonAccounts(logger *zap.Logger, accountStore *client.AccountStore, skeletonAccounts []model.Account) {
+	for _, account := range skeletonAccounts {
+		if err := accountStore.Add(account); err != nil {
+			logger.Warn("Failed to add bare-bones account from imported file", zap.String("error", err.Error()))
+		}
+	}
+}
+
+// importCSVFile imports a CSV statement, converted to transactions with the rules file's CSV import settings.
+// Expects a multipart form with the statement in the 'file' field. The statement's account is set with the 'account' query param to the account's ID.
+func importCSVFile(ldgStore *ledger.Store, accountStore *client.AccountStore, rulesStore *rules.Store, duplicateStore *duplicate.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID := c.Query("account")
+		var account model.Account
+		found, err := accountStore.Get(accountID, &account)
+		if err != nil {
 			abortWithClientError(c, http.StatusInternalServerError, err)
 			return
 		}
-
-		accountsAdded := 0
-		for _, account := range skeletonAccounts {
-			if err := accountStore.Add(account); err != nil {
-				logger.Warn("Failed to add bare-bones account from imported file", zap.String("error", err.Error()))
-			} else {
-				accountsAdded++
-			}
+		if !found {
+			abortWithClientError(c, http.StatusBadRequest, errors.Errorf("Account not found with ID: %q", accountID))
+			return
 		}
-		c.Status(http.StatusNoContent)
+		accountName := model.LedgerAccountName(account)
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		defer file.Close()
+		txns, err := rulesStore.CSVImport().Transactions(file, accountName)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		warnings, ok := addImportedTransactions(c, ldgStore, accountStore, rulesStore, duplicateStore, txns, []string{accountName})
+		if !ok {
+			return
+		}
+		statusWithWarnings(c, warnings)
+	}
+}
+
+// addImportedTransactions categorizes uncategorized imported transactions with rules, merges transfers, holds likely duplicates, then adds the rest to the ledger.
+// Transfers are merged between the stored accounts and 'importedAccounts'. Returns any failed balance assertions as warnings, or false if the request was aborted.
+func addImportedTransactions(
+	c *gin.Context,
+	ldgStore *ledger.Store,
+	accountStore *client.AccountStore,
+	rulesStore *rules.Store,
+	duplicateStore *duplicate.Store,
+	txns []ledger.Transaction,
+	importedAccounts []string,
+) ([]string, bool) {
+	// only categorize transactions the file didn't, otherwise rules would replace the file's categories
+	rulesStore.ApplyUncategorized(txns)
+	accounts, err := accountStore.LedgerAccountNames()
+	if err != nil {
+		abortWithClientError(c, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	accounts = append(accounts, importedAccounts...)
+	logger := c.MustGet(loggerKey).(*zap.Logger)
+	// write merged transfers and new transactions to disk at once
+	err = ldgStore.Batch(func() error {
+		txns = ldgStore.MergeTransfers(txns, accounts)
+		txns = sync.HoldDuplicates(logger, ldgStore, duplicateStore, txns)
+		return ldgStore.AddTransactions(txns)
+	})
+	warnings, err := balanceWarnings(c, err)
+	switch err.(type) {
+	case ledger.Error:
+		abortWithClientError(c, http.StatusBadRequest, err)
+		return nil, false
+	case nil:
+		return warnings, true
+	default:
+		abortWithClientError(c, http.StatusInternalServerError, err)
+		return nil, false
 	}
 }
 
@@ -529,12 +780,16 @@ func reimportTransactions(ldgStore *ledger.Store, rulesStore *rules.Store) gin.H
 		if len(body.Accounts) == 0 {
 			body.Accounts = []string{model.Uncategorized}
 		}
-		result := ldgStore.Query(ledger.QueryOptions{
+		result, err := ldgStore.Query(ledger.QueryOptions{
 			Start: start,
 			End:   end,
 			// currently accounts are fixed to "uncategorized" and "expenses:uncategorized"
 			Accounts: body.Accounts,
 		}, 1, ldgStore.Size())
+		if err != nil {
+			abortWithClientError(c, http.StatusInternalServerError, err)
+			return
+		}
 		rulesStore.ApplyAll(result.Transactions)
 		updatedTxns := make(map[string]ledger.Transaction, len(result.Transactions))
 		for _, txn := range result.Transactions {
@@ -590,7 +845,7 @@ func renameSuggestions(accountStore *client.AccountStore) gin.HandlerFunc {
 		var account model.Account
 		err := accountStore.Iter(&account, func(string) bool {
 			// if old Discover direct connect account, show rename to use new Org and FID
-			if account.Institution().Org() == DiscoverOldOrg && account.Institution().FID() == "7101" {
+			if account.Institution() != nil && account.Institution().Org() == DiscoverOldOrg && account.Institution().FID() == "7101" {
 				ledgerAccount := model.LedgerFormat(account).String()
 				accountID := account.ID()
 				suggestions = append(suggestions, renameParams{
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/johnstarich/sage/reconcile"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+func getReconciliations(store *reconcile.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reconciliations, err := store.List(c.Query("account"))
+		if err != nil {
+			abortWithClientError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"Reconciliations": reconciliations,
+		})
+	}
+}
+
+// writeReconciliationStatus responds with the current status of reconciliation 'id'
+func writeReconciliationStatus(c *gin.Context, store *reconcile.Store, ldgStore *ledger.Store, id string) {
+	reconciliation, found, err := store.Get(id)
+	if err != nil {
+		abortWithClientError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !found {
+		abortWithClientError(c, http.StatusNotFound, errors.New("Reconciliation not found: "+id))
+		return
+	}
+	c.JSON(http.StatusOK, reconciliation.Status(ldgStore))
+}
+
+func getReconciliation(store *reconcile.Store, ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeReconciliationStatus(c, store, ldgStore, c.Query("id"))
+	}
+}
+
+func startReconciliation(store *reconcile.Store, ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			Account          string `binding:"required"`
+			StatementDate    time.Time
+			StatementBalance decimal.Decimal
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		reconciliation, err := store.Start(body.Account, body.StatementDate, body.StatementBalance)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		writeReconciliationStatus(c, store, ldgStore, reconciliation.ID())
+	}
+}
+
+func updateReconciliation(store *reconcile.Store, ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			ID             string `binding:"required"`
+			TransactionIDs []string
+			Cleared        bool
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := store.SetCleared(body.ID, body.TransactionIDs, body.Cleared); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		writeReconciliationStatus(c, store, ldgStore, body.ID)
+	}
+}
+
+func finishReconciliation(store *reconcile.Store, ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			ID string `binding:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := store.Finish(body.ID, ldgStore); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func deleteReconciliation(store *reconcile.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			ID string `binding:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := store.Remove(body.ID); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// unlockTransaction allows a reconciled transaction to be updated again
+func unlockTransaction(ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body struct {
+			ID string `binding:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		if err := ldgStore.UnlockTransaction(body.ID); err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
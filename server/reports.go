@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/johnstarich/sage/reports"
+	"github.com/pkg/errors"
+)
+
+type reportFunc func(ldg *ledger.Ledger, options reports.Options) (reports.Report, error)
+
+// getReport serves a report with options from the query parameters 'start', 'end', 'compare', and 'valueIn'. Defaults to the current month.
+func getReport(ldgStore *ledger.Store, report reportFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start, end, err := getStartEndTimes(c.Query("start"), c.Query("end"), startOfMonth)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		options := reports.Options{
+			Start:   start,
+			End:     end,
+			ValueIn: c.Query("valueIn"),
+		}
+		if compare := c.Query("compare"); compare != "" {
+			options.Compare, err = strconv.Atoi(compare)
+			if err != nil {
+				abortWithClientError(c, http.StatusBadRequest, errors.Errorf("Invalid number of comparison periods: %q", compare))
+				return
+			}
+		}
+
+		resp, err := report(ldgStore.Ledger, options)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// getRegister serves an account register with options from the query parameters 'account', 'start', and 'end'. Dates are optional.
+func getRegister(ldgStore *ledger.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		options := reports.RegisterOptions{Account: c.Query("account")}
+		var err error
+		if start := c.Query("start"); start != "" {
+			options.Start, err = time.Parse(time.RFC3339, start)
+			if err != nil {
+				abortWithClientError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if end := c.Query("end"); end != "" {
+			options.End, err = time.Parse(time.RFC3339, end)
+			if err != nil {
+				abortWithClientError(c, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		register, err := reports.AccountRegister(ldgStore.Ledger, options)
+		if err != nil {
+			abortWithClientError(c, http.StatusBadRequest, err)
+			return
+		}
+		c.JSON(http.StatusOK, register)
+	}
+}
@@ -14,8 +14,26 @@ import (
 
 // CSVRule is the request model for changing a single rule
 type CSVRule struct {
-	Conditions []string
-	Account2   string
+	Conditions         []string
+	Account2           string
+	Payee              string
+	TransactionComment string
+	Tags               map[string]string
+	Transfer           string
+	Splits             []rules.SplitPosting
+	SplitRemainder     string
+}
+
+func (r CSVRule) actions() rules.Actions {
+	return rules.Actions{
+		Account2:           r.Account2,
+		Payee:              r.Payee,
+		TransactionComment: r.TransactionComment,
+		Tags:               r.Tags,
+		Transfer:           r.Transfer,
+		Splits:             r.Splits,
+		SplitRemainder:     r.SplitRemainder,
+	}
 }
 
 func getRules(rulesStore *rules.Store, ldgStore *ledger.Store) gin.HandlerFunc {
@@ -95,7 +113,7 @@ func updateRule(rulesFile vcs.File, rulesStore *rules.Store) gin.HandlerFunc {
 			abortWithClientError(c, http.StatusBadRequest, errors.New("Rule index is required"))
 			return
 		}
-		rule, err := rules.NewCSVRule("", bodyRule.Account2, "", bodyRule.Conditions...)
+		rule, err := rules.NewRule(bodyRule.actions(), bodyRule.Conditions...)
 		if err != nil {
 			abortWithClientError(c, http.StatusBadRequest, err)
 			return
@@ -120,7 +138,7 @@ func addRule(rulesFile vcs.File, rulesStore *rules.Store) gin.HandlerFunc {
 			abortWithClientError(c, http.StatusBadRequest, err)
 			return
 		}
-		rule, err := rules.NewCSVRule("", bodyRule.Account2, "", bodyRule.Conditions...)
+		rule, err := rules.NewRule(bodyRule.actions(), bodyRule.Conditions...)
 		if err != nil {
 			abortWithClientError(c, http.StatusBadRequest, err)
 			return
@@ -11,9 +11,13 @@ import (
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
 	"github.com/johnstarich/sage/client"
+	"github.com/johnstarich/sage/duplicate"
+	"github.com/johnstarich/sage/inbox"
 	"github.com/johnstarich/sage/ledger"
 	"github.com/johnstarich/sage/plaindb"
+	"github.com/johnstarich/sage/reconcile"
 	"github.com/johnstarich/sage/redactor"
+	"github.com/johnstarich/sage/reports"
 	"github.com/johnstarich/sage/rules"
 	"github.com/johnstarich/sage/sync"
 	"github.com/johnstarich/sage/vcs"
@@ -37,6 +41,9 @@ func Run(
 	db plaindb.DB,
 	ldgStore *ledger.Store,
 	accountStore *client.AccountStore,
+	duplicateStore *duplicate.Store,
+	inboxStore *inbox.Store,
+	reconcileStore *reconcile.Store,
 	rulesFile vcs.File, rulesStore *rules.Store,
 	logger *zap.Logger,
 	options Options,
@@ -66,7 +73,7 @@ func Run(
 		engine.POST("/api/authz", signIn(auth))
 		api.Use(requireAuth(auth))
 	}
-	setupAPI(api, db, ldgStore, accountStore, rulesFile, rulesStore)
+	setupAPI(api, db, ldgStore, accountStore, duplicateStore, inboxStore, reconcileStore, rulesFile, rulesStore)
 
 	done := make(chan bool, 1)
 	errs := make(chan error, 2)
@@ -80,7 +87,7 @@ func Run(
 		// give gin server time to start running. don't perform unnecessary requests if gin fails to boot
 		time.Sleep(2 * time.Second)
 		runSync := func() {
-			sync.Sync(ldgStore, accountStore, rulesStore, false)
+			sync.Sync(logger, ldgStore, accountStore, rulesStore, duplicateStore, inboxStore, false)
 		}
 		runSync()
 		ticker := time.NewTicker(syncInterval)
@@ -121,13 +128,20 @@ func setupAPI(
 	db plaindb.DB,
 	ldgStore *ledger.Store,
 	accountStore *client.AccountStore,
+	duplicateStore *duplicate.Store,
+	inboxStore *inbox.Store,
+	reconcileStore *reconcile.Store,
 	rulesFile vcs.File,
 	rulesStore *rules.Store,
 ) {
 	router.GET("/getLedgerSyncStatus", getLedgerSyncStatus(ldgStore))
 	router.POST("/submitSyncPrompt", submitSyncPrompt(ldgStore))
-	router.POST("/syncLedger", syncLedger(ldgStore, accountStore, rulesStore))
-	router.POST("/importOFX", importOFXFile(ldgStore, accountStore, rulesStore))
+	router.POST("/syncLedger", syncLedger(ldgStore, accountStore, rulesStore, duplicateStore, inboxStore))
+	router.POST("/importOFX", importFile(ignoreQuery(client.ReadOFX), ldgStore, accountStore, rulesStore, duplicateStore))
+	router.POST("/importCAMT053", importFile(ignoreQuery(client.ReadCAMT053), ldgStore, accountStore, rulesStore, duplicateStore))
+	router.POST("/importMT940", importFile(ignoreQuery(client.ReadMT940), ldgStore, accountStore, rulesStore, duplicateStore))
+	router.POST("/importQIF", importFile(readQIF, ldgStore, accountStore, rulesStore, duplicateStore))
+	router.POST("/importCSV", importCSVFile(ldgStore, accountStore, rulesStore, duplicateStore))
 	router.POST("/renameLedgerAccount", renameLedgerAccount(ldgStore))
 	router.GET("/renameSuggestions", renameSuggestions(accountStore))
 
@@ -140,6 +154,7 @@ func setupAPI(
 	router.POST("/updateAccount", updateAccount(accountStore, ldgStore))
 	router.POST("/addAccount", addAccount(accountStore))
 	router.GET("/deleteAccount", removeAccount(accountStore))
+	router.POST("/addValuation", addValuation(accountStore, ldgStore))
 
 	router.GET("/web/getDriverNames", getWebConnectDrivers())
 
@@ -150,8 +165,19 @@ func setupAPI(
 	router.GET("/getTransactions", getTransactions(ldgStore, accountStore))
 	router.POST("/updateTransaction", updateTransaction(ldgStore))
 	router.POST("/updateTransactions", updateTransactions(ldgStore))
+	router.POST("/addTransaction", addTransaction(ldgStore))
+	router.POST("/deleteTransaction", deleteTransaction(ldgStore))
+	router.POST("/unlockTransaction", unlockTransaction(ldgStore))
 	router.POST("/reimportTransactions", reimportTransactions(ldgStore, rulesStore))
 
+	router.GET("/getDuplicates", getDuplicates(duplicateStore))
+	router.POST("/resolveDuplicate", resolveDuplicate(duplicateStore, ldgStore))
+
+	router.GET("/getInbox", getInbox(inboxStore))
+	router.POST("/updateInbox", updateInbox(inboxStore))
+	router.POST("/approveInbox", approveInbox(inboxStore, ldgStore))
+	router.POST("/rejectInbox", rejectInbox(inboxStore))
+
 	router.GET("/getRules", getRules(rulesStore, ldgStore))
 	router.GET("/getRule", getRule(rulesStore))
 	router.POST("/updateRules", updateRules(rulesFile, rulesStore))
@@ -164,4 +190,19 @@ func setupAPI(
 	router.POST("/updateBudget", updateBudget(db))
 	router.GET("/deleteBudget", deleteBudget(db))
 	router.GET("/getEverythingElseBudget", getEverythingElseBudgetDetails(db, ldgStore))
+
+	router.GET("/reports/incomeStatement", getReport(ldgStore, reports.IncomeStatement))
+	router.GET("/reports/balanceSheet", getReport(ldgStore, reports.BalanceSheet))
+	router.GET("/reports/cashFlow", getReport(ldgStore, reports.CashFlow))
+	router.GET("/reports/register", getRegister(ldgStore))
+	router.GET("/reports/gains", getGains(ldgStore))
+
+	router.GET("/getHoldings", getHoldings(ldgStore))
+
+	router.GET("/getReconciliations", getReconciliations(reconcileStore))
+	router.GET("/getReconciliation", getReconciliation(reconcileStore, ldgStore))
+	router.POST("/startReconciliation", startReconciliation(reconcileStore, ldgStore))
+	router.POST("/updateReconciliation", updateReconciliation(reconcileStore, ldgStore))
+	router.POST("/finishReconciliation", finishReconciliation(reconcileStore, ldgStore))
+	router.POST("/deleteReconciliation", deleteReconciliation(reconcileStore))
 }
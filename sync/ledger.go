@@ -5,32 +5,124 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aclindsa/ofxgo"
 	"github.com/johnstarich/sage/client"
 	"github.com/johnstarich/sage/client/direct"
 	"github.com/johnstarich/sage/client/model"
 	"github.com/johnstarich/sage/client/web"
+	"github.com/johnstarich/sage/duplicate"
 	sErrors "github.com/johnstarich/sage/errors"
+	"github.com/johnstarich/sage/inbox"
 	"github.com/johnstarich/sage/ledger"
 	"github.com/johnstarich/sage/prompter"
 	"github.com/johnstarich/sage/records"
 	"github.com/johnstarich/sage/rules"
+	"go.uber.org/zap"
 )
 
-// Sync fetches transactions for each account and categorizes them based on rules, then writes them to disk
-func Sync(ldgStore *ledger.Store, accountStore *client.AccountStore, rulesStore *rules.Store, syncFromLedgerStart bool) {
-	download := downloadTxns(accountStore)
+// Sync fetches transactions for each account and categorizes them based on rules, then writes them to disk.
+// Investment holdings are recorded as market prices.
+// Likely duplicates of existing transactions are held in duplicateStore for review.
+// If inboxStore is enabled, new transactions are staged there for review instead of written to the ledger.
+func Sync(logger *zap.Logger, ldgStore *ledger.Store, accountStore *client.AccountStore, rulesStore *rules.Store, duplicateStore *duplicate.Store, inboxStore *inbox.Store, syncFromLedgerStart bool) {
+	download := downloadTxns(ldgStore, accountStore)
+	processTxns := processTxns(logger, ldgStore, accountStore, rulesStore, duplicateStore, inboxStore)
 	if syncFromLedgerStart {
-		ldgStore.Resync(download, rulesStore.ApplyAll)
+		ldgStore.Resync(download, processTxns)
 	} else {
-		ldgStore.SyncRecent(download, rulesStore.ApplyAll)
+		ldgStore.SyncRecent(download, processTxns)
 	}
 }
 
-func downloadTxns(accountStore *client.AccountStore) func(start, end time.Time, prompter prompter.Prompter) ([]ledger.Transaction, error) {
+// processTxns categorizes uncategorized downloaded transactions with rules, merges transfers between accounts, then holds likely duplicates for review.
+// If the inbox is enabled, the remaining transactions are staged there and none are returned. Transfers are then only merged with each other, so the ledger doesn't change before review.
+func processTxns(logger *zap.Logger, ldgStore *ledger.Store, accountStore *client.AccountStore, rulesStore *rules.Store, duplicateStore *duplicate.Store, inboxStore *inbox.Store) func([]ledger.Transaction) []ledger.Transaction {
+	return func(txns []ledger.Transaction) []ledger.Transaction {
+		// investment transactions are already categorized, so rules must not replace their cash and income postings
+		rulesStore.ApplyUncategorized(txns)
+		accounts, err := accountStore.LedgerAccountNames()
+		switch {
+		case err != nil:
+			// accounts were just read for downloads, so skip merging transfers rather than failing the sync
+			logger.Warn("Failed to read accounts, skipping merging transfers", zap.Error(err))
+		case inboxStore.Enabled():
+			txns = ldgStore.MergeNewTransfers(txns, accounts)
+		default:
+			txns = ldgStore.MergeTransfers(txns, accounts)
+		}
+		txns = HoldDuplicates(logger, ldgStore, duplicateStore, txns)
+		if !inboxStore.Enabled() {
+			return txns
+		}
+		unstaged, err := inboxStore.Add(txns, ldgStore)
+		if err != nil {
+			// don't lose downloaded transactions if they can't be staged
+			logger.Warn("Failed to stage transactions in the inbox, adding them to the ledger instead", zap.Int("transactions", len(txns)), zap.Error(err))
+			return txns
+		}
+		return unstaged
+	}
+}
+
+// HoldDuplicates holds likely duplicates of existing transactions in duplicateStore and returns the remaining transactions.
+// If some duplicates can't be held, they're returned with the remaining transactions so none are lost.
+func HoldDuplicates(logger *zap.Logger, ldgStore *ledger.Store, duplicateStore *duplicate.Store, txns []ledger.Transaction) []ledger.Transaction {
+	unique, duplicates := ldgStore.FindDuplicates(txns)
+	if len(duplicates) == 0 {
+		return txns
+	}
+	unheld, err := duplicateStore.Hold(duplicates)
+	if err != nil {
+		logger.Warn("Failed to hold likely duplicate transactions for review", zap.Int("unheld", len(unheld)), zap.Error(err))
+	}
+	return append(unique, unheld...)
+}
+
+// AddHoldingPrices records the unit prices of holdings snapshots as market prices, skipping prices already in the ledger
+func AddHoldingPrices(ldg *ledger.Ledger, holdings []client.Holding) {
+	type priceKey struct {
+		date                string
+		commodity, currency string
+	}
+	existing := make(map[priceKey]bool)
+	for _, price := range ldg.Prices() {
+		existing[priceKey{price.Date.Format(ledger.DateFormat), price.Commodity, price.Currency}] = true
+	}
+	var prices []ledger.Price
+	for _, holding := range holdings {
+		price := holding.Price()
+		key := priceKey{price.Date.Format(ledger.DateFormat), price.Commodity, price.Currency}
+		if existing[key] || price.Commodity == "" || price.Currency == "" || price.Amount.IsZero() {
+			continue
+		}
+		existing[key] = true
+		prices = append(prices, price)
+	}
+	if len(prices) > 0 {
+		ldg.AddPrices(prices)
+	}
+}
+
+func downloadTxns(ldgStore *ledger.Store, accountStore *client.AccountStore) func(start, end time.Time, prompter prompter.Prompter) ([]ledger.Transaction, error) {
 	return func(start, end time.Time, prompter prompter.Prompter) ([]ledger.Transaction, error) {
+		var holdings []client.Holding
+		parseOFX := func(resp *ofxgo.Response) ([]model.Account, []ledger.Transaction, error) {
+			respHoldings, err := client.ParseOFXHoldings(resp)
+			if err == nil {
+				holdings = append(holdings, respHoldings...)
+			}
+			return client.ParseOFX(resp)
+		}
+		// holdings are written to disk with the synced transactions
+		defer func() { AddHoldingPrices(ldgStore.Ledger, holdings) }()
+
 		instMap := make(map[model.Institution][]model.Account)
 		var account model.Account
 		err := accountStore.Iter(&account, func(id string) bool {
+			if _, isManual := account.(*model.ManualAccount); isManual {
+				// manual accounts have no connector, only manual transactions and valuations update them
+				return true
+			}
 			inst := account.Institution()
 			instMap[inst] = append(instMap[inst], account)
 			return true
@@ -50,7 +142,7 @@ func downloadTxns(accountStore *client.AccountStore) func(start, end time.Time,
 						descriptions = append(descriptions, account.Description())
 					}
 				}
-				txns, err := direct.Statement(connector, start, end, requestors, client.ParseOFX)
+				txns, err := direct.Statement(connector, start, end, requestors, parseOFX)
 				errs.AddErr(wrapDownloadErr(err, descriptions))
 				allTxns = append(allTxns, txns...)
 			}
@@ -61,7 +153,7 @@ func downloadTxns(accountStore *client.AccountStore) func(start, end time.Time,
 					accountIDs = append(accountIDs, account.ID())
 					descriptions = append(descriptions, account.Description())
 				}
-				txns, err := web.Statement(connector, start, end, accountIDs, client.ParseOFX, prompter)
+				txns, err := web.Statement(connector, start, end, accountIDs, parseOFX, prompter)
 				if !errs.AddErr(wrapDownloadErr(err, descriptions)) {
 					// TODO remove break after beta
 					break // beta: fail immediately on web connector error
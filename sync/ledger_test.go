@@ -0,0 +1,110 @@
+package sync
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/aclindsa/ofxgo"
+	"github.com/johnstarich/sage/client"
+	"github.com/johnstarich/sage/duplicate"
+	"github.com/johnstarich/sage/inbox"
+	"github.com/johnstarich/sage/ledger"
+	"github.com/johnstarich/sage/plaindb"
+	"github.com/johnstarich/sage/rules"
+	"github.com/johnstarich/sage/vcs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+type mockFile struct {
+	buf bytes.Buffer
+}
+
+func (m *mockFile) Write(b []byte) error {
+	m.buf.Reset()
+	_, err := m.buf.Write(b)
+	return err
+}
+
+func (m *mockFile) Append(b []byte) error {
+	_, err := m.buf.Write(b)
+	return err
+}
+
+func (m *mockFile) Read() ([]byte, error) {
+	return m.buf.Bytes(), nil
+}
+
+func (m *mockFile) Path() string {
+	return "ledger.journal"
+}
+
+func (m *mockFile) Relative(path string) vcs.File {
+	return &mockFile{}
+}
+
+func makeOFXAmount(f float64) ofxgo.Amount {
+	rat := &big.Rat{}
+	rat.SetFloat64(f)
+	return ofxgo.Amount{Rat: *rat}
+}
+
+func TestProcessTxnsInvestments(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	accountStore, err := client.NewAccountStore(plaindb.NewMockDB(plaindb.MockConfig{}))
+	require.NoError(t, err)
+	db := plaindb.NewMockDB(plaindb.MockConfig{FileReader: func(fileName string) ([]byte, error) {
+		return []byte(`{}`), nil
+	}})
+	duplicateStore, err := duplicate.NewStore(db)
+	require.NoError(t, err)
+	inboxStore, err := inbox.NewStore(db, false)
+	require.NoError(t, err)
+	ldgStore, err := ledger.NewStore(&mockFile{}, logger)
+	require.NoError(t, err)
+	rulesStore := rules.NewStore(nil, nil)
+
+	usd, err := ofxgo.NewCurrSymbol("USD")
+	require.NoError(t, err)
+	secID := ofxgo.SecurityID{UniqueID: "922908769", UniqueIDType: "CUSIP"}
+	invTran := func(id string) ofxgo.InvTran {
+		return ofxgo.InvTran{FiTID: ofxgo.String(id), DtTrade: ofxgo.Date{Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}}
+	}
+	resp := &ofxgo.Response{
+		Signon: ofxgo.SignonResponse{Org: "Broker", Fid: "1234"},
+		InvStmt: []ofxgo.Message{
+			&ofxgo.InvStatementResponse{
+				CurDef:      *usd,
+				InvAcctFrom: ofxgo.InvAcct{BrokerID: "broker.example.com", AcctID: "5678"},
+				InvTranList: &ofxgo.InvTranList{
+					InvTransactions: []ofxgo.InvTransaction{
+						ofxgo.BuyStock{InvBuy: ofxgo.InvBuy{InvTran: invTran("buy1"), SecID: secID, Units: makeOFXAmount(10), Total: makeOFXAmount(-1000)}},
+						ofxgo.Income{InvTran: invTran("div1"), SecID: secID, IncomeType: ofxgo.IncomeTypeDiv, Total: makeOFXAmount(12.5)},
+					},
+					BankTransactions: []ofxgo.InvBankTransaction{
+						{Transactions: []ofxgo.Transaction{
+							{TrnType: ofxgo.TrnTypeCredit, DtPosted: ofxgo.Date{Time: time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)}, TrnAmt: makeOFXAmount(500), FiTID: "deposit1", Name: "Deposit"},
+						}},
+					},
+				},
+			},
+		},
+	}
+	_, txns, err := client.ParseOFX(resp)
+	require.NoError(t, err)
+
+	txns = processTxns(logger, ldgStore, accountStore, rulesStore, duplicateStore, inboxStore)(txns)
+	accounts := make(map[string]string)
+	for _, txn := range txns {
+		require.Len(t, txn.Postings, 2)
+		accounts[txn.Postings[0].Tags["id"]] = txn.Postings[1].Account
+	}
+	assert.Equal(t, map[string]string{
+		"1234-5678-deposit1": "revenues:deposits",
+		"1234-5678-buy1":     "assets:Broker:****5678",
+		"1234-5678-div1":     "revenues:Investments:Dividends",
+	}, accounts, "Rules should only categorize uncategorized transactions")
+}
@@ -3,11 +3,18 @@ package vcs
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 type File interface {
 	Write(b []byte) error
+	// Append adds 'b' to the end of the file and commits it, without rewriting the existing contents
+	Append(b []byte) error
 	Read() ([]byte, error)
+	// Path returns the file's path
+	Path() string
+	// Relative returns a version-controlled file at 'path' relative to this file's directory. Absolute paths are used as-is.
+	Relative(path string) File
 }
 
 type file struct {
@@ -26,6 +33,21 @@ func (f *file) Write(b []byte) error {
 	return f.repo.CommitFiles(diskWriter(f.path, b), "Update "+f.path, f.path)
 }
 
+func (f *file) Append(b []byte) error {
+	return f.repo.CommitFiles(diskAppender(f.path, b), "Update "+f.path, f.path)
+}
+
+func (f *file) Path() string {
+	return f.path
+}
+
+func (f *file) Relative(path string) File {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(f.path), path)
+	}
+	return f.repo.File(path)
+}
+
 func (f *file) Read() ([]byte, error) {
 	buf, err := ioutil.ReadFile(f.path)
 	if os.IsNotExist(err) {
@@ -39,3 +61,17 @@ func diskWriter(path string, b []byte) func() error {
 		return ioutil.WriteFile(path, b, 0750) // nolint:gosec // File should be written and rewritten by Sage, then easily read by other programs for custom tools.
 	}
 }
+
+func diskAppender(path string, b []byte) func() error {
+	return func() error {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0750) // nolint:gosec // Same permissions as diskWriter
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(b)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
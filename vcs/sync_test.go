@@ -46,4 +46,30 @@ func TestFile(t *testing.T) {
 	assert.Equal(t, "bucket.json", file.Name)
 	assert.Equal(t, "hi there", contents)
 	assert.Equal(t, "Update ./testdb/bucket.json", commit.Message)
+
+	err = f.Append([]byte(", friend"))
+	assert.NoError(t, err)
+
+	buf, err = f.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "hi there, friend", string(buf))
+
+	commits, err = repo.repo.Log(&git.LogOptions{})
+	require.NoError(t, err)
+	commit, err = commits.Next()
+	require.NoError(t, err)
+	files, err = commit.Files()
+	require.NoError(t, err)
+	file, err = files.Next()
+	require.NoError(t, err)
+	contents, err = file.Contents()
+	require.NoError(t, err)
+	assert.Equal(t, "hi there, friend", contents)
+}
+
+func TestFileRelative(t *testing.T) {
+	repo := &syncRepo{}
+	f := repo.File("testdb/ledger.journal")
+	assert.Equal(t, repo.File("testdb/years/2019.journal"), f.Relative("years/2019.journal"))
+	assert.Equal(t, repo.File("/tmp/2019.journal"), f.Relative("/tmp/2019.journal"))
 }
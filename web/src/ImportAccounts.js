@@ -1,12 +1,22 @@
 import API from './API';
 import React from 'react';
+import Alert from 'react-bootstrap/Alert';
 import Button from 'react-bootstrap/Button';
 import Col from 'react-bootstrap/Col';
 import Container from 'react-bootstrap/Container';
 import Form from 'react-bootstrap/Form';
 import Row from 'react-bootstrap/Row';
 
+function feedbackLines(feedback) {
+  return feedback.trim().split("\n").map(line =>
+    <span key={line}>{line}<br /></span>
+  )
+}
+
 export default function ImportAccounts() {
+  const [feedback, setFeedback] = React.useState(null)
+  const [warnings, setWarnings] = React.useState(null)
+
   return (
     <Container>
       <Row><Col><h2>Import</h2></Col></Row>
@@ -15,6 +25,23 @@ export default function ImportAccounts() {
           <p>Import OFX or QFX files. Typically, you can download these from your financial institution's "Quicken" or "Microsoft Money" downloads.</p>
         </Col>
       </Row>
+      {feedback ? (
+        <Row>
+          <Col>
+            <Alert variant="danger">{feedbackLines(feedback)}</Alert>
+          </Col>
+        </Row>
+      ) : null}
+      {warnings ? (
+        <Row>
+          <Col>
+            <Alert variant="warning" dismissible onClose={() => window.location.reload()}>
+              <p>Imported with warnings:</p>
+              {feedbackLines(warnings)}
+            </Alert>
+          </Col>
+        </Row>
+      ) : null}
       <Form
         noValidate
         onSubmit={e => {
@@ -27,13 +54,22 @@ export default function ImportAccounts() {
             if (files.length !== 1) {
               throw Error("Must provide one file to import")
             }
+            setFeedback(null)
+            setWarnings(null)
             API.post('/v1/importOFX', files[0])
-              .then(() => window.location.reload())
+              .then(res => {
+                if (res.data && res.data.Warnings && res.data.Warnings.length > 0) {
+                  // reload once the warnings are dismissed
+                  setWarnings(res.data.Warnings.join('\n'))
+                  return
+                }
+                window.location.reload()
+              })
               .catch(e => {
-                if (!e.response.data || !e.response.data.Error) {
+                if (!e.response || !e.response.data || !e.response.data.Error) {
                   throw e
                 }
-                alert(e.response.data.Error)
+                setFeedback(e.response.data.Error)
               })
           }
         }}